@@ -3,6 +3,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"net"
@@ -35,11 +36,12 @@ func main() {
 		return
 	}
 
-	conn, connErr := net.Dial("tcp", net.JoinHostPort(ipAddress, fmt.Sprintf("%d", port)))
+	// The client reconnects on its own when the server closes an idle connection
+	c, connErr := client.Dial(context.Background(), net.JoinHostPort(ipAddress, fmt.Sprintf("%d", port)), client.Options{})
 	if connErr != nil {
-		fmt.Printf("Fatal error occurred! %v", connErr)
+		fmt.Printf("Fatal error occurred! %v\n", connErr)
+		return
 	}
-	c := client.New(&conn)
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("--------------")
@@ -124,8 +126,16 @@ out:
 		case "EXIT":
 			break out
 		default:
-			fmt.Println("* Command specified not found")
-			continue
+			// Commands without a method of their own are sent as they are
+			args := make([]any, len(commands)-1)
+			for i, arg := range commands[1:] {
+				args[i] = arg
+			}
+			var rep client.Reply
+			rep, err = c.Do(context.Background(), commands[0], args...)
+			if err == nil && !rep.IsNil() {
+				result = formatReply(rep, "  ")
+			}
 		}
 
 		if redigoerr.ConnectionRelated(err) {
@@ -140,12 +150,40 @@ out:
 		}
 	}
 
-	connErr = conn.Close()
+	connErr = c.Close()
 	if connErr != nil {
 		fmt.Printf("An error occurred while closing the connection - %e\n", connErr)
 	}
 }
 
+// formatReply shows a reply much like the REDIS CLI does, numbering the elements of arrays
+func formatReply(rep client.Reply, indent string) string {
+	switch rep.Type() {
+	case "nil":
+		return "(nil)"
+	case "integer":
+		integer, _ := rep.Int()
+		return fmt.Sprintf("(integer) %d", integer)
+	case "array", "set", "push", "map":
+		elements, _ := rep.Array()
+		if len(elements) == 0 {
+			return "(empty array)"
+		}
+		var b strings.Builder
+		for i, element := range elements {
+			if i > 0 {
+				b.WriteString("\n" + indent)
+			}
+			prefix := fmt.Sprintf("%d) ", i+1)
+			b.WriteString(prefix + formatReply(element, indent+strings.Repeat(" ", len(prefix))))
+		}
+		return b.String()
+	default:
+		str, _ := rep.String()
+		return strconv.Quote(str)
+	}
+}
+
 func filter[T any](arr []T, filter func(T) bool) []T {
 	res := []T{}
 	for _, t := range arr {
@@ -3,9 +3,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os/signal"
 	"regexp"
+	"syscall"
 
 	"github.com/Arthur-phys/redigo/pkg/server"
 )
@@ -14,37 +17,151 @@ var ipAddress string
 var port uint
 var messageSizeLimit int
 var workerAmount uint64
+var minWorkers uint64
+var maxWorkers uint64
+var workerIdleTimeout int64
+var maxClients uint64
 var keepAlive int64
 var shutdownTolerance int64
+var metricsAddress string
+var slowLogThreshold int64
+var slowLogMaxLen int
+var databases int
+var maxMemory int64
+var logLevel string
+var configFile string
+var ioMode string
+var eventLoops int
+var clusterEnabled bool
+var clusterPort uint
+var clusterNodeTimeout int64
 
 func init() {
 	flag.StringVar(&ipAddress, "ip", "127.0.0.1", "Binding IP address for server.")
 	flag.UintVar(&port, "port", 6543, "Binding Port for server.")
 	flag.IntVar(&messageSizeLimit, "message_size", 10240, "Limit in size (bytes) for a single message delivered to the server.")
-	flag.Uint64Var(&workerAmount, "worker_amount", 10, "Number of workers to initialize.")
+	flag.Uint64Var(&workerAmount, "worker_amount", 10, "Number of workers to initialize. Ignored when min_workers or max_workers are given.")
+	flag.Uint64Var(&minWorkers, "min_workers", 0, "Number of workers kept alive even when idle.")
+	flag.Uint64Var(&maxWorkers, "max_workers", 0, "Number of workers that can be spawned when every worker is busy.")
+	flag.Int64Var(&workerIdleTimeout, "worker_idle_timeout", 60, "Time (in seconds) a worker above min_workers waits for a connection before stopping.")
+	flag.StringVar(&ioMode, "io_mode", "workers", "How connections are attended, either workers (one per connection) or eventloop (epoll, Linux only).")
+	flag.IntVar(&eventLoops, "event_loops", 0, "Number of event loops when io_mode is eventloop. 0 means one per CPU.")
+	flag.Uint64Var(&maxClients, "maxclients", 0, "Number of connections from which new ones are rejected. 0 means no limit.")
 	flag.Int64Var(&keepAlive, "keep_alive", 15, "Time (in seconds) to keep a connection open if no message is received.")
 	flag.Int64Var(&shutdownTolerance, "shutdown", 15, "Time (in seconds) given to workers when gracefully shutting down the server.")
+	flag.Int64Var(&slowLogThreshold, "slowlog_threshold", 10000, "Time (in microseconds) from which a command is kept in the slow log. Negative to disable it.")
+	flag.IntVar(&slowLogMaxLen, "slowlog_max_len", 128, "Number of entries kept in the slow log.")
+	flag.StringVar(&metricsAddress, "metrics", "", "Address (ip:port) to expose Prometheus metrics on /metrics. Disabled if empty.")
+	flag.IntVar(&databases, "databases", 16, "Number of logical databases clients can SELECT.")
+	flag.Int64Var(&maxMemory, "maxmemory", 0, "Memory (in bytes) from which commands adding data are rejected. 0 means no limit.")
+	flag.StringVar(&logLevel, "loglevel", "debug", "Log level, one of debug, verbose, notice, warning or nothing.")
+	flag.BoolVar(&clusterEnabled, "cluster", false, "Run as a node of a cluster, serving only the hash slots assigned to it.")
+	flag.UintVar(&clusterPort, "cluster_port", 0, "Port other nodes of the cluster gossip with. 0 means the port plus 10000.")
+	flag.Int64Var(&clusterNodeTimeout, "cluster_node_timeout", 15000, "Time (in milliseconds) a node can go without answering before being flagged as failing.")
+	flag.StringVar(&configFile, "config", "", "redis.conf-style configuration file. Flags given explicitly take precedence over it.")
 }
 
 func main() {
 	flag.Parse()
 
-	if ok, err := regexp.MatchString(`^((25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])$`, ipAddress); !ok || err != nil {
-		fmt.Printf("Invalid IP address - %s\n", ipAddress)
-		return
-	}
 	if port > uint(^uint16(0)) {
 		fmt.Printf("Unable to convert given port number (%d) to the corresponding range 0 - 65535\n", port)
 		return
 	}
+	if clusterPort > uint(^uint16(0)) {
+		fmt.Printf("Unable to convert given cluster port number (%d) to the corresponding range 0 - 65535\n", clusterPort)
+		return
+	}
 
 	serverConfig := server.Configuration{
-		IpAddress:         ipAddress,
-		Port:              uint16(port),
-		WorkerAmount:      workerAmount,
-		KeepAlive:         keepAlive,
-		MessageSizeLimit:  messageSizeLimit,
-		ShutdownTolerance: shutdownTolerance,
+		IpAddress:          ipAddress,
+		Port:               uint16(port),
+		WorkerAmount:       workerAmount,
+		MinWorkers:         minWorkers,
+		MaxWorkers:         maxWorkers,
+		WorkerIdleTimeout:  workerIdleTimeout,
+		MaxClients:         maxClients,
+		KeepAlive:          keepAlive,
+		MessageSizeLimit:   messageSizeLimit,
+		ShutdownTolerance:  shutdownTolerance,
+		MetricsAddress:     metricsAddress,
+		SlowLogThreshold:   slowLogThreshold,
+		SlowLogMaxLen:      slowLogMaxLen,
+		Databases:          databases,
+		MaxMemory:          maxMemory,
+		LogLevel:           logLevel,
+		EventLoops:         eventLoops,
+		ClusterEnabled:     clusterEnabled,
+		ClusterBusPort:     uint16(clusterPort),
+		ClusterNodeTimeout: clusterNodeTimeout,
+	}
+	if ioMode == "eventloop" {
+		serverConfig.ConnectionHandling = server.EventLoop
+	} else if ioMode != "workers" {
+		fmt.Printf("Invalid io mode - %s\n", ioMode)
+		return
+	}
+
+	if configFile != "" {
+		if err := server.LoadConfigurationFile(configFile, &serverConfig); err != nil {
+			fmt.Printf("Unable to load configuration file - %v\n", err)
+			return
+		}
+		// Flags given explicitly take precedence over the file
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "ip":
+				serverConfig.IpAddress = ipAddress
+			case "port":
+				serverConfig.Port = uint16(port)
+			case "message_size":
+				serverConfig.MessageSizeLimit = messageSizeLimit
+			case "worker_amount":
+				serverConfig.WorkerAmount = workerAmount
+			case "min_workers":
+				serverConfig.MinWorkers = minWorkers
+			case "max_workers":
+				serverConfig.MaxWorkers = maxWorkers
+			case "worker_idle_timeout":
+				serverConfig.WorkerIdleTimeout = workerIdleTimeout
+			case "maxclients":
+				serverConfig.MaxClients = maxClients
+			case "keep_alive":
+				serverConfig.KeepAlive = keepAlive
+			case "shutdown":
+				serverConfig.ShutdownTolerance = shutdownTolerance
+			case "metrics":
+				serverConfig.MetricsAddress = metricsAddress
+			case "slowlog_threshold":
+				serverConfig.SlowLogThreshold = slowLogThreshold
+			case "slowlog_max_len":
+				serverConfig.SlowLogMaxLen = slowLogMaxLen
+			case "databases":
+				serverConfig.Databases = databases
+			case "maxmemory":
+				serverConfig.MaxMemory = maxMemory
+			case "loglevel":
+				serverConfig.LogLevel = logLevel
+			case "io_mode":
+				serverConfig.ConnectionHandling = server.WorkerPerConnection
+				if ioMode == "eventloop" {
+					serverConfig.ConnectionHandling = server.EventLoop
+				}
+			case "event_loops":
+				serverConfig.EventLoops = eventLoops
+			case "cluster":
+				serverConfig.ClusterEnabled = clusterEnabled
+			case "cluster_port":
+				serverConfig.ClusterBusPort = uint16(clusterPort)
+			case "cluster_node_timeout":
+				serverConfig.ClusterNodeTimeout = clusterNodeTimeout
+			}
+		})
+	}
+
+	if ok, err := regexp.MatchString(`^((25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])$`, serverConfig.IpAddress); !ok || err != nil {
+		fmt.Printf("Invalid IP address - %s\n", serverConfig.IpAddress)
+		return
 	}
 
 	s, err := server.New(&serverConfig)
@@ -52,5 +169,10 @@ func main() {
 		fmt.Printf("Fatal error occurred - %v\n", err)
 		return
 	}
-	s.Run()
+	// Stop gracefully when asked to by the OS or the user (Using Ctrl+C for example)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := s.RunContext(ctx); err != nil {
+		fmt.Printf("Unable to shut down gracefully - %v\n", err)
+	}
 }
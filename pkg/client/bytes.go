@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+)
+
+// GetBytes reads a key as bytes, nil when it does not exist. Values are never converted to strings,
+// so any content (like \r\n or NUL bytes) is returned as it was stored.
+func (client *Client) GetBytes(key string) ([]byte, error) {
+	return client.GetBytesContext(context.Background(), key)
+}
+
+func (client *Client) GetBytesContext(ctx context.Context, key string) ([]byte, error) {
+	if client.cache != nil {
+		rep, err := client.cachedGet(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		// The cache keeps its own copy, which callers must not change
+		b, err := rep.Bytes()
+		return bytes.Clone(b), err
+	}
+	rep, err := client.callBytes(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	return rep.Bytes()
+}
+
+func (client *Client) SetBytes(key string, value []byte) error {
+	return client.SetBytesContext(context.Background(), key, value)
+}
+
+func (client *Client) SetBytesContext(ctx context.Context, key string, value []byte) error {
+	_, err := client.callBytes(ctx, "SET", key, value)
+	return err
+}
+
+func (client *Client) RPushBytes(key string, values ...[]byte) error {
+	return client.RPushBytesContext(context.Background(), key, values...)
+}
+
+func (client *Client) RPushBytesContext(ctx context.Context, key string, values ...[]byte) error {
+	_, err := client.callBytes(ctx, "RPUSH", key, values...)
+	return err
+}
+
+func (client *Client) LPushBytes(key string, values ...[]byte) error {
+	return client.LPushBytesContext(context.Background(), key, values...)
+}
+
+func (client *Client) LPushBytesContext(ctx context.Context, key string, values ...[]byte) error {
+	_, err := client.callBytes(ctx, "LPUSH", key, values...)
+	return err
+}
+
+func (client *Client) RPopBytes(key string) ([]byte, error) {
+	return client.RPopBytesContext(context.Background(), key)
+}
+
+func (client *Client) RPopBytesContext(ctx context.Context, key string) ([]byte, error) {
+	rep, err := client.callBytes(ctx, "RPOP", key)
+	if err != nil {
+		return nil, err
+	}
+	return rep.Bytes()
+}
+
+func (client *Client) LPopBytes(key string) ([]byte, error) {
+	return client.LPopBytesContext(context.Background(), key)
+}
+
+func (client *Client) LPopBytesContext(ctx context.Context, key string) ([]byte, error) {
+	rep, err := client.callBytes(ctx, "LPOP", key)
+	if err != nil {
+		return nil, err
+	}
+	return rep.Bytes()
+}
+
+func (client *Client) LIndexBytes(key string, index int) ([]byte, error) {
+	return client.LIndexBytesContext(context.Background(), key, index)
+}
+
+func (client *Client) LIndexBytesContext(ctx context.Context, key string, index int) ([]byte, error) {
+	rep, err := client.callBytes(ctx, "LINDEX", key, strconv.AppendInt(nil, int64(index), 10))
+	if err != nil {
+		return nil, err
+	}
+	return rep.Bytes()
+}
+
+// callBytes sends a command working on a single key, whose values are encoded as they are
+func (client *Client) callBytes(ctx context.Context, cmd string, key string, values ...[]byte) (Reply, error) {
+	command := appendBlob(appendBlob(appendHeader(nil, len(values)+2), cmd), key)
+	for _, value := range values {
+		command = appendBlob(command, value)
+	}
+	defer client.wrote(cmd, key)
+	return client.send(ctx, command, idempotent(cmd))
+}
+
+func (p *Pipeline) GetBytes(key string) *Future[[]byte] {
+	return queue(p, Reply.Bytes, "GET", key)
+}
+
+func (p *Pipeline) SetBytes(key string, value []byte) *Future[struct{}] {
+	future := &Future[struct{}]{convert: Reply.asStatus}
+	p.commands = appendBlob(appendBlob(appendBlob(appendHeader(p.commands, 3), "SET"), key), value)
+	p.pending = append(p.pending, future)
+	p.written = append(p.written, []string{"SET", key})
+	return future
+}
@@ -0,0 +1,93 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+// binary looks like RESP and holds every byte value, as a protobuf payload could
+func binary() []byte {
+	b := []byte("$3\r\nGET\r\n\x00%v%d\r\n")
+	for i := range 256 {
+		b = append(b, byte(i))
+	}
+	return b
+}
+
+func TestClient_Should_Round_Trip_Binary_Values_When_Using_Bytes(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.NewClient()
+	value := binary()
+
+	if err := c.SetBytes("proto", value); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, _ := s.Get("proto"); v != string(value) {
+		t.Errorf("Unexpected value stored! %q", v)
+	}
+	if v, err := c.GetBytes("proto"); err != nil || !bytes.Equal(v, value) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	if v, err := c.GetBytes("missing"); err != nil || v != nil {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+
+	if err := c.RPushBytes("protos", value, []byte("\r\n"), []byte{0}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if err := c.LPushBytes("protos", []byte{}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := c.LIndexBytes("protos", 1); err != nil || !bytes.Equal(v, value) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	if v, err := c.RPopBytes("protos"); err != nil || !bytes.Equal(v, []byte{0}) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	if v, err := c.LPopBytes("protos"); err != nil || len(v) != 0 || v == nil {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+
+	p := c.Pipeline()
+	p.SetBytes("other", value[:20])
+	get := p.GetBytes("other")
+	if _, err := p.Exec(); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := get.Result(); err != nil || !bytes.Equal(v, value[:20]) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	rep, err := c.Do(context.Background(), "GET", []byte("other"))
+	if v, _ := rep.Bytes(); err != nil || !bytes.Equal(v, value[:20]) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+}
+
+func TestClient_Should_Return_Copies_Of_Cached_Bytes_When_Tracking_Is_On(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.NewClient()
+	if err := c.EnableTracking(context.Background(), client.TrackingOptions{}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if err := c.SetBytes("proto", binary()); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	v, err := c.GetBytes("proto")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	v[0] = 'X'
+	if v, err = c.GetBytes("proto"); err != nil || !bytes.Equal(v, binary()) {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	if stats := c.CacheStats(); stats.Hits != 1 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
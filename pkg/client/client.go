@@ -47,234 +47,397 @@
 //		fmt.Printf("I got this! %v\n", res)
 //		conn.Close()
 //	}
+//
+// Many commands can be sent in a single write with a Pipeline, reading their results afterwards:
+//
+//	p := c.Pipeline()
+//	get := p.Get("Arturo")
+//	length := p.LLen("Gatos")
+//	errs, err := p.Exec()
+//	res, err := get.Result()
+//
+// Commands without a method of their own can be sent with Do, reading the reply with its typed accessors:
+//
+//	rep, err := c.Do(ctx, "BITCOUNT", "Arturo", 0, -1)
+//	count, err := rep.Int()
+//
+// Every method has a variant taking a context (GetContext, SetContext...), whose deadline and cancellation
+// interrupt the call. Options sets the timeouts applied when the context has none:
+//
+//	c, err := client.Dial(ctx, "127.0.0.1:8000", client.Options{ReadTimeout: time.Second})
+//	res, err := c.GetContext(ctx, "Arturo")
+//
+// A client created with Dial reconnects on its own once its connection is lost (for instance, closed by the server
+// after being idle), retrying reads that are safe to repeat with an exponential backoff.
+//
+// With tracking on, values read with Get are kept in memory until the server tells they changed:
+//
+//	err = c.EnableTracking(ctx, client.TrackingOptions{CacheSize: 1000})
+//
+// Values that are not text (like protobuf payloads) are sent and read unchanged with the Bytes variants:
+//
+//	err = c.SetBytes("Arturo", payload)
+//	payload, err = c.GetBytes("Arturo")
+//
+// Go values are stored with the Codec in Options (JSON by default), and structs as hashes, field by field:
+//
+//	err = client.SetAs(ctx, c, "Niji", cat)
+//	cat, err = client.GetAs[Cat](ctx, c, "Niji")
+//	err = c.HSetStruct(ctx, "cat:1", cat)
+//	cat, err = client.HGetAllAs[Cat](ctx, c, "cat:1")
+//
+// Keys can be spread among many servers with a ShardedClient, reading many of them at once from every node:
+//
+//	s, err := client.NewSharded(client.ShardedOptions{Addresses: []string{"10.0.0.1:8000", "10.0.0.2:8000"}})
+//	values, err := s.MGet("{user:1}:name", "{user:1}:age", "{user:2}:name")
+//
+// Servers in cluster mode are talked to with a ClusterClient instead, which learns who serves each hash slot
+// and follows the redirections of nodes:
+//
+//	c, err := client.NewCluster(ctx, client.ClusterOptions{Addresses: []string{"10.0.0.1:8000"}})
+//	err = c.Set("{user:1}:name", "Arturo")
 package client
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"errors"
 	"net"
+	"os"
+	"strconv"
+	"time"
 
-	"github.com/Arthur-phys/redigo/pkg/core/respparser"
 	"github.com/Arthur-phys/redigo/pkg/redigoerr"
 )
 
+const (
+	DefaultDialTimeout     = 5 * time.Second
+	DefaultReadTimeout     = 3 * time.Second
+	DefaultWriteTimeout    = 3 * time.Second
+	DefaultMaxRetries      = 3
+	DefaultMinRetryBackoff = 8 * time.Millisecond
+	DefaultMaxRetryBackoff = 512 * time.Millisecond
+)
+
+// Options configures the timeouts of a client and how it reconnects.
+// Zero values take the defaults, negative values disable the timeout (or the retries).
+// The deadline of the context given to a call is used instead when it comes sooner.
+type Options struct {
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Dial opens a new connection whenever the current one is lost. Dial sets it to connect to the address given,
+	// a client created over a connection without it never reconnects.
+	Dial func(ctx context.Context) (net.Conn, error)
+	// MaxRetries is the amount of times a read safe to repeat (like GET) is sent again after a connection error.
+	// Other commands fail, reconnecting on the next call, since the server may have executed them.
+	MaxRetries int
+	// Retries wait from MinRetryBackoff, doubling up to MaxRetryBackoff, with a random jitter
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+	// OnConnect is called every time a connection is opened, and OnDisconnect when it is lost (or closed, with a nil error)
+	OnConnect    func(conn net.Conn)
+	OnDisconnect func(conn net.Conn, err error)
+	// Codec encodes the values of GetAs, SetAs and struct fields stored in hashes, JSONCodec by default
+	Codec Codec
+}
+
+// withDefaults replaces zero values with the defaults
+func (options Options) withDefaults() Options {
+	if options.DialTimeout == 0 {
+		options.DialTimeout = DefaultDialTimeout
+	}
+	if options.ReadTimeout == 0 {
+		options.ReadTimeout = DefaultReadTimeout
+	}
+	if options.WriteTimeout == 0 {
+		options.WriteTimeout = DefaultWriteTimeout
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = DefaultMaxRetries
+	}
+	if options.MinRetryBackoff == 0 {
+		options.MinRetryBackoff = DefaultMinRetryBackoff
+	}
+	if options.MaxRetryBackoff == 0 {
+		options.MaxRetryBackoff = DefaultMaxRetryBackoff
+	}
+	if options.Codec == nil {
+		options.Codec = JSONCodec{}
+	}
+	return options
+}
+
 type Client struct {
-	conn   *net.Conn
-	buffer *bufio.Reader
-	p      *respparser.RESPParser
+	conn    *net.Conn
+	buffer  *bufio.Reader
+	options Options
+	// broken is set once a call fails midway, since the replies left in the connection can no longer be matched to a command
+	broken bool
+	// disconnected is set once the connection is closed because of it, waiting to be replaced
+	disconnected bool
+	closed       bool
+	usedAt       time.Time
+	// cache holds the values read while tracking is on, nil otherwise
+	cache *localCache
 }
 
+// New creates a client over an open connection with the default options
 func New(conn *net.Conn) *Client {
-	parser := respparser.New(conn, 10240)
-	return &Client{conn, bufio.NewReader(*conn), parser}
+	return NewWithOptions(conn, Options{})
 }
 
-func (client *Client) Get(key string) (string, error) {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*2\r\n$3\r\nGET\r\n$%d\r\n%v\r\n", len(key), key))
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return "", err
-	}
-	_, err = client.p.Read()
-	if err != nil {
-		return "", err
-	}
-	result, _, err := client.p.ParseBlobString()
-	if bytesDiffer(err) {
-		if isRESPNull(err) {
-			_, err := client.p.ParseNull()
-			return "", err
-		} else if isRESPError(err) {
-			_, err = client.p.ParseError()
-			return "", err
+// NewWithOptions creates a client over an open connection
+func NewWithOptions(conn *net.Conn, options Options) *Client {
+	return &Client{conn: conn, buffer: bufio.NewReader(*conn), options: options.withDefaults(), usedAt: time.Now()}
+}
+
+// Dial connects to a server over tcp, waiting no longer than the dial timeout (or the deadline of ctx).
+// The client dials the same address again whenever its connection is lost, unless options.Dial is given instead.
+func Dial(ctx context.Context, address string, options Options) (*Client, error) {
+	options = options.withDefaults()
+	if options.Dial == nil {
+		dialer := net.Dialer{}
+		if options.DialTimeout > 0 {
+			dialer.Timeout = options.DialTimeout
 		}
+		options.Dial = func(ctx context.Context) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", address)
+		}
+	}
+	client := &Client{options: options}
+	if err := client.connect(ctx); err != nil {
+		return nil, err
 	}
-	return result, err
+	return client, nil
 }
 
-func (client *Client) Set(key string, value string) error {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\n%v\r\n$%d\r\n%v\r\n", len(key), key, len(value), value))
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return err
+// Close closes the connection of the client, which is not used (or replaced) anymore
+func (client *Client) Close() error {
+	if client.closed {
+		return nil
 	}
-	_, err = client.p.Read()
-	if err != nil {
-		return err
+	client.closed = true
+	if client.disconnected {
+		return nil
 	}
-	_, err = client.p.ParseNull()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
-		return err
+	conn := *client.conn
+	err := conn.Close()
+	if client.options.OnDisconnect != nil {
+		client.options.OnDisconnect(conn, nil)
 	}
 	return err
 }
 
-func (client *Client) RPush(key string, args ...string) error {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*%d\r\n$5\r\nRPUSH\r\n$%d\r\n%v", len(args)+2, len(key), key))
-	for i := range args {
-		finalBytes = fmt.Appendf(finalBytes, fmt.Sprintf("\r\n$%d\r\n%v", len(args[i]), args[i]))
-	}
-	finalBytes = fmt.Appendf(finalBytes, "\r\n")
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return err
+// Broken tells if a call failed midway (a timeout, a cancellation or a connection error),
+// after which every call fails with redigoerr.ConnectionBroken and the connection should be closed.
+func (client *Client) Broken() bool {
+	return client.broken
+}
+
+func (client *Client) Get(key string) (string, error) {
+	return client.GetContext(context.Background(), key)
+}
+
+// GetContext reads a key, from the local cache when tracking is on (see EnableTracking)
+func (client *Client) GetContext(ctx context.Context, key string) (string, error) {
+	if client.cache != nil {
+		rep, err := client.cachedGet(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		return rep.String()
 	}
-	_, err = client.p.Read()
+	rep, err := client.call(ctx, "GET", key)
 	if err != nil {
-		return err
-	}
-	_, err = client.p.ParseNull()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
-		return err
+		return "", err
 	}
+	return rep.String()
+}
+
+func (client *Client) Set(key string, value string) error {
+	return client.SetContext(context.Background(), key, value)
+}
+
+func (client *Client) SetContext(ctx context.Context, key string, value string) error {
+	_, err := client.call(ctx, "SET", key, value)
+	return err
+}
+
+func (client *Client) RPush(key string, args ...string) error {
+	return client.RPushContext(context.Background(), key, args...)
+}
+
+func (client *Client) RPushContext(ctx context.Context, key string, args ...string) error {
+	_, err := client.call(ctx, append([]string{"RPUSH", key}, args...)...)
 	return err
 }
 
 func (client *Client) RPop(key string) (string, error) {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*2\r\n$4\r\nRPOP\r\n$%d\r\n%v\r\n", len(key), key))
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return "", err
-	}
-	_, err = client.p.Read()
+	return client.RPopContext(context.Background(), key)
+}
+
+func (client *Client) RPopContext(ctx context.Context, key string) (string, error) {
+	rep, err := client.call(ctx, "RPOP", key)
 	if err != nil {
 		return "", err
 	}
-	result, _, err := client.p.ParseBlobString()
-	if bytesDiffer(err) {
-		if isRESPNull(err) {
-			_, err := client.p.ParseNull()
-			return "", err
-		} else if isRESPError(err) {
-			_, err = client.p.ParseError()
-			return "", err
-		}
-	}
-	return result, err
+	return rep.String()
 }
 
 func (client *Client) LLen(key string) (int, error) {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*2\r\n$4\r\nLLEN\r\n$%d\r\n%v\r\n", len(key), key))
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return 0, err
-	}
-	_, err = client.p.Read()
+	return client.LLenContext(context.Background(), key)
+}
+
+func (client *Client) LLenContext(ctx context.Context, key string) (int, error) {
+	rep, err := client.call(ctx, "LLEN", key)
 	if err != nil {
 		return 0, err
 	}
-	result, _, err := client.p.ParseUInt()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
-		return 0, err
-	}
-	return result, err
+	return rep.asInt()
 }
 
 func (client *Client) LPop(key string) (string, error) {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*2\r\n$4\r\nLPOP\r\n$%d\r\n%v\r\n", len(key), key))
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return "", err
-	}
-	_, err = client.p.Read()
+	return client.LPopContext(context.Background(), key)
+}
+
+func (client *Client) LPopContext(ctx context.Context, key string) (string, error) {
+	rep, err := client.call(ctx, "LPOP", key)
 	if err != nil {
 		return "", err
 	}
-	result, _, err := client.p.ParseBlobString()
-	if bytesDiffer(err) {
-		if isRESPNull(err) {
-			_, err := client.p.ParseNull()
-			return "", err
-		} else if isRESPError(err) {
-			_, err = client.p.ParseError()
-			return "", err
-		}
-	}
-	return result, err
+	return rep.String()
 }
 
 func (client *Client) LPush(key string, args ...string) error {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*%d\r\n$5\r\nLPUSH\r\n$%d\r\n%v", len(args)+2, len(key), key))
-	for i := range args {
-		finalBytes = fmt.Appendf(finalBytes, fmt.Sprintf("\r\n$%d\r\n%v", len(args[i]), args[i]))
-	}
-	finalBytes = fmt.Appendf(finalBytes, "\r\n")
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return err
-	}
-	_, err = client.p.Read()
-	if err != nil {
-		return err
-	}
-	_, err = client.p.ParseNull()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
-		return err
-	}
+	return client.LPushContext(context.Background(), key, args...)
+}
+
+func (client *Client) LPushContext(ctx context.Context, key string, args ...string) error {
+	_, err := client.call(ctx, append([]string{"LPUSH", key}, args...)...)
 	return err
 }
 
 func (client *Client) LIndex(key string, index int) (string, error) {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*3\r\n$6\r\nLINDEX\r\n$%d\r\n%v\r\n$%d\r\n%v\r\n", len(key), key, len(fmt.Sprintf("%v", index)), index))
-	err := client.sendBytes(finalBytes)
+	return client.LIndexContext(context.Background(), key, index)
+}
+
+func (client *Client) LIndexContext(ctx context.Context, key string, index int) (string, error) {
+	rep, err := client.call(ctx, "LINDEX", key, strconv.Itoa(index))
 	if err != nil {
 		return "", err
 	}
-	_, err = client.p.Read()
+	return rep.String()
+}
+
+func (client *Client) Del(key string) error {
+	return client.DelContext(context.Background(), key)
+}
+
+func (client *Client) DelContext(ctx context.Context, key string) error {
+	_, err := client.call(ctx, "DEL", key)
+	return err
+}
+
+func (client *Client) Ping() (string, error) {
+	return client.PingContext(context.Background())
+}
+
+func (client *Client) PingContext(ctx context.Context) (string, error) {
+	rep, err := client.call(ctx, "PING")
 	if err != nil {
 		return "", err
 	}
-	result, _, err := client.p.ParseBlobString()
-	if bytesDiffer(err) {
-		if isRESPNull(err) {
-			_, err = client.p.ParseNull()
-			return "", err
-		} else if isRESPError(err) {
-			_, err = client.p.ParseError()
-			return "", err
-		}
-	}
-	return result, err
+	return rep.String()
 }
 
-func (client *Client) Del(key string) error {
-	finalBytes := fmt.Appendf([]byte{}, fmt.Sprintf("*2\r\n$3\r\nDEL\r\n$%d\r\n%v\r\n", len(key), key))
-	err := client.sendBytes(finalBytes)
+// Do sends any command to the server, encoding every argument (strings, []byte, integers, floats and booleans)
+// as a blob string, and returns its reply. An error sent by the server is returned along with the reply.
+func (client *Client) Do(ctx context.Context, cmd string, args ...any) (Reply, error) {
+	command, err := appendArgs(nil, append([]any{cmd}, args...)...)
 	if err != nil {
-		return err
+		return Reply{}, err
 	}
-	_, err = client.p.Read()
-	if err != nil {
-		return err
+	if client.cache != nil {
+		defer client.wrote(stringArgs(append([]any{cmd}, args...)...)...)
 	}
-	_, err = client.p.ParseNull()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
+	return client.send(ctx, command, idempotent(cmd))
+}
+
+// call sends a command made of strings and returns its reply, failing if it is an error
+func (client *Client) call(ctx context.Context, args ...string) (Reply, error) {
+	defer client.wrote(args...)
+	return client.send(ctx, appendCommand(nil, args...), idempotent(args[0]))
+}
+
+// send sends an encoded command and returns its reply, failing if it is an error
+func (client *Client) send(ctx context.Context, command []byte, idempotent bool) (Reply, error) {
+	var rep Reply
+	err := client.exchange(ctx, command, idempotent, func() (err error) {
+		rep, err = client.receive()
 		return err
+	})
+	if err != nil {
+		return Reply{}, err
 	}
-	return err
+	return rep, rep.Err()
 }
 
-func (client *Client) Ping() (string, error) {
-	finalBytes := fmt.Appendf([]byte{}, "*1\r\n$4\r\nPING\r\n")
-	err := client.sendBytes(finalBytes)
-	if err != nil {
-		return "", err
+// longAgo is a deadline in the past, used to interrupt a blocked read or write
+var longAgo = time.Unix(1, 0)
+
+// roundTrip writes a command and calls read to get its replies, applying the write and read timeouts
+// (or the deadline of ctx, when sooner) and interrupting both once ctx is done.
+// A failure on either side marks the client as broken.
+func (client *Client) roundTrip(ctx context.Context, command []byte, read func() error) error {
+	if client.broken {
+		return redigoerr.ConnectionBroken
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn := *client.conn
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(longAgo)
+		close(interrupted)
+	})
+
+	conn.SetWriteDeadline(client.deadline(ctx, client.options.WriteTimeout))
+	err := client.sendBytes(command)
+	if err == nil {
+		conn.SetReadDeadline(client.deadline(ctx, client.options.ReadTimeout))
+		err = read()
+	}
+
+	if !stop() {
+		// Wait for the deadline to be set, so it does not interrupt the next call instead
+		<-interrupted
 	}
-	_, err = client.p.Read()
+	client.usedAt = time.Now()
 	if err != nil {
-		return "", err
+		client.broken = true
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// The connection may reach the deadline of ctx just before ctx notices it
+		if ctxDeadline, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(ctxDeadline) {
+			return context.DeadlineExceeded
+		}
 	}
-	result, _, err := client.p.ParseBlobString()
-	if bytesDiffer(err) && isRESPError(err) {
-		_, err = client.p.ParseError()
-		return "", err
+	return err
+}
+
+// deadline returns the sooner between the deadline of ctx and the timeout given, if any
+func (client *Client) deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
 	}
-	return result, nil
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	return deadline
 }
 
 func (client *Client) sendBytes(b []byte) error {
@@ -286,18 +449,3 @@ func (client *Client) sendBytes(b []byte) error {
 	}
 	return nil
 }
-
-func bytesDiffer(err error) bool {
-	nerr, ok := err.(redigoerr.Error)
-	return nerr.Code == 5 && ok
-}
-
-func isRESPNull(err error) bool {
-	nerr, ok := err.(redigoerr.Error)
-	return nerr.ExtraContext["received"] == "_" && ok
-}
-
-func isRESPError(err error) bool {
-	nerr, ok := err.(redigoerr.Error)
-	return nerr.ExtraContext["received"] == "-" && ok
-}
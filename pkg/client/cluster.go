@@ -0,0 +1,468 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// DefaultMaxRedirects is the amount of MOVED, ASK or TRYAGAIN errors a call of a ClusterClient follows before failing,
+// unless told otherwise
+const DefaultMaxRedirects = 16
+
+// clusterTryAgainBackoff is waited (once more for every attempt) before sending again a command answered with TRYAGAIN
+const clusterTryAgainBackoff = 10 * time.Millisecond
+
+// clusterReloadTimeout bounds the reloads of the slot map made in the background
+const clusterReloadTimeout = 5 * time.Second
+
+// ClusterOptions configures a ClusterClient
+type ClusterOptions struct {
+	// Addresses are some nodes of the cluster, asked about the rest and about who serves each slot
+	Addresses []string
+	// MaxRedirects is the amount of redirections a call follows before failing with redigoerr.TooManyRedirects
+	MaxRedirects int
+	// Dial opens a new connection to a node. When nil, its address is dialed over tcp.
+	Dial func(ctx context.Context, address string) (net.Conn, error)
+	// Pool configures the pool of connections to every node, whose Address and Dial are ignored
+	Pool PoolOptions
+}
+
+// ClusterClient talks to a cluster of servers, each one serving part of the 16384 hash slots keys belong to
+// (see respparser.KeySlot). It keeps a map of who serves each slot, loaded with CLUSTER SLOTS, to send every
+// command straight to the right node through a pool of connections.
+//
+// Whenever the map is stale a node answers MOVED, which updates it and sends the command again. While a slot
+// is migrated, keys already moved are answered with ASK, which sends the command to the new node (after ASKING)
+// without updating the map. Like a ShardedClient, it is safe to use from many goroutines at once.
+type ClusterClient struct {
+	options ClusterOptions
+	lock    sync.RWMutex
+	// slots holds the address of the node serving every slot, empty while unknown
+	slots     [respparser.ClusterSlots]string
+	pools     map[string]*Pool
+	closed    bool
+	reloading atomic.Bool
+}
+
+// NewCluster creates a client for the cluster the addresses given belong to, loading the slot map from the first one answering
+func NewCluster(ctx context.Context, options ClusterOptions) (*ClusterClient, error) {
+	if len(options.Addresses) == 0 {
+		redigoError := redigoerr.InvalidShardNodes
+		redigoError.ExtraContext = map[string]string{"reason": "at least one address is needed"}
+		return nil, redigoError
+	}
+	if options.MaxRedirects <= 0 {
+		options.MaxRedirects = DefaultMaxRedirects
+	}
+	c := &ClusterClient{options: options, pools: make(map[string]*Pool)}
+	if err := c.ReloadSlots(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// pool returns the pool of connections to a node, opening it the first time
+func (c *ClusterClient) pool(address string) (*Pool, error) {
+	c.lock.RLock()
+	pool, ok := c.pools[address]
+	c.lock.RUnlock()
+	if ok {
+		return pool, nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil, redigoerr.ClientClosed
+	}
+	if pool, ok := c.pools[address]; ok {
+		return pool, nil
+	}
+	options := c.options.Pool
+	options.Address = address
+	options.Dial = nil
+	if dial := c.options.Dial; dial != nil {
+		options.Dial = func(ctx context.Context) (net.Conn, error) {
+			return dial(ctx, address)
+		}
+	}
+	pool, err := NewPool(options)
+	if err != nil {
+		return nil, err
+	}
+	c.pools[address] = pool
+	return pool, nil
+}
+
+// ReloadSlots asks the nodes known (the addresses given first) who serves each slot, replacing the whole map
+// with the answer of the first one that replies
+func (c *ClusterClient) ReloadSlots(ctx context.Context) error {
+	addresses := append([]string{}, c.options.Addresses...)
+	c.lock.RLock()
+	for address := range c.pools {
+		addresses = append(addresses, address)
+	}
+	c.lock.RUnlock()
+
+	var errs []error
+	for _, address := range addresses {
+		pool, err := c.pool(address)
+		if err != nil {
+			return err
+		}
+		var slots [respparser.ClusterSlots]string
+		err = pool.Do(ctx, func(cl *Client) error {
+			rep, err := cl.Do(ctx, "CLUSTER", "SLOTS")
+			if err != nil {
+				return err
+			}
+			return parseClusterSlots(rep, &slots)
+		})
+		if err == nil {
+			c.lock.Lock()
+			c.slots = slots
+			c.lock.Unlock()
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// parseClusterSlots fills a slot map with a reply of CLUSTER SLOTS
+func parseClusterSlots(rep Reply, slots *[respparser.ClusterSlots]string) error {
+	ranges, err := rep.Array()
+	if err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		elements, err := r.Array()
+		if err != nil {
+			return err
+		}
+		if len(elements) < 3 {
+			return unexpectedReply('*', strconv.Itoa(len(elements)))
+		}
+		start, err := elements[0].Int()
+		if err != nil {
+			return err
+		}
+		end, err := elements[1].Int()
+		if err != nil {
+			return err
+		}
+		node, err := elements[2].Array()
+		if err != nil {
+			return err
+		}
+		if len(node) < 2 {
+			return unexpectedReply('*', strconv.Itoa(len(node)))
+		}
+		ip, err := node[0].String()
+		if err != nil {
+			return err
+		}
+		port, err := node[1].Int()
+		if err != nil {
+			return err
+		}
+		address := net.JoinHostPort(ip, strconv.FormatInt(port, 10))
+		for slot := max(start, 0); slot <= end && slot < respparser.ClusterSlots; slot++ {
+			slots[slot] = address
+		}
+	}
+	return nil
+}
+
+// reloadInBackground reloads the slot map unless a reload is already running
+func (c *ClusterClient) reloadInBackground() {
+	if !c.reloading.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.reloading.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), clusterReloadTimeout)
+		defer cancel()
+		c.ReloadSlots(ctx)
+	}()
+}
+
+// Node returns the address of the node serving the slot of a key, as far as the client knows
+func (c *ClusterClient) Node(key string) string {
+	return c.node(respparser.KeySlot(key))
+}
+
+func (c *ClusterClient) node(slot int) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if address := c.slots[slot]; address != "" {
+		return address
+	}
+	// Any node redirects the command to the right one
+	return c.options.Addresses[0]
+}
+
+// Nodes returns the address of every node serving a slot, sorted
+func (c *ClusterClient) Nodes() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	nodes := map[string]struct{}{}
+	for _, address := range c.slots {
+		if address != "" {
+			nodes[address] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(nodes))
+}
+
+// Close closes the connections to every node. Connections in use are closed once their calls end.
+func (c *ClusterClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	for _, pool := range c.pools {
+		pool.Close()
+	}
+	return nil
+}
+
+// redirection tells whether err is a MOVED, ASK or TRYAGAIN sent by a node, along with the slot and address in it
+func redirection(err error) (kind string, slot int, address string) {
+	var redigoError redigoerr.Error
+	if !errors.As(err, &redigoError) || redigoError.Code != redigoerr.ErrorReceived.Code {
+		return "", 0, ""
+	}
+	fields := strings.Fields(redigoError.ExtraContext["text"])
+	switch {
+	case len(fields) == 3 && (fields[0] == "MOVED" || fields[0] == "ASK"):
+		slot, err := strconv.Atoi(fields[1])
+		if err != nil || slot < 0 || slot >= respparser.ClusterSlots {
+			return "", 0, ""
+		}
+		return fields[0], slot, fields[2]
+	case len(fields) > 0 && fields[0] == "TRYAGAIN":
+		return fields[0], 0, ""
+	}
+	return "", 0, ""
+}
+
+// moved records the node now serving a slot, reloading the whole map in the background since more slots
+// probably moved along
+func (c *ClusterClient) moved(slot int, address string) {
+	c.lock.Lock()
+	c.slots[slot] = address
+	c.lock.Unlock()
+	c.reloadInBackground()
+}
+
+// Do runs f with a connection to the node serving the slot of a key, for any call without a method of its own.
+// Redirections are followed, running f again with a connection to the node the error points to:
+//
+//	err := c.Do(ctx, "cat", func(cl *client.Client) error {
+//		_, err := cl.HSet("cat", "name", "Niji")
+//		return err
+//	})
+func (c *ClusterClient) Do(ctx context.Context, key string, f func(c *Client) error) error {
+	slot := respparser.KeySlot(key)
+	address, asking := c.node(slot), false
+	for redirects := 0; ; redirects++ {
+		pool, err := c.pool(address)
+		if err != nil {
+			return err
+		}
+		err = pool.Do(ctx, func(cl *Client) error {
+			if asking {
+				if _, err := cl.Do(ctx, "ASKING"); err != nil {
+					return err
+				}
+			}
+			return f(cl)
+		})
+		kind, movedSlot, target := redirection(err)
+		if kind == "" {
+			if redigoerr.ConnectionRelated(err) {
+				// The node may be gone, with its slots served by another one
+				c.reloadInBackground()
+			}
+			return err
+		}
+		if redirects >= c.options.MaxRedirects {
+			redigoError := redigoerr.TooManyRedirects
+			redigoError.From = err
+			redigoError.ExtraContext = map[string]string{"key": key, "redirects": strconv.Itoa(redirects)}
+			return redigoError
+		}
+		switch kind {
+		case "MOVED":
+			c.moved(movedSlot, target)
+			address, asking = target, false
+		case "ASK":
+			address, asking = target, true
+		case "TRYAGAIN":
+			address, asking = c.node(slot), false
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(redirects+1) * clusterTryAgainBackoff):
+			}
+		}
+	}
+}
+
+// ForEachNode runs f with a connection to every node serving a slot at the same time, like to send FLUSHALL or PING
+func (c *ClusterClient) ForEachNode(ctx context.Context, f func(address string, c *Client) error) error {
+	pools := map[string]*Pool{}
+	for _, address := range c.Nodes() {
+		pool, err := c.pool(address)
+		if err != nil {
+			return err
+		}
+		pools[address] = pool
+	}
+	return runAll(pools, func(address string, pool *Pool) error {
+		return pool.Do(ctx, func(cl *Client) error {
+			return f(address, cl)
+		})
+	})
+}
+
+// fanOut groups keys by the node serving their slot, sending a pipeline to every node involved at the same time.
+// queue adds the command of a key to a pipeline, returning a function that reads its result once executed.
+// Keys redirected elsewhere are sent again one by one, following redirections like Do.
+func (c *ClusterClient) fanOut(ctx context.Context, keys []string, queue func(p *Pipeline, index int) func() error) error {
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		address := c.Node(key)
+		groups[address] = append(groups[address], i)
+	}
+	var (
+		lock       sync.Mutex
+		redirected []int
+	)
+	err := runAll(groups, func(address string, indexes []int) error {
+		pool, err := c.pool(address)
+		if err != nil {
+			return err
+		}
+		return pool.Do(ctx, func(cl *Client) error {
+			p := cl.Pipeline()
+			results := make([]func() error, len(indexes))
+			for i, index := range indexes {
+				results[i] = queue(p, index)
+			}
+			if _, err := p.ExecContext(ctx); err != nil {
+				return err
+			}
+			var errs []error
+			for i, index := range indexes {
+				err := results[i]()
+				kind, slot, target := redirection(err)
+				if kind == "MOVED" {
+					c.moved(slot, target)
+				}
+				if kind != "" {
+					lock.Lock()
+					redirected = append(redirected, index)
+					lock.Unlock()
+				} else if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		})
+	})
+	errs := []error{err}
+	for _, index := range redirected {
+		errs = append(errs, c.Do(ctx, keys[index], func(cl *Client) error {
+			p := cl.Pipeline()
+			result := queue(p, index)
+			if _, err := p.ExecContext(ctx); err != nil {
+				return err
+			}
+			return result()
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ClusterClient) Get(key string) (string, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *ClusterClient) GetContext(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.Do(ctx, key, func(cl *Client) (err error) {
+		value, err = cl.GetContext(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (c *ClusterClient) Set(key string, value string) error {
+	return c.SetContext(context.Background(), key, value)
+}
+
+func (c *ClusterClient) SetContext(ctx context.Context, key string, value string) error {
+	return c.Do(ctx, key, func(cl *Client) error {
+		return cl.SetContext(ctx, key, value)
+	})
+}
+
+// MGet reads many keys, sending a single pipeline to every node involved at the same time.
+// Values are returned in the order of the keys, empty for those that do not exist.
+func (c *ClusterClient) MGet(keys ...string) ([]string, error) {
+	return c.MGetContext(context.Background(), keys...)
+}
+
+func (c *ClusterClient) MGetContext(ctx context.Context, keys ...string) ([]string, error) {
+	values := make([]string, len(keys))
+	err := c.fanOut(ctx, keys, func(p *Pipeline, index int) func() error {
+		future := p.Get(keys[index])
+		return func() (err error) {
+			values[index], err = future.Result()
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// MSet sets many keys, sending a single pipeline to every node involved at the same time.
+// Nodes do not agree on the outcome, so some keys may be set even if others fail.
+func (c *ClusterClient) MSet(values map[string]string) error {
+	return c.MSetContext(context.Background(), values)
+}
+
+func (c *ClusterClient) MSetContext(ctx context.Context, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return c.fanOut(ctx, keys, func(p *Pipeline, index int) func() error {
+		return p.Set(keys[index], values[keys[index]]).Err
+	})
+}
+
+// Del removes many keys, sending a single pipeline to every node involved at the same time
+func (c *ClusterClient) Del(keys ...string) error {
+	return c.DelContext(context.Background(), keys...)
+}
+
+func (c *ClusterClient) DelContext(ctx context.Context, keys ...string) error {
+	return c.fanOut(ctx, keys, func(p *Pipeline, index int) func() error {
+		return p.Del(keys[index]).Err
+	})
+}
@@ -0,0 +1,306 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+// do sends a command to a single node, failing the test if it is refused
+func do(t *testing.T, s *redigotest.Server, args ...any) client.Reply {
+	t.Helper()
+	rep, err := s.Client().Do(context.Background(), args[0].(string), args[1:]...)
+	if err != nil {
+		t.Fatalf("An error occurred sending %v! %v", args, err)
+	}
+	return rep
+}
+
+func nodeID(t *testing.T, s *redigotest.Server) string {
+	t.Helper()
+	id, _ := do(t, s, "CLUSTER", "MYID").String()
+	return id
+}
+
+// startMigration marks the slot of key as moving from the node serving it to another one, like redis-cli does
+// before sending keys, returning both nodes
+func startMigration(t *testing.T, c *redigotest.Cluster, cc *client.ClusterClient, key string) (*redigotest.Server, *redigotest.Server) {
+	t.Helper()
+	from := c.Node(cc.Node(key))
+	to := c.Nodes()[0]
+	if to == from {
+		to = c.Nodes()[1]
+	}
+	slot := respparser.KeySlot(key)
+	do(t, to, "CLUSTER", "SETSLOT", slot, "IMPORTING", nodeID(t, from))
+	do(t, from, "CLUSTER", "SETSLOT", slot, "MIGRATING", nodeID(t, to))
+	return from, to
+}
+
+// migrate sends keys from a node to another one
+func migrate(t *testing.T, from *redigotest.Server, to *redigotest.Server, keys ...string) {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(to.Addr())
+	args := []any{"MIGRATE", host, port, "", 0, 1000, "KEYS"}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	do(t, from, args...)
+}
+
+func TestClusterClient_Should_Follow_ASK_When_Slot_Is_Migrating(t *testing.T) {
+	c := redigotest.NewCluster(t, 2)
+	cc := c.NewClient()
+	if err := cc.MSet(map[string]string{"{user}:name": "Arturo", "{user}:age": "26"}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	from, to := startMigration(t, c, cc, "{user}")
+	migrate(t, from, to, "{user}:name")
+
+	// The key migrated is read from the new node, the other one still from the old node
+	if v, err := cc.Get("{user}:name"); err != nil || v != "Arturo" {
+		t.Errorf("Unexpected value! %v - %s", err, v)
+	}
+	if v, err := cc.Get("{user}:age"); err != nil || v != "26" {
+		t.Errorf("Unexpected value! %v - %s", err, v)
+	}
+	if _, ok := from.Get("{user}:name"); ok {
+		t.Errorf("Key was not removed from the old node!")
+	}
+	if v, _ := to.Get("{user}:name"); v != "Arturo" {
+		t.Errorf("Unexpected value in the new node! %s", v)
+	}
+	// ASK does not change who serves the slot
+	if node := cc.Node("{user}:name"); node != from.Addr() {
+		t.Errorf("Unexpected node! %s", node)
+	}
+	// Without ASKING the new node sends clients back to the old one
+	if _, err := to.Client().Get("{user}:name"); !isCode(err, redigoerr.ErrorReceived.Code) ||
+		!strings.HasPrefix(err.(redigoerr.Error).ExtraContext["text"], "MOVED ") {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	// Commands over keys split among both nodes have to wait for the migration to end
+	if _, err := from.Client().Do(context.Background(), "PFCOUNT", "{user}:name", "{user}:age"); !isCode(err, redigoerr.ErrorReceived.Code) ||
+		!strings.HasPrefix(err.(redigoerr.Error).ExtraContext["text"], "TRYAGAIN") {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
+
+func TestClusterClient_Should_Keep_Every_Write_When_Written_While_Migrating(t *testing.T) {
+	c := redigotest.NewCluster(t, 2)
+	cc := c.NewClient()
+	if err := cc.Set("{user}:visits", "0"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	from, to := startMigration(t, c, cc, "{user}")
+
+	// Writes keep landing on the old node until the key is gone, when it starts answering ASK.
+	// While the key is being sent they are answered with TRYAGAIN, as a cluster client would retry them.
+	written := make(chan int)
+	writer := from.NewClient()
+	go func() {
+		last := 0
+		defer func() { written <- last }()
+		for i := 1; ; i++ {
+			err := writer.Set("{user}:visits", fmt.Sprint(i))
+			if err != nil {
+				if isCode(err, redigoerr.ErrorReceived.Code) && strings.HasPrefix(err.(redigoerr.Error).ExtraContext["text"], "TRYAGAIN ") {
+					continue
+				}
+				if !isCode(err, redigoerr.ErrorReceived.Code) || !strings.HasPrefix(err.(redigoerr.Error).ExtraContext["text"], "ASK ") {
+					t.Errorf("Unexpected error! %v", err)
+				}
+				return
+			}
+			last = i
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	migrate(t, from, to, "{user}:visits")
+
+	last := <-written
+	if v, _ := to.Get("{user}:visits"); v != fmt.Sprint(last) {
+		t.Errorf("Write lost while migrating! %s - %d", v, last)
+	}
+}
+
+func TestMigrate_Should_Keep_Keys_When_Target_Database_Does_Not_Exist(t *testing.T) {
+	from, to := redigotest.NewServer(t), redigotest.NewServer(t)
+	from.Seed(map[string]string{"name": "Arturo"})
+	host, port, _ := net.SplitHostPort(to.Addr())
+	_, err := from.Client().Do(context.Background(), "MIGRATE", host, port, "name", 100, 1000)
+	if !isCode(err, redigoerr.ErrorReceived.Code) || !strings.Contains(err.(redigoerr.Error).ExtraContext["text"], "Target instance replied with error") {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if v, ok := from.Get("name"); !ok || v != "Arturo" {
+		t.Errorf("Key was removed from the source! %s", v)
+	}
+	// Nothing is restored in another database either
+	if to.Keys() != 0 {
+		t.Errorf("Key was restored in the target!")
+	}
+}
+
+func TestMigrate_Should_Keep_Keys_When_Changed_While_Being_Sent(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"name": "Arturo"})
+	// The target answers once the key changed in the source
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer target.Close()
+	received, reply := make(chan struct{}), make(chan struct{})
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Read(make([]byte, 1024))
+		close(received)
+		<-reply
+		conn.Write([]byte("+OK\r\n"))
+	}()
+	host, port, _ := net.SplitHostPort(target.Addr().String())
+	migrated := make(chan error)
+	go func() {
+		_, err := s.NewClient().Do(context.Background(), "MIGRATE", host, port, "name", 0, 1000)
+		migrated <- err
+	}()
+	<-received
+	// The database is not locked while waiting for the target
+	if err := s.Client().Set("name", "Gene"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	close(reply)
+	if err := <-migrated; !isCode(err, redigoerr.ErrorReceived.Code) || !strings.Contains(err.(redigoerr.Error).ExtraContext["text"], "changed while being migrated") {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if v, _ := s.Get("name"); v != "Gene" {
+		t.Errorf("Changed key was removed! %s", v)
+	}
+}
+
+func TestClusterClient_Should_Follow_MOVED_When_Slot_Was_Migrated(t *testing.T) {
+	c := redigotest.NewCluster(t, 3)
+	cc := c.NewClient()
+	if err := cc.Set("{user}:name", "Arturo"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	from, to := startMigration(t, c, cc, "{user}")
+	migrate(t, from, to, "{user}:name")
+	slot := respparser.KeySlot("{user}")
+	do(t, to, "CLUSTER", "SETSLOT", slot, "NODE", nodeID(t, to))
+	do(t, from, "CLUSTER", "SETSLOT", slot, "NODE", nodeID(t, to))
+
+	// The client still believes the old node serves the slot, until it is told otherwise
+	if v, err := cc.Get("{user}:name"); err != nil || v != "Arturo" {
+		t.Errorf("Unexpected value! %v - %s", err, v)
+	}
+	if node := cc.Node("{user}:name"); node != to.Addr() {
+		t.Errorf("Slot map was not updated! %s", node)
+	}
+	if err := cc.Set("{user}:age", "26"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, _ := to.Get("{user}:age"); v != "26" {
+		t.Errorf("Unexpected value in the new node! %s", v)
+	}
+	// The rest of the cluster learns about it through gossip
+	moved := fmt.Sprintf("MOVED %d %s", slot, to.Addr())
+	for _, node := range c.Nodes() {
+		if node == to {
+			continue
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, err := node.Client().Get("{user}:name")
+			if isCode(err, redigoerr.ErrorReceived.Code) && err.(redigoerr.Error).ExtraContext["text"] == moved {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("Unexpected error from %s! %v", node.Addr(), err)
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestClusterClient_Should_Store_Every_Key_In_Its_Node_When_Spreading_Keys(t *testing.T) {
+	c := redigotest.NewCluster(t, 3)
+	cc := c.NewClient()
+	values := make(map[string]string)
+	keys := []string{}
+	for i := range 100 {
+		keys = append(keys, fmt.Sprintf("cat:%d", i))
+		values[keys[i]] = fmt.Sprint(i)
+	}
+	if err := cc.MSet(values); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for _, node := range c.Nodes() {
+		if node.Keys() == 0 {
+			t.Errorf("Node %s got no key!", node.Addr())
+		}
+	}
+	for key, value := range values {
+		if v, _ := c.Node(cc.Node(key)).Get(key); v != value {
+			t.Errorf("Unexpected value for %s! %s", key, v)
+		}
+	}
+
+	got, err := cc.MGet(append(keys, "missing")...)
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for i, key := range keys {
+		if got[i] != values[key] {
+			t.Errorf("Unexpected value for %s! %s", key, got[i])
+		}
+	}
+	if got[len(keys)] != "" {
+		t.Errorf("Unexpected value for a missing key! %s", got[len(keys)])
+	}
+
+	if err := cc.Del(keys...); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for _, node := range c.Nodes() {
+		if node.Keys() != 0 {
+			t.Errorf("Node %s kept %d keys!", node.Addr(), node.Keys())
+		}
+	}
+}
+
+func TestClusterClient_Should_Fail_When_Redirected_Too_Many_Times(t *testing.T) {
+	c := redigotest.NewCluster(t, 2)
+	cc, err := client.NewCluster(context.Background(), client.ClusterOptions{Addresses: []string{c.Nodes()[0].Addr()}, MaxRedirects: 3})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer cc.Close()
+	// The owner gives the slot away without the other node taking it, so each one sends clients to the other
+	from := c.Node(cc.Node("cat"))
+	to := c.Nodes()[0]
+	if to == from {
+		to = c.Nodes()[1]
+	}
+	do(t, from, "CLUSTER", "SETSLOT", respparser.KeySlot("cat"), "NODE", nodeID(t, to))
+
+	if _, err := cc.Get("cat"); !isCode(err, redigoerr.TooManyRedirects.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
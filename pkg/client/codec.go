@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// Codec turns Go values into the bytes stored in the server and back, see GetAs and SetAs.
+// Unmarshal receives a pointer to the value to fill.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec stores values as JSON, readable by clients in any language. It is the codec used by default.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec stores values with encoding/gob, which only Go programs read but keeps types like maps with
+// non string keys. Every value carries its type description, so it suits bigger values better.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// RawCodec stores []byte and string values as they are (like protobuf payloads already encoded),
+// failing with redigoerr.UnsupportedArgument for any other type.
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case *[]byte:
+		return *v, nil
+	case *string:
+		return []byte(*v), nil
+	default:
+		return nil, redigoerr.UnsupportedArgument
+	}
+}
+
+func (RawCodec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = bytes.Clone(data)
+	case *string:
+		*v = string(data)
+	default:
+		return redigoerr.UnsupportedArgument
+	}
+	return nil
+}
+
+// GetAs reads a key, decoding it with the codec of the client. A key that does not exist fails with
+// redigoerr.KeyNotFoundInDictionary, since the zero value of T could have been stored.
+func GetAs[T any](ctx context.Context, client *Client, key string) (T, error) {
+	var value T
+	b, err := client.GetBytesContext(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	if b == nil {
+		redigoError := redigoerr.KeyNotFoundInDictionary
+		redigoError.ExtraContext = map[string]string{"key": key}
+		return value, redigoError
+	}
+	if err = client.options.Codec.Unmarshal(b, &value); err != nil {
+		return value, decodeError(err, key)
+	}
+	return value, nil
+}
+
+// SetAs encodes a value with the codec of the client and sets it as the value of a key
+func SetAs[T any](ctx context.Context, client *Client, key string, value T) error {
+	b, err := client.options.Codec.Marshal(value)
+	if err != nil {
+		return encodeError(err, key)
+	}
+	return client.SetBytesContext(ctx, key, b)
+}
+
+func encodeError(err error, key string) error {
+	redigoError := redigoerr.UnableToEncodeValue
+	redigoError.From = err
+	redigoError.ExtraContext = map[string]string{"key": key}
+	return redigoError
+}
+
+func decodeError(err error, key string) error {
+	redigoError := redigoerr.UnableToDecodeValue
+	redigoError.From = err
+	redigoError.ExtraContext = map[string]string{"key": key}
+	return redigoError
+}
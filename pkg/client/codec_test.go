@@ -0,0 +1,87 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+type cat struct {
+	Name  string
+	Age   int
+	Toys  []string
+	Owner *string
+}
+
+func TestSetAs_Should_Round_Trip_Typed_Values_When_Using_Any_Codec(t *testing.T) {
+	s := redigotest.NewServer(t)
+	owner := "Arturo"
+	niji := cat{Name: "Niji", Age: 3, Toys: []string{"mouse", "laser"}, Owner: &owner}
+	for _, codec := range []client.Codec{nil, client.JSONCodec{}, client.GobCodec{}} {
+		conn, err := net.Dial("tcp", s.Addr())
+		if err != nil {
+			t.Fatalf("An error occurred! %v", err)
+		}
+		c := client.NewWithOptions(&conn, client.Options{Codec: codec})
+		if err = client.SetAs(context.Background(), c, "cat", niji); err != nil {
+			t.Fatalf("An error occurred! %v", err)
+		}
+		v, err := client.GetAs[cat](context.Background(), c, "cat")
+		if err != nil || v.Name != "Niji" || v.Age != 3 || !slices.Equal(v.Toys, niji.Toys) || *v.Owner != owner {
+			t.Errorf("Unexpected value! %+v - %v", v, err)
+		}
+		c.Close()
+	}
+	// JSON is used by default, so other clients can read the values
+	if err := client.SetAs(context.Background(), s.NewClient(), "cat", niji); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, _ := s.Get("cat"); v != `{"Name":"Niji","Age":3,"Toys":["mouse","laser"],"Owner":"Arturo"}` {
+		t.Errorf("Unexpected value stored! %s", v)
+	}
+}
+
+func TestGetAs_Should_Return_Error_When_Key_Is_Missing_Or_Can_Not_Be_Decoded(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"cat": "Niji"})
+	c := s.NewClient()
+	if _, err := client.GetAs[cat](context.Background(), c, "missing"); !redigoerr.KeyNotFound(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err := client.GetAs[cat](context.Background(), c, "cat"); !isCode(err, redigoerr.UnableToDecodeValue.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err := client.SetAs(context.Background(), c, "cat", func() {}); !isCode(err, redigoerr.UnableToEncodeValue.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
+
+func TestRawCodec_Should_Store_Bytes_As_They_Are_When_Given_Bytes_Or_Strings(t *testing.T) {
+	s := redigotest.NewServer(t)
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	c := client.NewWithOptions(&conn, client.Options{Codec: client.RawCodec{}})
+	defer c.Close()
+	if err = client.SetAs(context.Background(), c, "payload", []byte("\x08\x96\x01")); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, _ := s.Get("payload"); v != "\x08\x96\x01" {
+		t.Errorf("Unexpected value stored! %q", v)
+	}
+	if v, err := client.GetAs[string](context.Background(), c, "payload"); err != nil || v != "\x08\x96\x01" {
+		t.Errorf("Unexpected value! %q - %v", v, err)
+	}
+	if err = client.SetAs(context.Background(), c, "payload", 3); !isCode(err, redigoerr.UnableToEncodeValue.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+// stalledServer accepts a single connection, answering the first command with partial and then never again
+func stalledServer(t *testing.T, partial string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		listener.Close()
+	})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			return
+		}
+		conn.Write([]byte(partial))
+		<-done
+	}()
+	return listener.Addr().String()
+}
+
+func TestClient_Should_Mark_Connection_Broken_When_Reply_Times_Out_Midway(t *testing.T) {
+	address := stalledServer(t, "$10\r\nNiji")
+	// A client created over a connection never reconnects
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	c := client.NewWithOptions(&conn, client.Options{ReadTimeout: 50 * time.Millisecond})
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.Get("cat")
+	if !redigoerr.ConnectionRelated(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read timeout was not applied! %v", elapsed)
+	}
+	if !c.Broken() {
+		t.Errorf("Client was not marked as broken!")
+	}
+	// The rest of the reply can not be told apart from the next one anymore
+	if _, err = c.Get("cat"); !isCode(err, redigoerr.ConnectionBroken.Code) || !redigoerr.ConnectionRelated(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
+
+func TestClient_Should_Return_Context_Error_When_Deadline_Comes_Before_Timeout(t *testing.T) {
+	address := stalledServer(t, "")
+	c, err := client.Dial(context.Background(), address, client.Options{})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = c.GetContext(ctx, "cat"); err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Deadline was not applied! %v", elapsed)
+	}
+	if !c.Broken() {
+		t.Errorf("Client was not marked as broken!")
+	}
+}
+
+func TestClient_Should_Abort_Call_When_Context_Is_Canceled(t *testing.T) {
+	address := stalledServer(t, "")
+	// No timeouts at all, only the cancellation can stop the call
+	c, err := client.Dial(context.Background(), address, client.Options{ReadTimeout: -1, WriteTimeout: -1})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	result := make(chan error, 1)
+	go func() {
+		result <- c.SetContext(ctx, "cat", "Niji")
+	}()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("Unexpected error! %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Call was not aborted!")
+	}
+}
+
+func TestClient_Should_Keep_Working_When_Context_Is_Done_After_The_Call(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c, err := client.Dial(context.Background(), s.Addr(), client.Options{})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if err = c.SetContext(ctx, "cat", "Niji"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	cancel()
+	if _, err = c.GetContext(ctx, "cat"); err != context.Canceled {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	// A done context fails the call before sending anything, the connection stays usable
+	if c.Broken() {
+		t.Errorf("Client was marked as broken!")
+	}
+	if v, err := c.GetContext(context.Background(), "cat"); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	p := c.Pipeline()
+	pong := p.Ping()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err = p.ExecContext(ctx); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, err := pong.Result(); err != nil || v != "PONG" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+}
+
+func TestPipeline_Should_Fail_Every_Command_When_Exec_Times_Out(t *testing.T) {
+	address := stalledServer(t, "_\r\n")
+	c, err := client.Dial(context.Background(), address, client.Options{ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	p := c.Pipeline()
+	set := p.Set("cat", "Niji")
+	get := p.Get("cat")
+	errs, err := p.Exec()
+	if !redigoerr.ConnectionRelated(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if errs[0] != nil || set.Err() != nil {
+		t.Errorf("Reply received was discarded! %v", errs[0])
+	}
+	if !redigoerr.ConnectionRelated(get.Err()) || !c.Broken() {
+		t.Errorf("Unexpected error! %v", get.Err())
+	}
+}
+
+func TestDial_Should_Fail_When_Context_Is_Done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Dial(ctx, "127.0.0.1:1", client.Options{}); err == nil {
+		t.Errorf("Connection was opened!")
+	}
+}
@@ -0,0 +1,172 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+func TestDo_Should_Encode_Every_Argument_When_Types_Are_Mixed(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.Client()
+	ctx := context.Background()
+	value := []byte("Niji\r\nAnubis\x00")
+	rep, err := c.Do(ctx, "SET", "cats", value)
+	if err != nil || !rep.IsNil() {
+		t.Fatalf("An error occurred! %v - %s", err, rep.Type())
+	}
+	if v, _ := s.Get("cats"); v != string(value) {
+		t.Errorf("Unexpected value! %q", v)
+	}
+	if _, err = c.Do(ctx, "SETBIT", "bits", 7, true); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if _, err = c.Do(ctx, "SETBIT", "bits", uint8(9), 1); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	rep, err = c.Do(ctx, "BITCOUNT", "bits", 0, int64(-1))
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if count, err := rep.Int(); err != nil || count != 2 {
+		t.Errorf("Unexpected value! %d - %v", count, err)
+	}
+
+	if _, err = c.Do(ctx, "GEOADD", "cities", -99.1332, float32(19.4326), "CDMX"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	rep, err = c.Do(ctx, "GEOPOS", "cities", "CDMX", "Atlantis")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	positions, err := rep.Array()
+	if err != nil || len(positions) != 2 {
+		t.Fatalf("Unexpected value! %d - %v", len(positions), err)
+	}
+	coordinates, err := positions[0].Array()
+	if err != nil || len(coordinates) != 2 {
+		t.Fatalf("Unexpected value! %d - %v", len(coordinates), err)
+	}
+	if longitude, _ := coordinates[0].String(); len(longitude) < 6 || longitude[:6] != "-99.13" {
+		t.Errorf("Unexpected value! %s", longitude)
+	}
+	if !positions[1].IsNil() {
+		t.Errorf("Unexpected value! %s", positions[1].Type())
+	}
+}
+
+func TestDo_Should_Return_A_Map_When_Reply_Holds_Pairs(t *testing.T) {
+	s := redigotest.NewServer(t)
+	rep, err := s.Client().Do(context.Background(), "CONFIG", "GET", "slowlog-*")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	config, err := rep.Map()
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if len(config) != 2 {
+		t.Errorf("Unexpected amount of entries! %d", len(config))
+	}
+	if v, err := config["slowlog-log-slower-than"].Int(); err != nil || v != -1 {
+		t.Errorf("Unexpected value! %d - %v", v, err)
+	}
+	if _, err := config["slowlog-max-len"].String(); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+}
+
+func TestDo_Should_Keep_Connection_Usable_When_Server_Or_Arguments_Fail(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"name": "Arturo"})
+	c := s.Client()
+	ctx := context.Background()
+
+	// LLEN over a string fails, without closing the connection
+	rep, err := c.Do(ctx, "LLEN", "name")
+	if !isCode(err, redigoerr.ErrorReceived.Code) || rep.Type() != "error" {
+		t.Errorf("Unexpected error! %v - %s", err, rep.Type())
+	}
+	if _, err = c.Do(ctx, "SET", "name", struct{}{}); !isCode(err, redigoerr.UnsupportedArgument.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err = rep.Int(); !isCode(err, redigoerr.ErrorReceived.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+
+	rep, err = c.Do(ctx, "GET", "name")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := rep.String(); err != nil || v != "Arturo" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if _, err = rep.Array(); !isCode(err, redigoerr.UnexpectedFirstByte.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	rep, err = c.Do(ctx, "GET", "missing")
+	if err != nil || !rep.IsNil() {
+		t.Errorf("Unexpected value! %s - %v", rep.Type(), err)
+	}
+}
+
+func TestDo_Should_Not_Send_Command_When_Context_Is_Done(t *testing.T) {
+	s := redigotest.NewServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := s.Client().Do(ctx, "SET", "name", "Arturo"); err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, ok := s.Get("name"); ok {
+		t.Errorf("Command was sent!")
+	}
+}
+
+func TestPipeline_Should_Queue_Any_Command_When_Using_Do(t *testing.T) {
+	s := redigotest.NewServer(t)
+	p := s.Client().Pipeline()
+	set := p.Do("SET", "counter", 41)
+	bits := p.Do("SETBIT", "bits", 3, 1)
+	wrong := p.Do("GET", 1.5, struct{}{})
+	get := p.Do("GET", "counter")
+	if p.Len() != 4 {
+		t.Errorf("Unexpected amount of commands! %d", p.Len())
+	}
+	if !isCode(wrong.Err(), redigoerr.UnsupportedArgument.Code) {
+		t.Errorf("Unexpected error! %v", wrong.Err())
+	}
+	errs, err := p.Exec()
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	// The command that was never sent keeps its place among the errors
+	for i, err := range errs {
+		if i == 2 {
+			if !isCode(err, redigoerr.UnsupportedArgument.Code) {
+				t.Errorf("Unexpected error for command %d! %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("An error occurred for command %d! %v", i, err)
+		}
+	}
+	if !isCode(wrong.Err(), redigoerr.UnsupportedArgument.Code) {
+		t.Errorf("Unexpected error! %v", wrong.Err())
+	}
+	if rep, err := set.Result(); err != nil || !rep.IsNil() {
+		t.Errorf("Unexpected value! %s - %v", rep.Type(), err)
+	}
+	if rep, _ := bits.Result(); rep.Type() != "integer" {
+		t.Errorf("Unexpected value! %s", rep.Type())
+	}
+	rep, _ := get.Result()
+	if v, err := rep.Int(); err != nil || v != 41 {
+		t.Errorf("Unexpected value! %d - %v", v, err)
+	}
+}
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"context"
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// HSet sets fields of a hash, given as field value pairs, returning the amount of fields that did not exist before
+func (client *Client) HSet(key string, pairs ...string) (int, error) {
+	return client.HSetContext(context.Background(), key, pairs...)
+}
+
+func (client *Client) HSetContext(ctx context.Context, key string, pairs ...string) (int, error) {
+	rep, err := client.call(ctx, append([]string{"HSET", key}, pairs...)...)
+	if err != nil {
+		return 0, err
+	}
+	return rep.asInt()
+}
+
+// HGet reads a field of a hash, where a missing field is the empty string
+func (client *Client) HGet(key string, field string) (string, error) {
+	return client.HGetContext(context.Background(), key, field)
+}
+
+func (client *Client) HGetContext(ctx context.Context, key string, field string) (string, error) {
+	rep, err := client.call(ctx, "HGET", key, field)
+	if err != nil {
+		return "", err
+	}
+	return rep.String()
+}
+
+// HGetAll reads every field of a hash, empty when the key does not exist
+func (client *Client) HGetAll(key string) (map[string]string, error) {
+	return client.HGetAllContext(context.Background(), key)
+}
+
+func (client *Client) HGetAllContext(ctx context.Context, key string) (map[string]string, error) {
+	rep, err := client.call(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := rep.Map()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(fields))
+	for field, value := range fields {
+		if m[field], err = value.String(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// HDel removes fields of a hash, returning the amount removed
+func (client *Client) HDel(key string, fields ...string) (int, error) {
+	return client.HDelContext(context.Background(), key, fields...)
+}
+
+func (client *Client) HDelContext(ctx context.Context, key string, fields ...string) (int, error) {
+	rep, err := client.call(ctx, append([]string{"HDEL", key}, fields...)...)
+	if err != nil {
+		return 0, err
+	}
+	return rep.asInt()
+}
+
+// HSetStruct stores the exported fields of a struct (or a pointer to one) as the fields of a hash.
+// The `redis` tag renames a field, "-" skips it and the omitempty option skips zero values:
+//
+//	type Cat struct {
+//		Name  string    `redis:"name"`
+//		Born  time.Time `redis:"born,omitempty"`
+//		Toys  []string  `redis:"toys"`
+//		Owner *string   `redis:"-"`
+//	}
+//
+// Strings, []byte, booleans and numbers are stored as text, like types implementing encoding.TextMarshaler.
+// Any other type (like slices or nested structs) is encoded with the codec of the client. Nil pointers are skipped.
+func (client *Client) HSetStruct(ctx context.Context, key string, v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		redigoError := redigoerr.UnsupportedArgument
+		redigoError.ExtraContext = map[string]string{"kind": value.Kind().String()}
+		return redigoError
+	}
+	args := []any{key}
+	for _, field := range hashFieldsOf(value.Type()) {
+		fieldValue := value.FieldByIndex(field.index)
+		if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if field.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		b, err := client.encodeField(fieldValue)
+		if err != nil {
+			return encodeError(err, key+"."+field.name)
+		}
+		args = append(args, field.name, b)
+	}
+	if len(args) == 1 {
+		return nil
+	}
+	_, err := client.Do(ctx, "HSET", args...)
+	return err
+}
+
+// HGetAllAs reads a hash into a struct, the opposite of HSetStruct. Fields missing from the hash are left
+// with their zero value, while a key that does not exist fails with redigoerr.KeyNotFoundInDictionary.
+func HGetAllAs[T any](ctx context.Context, client *Client, key string) (T, error) {
+	var v T
+	value := reflect.ValueOf(&v).Elem()
+	if value.Kind() != reflect.Struct {
+		redigoError := redigoerr.UnsupportedArgument
+		redigoError.ExtraContext = map[string]string{"type": value.Type().String()}
+		return v, redigoError
+	}
+	rep, err := client.call(ctx, "HGETALL", key)
+	if err != nil {
+		return v, err
+	}
+	fields, err := rep.Map()
+	if err != nil {
+		return v, err
+	}
+	if len(fields) == 0 {
+		redigoError := redigoerr.KeyNotFoundInDictionary
+		redigoError.ExtraContext = map[string]string{"key": key}
+		return v, redigoError
+	}
+	for _, field := range hashFieldsOf(value.Type()) {
+		stored, ok := fields[field.name]
+		if !ok {
+			continue
+		}
+		b, err := stored.Bytes()
+		if err != nil {
+			return v, err
+		}
+		fieldValue := value.FieldByIndex(field.index)
+		if fieldValue.Kind() == reflect.Pointer {
+			fieldValue.Set(reflect.New(fieldValue.Type().Elem()))
+			fieldValue = fieldValue.Elem()
+		}
+		if err = client.decodeField(b, fieldValue); err != nil {
+			return v, decodeError(err, key+"."+field.name)
+		}
+	}
+	return v, nil
+}
+
+// hashField is an exported struct field stored in a hash
+type hashField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+}
+
+// hashFields holds the fields of every struct type already mapped, which never change
+var hashFields sync.Map
+
+func hashFieldsOf(t reflect.Type) []hashField {
+	if fields, ok := hashFields.Load(t); ok {
+		return fields.([]hashField)
+	}
+	fields := []hashField{}
+	for _, f := range reflect.VisibleFields(t) {
+		// Fields of embedded structs are promoted, so they are stored in the same hash
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) || !reachable(t, f.Index) {
+			continue
+		}
+		name, options, _ := strings.Cut(f.Tag.Get("redis"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, hashField{index: f.Index, name: name, omitEmpty: options == "omitempty"})
+	}
+	hashFields.Store(t, fields)
+	return fields
+}
+
+// reachable tells if a promoted field is embedded through exported (and not pointer) structs only,
+// since the fields of the rest can not be read or set
+func reachable(t reflect.Type, index []int) bool {
+	for i := range len(index) - 1 {
+		f := t.Field(index[i])
+		if !f.IsExported() || f.Type.Kind() != reflect.Struct {
+			return false
+		}
+		t = f.Type
+	}
+	return true
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func (client *Client) encodeField(v reflect.Value) ([]byte, error) {
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler).MarshalText()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return []byte{'1'}, nil
+		}
+		return []byte{'0'}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(nil, v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(nil, v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return v.Bytes(), nil
+	}
+	return client.options.Codec.Marshal(v.Interface())
+}
+
+func (client *Client) decodeField(b []byte, v reflect.Value) error {
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(b)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+		return nil
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(string(b))
+		v.SetBool(parsed)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(string(b), 10, v.Type().Bits())
+		v.SetInt(parsed)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		parsed, err := strconv.ParseUint(string(b), 10, v.Type().Bits())
+		v.SetUint(parsed)
+		return err
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(string(b), v.Type().Bits())
+		v.SetFloat(parsed)
+		return err
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes(b)
+		return nil
+	}
+	return client.options.Codec.Unmarshal(b, v.Addr().Interface())
+}
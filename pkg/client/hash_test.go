@@ -0,0 +1,115 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+type Pet struct {
+	Name string `redis:"name"`
+}
+
+type profile struct {
+	Pet
+	Born     time.Time         `redis:"born"`
+	Lives    uint8             `redis:"lives"`
+	Weight   float64           `redis:"weight,omitempty"`
+	Indoor   bool              `redis:"indoor"`
+	Toys     []string          `redis:"toys"`
+	Vet      *string           `redis:"vet"`
+	Avatar   []byte            `redis:"avatar"`
+	Notes    map[string]string `redis:"-"`
+	Untagged int
+	hidden   int
+}
+
+func TestClient_Should_Read_And_Write_Fields_When_Using_Hashes(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.NewClient()
+	if added, err := c.HSet("cat", "name", "Niji", "age", "3"); err != nil || added != 2 {
+		t.Errorf("Unexpected value! %d - %v", added, err)
+	}
+	if v, err := c.HGet("cat", "name"); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if removed, err := c.HDel("cat", "age", "owner"); err != nil || removed != 1 {
+		t.Errorf("Unexpected value! %d - %v", removed, err)
+	}
+	if fields, err := c.HGetAll("cat"); err != nil || !maps.Equal(fields, map[string]string{"name": "Niji"}) {
+		t.Errorf("Unexpected value! %v - %v", fields, err)
+	}
+	if _, err := c.LLen("cat"); !isCode(err, redigoerr.ErrorReceived.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
+
+func TestHSetStruct_Should_Map_Fields_By_Tag_When_Round_Tripping_A_Struct(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.NewClient()
+	born := time.Date(2021, time.May, 4, 0, 0, 0, 0, time.UTC)
+	niji := profile{
+		Pet:      Pet{Name: "Niji"},
+		Born:     born,
+		Lives:    7,
+		Indoor:   true,
+		Toys:     []string{"mouse"},
+		Avatar:   []byte{0, '\r', '\n'},
+		Notes:    map[string]string{"food": "fish"},
+		Untagged: 1,
+		hidden:   2,
+	}
+	if err := c.HSetStruct(context.Background(), "cat", &niji); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	fields, err := c.HGetAll("cat")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	expected := map[string]string{
+		"name": "Niji", "born": "2021-05-04T00:00:00Z", "lives": "7", "indoor": "1",
+		"toys": `["mouse"]`, "avatar": "\x00\r\n", "Untagged": "1",
+	}
+	if !maps.Equal(fields, expected) {
+		t.Errorf("Unexpected fields! %v", fields)
+	}
+
+	v, err := client.HGetAllAs[profile](context.Background(), c, "cat")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v.Name != "Niji" || !v.Born.Equal(born) || v.Lives != 7 || !v.Indoor || !slices.Equal(v.Toys, niji.Toys) ||
+		v.Vet != nil || string(v.Avatar) != "\x00\r\n" || v.Notes != nil || v.Untagged != 1 || v.hidden != 0 {
+		t.Errorf("Unexpected value! %+v", v)
+	}
+
+	vet := "Dr. Gato"
+	c.HSetStruct(context.Background(), "cat", profile{Weight: 4.5, Vet: &vet})
+	if v, err = client.HGetAllAs[profile](context.Background(), c, "cat"); err != nil || v.Weight != 4.5 || *v.Vet != vet {
+		t.Errorf("Unexpected value! %+v - %v", v, err)
+	}
+}
+
+func TestHGetAllAs_Should_Return_Error_When_Hash_Is_Missing_Or_Invalid(t *testing.T) {
+	s := redigotest.NewServer(t)
+	c := s.NewClient()
+	if _, err := client.HGetAllAs[profile](context.Background(), c, "missing"); !redigoerr.KeyNotFound(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	c.HSet("cat", "lives", "many")
+	if _, err := client.HGetAllAs[profile](context.Background(), c, "cat"); !isCode(err, redigoerr.UnableToDecodeValue.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err := c.HSetStruct(context.Background(), "cat", "Niji"); !isCode(err, redigoerr.UnsupportedArgument.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// Future holds the result of a command queued in a pipeline, available once the pipeline is executed.
+// Commands answering only whether they succeeded (like SET) give a Future[struct{}].
+type Future[T any] struct {
+	value    T
+	err      error
+	executed bool
+	convert  func(Reply) (T, error)
+}
+
+// Result returns the value received for the command, or the error the server (or connection) gave for it
+func (f *Future[T]) Result() (T, error) {
+	if !f.executed {
+		var zero T
+		return zero, redigoerr.PipelineNotExecuted
+	}
+	return f.value, f.err
+}
+
+// Err returns only the error of the command
+func (f *Future[T]) Err() error {
+	_, err := f.Result()
+	return err
+}
+
+func (f *Future[T]) set(rep Reply) error {
+	f.value, f.err = f.convert(rep)
+	f.executed = true
+	return f.err
+}
+
+func (f *Future[T]) fail(err error) {
+	f.err = err
+	f.executed = true
+}
+
+// pending is a queued command waiting for its reply, whatever its type
+type pending interface {
+	set(rep Reply) error
+	fail(err error)
+}
+
+// unsent stands for a command that could not be queued, so that errors keep the order commands were queued in.
+// Nothing is sent for it, Exec only reports its error.
+type unsent struct {
+	err error
+}
+
+func (u unsent) set(rep Reply) error {
+	return u.err
+}
+
+func (u unsent) fail(err error) {}
+
+// Pipeline queues commands to send them to the server in a single write, reading every reply afterwards.
+// It is not safe to use a pipeline (or its client) from several goroutines at once.
+type Pipeline struct {
+	client   *Client
+	commands []byte
+	pending  []pending
+	// written holds the commands that may change keys, forgotten by the local cache once executed
+	written [][]string
+}
+
+// Pipeline returns an empty pipeline over the connection of the client
+func (client *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: client}
+}
+
+// Len returns the amount of commands queued
+func (p *Pipeline) Len() int {
+	return len(p.pending)
+}
+
+func queue[T any](p *Pipeline, convert func(Reply) (T, error), args ...string) *Future[T] {
+	p.commands = appendCommand(p.commands, args...)
+	if !idempotent(args[0]) {
+		p.written = append(p.written, args)
+	}
+	future := &Future[T]{convert: convert}
+	p.pending = append(p.pending, future)
+	return future
+}
+
+// Do queues any command, see Client.Do for the arguments accepted.
+// Arguments of unsupported types make the future fail with redigoerr.UnsupportedArgument, and Exec reports it
+// in place of the command without sending it.
+func (p *Pipeline) Do(args ...any) *Future[Reply] {
+	future := &Future[Reply]{convert: Reply.asReply}
+	commands, err := appendArgs(p.commands, args...)
+	if err != nil {
+		future.fail(err)
+		p.pending = append(p.pending, unsent{err: err})
+		return future
+	}
+	p.commands = commands
+	p.pending = append(p.pending, future)
+	if written := stringArgs(args...); len(written) > 0 && !idempotent(written[0]) {
+		p.written = append(p.written, written)
+	}
+	return future
+}
+
+func (p *Pipeline) Get(key string) *Future[string] {
+	return queue(p, Reply.String, "GET", key)
+}
+
+func (p *Pipeline) Set(key string, value string) *Future[struct{}] {
+	return queue(p, Reply.asStatus, "SET", key, value)
+}
+
+func (p *Pipeline) Del(key string) *Future[struct{}] {
+	return queue(p, Reply.asStatus, "DEL", key)
+}
+
+func (p *Pipeline) RPush(key string, args ...string) *Future[struct{}] {
+	return queue(p, Reply.asStatus, append([]string{"RPUSH", key}, args...)...)
+}
+
+func (p *Pipeline) LPush(key string, args ...string) *Future[struct{}] {
+	return queue(p, Reply.asStatus, append([]string{"LPUSH", key}, args...)...)
+}
+
+func (p *Pipeline) RPop(key string) *Future[string] {
+	return queue(p, Reply.String, "RPOP", key)
+}
+
+func (p *Pipeline) LPop(key string) *Future[string] {
+	return queue(p, Reply.String, "LPOP", key)
+}
+
+func (p *Pipeline) LIndex(key string, index int) *Future[string] {
+	return queue(p, Reply.String, "LINDEX", key, strconv.Itoa(index))
+}
+
+func (p *Pipeline) LLen(key string) *Future[int] {
+	return queue(p, Reply.asInt, "LLEN", key)
+}
+
+func (p *Pipeline) Ping() *Future[string] {
+	return queue(p, Reply.String, "PING")
+}
+
+// Exec sends every queued command in a single write and reads their replies in order, filling the futures.
+// An error for one command does not stop the rest: errors are returned per command, nil for those that succeeded.
+// The second error is only for the connection failing, in which case every command without a reply holds it.
+// The pipeline is empty afterwards, ready to queue more commands.
+func (p *Pipeline) Exec() ([]error, error) {
+	return p.ExecContext(context.Background())
+}
+
+// ExecContext is Exec, interrupted once ctx is done. The read timeout of the client applies to the whole batch.
+func (p *Pipeline) ExecContext(ctx context.Context) ([]error, error) {
+	commands, queued, written := p.commands, p.pending, p.written
+	p.commands, p.pending, p.written = nil, nil, nil
+	defer func() {
+		for _, args := range written {
+			p.client.wrote(args...)
+		}
+	}()
+	errs := make([]error, len(queued))
+	for i, command := range queued {
+		if u, ok := command.(unsent); ok {
+			errs[i] = u.err
+		}
+	}
+	if len(commands) == 0 {
+		return errs, nil
+	}
+
+	read := 0
+	// Some commands may have been executed when the connection is lost, so a pipeline is never retried
+	err := p.client.exchange(ctx, commands, false, func() error {
+		for ; read < len(queued); read++ {
+			if _, ok := queued[read].(unsent); ok {
+				continue
+			}
+			rep, err := p.client.receive()
+			if err != nil {
+				return err
+			}
+			errs[read] = queued[read].set(rep)
+		}
+		return nil
+	})
+	if err != nil {
+		// Replies are read in order, so none of the remaining ones can be trusted
+		for i := read; i < len(queued); i++ {
+			if _, ok := queued[i].(unsent); ok {
+				continue
+			}
+			queued[i].fail(err)
+			errs[i] = err
+		}
+		return errs, err
+	}
+	return errs, nil
+}
@@ -0,0 +1,90 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+func TestPipeline_Should_Return_Results_In_Order_When_A_Command_Fails(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"name": "Arturo"})
+	p := s.Client().Pipeline()
+	set := p.Set("cat", "Niji")
+	get := p.Get("cat")
+	// LLEN over a string fails, without stopping what comes after
+	llen := p.LLen("name")
+	push := p.RPush("cats", "Anubis", "Don Bigos")
+	length := p.LLen("cats")
+	missing := p.Get("missing")
+	if _, err := get.Result(); !isCode(err, redigoerr.PipelineNotExecuted.Code) {
+		t.Errorf("Result available before executing! %v", err)
+	}
+
+	errs, err := p.Exec()
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if len(errs) != 6 || errs[0] != nil || errs[1] != nil || errs[2] == nil || errs[3] != nil || errs[4] != nil || errs[5] != nil {
+		t.Errorf("Unexpected errors! %v", errs)
+	}
+	if err := set.Err(); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, err := get.Result(); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if !isCode(llen.Err(), redigoerr.ErrorReceived.Code) {
+		t.Errorf("Unexpected error! %v", llen.Err())
+	}
+	if err := push.Err(); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, err := length.Result(); err != nil || v != 2 {
+		t.Errorf("Unexpected value! %d - %v", v, err)
+	}
+	if v, err := missing.Result(); err != nil || v != "" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if p.Len() != 0 {
+		t.Errorf("Pipeline was not emptied! %d", p.Len())
+	}
+}
+
+func TestPipeline_Should_Send_Every_Command_When_Batch_Is_Bigger_Than_A_Message(t *testing.T) {
+	s := redigotest.NewServer(t)
+	p := s.Client().Pipeline()
+	for i := range 2000 {
+		p.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i))
+	}
+	last := p.Get("key-1999")
+	errs, err := p.Exec()
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("An error occurred for command %d! %v", i, err)
+		}
+	}
+	if v, _ := last.Result(); v != "value-1999" {
+		t.Errorf("Unexpected value! %s", v)
+	}
+	if s.Keys() != 2000 {
+		t.Errorf("Unexpected amount of keys! %d", s.Keys())
+	}
+	// The client keeps working normally afterwards
+	if v, err := s.Client().Get("key-0"); err != nil || v != "value-0" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+}
+
+func isCode(err error, code uint16) bool {
+	errCode, ok := redigoerr.ErrorCode(err)
+	return ok && errCode == code
+}
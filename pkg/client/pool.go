@@ -0,0 +1,404 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// poolDialTimeout is the time given to connections dialed in the background to keep MinIdle
+const poolDialTimeout = 5 * time.Second
+
+// PoolOptions configures a Pool. Zero values mean no limit (or no check) unless stated otherwise.
+type PoolOptions struct {
+	// Dial opens a new connection. When nil, Address is dialed over tcp.
+	Dial    func(ctx context.Context) (net.Conn, error)
+	Address string
+	// MinIdle connections are kept open in the background, ready to be handed out.
+	MinIdle int
+	// MaxActive is the amount of connections in use from which Get waits for one to be returned.
+	// Idle connections are reused before dialing, so no more than MaxActive connections are ever open.
+	MaxActive int
+	// IdleTimeout closes connections left idle for longer.
+	IdleTimeout time.Duration
+	// MaxConnAge closes connections older than this once they are returned (or found idle).
+	MaxConnAge time.Duration
+	// HealthCheckAfter sends a PING to connections idle for longer before handing them out,
+	// replacing those that do not answer. Zero checks every connection handed out, a negative value never does.
+	HealthCheckAfter time.Duration
+	// Client sets the timeouts of every connection, its DialTimeout being used when Dial is nil.
+	Client Options
+}
+
+// PoolStats describes how a pool has been used
+type PoolStats struct {
+	// Hits are connections handed out from the idle ones, Misses the ones dialed for it
+	Hits   uint64
+	Misses uint64
+	// Timeouts are calls to Get whose context was done while waiting for a connection
+	Timeouts uint64
+	// WaitCount and WaitDuration tell how often (and how long) Get had to wait because of MaxActive
+	WaitCount    uint64
+	WaitDuration time.Duration
+	// StaleConns are connections closed for being idle or old for too long, or failing a health check
+	StaleConns uint64
+	TotalConns int
+	IdleConns  int
+}
+
+// Pool keeps connections to a server to be reused by many goroutines, each one using a connection at a time.
+type Pool struct {
+	options PoolOptions
+	// slots holds a value per connection in use when MaxActive is set
+	slots chan struct{}
+
+	lock   sync.Mutex
+	idle   []*PooledClient
+	total  int
+	closed bool
+	done   chan struct{}
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	timeouts     atomic.Uint64
+	waitCount    atomic.Uint64
+	waitDuration atomic.Int64
+	staleConns   atomic.Uint64
+}
+
+// PooledClient is a client borrowed from a pool. Close returns it to the pool instead of closing the connection.
+type PooledClient struct {
+	*Client
+	pool      *Pool
+	createdAt time.Time
+	usedAt    time.Time
+	broken    bool
+	returned  bool
+}
+
+// NewPool creates a pool, opening MinIdle connections in the background
+func NewPool(options PoolOptions) (*Pool, error) {
+	if options.Dial == nil {
+		if options.Address == "" {
+			redigoError := redigoerr.InvalidPoolOptions
+			redigoError.ExtraContext = map[string]string{"reason": "either Dial or Address is needed"}
+			return nil, redigoError
+		}
+		address := options.Address
+		dialTimeout := options.Client.withDefaults().DialTimeout
+		options.Dial = func(ctx context.Context) (net.Conn, error) {
+			var dialer net.Dialer
+			if dialTimeout > 0 {
+				dialer.Timeout = dialTimeout
+			}
+			return dialer.DialContext(ctx, "tcp", address)
+		}
+	}
+	if options.MaxActive > 0 && options.MinIdle > options.MaxActive {
+		redigoError := redigoerr.InvalidPoolOptions
+		redigoError.ExtraContext = map[string]string{"reason": "MinIdle is above MaxActive"}
+		return nil, redigoError
+	}
+	p := &Pool{options: options, done: make(chan struct{})}
+	if options.MaxActive > 0 {
+		p.slots = make(chan struct{}, options.MaxActive)
+	}
+	go p.maintain()
+	return p, nil
+}
+
+// Get returns an idle connection or dials a new one, waiting while MaxActive connections are in use
+// until one is returned or ctx is done.
+func (p *Pool) Get(ctx context.Context) (*PooledClient, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		pc, err := p.popIdle()
+		if err != nil {
+			p.release()
+			return nil, err
+		}
+		if pc == nil {
+			break
+		}
+		if !p.expired(pc, time.Now()) && p.healthy(pc) {
+			p.hits.Add(1)
+			pc.returned = false
+			return pc, nil
+		}
+		p.staleConns.Add(1)
+		p.closeConn(pc)
+	}
+	p.misses.Add(1)
+	pc, err := p.dial(ctx)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return pc, nil
+}
+
+// Do runs f with a connection of the pool, which is discarded if f fails because of it
+func (p *Pool) Do(ctx context.Context, f func(c *Client) error) error {
+	pc, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	err = f(pc.Client)
+	if redigoerr.ConnectionRelated(err) {
+		pc.MarkBroken()
+	}
+	pc.Close()
+	return err
+}
+
+// Stats returns the current statistics of the pool
+func (p *Pool) Stats() PoolStats {
+	p.lock.Lock()
+	total, idle := p.total, len(p.idle)
+	p.lock.Unlock()
+	return PoolStats{
+		Hits:         p.hits.Load(),
+		Misses:       p.misses.Load(),
+		Timeouts:     p.timeouts.Load(),
+		WaitCount:    p.waitCount.Load(),
+		WaitDuration: time.Duration(p.waitDuration.Load()),
+		StaleConns:   p.staleConns.Load(),
+		TotalConns:   total,
+		IdleConns:    idle,
+	}
+}
+
+// Close closes every idle connection. Connections in use are closed once returned.
+func (p *Pool) Close() error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.lock.Unlock()
+	close(p.done)
+	for _, pc := range idle {
+		p.closeConn(pc)
+	}
+	return nil
+}
+
+// MarkBroken makes Close discard the connection instead of returning it to the pool,
+// which happens on its own when a call leaves the client broken.
+func (pc *PooledClient) MarkBroken() {
+	pc.broken = true
+}
+
+// Close returns the connection to the pool, unless it is broken, too old or the pool was closed.
+// Calling it again does nothing.
+func (pc *PooledClient) Close() error {
+	if pc.returned {
+		return nil
+	}
+	pc.returned = true
+	p := pc.pool
+	defer p.release()
+	p.lock.Lock()
+	pc.usedAt = time.Now()
+	if pc.broken || pc.Broken() || p.closed || p.expired(pc, pc.usedAt) {
+		p.lock.Unlock()
+		p.closeConn(pc)
+		return nil
+	}
+	p.idle = append(p.idle, pc)
+	p.lock.Unlock()
+	return nil
+}
+
+// popIdle takes the most recently used idle connection
+func (p *Pool) popIdle() (*PooledClient, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil, redigoerr.PoolClosed
+	}
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+	pc := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return pc, nil
+}
+
+// expired tells if a connection has to be closed instead of reused
+func (p *Pool) expired(pc *PooledClient, now time.Time) bool {
+	return (p.options.IdleTimeout > 0 && now.Sub(pc.usedAt) > p.options.IdleTimeout) ||
+		(p.options.MaxConnAge > 0 && now.Sub(pc.createdAt) > p.options.MaxConnAge)
+}
+
+// healthy pings a connection idle for longer than HealthCheckAfter
+func (p *Pool) healthy(pc *PooledClient) bool {
+	if p.options.HealthCheckAfter < 0 || time.Since(pc.usedAt) < p.options.HealthCheckAfter {
+		return true
+	}
+	_, err := pc.Ping()
+	return err == nil
+}
+
+// acquire takes a slot to use a connection, waiting for one while MaxActive connections are in use
+func (p *Pool) acquire(ctx context.Context) error {
+	if p.tryAcquire() {
+		return nil
+	}
+	p.waitCount.Add(1)
+	start := time.Now()
+	defer func() { p.waitDuration.Add(int64(time.Since(start))) }()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-p.done:
+		return redigoerr.PoolClosed
+	case <-ctx.Done():
+		p.timeouts.Add(1)
+		redigoError := redigoerr.PoolTimeout
+		redigoError.From = ctx.Err()
+		return redigoError
+	}
+}
+
+// tryAcquire takes a slot without waiting, telling whether it got one
+func (p *Pool) tryAcquire() bool {
+	if p.slots == nil {
+		return true
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Pool) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// dial opens a connection, counting it
+func (p *Pool) dial(ctx context.Context) (*PooledClient, error) {
+	pc, err := p.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	p.total++
+	p.lock.Unlock()
+	return pc, nil
+}
+
+// open opens a connection without counting it
+func (p *Pool) open(ctx context.Context) (*PooledClient, error) {
+	conn, err := p.options.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &PooledClient{Client: NewWithOptions(&conn, p.options.Client), pool: p, createdAt: now, usedAt: now}, nil
+}
+
+// closeConn closes the connection of a client no longer in the pool
+func (p *Pool) closeConn(pc *PooledClient) {
+	pc.Client.Close()
+	p.lock.Lock()
+	p.total--
+	p.lock.Unlock()
+}
+
+// maintain closes expired idle connections and keeps MinIdle connections open until the pool is closed
+func (p *Pool) maintain() {
+	interval := time.Second
+	for _, limit := range []time.Duration{p.options.IdleTimeout, p.options.MaxConnAge} {
+		if limit > 0 {
+			interval = min(interval, max(limit/2, 10*time.Millisecond))
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.reap()
+		p.fill()
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) reap() {
+	now := time.Now()
+	p.lock.Lock()
+	kept := p.idle[:0]
+	expired := []*PooledClient{}
+	for _, pc := range p.idle {
+		if p.expired(pc, now) {
+			expired = append(expired, pc)
+		} else {
+			kept = append(kept, pc)
+		}
+	}
+	p.idle = kept
+	p.lock.Unlock()
+	for _, pc := range expired {
+		p.staleConns.Add(1)
+		p.closeConn(pc)
+	}
+}
+
+// fill dials connections until MinIdle are idle, never going over MaxActive connections open.
+// Like Get, every dial holds a slot, and the connection is counted before dialing, so nobody dials past MaxActive meanwhile.
+func (p *Pool) fill() {
+	for {
+		if !p.tryAcquire() {
+			return
+		}
+		p.lock.Lock()
+		missing := !p.closed && len(p.idle) < p.options.MinIdle &&
+			(p.options.MaxActive <= 0 || p.total < p.options.MaxActive)
+		if missing {
+			p.total++
+		}
+		p.lock.Unlock()
+		if !missing {
+			p.release()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), poolDialTimeout)
+		pc, err := p.open(ctx)
+		cancel()
+		if err != nil {
+			p.lock.Lock()
+			p.total--
+			p.lock.Unlock()
+			p.release()
+			return
+		}
+		pc.returned = true
+		p.lock.Lock()
+		// The pool may have been closed while dialing
+		if p.closed {
+			p.lock.Unlock()
+			p.release()
+			p.closeConn(pc)
+			return
+		}
+		p.idle = append(p.idle, pc)
+		p.lock.Unlock()
+		p.release()
+	}
+}
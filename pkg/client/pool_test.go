@@ -0,0 +1,227 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+// trackedDial dials a server keeping every connection, so tests can break them
+func trackedDial(address string) (func(ctx context.Context) (net.Conn, error), func() []net.Conn) {
+	var (
+		lock  sync.Mutex
+		conns []net.Conn
+	)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			lock.Lock()
+			conns = append(conns, conn)
+			lock.Unlock()
+		}
+		return conn, err
+	}
+	return dial, func() []net.Conn {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]net.Conn{}, conns...)
+	}
+}
+
+func TestPool_Should_Reuse_Connections_When_Returned(t *testing.T) {
+	s := redigotest.NewServer(t)
+	pool, err := client.NewPool(client.PoolOptions{Address: s.Addr(), HealthCheckAfter: -1})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer pool.Close()
+	for range 3 {
+		err := pool.Do(context.Background(), func(c *client.Client) error {
+			return c.Set("Arturo", "26")
+		})
+		if err != nil {
+			t.Errorf("An error occurred! %v", err)
+		}
+	}
+	stats := pool.Stats()
+	if stats.Misses != 1 || stats.Hits != 2 || stats.TotalConns != 1 || stats.IdleConns != 1 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
+
+func TestPool_Should_Wait_For_A_Connection_When_MaxActive_Are_In_Use(t *testing.T) {
+	s := redigotest.NewServer(t)
+	pool, err := client.NewPool(client.PoolOptions{Address: s.Addr(), MaxActive: 1})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer pool.Close()
+	first, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !isCode(err, redigoerr.PoolTimeout.Code) {
+		t.Errorf("Connection handed out over MaxActive! %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		first.Close()
+	}()
+	second, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer second.Close()
+	if stats := pool.Stats(); stats.Timeouts != 1 || stats.WaitCount != 2 || stats.TotalConns != 1 || stats.WaitDuration < 50*time.Millisecond {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
+
+func TestPool_Should_Replace_Connections_When_Broken_Or_Idle_For_Too_Long(t *testing.T) {
+	s := redigotest.NewServer(t)
+	dial, conns := trackedDial(s.Addr())
+	pool, err := client.NewPool(client.PoolOptions{Dial: dial, MinIdle: 2, IdleTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer pool.Close()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().IdleConns < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The health check notices the connection was closed and dials another one
+	for _, conn := range conns() {
+		conn.Close()
+	}
+	if err := pool.Do(context.Background(), func(c *client.Client) error {
+		_, err := c.Ping()
+		return err
+	}); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+
+	// Idle connections are closed, while MinIdle keeps new ones ready
+	time.Sleep(300 * time.Millisecond)
+	stats := pool.Stats()
+	if stats.StaleConns < 3 || stats.IdleConns != 2 || stats.TotalConns != 2 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
+
+func TestPool_Should_Return_Error_When_Closed(t *testing.T) {
+	s := redigotest.NewServer(t)
+	pool, err := client.NewPool(client.PoolOptions{Address: s.Addr()})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	pool.Close()
+	if _, err := pool.Get(context.Background()); !isCode(err, redigoerr.PoolClosed.Code) {
+		t.Errorf("Connection handed out after closing! %v", err)
+	}
+	if _, err := client.NewPool(client.PoolOptions{}); !isCode(err, redigoerr.InvalidPoolOptions.Code) {
+		t.Errorf("Pool created without a way to dial! %v", err)
+	}
+}
+
+// closeTrackingConn tells when a connection is closed
+type closeTrackingConn struct {
+	net.Conn
+	closed chan struct{}
+}
+
+func (c *closeTrackingConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return c.Conn.Close()
+}
+
+// gatedDial dials a server once gate is closed, sending every connection opened through conns
+func gatedDial(address string, gate chan struct{}, conns chan *closeTrackingConn) func(ctx context.Context) (net.Conn, error) {
+	return func(ctx context.Context) (net.Conn, error) {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		tracked := &closeTrackingConn{Conn: conn, closed: make(chan struct{})}
+		conns <- tracked
+		return tracked, nil
+	}
+}
+
+func TestPool_Should_Not_Go_Over_MaxActive_When_Filling_MinIdle(t *testing.T) {
+	s := redigotest.NewServer(t)
+	gate, conns := make(chan struct{}), make(chan *closeTrackingConn, 4)
+	pool, err := client.NewPool(client.PoolOptions{Dial: gatedDial(s.Addr(), gate, conns), MinIdle: 1, MaxActive: 1, HealthCheckAfter: -1})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer pool.Close()
+	// Give the pool time to start dialing the idle connection
+	time.Sleep(20 * time.Millisecond)
+
+	// The connection being dialed in the background already counts towards MaxActive
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); !isCode(err, redigoerr.PoolTimeout.Code) {
+		t.Errorf("Connection handed out over MaxActive! %v", err)
+	}
+	close(gate)
+	pc, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	pc.Close()
+	if stats := pool.Stats(); stats.TotalConns != 1 || len(conns) != 1 {
+		t.Errorf("Unexpected stats! %+v - %d dialed", stats, len(conns))
+	}
+}
+
+func TestPool_Should_Close_Connection_When_Closed_While_Filling_MinIdle(t *testing.T) {
+	s := redigotest.NewServer(t)
+	gate, conns := make(chan struct{}), make(chan *closeTrackingConn, 4)
+	pool, err := client.NewPool(client.PoolOptions{Dial: gatedDial(s.Addr(), gate, conns), MinIdle: 1})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	pool.Close()
+	close(gate)
+
+	select {
+	case conn := <-conns:
+		select {
+		case <-conn.closed:
+		case <-time.After(time.Second):
+			t.Errorf("Connection dialed while closing the pool was left open!")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("No connection was dialed!")
+	}
+	if stats := pool.Stats(); stats.TotalConns != 0 || stats.IdleConns != 0 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
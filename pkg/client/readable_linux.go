@@ -0,0 +1,45 @@
+//go:build linux
+
+package client
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// readable tells without waiting if something arrived on the connection, failing with io.EOF once the server closed it.
+// It peeks at the socket, so nothing is taken from it.
+func readable(conn net.Conn, buffer *bufio.Reader) (bool, error) {
+	syscallConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return readableWithin(conn, buffer)
+	}
+	rawConn, err := syscallConn.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	// A deadline already past fails the read without calling the function
+	conn.SetReadDeadline(time.Time{})
+	var n int
+	var peekErr error
+	b := make([]byte, 1)
+	err = rawConn.Read(func(fd uintptr) bool {
+		n, _, peekErr = syscall.Recvfrom(int(fd), b, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		return true
+	})
+	switch {
+	case err != nil:
+		return false, err
+	case peekErr == syscall.EAGAIN || peekErr == syscall.EINTR:
+		return false, nil
+	case peekErr != nil:
+		return false, peekErr
+	case n == 0:
+		return false, io.EOF
+	default:
+		return true, nil
+	}
+}
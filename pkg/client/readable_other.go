@@ -0,0 +1,13 @@
+//go:build !linux
+
+package client
+
+import (
+	"bufio"
+	"net"
+)
+
+// readable tells if something arrived on the connection, waiting the least possible.
+func readable(conn net.Conn, buffer *bufio.Reader) (bool, error) {
+	return readableWithin(conn, buffer)
+}
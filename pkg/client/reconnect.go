@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// staleCheckAfter is the time a connection has to be idle before checking whether the server closed it
+const staleCheckAfter = time.Second
+
+// staleCheckWait is how long that check waits for a connection still open
+const staleCheckWait = time.Millisecond
+
+// idempotentCommands only read, so sending them again after a connection error can not change anything
+var idempotentCommands = map[string]bool{
+	"GET": true, "LINDEX": true, "LLEN": true, "PING": true,
+	"GETBIT": true, "BITCOUNT": true, "BITPOS": true, "PFCOUNT": true,
+	"GEOPOS": true, "GEODIST": true, "GEOHASH": true, "GEOSEARCH": true,
+	"JSON.GET": true, "JSON.TYPE": true, "JSON.OBJKEYS": true,
+	"HGET": true, "HGETALL": true, "HLEN": true,
+	"DUMP": true,
+}
+
+func idempotent(cmd string) bool {
+	return idempotentCommands[strings.ToUpper(cmd)]
+}
+
+// exchange runs a round trip, reconnecting first when the connection was lost.
+// When the round trip itself loses the connection, it is retried only if the command is idempotent.
+func (client *Client) exchange(ctx context.Context, command []byte, idempotent bool, read func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := client.ensureConnected(ctx); err != nil {
+			// Nothing was sent, so any command can be tried again
+			if client.closed || ctx.Err() != nil || attempt >= client.options.MaxRetries {
+				return err
+			}
+			if err := client.backoff(ctx, attempt); err != nil {
+				return err
+			}
+			continue
+		}
+		err := client.roundTrip(ctx, command, read)
+		if err == nil || client.options.Dial == nil || !redigoerr.ConnectionRelated(err) {
+			return err
+		}
+		client.disconnect(err)
+		if !idempotent || attempt >= client.options.MaxRetries {
+			return err
+		}
+		if err := client.backoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// ensureConnected replaces a lost connection, checking first if the server closed it while idle
+func (client *Client) ensureConnected(ctx context.Context) error {
+	if client.closed {
+		return redigoerr.ClientClosed
+	}
+	if client.options.Dial == nil {
+		return nil
+	}
+	if !client.broken && time.Since(client.usedAt) >= staleCheckAfter {
+		if err := client.stale(); err != nil {
+			client.disconnect(err)
+		}
+	}
+	if client.broken {
+		client.disconnect(redigoerr.ConnectionBroken)
+		return client.connect(ctx)
+	}
+	return nil
+}
+
+// stale tells if the server closed the connection while idle, handling the push messages it sent meanwhile
+func (client *Client) stale() error {
+	return client.drainPushes()
+}
+
+// readableWithin reads from the connection waiting the least possible, which only fails with a timeout while it is
+// still open. A deadline already past would fail without even trying to read.
+func readableWithin(conn net.Conn, buffer *bufio.Reader) (bool, error) {
+	conn.SetReadDeadline(time.Now().Add(staleCheckWait))
+	_, err := buffer.Peek(1)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return false, nil
+	}
+	return false, err
+}
+
+// connect dials a new connection, replacing the current one
+func (client *Client) connect(ctx context.Context) error {
+	conn, err := client.options.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	client.conn = &conn
+	if client.buffer == nil {
+		client.buffer = bufio.NewReader(conn)
+	} else {
+		client.buffer.Reset(conn)
+	}
+	client.broken, client.disconnected = false, false
+	client.usedAt = time.Now()
+	if client.options.OnConnect != nil {
+		client.options.OnConnect(conn)
+	}
+	if client.cache != nil {
+		// Tracking belongs to the connection, so it is turned on again for the new one
+		if err := client.sendTracking(ctx, client.cache.options); err != nil {
+			client.disconnect(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// disconnect closes a lost connection, once
+func (client *Client) disconnect(err error) {
+	client.broken = true
+	if client.disconnected {
+		return
+	}
+	client.disconnected = true
+	// Invalidations sent meanwhile are lost along with the connection
+	client.cache.clear()
+	conn := *client.conn
+	conn.Close()
+	if client.options.OnDisconnect != nil {
+		client.options.OnDisconnect(conn, err)
+	}
+}
+
+// backoff waits before the next attempt, doubling from MinRetryBackoff up to MaxRetryBackoff.
+// Only the second half of the wait is random, so that clients losing their connections at once do not retry together.
+func (client *Client) backoff(ctx context.Context, attempt int) error {
+	wait := client.options.MaxRetryBackoff
+	if attempt < 30 {
+		wait = min(client.options.MinRetryBackoff<<attempt, client.options.MaxRetryBackoff)
+	}
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	wait = wait/2 + rand.N(wait/2+1)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
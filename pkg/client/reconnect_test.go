@@ -0,0 +1,224 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+	"github.com/Arthur-phys/redigo/pkg/server"
+)
+
+// flakyProxy forwards connections to address, except for the first one which is closed as soon as a command arrives
+func flakyProxy(t *testing.T, address string) (string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	accepted := &atomic.Int32{}
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		listener.Close()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if accepted.Add(1) == 1 {
+				conn.Read(make([]byte, 1024))
+				conn.Close()
+				continue
+			}
+			upstream, err := net.Dial("tcp", address)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			go func() {
+				defer wg.Done()
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String(), accepted
+}
+
+func TestClient_Should_Reconnect_When_Server_Closes_An_Idle_Connection(t *testing.T) {
+	s := redigotest.NewServerWithConfig(t, &server.Configuration{
+		MinWorkers:        1,
+		MaxWorkers:        64,
+		KeepAlive:         1,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+	})
+	var connects, disconnects atomic.Int32
+	c, err := client.Dial(context.Background(), s.Addr(), client.Options{
+		OnConnect:    func(conn net.Conn) { connects.Add(1) },
+		OnDisconnect: func(conn net.Conn, err error) { disconnects.Add(1) },
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+	if err = c.Set("cat", "Niji"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+
+	// The server closes the connection after a second without commands
+	time.Sleep(1500 * time.Millisecond)
+	if err = c.Set("cat", "Anubis"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, _ := s.Get("cat"); v != "Anubis" {
+		t.Errorf("Unexpected value! %s", v)
+	}
+	if connects.Load() != 2 || disconnects.Load() != 1 {
+		t.Errorf("Unexpected amount of events! %d - %d", connects.Load(), disconnects.Load())
+	}
+}
+
+func TestClient_Should_Retry_Read_When_Connection_Is_Lost_During_The_Call(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"cat": "Niji"})
+	address, accepted := flakyProxy(t, s.Addr())
+	var lost error
+	c, err := client.Dial(context.Background(), address, client.Options{
+		OnDisconnect: func(conn net.Conn, err error) { lost = err },
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	if v, err := c.Get("cat"); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if accepted.Load() != 2 {
+		t.Errorf("Unexpected amount of connections! %d", accepted.Load())
+	}
+	if !redigoerr.ConnectionRelated(lost) {
+		t.Errorf("Unexpected error! %v", lost)
+	}
+}
+
+func TestClient_Should_Not_Retry_Write_When_Connection_Is_Lost_During_The_Call(t *testing.T) {
+	s := redigotest.NewServer(t)
+	address, accepted := flakyProxy(t, s.Addr())
+	c, err := client.Dial(context.Background(), address, client.Options{})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	// The server may have executed it, so it is up to the caller to send it again
+	if err = c.Set("cat", "Niji"); !redigoerr.ConnectionRelated(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if accepted.Load() != 1 {
+		t.Errorf("Unexpected amount of connections! %d", accepted.Load())
+	}
+	if err = c.Set("cat", "Niji"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, _ := s.Get("cat"); v != "Niji" || accepted.Load() != 2 {
+		t.Errorf("Unexpected value! %s - %d", v, accepted.Load())
+	}
+}
+
+func TestClient_Should_Back_Off_Between_Attempts_When_Dial_Keeps_Failing(t *testing.T) {
+	s := redigotest.NewServer(t)
+	address, _ := flakyProxy(t, s.Addr())
+	refused := errors.New("refused")
+	var dials atomic.Int32
+	c, err := client.Dial(context.Background(), address, client.Options{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			if dials.Add(1) > 1 {
+				return nil, refused
+			}
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "tcp", address)
+		},
+		MaxRetries:      2,
+		MinRetryBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	if _, err = c.Get("cat"); err != refused {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	// Half of each wait is random: at least 10ms and then 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Attempts did not back off! %v", elapsed)
+	}
+	if dials.Load() != 3 {
+		t.Errorf("Unexpected amount of dials! %d", dials.Load())
+	}
+
+	// Canceling the call stops waiting for the next attempt
+	address, _ = flakyProxy(t, s.Addr())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c, err = client.Dial(context.Background(), address, client.Options{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "tcp", address)
+		},
+		MinRetryBackoff: time.Hour,
+		MaxRetryBackoff: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+	if _, err = c.GetContext(ctx, "cat"); err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
+
+func TestClient_Should_Fail_Every_Call_When_Closed(t *testing.T) {
+	s := redigotest.NewServer(t)
+	var disconnected []error
+	c, err := client.Dial(context.Background(), s.Addr(), client.Options{
+		OnDisconnect: func(conn net.Conn, err error) { disconnected = append(disconnected, err) },
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	c.Close()
+	if _, err = c.Get("cat"); !isCode(err, redigoerr.ClientClosed.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if len(disconnected) != 1 || disconnected[0] != nil {
+		t.Errorf("Unexpected events! %v", disconnected)
+	}
+}
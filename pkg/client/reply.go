@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// Reply is a single RESP value received from the server, read with its typed accessors.
+// Arrays (and maps, as key value pairs) hold their elements.
+type Reply struct {
+	kind byte
+	str  string
+	// blob holds blob strings as they were received, so values read as bytes are never converted
+	blob     []byte
+	integer  int64
+	elements []Reply
+}
+
+// readReply reads a whole reply from the connection, no matter how many reads it takes.
+// Errors of the connection are returned as they are, so redigoerr.ConnectionRelated recognizes them.
+func readReply(r *bufio.Reader) (Reply, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return Reply{}, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return Reply{}, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return Reply{}, unexpectedReply(kind, line)
+	}
+	line = line[:len(line)-2]
+
+	switch kind {
+	case '+', '-', ',':
+		return Reply{kind: kind, str: line}, nil
+	case '_':
+		return Reply{kind: kind}, nil
+	case '#':
+		return Reply{kind: kind, integer: boolToInt(line == "t")}, nil
+	case ':':
+		integer, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return Reply{}, unexpectedReply(kind, line)
+		}
+		return Reply{kind: kind, integer: integer}, nil
+	case '$', '=':
+		size, err := strconv.Atoi(line)
+		if err != nil || size < -1 {
+			return Reply{}, unexpectedReply(kind, line)
+		}
+		// RESP2 null bulk string
+		if size == -1 {
+			return Reply{kind: '_'}, nil
+		}
+		blob := make([]byte, size+2)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return Reply{}, err
+		}
+		return Reply{kind: kind, blob: blob[:size]}, nil
+	case '*', '%', '~', '>':
+		size, err := strconv.Atoi(line)
+		if err != nil || size < -1 {
+			return Reply{}, unexpectedReply(kind, line)
+		}
+		if size == -1 {
+			return Reply{kind: '_'}, nil
+		}
+		// Maps hold two values per entry
+		if kind == '%' {
+			size *= 2
+		}
+		elements := make([]Reply, size)
+		for i := range elements {
+			if elements[i], err = readReply(r); err != nil {
+				return Reply{}, err
+			}
+		}
+		return Reply{kind: kind, elements: elements}, nil
+	default:
+		return Reply{}, unexpectedReply(kind, line)
+	}
+}
+
+// Err returns the error sent by the server, if this reply is one
+func (rep Reply) Err() error {
+	if rep.kind != '-' {
+		return nil
+	}
+	redigoError := redigoerr.ErrorReceived
+	redigoError.ExtraContext = map[string]string{"text": rep.str}
+	return redigoError
+}
+
+// IsNil tells if the server answered with a null, which REDIGO uses for missing keys and for commands that only succeed
+func (rep Reply) IsNil() bool {
+	return rep.kind == '_'
+}
+
+// String returns a blob or simple string, where null is the empty string (use IsNil to tell them apart).
+// Integers are converted to their decimal representation.
+func (rep Reply) String() (string, error) {
+	if err := rep.Err(); err != nil {
+		return "", err
+	}
+	switch rep.kind {
+	case '$', '=':
+		return string(rep.blob), nil
+	case '+', ',':
+		return rep.str, nil
+	case ':':
+		return strconv.FormatInt(rep.integer, 10), nil
+	case '_':
+		return "", nil
+	default:
+		return "", unexpectedReply(rep.kind, "")
+	}
+}
+
+// Bytes returns a blob or simple string as bytes, where null is nil. Blob strings are returned as received,
+// without copying them.
+func (rep Reply) Bytes() ([]byte, error) {
+	if err := rep.Err(); err != nil {
+		return nil, err
+	}
+	switch rep.kind {
+	case '$', '=':
+		return rep.blob, nil
+	case '+', ',':
+		return []byte(rep.str), nil
+	case ':':
+		return strconv.AppendInt(nil, rep.integer, 10), nil
+	case '_':
+		return nil, nil
+	default:
+		return nil, unexpectedReply(rep.kind, "")
+	}
+}
+
+// Int returns an integer, also parsing strings holding one
+func (rep Reply) Int() (int64, error) {
+	if err := rep.Err(); err != nil {
+		return 0, err
+	}
+	switch rep.kind {
+	case ':', '#':
+		return rep.integer, nil
+	case '$', '+':
+		str, _ := rep.String()
+		integer, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, unexpectedReply(rep.kind, str)
+		}
+		return integer, nil
+	default:
+		return 0, unexpectedReply(rep.kind, "")
+	}
+}
+
+// Array returns the elements of an array (or set, or push message). Maps are returned as key value pairs.
+func (rep Reply) Array() ([]Reply, error) {
+	if err := rep.Err(); err != nil {
+		return nil, err
+	}
+	switch rep.kind {
+	case '*', '%', '~', '>':
+		return rep.elements, nil
+	default:
+		return nil, unexpectedReply(rep.kind, "")
+	}
+}
+
+// Map returns a map, or an array of key value pairs as one
+func (rep Reply) Map() (map[string]Reply, error) {
+	elements, err := rep.Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(elements)%2 != 0 {
+		return nil, unexpectedReply(rep.kind, strconv.Itoa(len(elements)))
+	}
+	m := make(map[string]Reply, len(elements)/2)
+	for i := 0; i < len(elements); i += 2 {
+		key, err := elements[i].String()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = elements[i+1]
+	}
+	return m, nil
+}
+
+// Type returns the name of the RESP type of the reply
+func (rep Reply) Type() string {
+	switch rep.kind {
+	case '$', '=':
+		return "string"
+	case '+':
+		return "status"
+	case '-':
+		return "error"
+	case ':':
+		return "integer"
+	case ',':
+		return "double"
+	case '#':
+		return "boolean"
+	case '_':
+		return "nil"
+	case '*':
+		return "array"
+	case '%':
+		return "map"
+	case '~':
+		return "set"
+	case '>':
+		return "push"
+	default:
+		return "unknown"
+	}
+}
+
+func (rep Reply) asInt() (int, error) {
+	if err := rep.Err(); err != nil {
+		return 0, err
+	}
+	if rep.kind != ':' {
+		return 0, unexpectedReply(rep.kind, "")
+	}
+	return int(rep.integer), nil
+}
+
+// asStatus checks a command succeeded, which REDIGO answers with a null
+func (rep Reply) asStatus() (struct{}, error) {
+	return struct{}{}, rep.Err()
+}
+
+// asReply keeps the reply as it is, checking only for errors
+func (rep Reply) asReply() (Reply, error) {
+	return rep, rep.Err()
+}
+
+func unexpectedReply(kind byte, line string) error {
+	redigoError := redigoerr.UnexpectedFirstByte
+	redigoError.ExtraContext = map[string]string{"received": string(kind), "line": line}
+	return redigoError
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// appendArgs encodes a command as a RESP array of blob strings, formatting every argument without fmt.
+// Strings, []byte, integers, floats and booleans (as 1 or 0) are accepted.
+func appendArgs(b []byte, args ...any) ([]byte, error) {
+	b = appendHeader(b, len(args))
+	scratch := make([]byte, 0, 24)
+	for i, arg := range args {
+		var value []byte
+		switch v := arg.(type) {
+		case string:
+			b = appendBlob(b, v)
+			continue
+		case []byte:
+			value = v
+		case int:
+			value = strconv.AppendInt(scratch[:0], int64(v), 10)
+		case int8:
+			value = strconv.AppendInt(scratch[:0], int64(v), 10)
+		case int16:
+			value = strconv.AppendInt(scratch[:0], int64(v), 10)
+		case int32:
+			value = strconv.AppendInt(scratch[:0], int64(v), 10)
+		case int64:
+			value = strconv.AppendInt(scratch[:0], v, 10)
+		case uint:
+			value = strconv.AppendUint(scratch[:0], uint64(v), 10)
+		case uint8:
+			value = strconv.AppendUint(scratch[:0], uint64(v), 10)
+		case uint16:
+			value = strconv.AppendUint(scratch[:0], uint64(v), 10)
+		case uint32:
+			value = strconv.AppendUint(scratch[:0], uint64(v), 10)
+		case uint64:
+			value = strconv.AppendUint(scratch[:0], v, 10)
+		case float32:
+			value = strconv.AppendFloat(scratch[:0], float64(v), 'f', -1, 32)
+		case float64:
+			value = strconv.AppendFloat(scratch[:0], v, 'f', -1, 64)
+		case bool:
+			value = append(scratch[:0], '0')
+			if v {
+				value[0] = '1'
+			}
+		default:
+			redigoError := redigoerr.UnsupportedArgument
+			redigoError.ExtraContext = map[string]string{"position": strconv.Itoa(i)}
+			return nil, redigoError
+		}
+		b = appendBlob(b, value)
+	}
+	return b, nil
+}
+
+// appendCommand encodes a command as a RESP array of blob strings
+func appendCommand(b []byte, args ...string) []byte {
+	b = appendHeader(b, len(args))
+	for _, arg := range args {
+		b = appendBlob(b, arg)
+	}
+	return b
+}
+
+// appendHeader starts a RESP array of size elements
+func appendHeader(b []byte, size int) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(size), 10)
+	return append(b, '\r', '\n')
+}
+
+// appendBlob encodes a blob string, copying its content as it is
+func appendBlob[T string | []byte](b []byte, blob T) []byte {
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(blob)), 10)
+	b = append(b, '\r', '\n')
+	b = append(b, blob...)
+	return append(b, '\r', '\n')
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// DefaultVirtualNodes is the amount of points every node takes in the ring of a ShardedClient, unless told otherwise
+const DefaultVirtualNodes = 160
+
+// ShardedOptions configures a ShardedClient
+type ShardedOptions struct {
+	// Addresses are the servers keys are spread among
+	Addresses []string
+	// VirtualNodes is the amount of points every node takes in the ring. More points spread keys more evenly,
+	// at the cost of a bigger ring.
+	VirtualNodes int
+	// Dial opens a new connection to a node. When nil, its address is dialed over tcp.
+	Dial func(ctx context.Context, address string) (net.Conn, error)
+	// Pool configures the pool of connections to every node, whose Address and Dial are ignored
+	Pool PoolOptions
+}
+
+// ShardedClient spreads keys among many servers by consistent hashing, keeping a pool of connections to each one.
+// Every node takes many points (virtual nodes) in a ring of hashes, and a key belongs to the first point found
+// after its own hash. Adding or removing a node only moves the keys between its points and the previous ones,
+// about 1/N of them, instead of almost every key.
+//
+// Only the part of a key between its first braces is hashed when not empty (see respparser.HashTag), so keys
+// like {user:1}:name and {user:1}:age always share a node. Unlike a single Client, it is safe to use from
+// many goroutines at once.
+type ShardedClient struct {
+	options ShardedOptions
+	lock    sync.RWMutex
+	// ring is replaced whenever a node is added or removed, never changed
+	ring   *ring
+	pools  map[string]*Pool
+	closed bool
+}
+
+// ringPoint is a virtual node
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// ring holds the virtual nodes of every node, sorted by hash
+type ring struct {
+	points []ringPoint
+}
+
+func newRing(nodes []string, virtualNodes int) *ring {
+	points := make([]ringPoint, 0, len(nodes)*virtualNodes)
+	for _, node := range nodes {
+		for i := range virtualNodes {
+			points = append(points, ringPoint{hash: ringHash(node + "-" + strconv.Itoa(i)), node: node})
+		}
+	}
+	// Ties are unlikely, but sorting by node too keeps every client agreeing on where keys go
+	slices.SortFunc(points, func(a, b ringPoint) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), strings.Compare(a.node, b.node))
+	})
+	return &ring{points: points}
+}
+
+// node returns the node a key belongs to: the owner of the first point from its hash, wrapping around the ring
+func (r *ring) node(key string) string {
+	hash := ringHash(respparser.HashTag(key))
+	i, _ := slices.BinarySearchFunc(r.points, hash, func(point ringPoint, hash uint64) int {
+		return cmp.Compare(point.hash, hash)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// ringHash is FNV-1a followed by the finalizer of splitmix64, since FNV alone spreads similar strings
+// (like the names of the virtual nodes of a node) poorly
+func ringHash(s string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint64(s[i])
+		hash *= 1099511628211
+	}
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+	return hash
+}
+
+// NewSharded creates a client spreading keys among the addresses given. Connections are opened when first needed.
+func NewSharded(options ShardedOptions) (*ShardedClient, error) {
+	if len(options.Addresses) == 0 {
+		redigoError := redigoerr.InvalidShardNodes
+		redigoError.ExtraContext = map[string]string{"reason": "at least one address is needed"}
+		return nil, redigoError
+	}
+	if options.VirtualNodes <= 0 {
+		options.VirtualNodes = DefaultVirtualNodes
+	}
+	s := &ShardedClient{options: options, pools: make(map[string]*Pool, len(options.Addresses))}
+	for _, address := range options.Addresses {
+		if err := s.addPool(address); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	s.ring = newRing(options.Addresses, options.VirtualNodes)
+	return s, nil
+}
+
+// addPool opens the pool of a new node, without adding it to the ring
+func (s *ShardedClient) addPool(address string) error {
+	if _, ok := s.pools[address]; ok {
+		redigoError := redigoerr.InvalidShardNodes
+		redigoError.ExtraContext = map[string]string{"reason": "address given twice", "address": address}
+		return redigoError
+	}
+	options := s.options.Pool
+	options.Address = address
+	options.Dial = nil
+	if dial := s.options.Dial; dial != nil {
+		options.Dial = func(ctx context.Context) (net.Conn, error) {
+			return dial(ctx, address)
+		}
+	}
+	pool, err := NewPool(options)
+	if err != nil {
+		return err
+	}
+	s.pools[address] = pool
+	return nil
+}
+
+// AddNode adds a server to the ring. The keys it now owns are not copied from their previous nodes,
+// so they read as missing until written again (or copied by the caller, see Node).
+func (s *ShardedClient) AddNode(address string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return redigoerr.ClientClosed
+	}
+	if err := s.addPool(address); err != nil {
+		return err
+	}
+	s.ring = newRing(s.nodes(), s.options.VirtualNodes)
+	return nil
+}
+
+// RemoveNode takes a server out of the ring, closing its connections once they are no longer in use.
+// Its keys are owned by the rest of the nodes from then on, without being copied.
+func (s *ShardedClient) RemoveNode(address string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return redigoerr.ClientClosed
+	}
+	pool, ok := s.pools[address]
+	if !ok {
+		redigoError := redigoerr.InvalidShardNodes
+		redigoError.ExtraContext = map[string]string{"reason": "address is not a node", "address": address}
+		return redigoError
+	}
+	if len(s.pools) == 1 {
+		redigoError := redigoerr.InvalidShardNodes
+		redigoError.ExtraContext = map[string]string{"reason": "at least one address is needed", "address": address}
+		return redigoError
+	}
+	delete(s.pools, address)
+	s.ring = newRing(s.nodes(), s.options.VirtualNodes)
+	return pool.Close()
+}
+
+// Nodes returns the address of every node, sorted
+func (s *ShardedClient) Nodes() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.nodes()
+}
+
+func (s *ShardedClient) nodes() []string {
+	nodes := make([]string, 0, len(s.pools))
+	for address := range s.pools {
+		nodes = append(nodes, address)
+	}
+	slices.Sort(nodes)
+	return nodes
+}
+
+// Node returns the address of the node a key belongs to
+func (s *ShardedClient) Node(key string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.ring.node(key)
+}
+
+// Close closes the connections to every node. Connections in use are closed once their calls end.
+func (s *ShardedClient) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for _, pool := range s.pools {
+		pool.Close()
+	}
+	return nil
+}
+
+// Do runs f with a connection to the node a key belongs to, for any call without a method of its own:
+//
+//	err := s.Do(ctx, "cat", func(c *client.Client) error {
+//		_, err := c.HSet("cat", "name", "Niji")
+//		return err
+//	})
+func (s *ShardedClient) Do(ctx context.Context, key string, f func(c *Client) error) error {
+	s.lock.RLock()
+	pool := s.pools[s.ring.node(key)]
+	s.lock.RUnlock()
+	return pool.Do(ctx, f)
+}
+
+// ForEachNode runs f with a connection to every node at the same time, like to send FLUSHALL or PING
+func (s *ShardedClient) ForEachNode(ctx context.Context, f func(address string, c *Client) error) error {
+	s.lock.RLock()
+	pools := make(map[string]*Pool, len(s.pools))
+	for address, pool := range s.pools {
+		pools[address] = pool
+	}
+	s.lock.RUnlock()
+	return runAll(pools, func(address string, pool *Pool) error {
+		return pool.Do(ctx, func(c *Client) error {
+			return f(address, c)
+		})
+	})
+}
+
+// fanOut groups keys by the node they belong to, running f with a connection to every node involved at the
+// same time, along with the positions of its keys
+func (s *ShardedClient) fanOut(ctx context.Context, keys []string, f func(c *Client, indexes []int) error) error {
+	s.lock.RLock()
+	groups := make(map[*Pool][]int)
+	for i, key := range keys {
+		pool := s.pools[s.ring.node(key)]
+		groups[pool] = append(groups[pool], i)
+	}
+	s.lock.RUnlock()
+	return runAll(groups, func(pool *Pool, indexes []int) error {
+		return pool.Do(ctx, func(c *Client) error {
+			return f(c, indexes)
+		})
+	})
+}
+
+// runAll calls f for every entry of m in its own goroutine, joining the errors returned
+func runAll[K comparable, V any](m map[K]V, f func(k K, v V) error) error {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+	)
+	for k, v := range m {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(k, v); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// exec executes a pipeline, returning the connection error or else the errors of its commands joined
+func exec(ctx context.Context, p *Pipeline) error {
+	errs, err := p.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ShardedClient) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+func (s *ShardedClient) GetContext(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.Do(ctx, key, func(c *Client) (err error) {
+		value, err = c.GetContext(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (s *ShardedClient) Set(key string, value string) error {
+	return s.SetContext(context.Background(), key, value)
+}
+
+func (s *ShardedClient) SetContext(ctx context.Context, key string, value string) error {
+	return s.Do(ctx, key, func(c *Client) error {
+		return c.SetContext(ctx, key, value)
+	})
+}
+
+// MGet reads many keys, sending a single pipeline to every node involved at the same time.
+// Values are returned in the order of the keys, empty for those that do not exist.
+func (s *ShardedClient) MGet(keys ...string) ([]string, error) {
+	return s.MGetContext(context.Background(), keys...)
+}
+
+func (s *ShardedClient) MGetContext(ctx context.Context, keys ...string) ([]string, error) {
+	values := make([]string, len(keys))
+	err := s.fanOut(ctx, keys, func(c *Client, indexes []int) error {
+		p := c.Pipeline()
+		futures := make([]*Future[string], len(indexes))
+		for i, index := range indexes {
+			futures[i] = p.Get(keys[index])
+		}
+		if err := exec(ctx, p); err != nil {
+			return err
+		}
+		for i, index := range indexes {
+			values[index], _ = futures[i].Result()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// MSet sets many keys, sending a single pipeline to every node involved at the same time.
+// Nodes do not agree on the outcome, so some keys may be set even if others fail.
+func (s *ShardedClient) MSet(values map[string]string) error {
+	return s.MSetContext(context.Background(), values)
+}
+
+func (s *ShardedClient) MSetContext(ctx context.Context, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return s.fanOut(ctx, keys, func(c *Client, indexes []int) error {
+		p := c.Pipeline()
+		for _, index := range indexes {
+			p.Set(keys[index], values[keys[index]])
+		}
+		return exec(ctx, p)
+	})
+}
+
+// Del removes many keys, sending a single pipeline to every node involved at the same time
+func (s *ShardedClient) Del(keys ...string) error {
+	return s.DelContext(context.Background(), keys...)
+}
+
+func (s *ShardedClient) DelContext(ctx context.Context, keys ...string) error {
+	return s.fanOut(ctx, keys, func(c *Client, indexes []int) error {
+		p := c.Pipeline()
+		for _, index := range indexes {
+			p.Del(keys[index])
+		}
+		return exec(ctx, p)
+	})
+}
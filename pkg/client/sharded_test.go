@@ -0,0 +1,181 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+)
+
+// shards starts n servers, returning them by address
+func shards(t *testing.T, n int) map[string]*redigotest.Server {
+	servers := make(map[string]*redigotest.Server, n)
+	for range n {
+		s := redigotest.NewServer(t)
+		servers[s.Addr()] = s
+	}
+	return servers
+}
+
+func addresses(servers map[string]*redigotest.Server) []string {
+	addresses := make([]string, 0, len(servers))
+	for address := range servers {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func TestShardedClient_Should_Store_Every_Key_In_Its_Node_When_Spreading_Keys(t *testing.T) {
+	servers := shards(t, 3)
+	s, err := client.NewSharded(client.ShardedOptions{Addresses: addresses(servers)})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer s.Close()
+	values := make(map[string]string)
+	keys := []string{}
+	for i := range 100 {
+		keys = append(keys, fmt.Sprintf("cat:%d", i))
+		values[keys[i]] = fmt.Sprint(i)
+	}
+	if err = s.MSet(values); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+
+	used := make(map[string]int)
+	for key, value := range values {
+		node := s.Node(key)
+		used[node]++
+		if v, _ := servers[node].Get(key); v != value {
+			t.Errorf("Unexpected value in %s for %s! %s", node, key, v)
+		}
+	}
+	if len(used) != 3 {
+		t.Errorf("Unexpected nodes used! %v", used)
+	}
+
+	got, err := s.MGet(append(keys, "missing")...)
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for i, key := range keys {
+		if got[i] != values[key] {
+			t.Errorf("Unexpected value for %s! %s", key, got[i])
+		}
+	}
+	if got[len(keys)] != "" {
+		t.Errorf("Unexpected value for missing! %s", got[len(keys)])
+	}
+
+	if err = s.Del(keys[:50]...); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := s.Get(keys[0]); err != nil || v != "" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if v, err := s.Get(keys[99]); err != nil || v != "99" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+}
+
+func TestShardedClient_Should_Keep_Keys_Together_When_Sharing_A_Hash_Tag(t *testing.T) {
+	servers := shards(t, 3)
+	s, err := client.NewSharded(client.ShardedOptions{Addresses: addresses(servers)})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer s.Close()
+	for i := range 20 {
+		user := fmt.Sprintf("{user:%d}", i)
+		if s.Node(user+":name") != s.Node(user+":cart") || s.Node(user+":name") != s.Node(fmt.Sprintf("user:%d", i)) {
+			t.Errorf("Unexpected nodes for %s!", user)
+		}
+	}
+
+	err = s.Do(context.Background(), "{user:1}:cart", func(c *client.Client) error {
+		return c.RPush("{user:1}:cart", "mouse", "laser")
+	})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if n, err := servers[s.Node("user:1")].NewClient().LLen("{user:1}:cart"); err != nil || n != 2 {
+		t.Errorf("Unexpected value! %d - %v", n, err)
+	}
+}
+
+func TestShardedClient_Should_Move_Only_Keys_Of_The_Node_When_Adding_Or_Removing_One(t *testing.T) {
+	before, err := client.NewSharded(client.ShardedOptions{Addresses: []string{"a:1", "b:1", "c:1"}})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer before.Close()
+	after, _ := client.NewSharded(client.ShardedOptions{Addresses: []string{"c:1", "a:1", "b:1"}})
+	defer after.Close()
+	if err = after.AddNode("d:1"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+
+	moved := 0
+	for i := range 10000 {
+		key := fmt.Sprintf("key:%d", i)
+		if node := after.Node(key); node != before.Node(key) {
+			moved++
+			if node != "d:1" {
+				t.Fatalf("Unexpected node for %s! %s", key, node)
+			}
+		}
+	}
+	// A fourth node owns about a quarter of the keys
+	if moved < 1500 || moved > 3500 {
+		t.Errorf("Unexpected amount of keys moved! %d", moved)
+	}
+
+	if err = after.RemoveNode("d:1"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for i := range 10000 {
+		key := fmt.Sprintf("key:%d", i)
+		if after.Node(key) != before.Node(key) {
+			t.Fatalf("Unexpected node for %s! %s", key, after.Node(key))
+		}
+	}
+	if nodes := after.Nodes(); !slices.Equal(nodes, []string{"a:1", "b:1", "c:1"}) {
+		t.Errorf("Unexpected nodes! %v", nodes)
+	}
+}
+
+func TestShardedClient_Should_Return_Error_When_Nodes_Are_Invalid(t *testing.T) {
+	if _, err := client.NewSharded(client.ShardedOptions{}); !isCode(err, redigoerr.InvalidShardNodes.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err := client.NewSharded(client.ShardedOptions{Addresses: []string{"a:1", "a:1"}}); !isCode(err, redigoerr.InvalidShardNodes.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	s, err := client.NewSharded(client.ShardedOptions{Addresses: []string{"a:1"}})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if err = s.AddNode("a:1"); !isCode(err, redigoerr.InvalidShardNodes.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err = s.RemoveNode("b:1"); !isCode(err, redigoerr.InvalidShardNodes.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err = s.RemoveNode("a:1"); !isCode(err, redigoerr.InvalidShardNodes.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	s.Close()
+	if err = s.AddNode("b:1"); !isCode(err, redigoerr.ClientClosed.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err = s.Get("cat"); !isCode(err, redigoerr.PoolClosed.Code) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// DefaultCacheSize is the amount of keys kept by a client with tracking on, unless told otherwise
+const DefaultCacheSize = 10000
+
+// TrackingOptions configures client side caching, see EnableTracking
+type TrackingOptions struct {
+	// BCAST makes the server tell about every key changed starting with one of the Prefixes (any key, without them),
+	// instead of only the keys read by the client. Only keys the server tells about are cached.
+	BCAST    bool
+	Prefixes []string
+	// NoLoop stops the server from telling about keys changed by the client itself, which it forgets on its own anyway
+	NoLoop bool
+	// CacheSize is the amount of keys kept, the least recently used ones being evicted first
+	CacheSize int
+}
+
+// CacheStats describes how the local cache of a client has been used
+type CacheStats struct {
+	// Hits are GETs answered from the cache, Misses the ones sent to the server
+	Hits   uint64
+	Misses uint64
+	// Invalidations are keys (or whole caches, when the server flushes them) forgotten because they changed
+	Invalidations uint64
+	Evictions     uint64
+	Size          int
+}
+
+// localCache keeps the replies to GET in memory, evicting the least recently used keys
+type localCache struct {
+	options TrackingOptions
+	entries map[string]*list.Element
+	// order holds *cacheEntry, the most recently used first
+	order *list.List
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key string
+	rep Reply
+}
+
+func newLocalCache(options TrackingOptions) *localCache {
+	if options.CacheSize <= 0 {
+		options.CacheSize = DefaultCacheSize
+	}
+	return &localCache{options: options, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// tracked tells if the server tells about changes to the key, since caching any other would keep it stale
+func (c *localCache) tracked(key string) bool {
+	if !c.options.BCAST || len(c.options.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range c.options.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *localCache) get(key string) (Reply, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return Reply{}, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).rep, true
+}
+
+func (c *localCache) put(key string, rep Reply) {
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).rep = rep
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, rep: rep})
+	for c.order.Len() > c.options.CacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// invalidate forgets the keys given. Methods are safe to call on a nil *localCache, doing nothing.
+func (c *localCache) invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+	for _, key := range keys {
+		c.stats.Invalidations++
+		if element, ok := c.entries[key]; ok {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clear forgets every key
+func (c *localCache) clear() {
+	if c == nil {
+		return
+	}
+	c.stats.Invalidations++
+	clear(c.entries)
+	c.order.Init()
+}
+
+// EnableTracking turns CLIENT TRACKING on, keeping the values read with Get in a local cache until the server
+// tells they changed. Keys changed by the client itself are forgotten right away, whatever the options.
+// Tracking is turned on again whenever the client reconnects, starting with an empty cache.
+func (client *Client) EnableTracking(ctx context.Context, options TrackingOptions) error {
+	if err := client.sendTracking(ctx, options); err != nil {
+		return err
+	}
+	// The cache is replaced only now, so a reconnection meanwhile sends the previous options
+	client.cache = newLocalCache(options)
+	return nil
+}
+
+// DisableTracking turns CLIENT TRACKING off, dropping the local cache
+func (client *Client) DisableTracking(ctx context.Context) error {
+	// Once asked, the server may stop telling about changes at any moment
+	client.cache = nil
+	_, err := client.call(ctx, "CLIENT", "TRACKING", "OFF")
+	return err
+}
+
+// CacheStats returns how the local cache has been used since tracking was turned on
+func (client *Client) CacheStats() CacheStats {
+	if client.cache == nil {
+		return CacheStats{}
+	}
+	stats := client.cache.stats
+	stats.Size = client.cache.order.Len()
+	return stats
+}
+
+// sendTracking turns tracking on in the current connection. It runs a single round trip, since it is also part of
+// replacing the connection.
+func (client *Client) sendTracking(ctx context.Context, options TrackingOptions) error {
+	args := []string{"CLIENT", "TRACKING", "ON"}
+	if options.BCAST {
+		args = append(args, "BCAST")
+	}
+	for _, prefix := range options.Prefixes {
+		args = append(args, "PREFIX", prefix)
+	}
+	if options.NoLoop {
+		args = append(args, "NOLOOP")
+	}
+	var rep Reply
+	err := client.roundTrip(ctx, appendCommand(nil, args...), func() (err error) {
+		rep, err = client.receive()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return rep.Err()
+}
+
+// cachedGet answers a GET from the local cache, once every invalidation received so far has been applied
+func (client *Client) cachedGet(ctx context.Context, key string) (Reply, error) {
+	cache := client.cache
+	if !client.broken && cache.tracked(key) {
+		if err := client.drainPushes(); err != nil {
+			// The next call replaces the connection (or fails, if it can not)
+			client.broken = true
+			cache.clear()
+		} else if rep, ok := cache.get(key); ok {
+			return rep, nil
+		}
+	}
+	// An invalidation arriving along with the reply may be about a value older than it, or not
+	invalidations := cache.stats.Invalidations
+	rep, err := client.call(ctx, "GET", key)
+	if err != nil {
+		return rep, err
+	}
+	if client.cache == cache && cache.stats.Invalidations == invalidations && cache.tracked(key) {
+		cache.put(key, rep)
+	}
+	return rep, nil
+}
+
+// receive reads the next reply, handling the push messages sent before it
+func (client *Client) receive() (Reply, error) {
+	for {
+		rep, err := readReply(client.buffer)
+		if err != nil || rep.kind != '>' {
+			return rep, err
+		}
+		client.handlePush(rep)
+	}
+}
+
+// drainPushes handles the push messages received since the last call, without waiting for more.
+// Anything else was not asked for, so it can not be matched to a command and breaks the connection.
+func (client *Client) drainPushes() error {
+	conn := *client.conn
+	for {
+		if client.buffer.Buffered() == 0 {
+			if ok, err := readable(conn, client.buffer); !ok || err != nil {
+				return err
+			}
+		}
+		// A message may have only partially arrived
+		conn.SetReadDeadline(client.deadline(context.Background(), client.options.ReadTimeout))
+		rep, err := readReply(client.buffer)
+		if err != nil {
+			return err
+		}
+		if rep.kind != '>' {
+			return redigoerr.ConnectionBroken
+		}
+		client.handlePush(rep)
+	}
+}
+
+// handlePush applies an invalidation, where a null stands for every key. Other push messages are ignored.
+func (client *Client) handlePush(rep Reply) {
+	if len(rep.elements) != 2 || string(rep.elements[0].blob) != "invalidate" {
+		return
+	}
+	if rep.elements[1].IsNil() {
+		client.cache.clear()
+		return
+	}
+	for _, key := range rep.elements[1].elements {
+		client.cache.invalidate(string(key.blob))
+	}
+}
+
+// wrote forgets the keys a command may have changed, without waiting for the server to tell.
+// Not knowing which keys a command works on (like FLUSHDB or SELECT), every key is forgotten.
+func (client *Client) wrote(args ...string) {
+	if client.cache == nil || len(args) == 0 || idempotent(args[0]) {
+		return
+	}
+	keys := respparser.CommandKeys(append([]string{strings.ToUpper(args[0])}, args[1:]...))
+	if len(keys) == 0 {
+		client.cache.clear()
+		return
+	}
+	client.cache.invalidate(keys...)
+}
+
+// stringArgs returns the arguments of a command that may be keys, leaving the rest empty
+func stringArgs(args ...any) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			strs[i] = v
+		case []byte:
+			strs[i] = string(v)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,176 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/redigotest"
+	"github.com/Arthur-phys/redigo/pkg/server"
+)
+
+// eventually calls Get until it returns expected, since invalidations arrive apart from replies
+func eventually(t *testing.T, c *client.Client, key string, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		v, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("An error occurred! %v", err)
+		}
+		if v == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Unexpected value! %s", v)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_Should_Serve_Get_From_Cache_When_Tracking_Is_On(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"cat": "Niji"})
+	c := s.NewClient()
+	if err := c.EnableTracking(context.Background(), client.TrackingOptions{}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := c.Get("cat"); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if v, err := c.Get("cat"); err != nil || v != "Niji" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if stats := c.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+	// Seeding skips commands, but the server still tells about the keys seeded
+	s.Seed(map[string]string{"cat": "Anubis"})
+	eventually(t, c, "cat", "Anubis")
+
+	if err := c.DisableTracking(context.Background()); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := c.Get("cat"); err != nil || v != "Anubis" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+}
+
+func TestClient_Should_Forget_Cached_Keys_When_They_Change(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"cat": "Niji", "dog": "Firulais"})
+	c, writer := s.NewClient(), s.NewClient()
+	if err := c.EnableTracking(context.Background(), client.TrackingOptions{NoLoop: true}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	c.Get("cat")
+	c.Get("dog")
+
+	if err := writer.Set("cat", "Anubis"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	eventually(t, c, "cat", "Anubis")
+	if v, err := c.Get("dog"); err != nil || v != "Firulais" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+
+	// The client forgets what it changed itself without being told
+	if err := c.Set("dog", "Boby"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := c.Get("dog"); err != nil || v != "Boby" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	p := c.Pipeline()
+	p.Del("dog")
+	if _, err := p.Exec(); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if v, err := c.Get("dog"); err != nil || v != "" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+
+	// A flush tells every client to forget all of its keys
+	c.Get("cat")
+	if _, err := writer.Do(context.Background(), "FLUSHALL"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	eventually(t, c, "cat", "")
+}
+
+func TestClient_Should_Evict_Least_Recently_Used_Keys_When_Cache_Is_Full(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"a": "1", "b": "2", "c": "3"})
+	c := s.NewClient()
+	if err := c.EnableTracking(context.Background(), client.TrackingOptions{CacheSize: 2}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	for _, key := range []string{"a", "b", "a", "c", "b"} {
+		c.Get(key)
+	}
+	// b was evicted by c, being older than a
+	if stats := c.CacheStats(); stats.Hits != 1 || stats.Misses != 4 || stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+}
+
+func TestClient_Should_Only_Cache_Keys_Matching_Prefixes_When_Using_BCAST(t *testing.T) {
+	s := redigotest.NewServer(t)
+	s.Seed(map[string]string{"user:1": "Arturo", "other": "Gene"})
+	c, writer := s.NewClient(), s.NewClient()
+	err := c.EnableTracking(context.Background(), client.TrackingOptions{BCAST: true, Prefixes: []string{"user:"}})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	c.Get("user:1")
+	c.Get("other")
+	if stats := c.CacheStats(); stats.Size != 1 {
+		t.Errorf("Unexpected stats! %+v", stats)
+	}
+
+	if err = writer.Set("user:1", "Juan"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	eventually(t, c, "user:1", "Juan")
+}
+
+func TestClient_Should_Track_Again_With_An_Empty_Cache_When_Reconnecting(t *testing.T) {
+	s := redigotest.NewServerWithConfig(t, &server.Configuration{
+		MinWorkers:        1,
+		MaxWorkers:        64,
+		KeepAlive:         1,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+	})
+	s.Seed(map[string]string{"cat": "Niji"})
+	c, err := client.Dial(context.Background(), s.Addr(), client.Options{})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer c.Close()
+	if err = c.EnableTracking(context.Background(), client.TrackingOptions{}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	c.Get("cat")
+
+	// Changes made while disconnected are never told, so nothing cached before is trusted
+	time.Sleep(1500 * time.Millisecond)
+	s.Seed(map[string]string{"cat": "Anubis"})
+	if v, err := c.Get("cat"); err != nil || v != "Anubis" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	writer, err := client.Dial(context.Background(), s.Addr(), client.Options{})
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	defer writer.Close()
+	if err = writer.Set("cat", "Pingüica"); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	eventually(t, c, "cat", "Pingüica")
+}
@@ -0,0 +1,377 @@
+package cache
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// MaxBitOffset is the biggest offset (exclusive) a bit operation can reach, equivalent to a 512MB string
+const MaxBitOffset = uint64(1) << 32
+
+// BitRange delimits the portion of a string taken into account by BitCount and BitPos.
+// Start and End are inclusive and can be negative to count from the end of the string.
+// When InBits is true they refer to bits instead of bytes.
+type BitRange struct {
+	Start  int
+	End    int
+	EndSet bool
+	InBits bool
+}
+
+// BitFieldOverflow determines how BitField behaves when a SET or INCRBY goes out of range
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldKind is the operation performed by a single BitFieldOp
+type BitFieldKind int
+
+const (
+	BitFieldGet BitFieldKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp is a single GET, SET or INCRBY subcommand of BITFIELD.
+// Width goes from 1 to 64 for signed integers and from 1 to 63 for unsigned ones.
+type BitFieldOp struct {
+	Kind     BitFieldKind
+	Signed   bool
+	Width    uint
+	Offset   uint64
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// bytesOf retrieves the value of a key as bytes, an absent key is an empty string
+func (c *Cache) bytesOf(key string) ([]byte, bool, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return []byte{}, false, nil
+	}
+	vAsString, ok := v.(string)
+	if !ok {
+		return nil, true, redigoerr.WrongType
+	}
+	return []byte(vAsString), true, nil
+}
+
+// growTo zero-extends b so that the bit at offset exists
+func growTo(b []byte, offset uint64) []byte {
+	needed := int(offset/8) + 1
+	if len(b) < needed {
+		b = append(b, make([]byte, needed-len(b))...)
+	}
+	return b
+}
+
+func getBit(b []byte, offset uint64) byte {
+	byteIndex := offset / 8
+	if byteIndex >= uint64(len(b)) {
+		return 0
+	}
+	return (b[byteIndex] >> (7 - offset%8)) & 1
+}
+
+func setBit(b []byte, offset uint64, value byte) {
+	mask := byte(1) << (7 - offset%8)
+	if value == 1 {
+		b[offset/8] |= mask
+	} else {
+		b[offset/8] &^= mask
+	}
+}
+
+func checkBitOffset(offset uint64) error {
+	if offset >= MaxBitOffset {
+		err := redigoerr.BitOffsetOutOfRange
+		err.ExtraContext = map[string]string{"offset": fmt.Sprintf("%d", offset)}
+		return err
+	}
+	return nil
+}
+
+func (c *Cache) SetBit(key string, offset uint64, value byte) (byte, error) {
+	if err := checkBitOffset(offset); err != nil {
+		return 0, err
+	}
+	if value > 1 {
+		return 0, redigoerr.BitValueOutOfRange
+	}
+	b, _, err := c.bytesOf(key)
+	if err != nil {
+		return 0, err
+	}
+	b = growTo(b, offset)
+	previous := getBit(b, offset)
+	setBit(b, offset, value)
+	c.dict[key] = string(b)
+	return previous, nil
+}
+
+func (c *Cache) GetBit(key string, offset uint64) (byte, error) {
+	if err := checkBitOffset(offset); err != nil {
+		return 0, err
+	}
+	b, _, err := c.bytesOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return getBit(b, offset), nil
+}
+
+// normalize converts a BitRange into absolute, inclusive bit positions.
+// It returns false when the range is empty.
+func (r BitRange) normalize(byteLen int) (uint64, uint64, bool) {
+	total := byteLen
+	if r.InBits {
+		total = byteLen * 8
+	}
+	start, end := r.Start, r.End
+	if !r.EndSet {
+		end = total - 1
+	}
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	start = max(start, 0)
+	end = max(end, 0)
+	end = min(end, total-1)
+	if total == 0 || start > end {
+		return 0, 0, false
+	}
+	if r.InBits {
+		return uint64(start), uint64(end), true
+	}
+	return uint64(start) * 8, uint64(end)*8 + 7, true
+}
+
+func (c *Cache) BitCount(key string, r BitRange) (int, error) {
+	b, _, err := c.bytesOf(key)
+	if err != nil {
+		return 0, err
+	}
+	start, end, ok := r.normalize(len(b))
+	if !ok {
+		return 0, nil
+	}
+	count := 0
+	for offset := start; offset <= end; {
+		// Whole bytes are counted at once, stray bits one by one
+		if offset%8 == 0 && offset+7 <= end {
+			count += bits.OnesCount8(b[offset/8])
+			offset += 8
+			continue
+		}
+		count += int(getBit(b, offset))
+		offset++
+	}
+	return count, nil
+}
+
+func (c *Cache) BitPos(key string, bit byte, r BitRange) (int, error) {
+	if bit > 1 {
+		return 0, redigoerr.BitValueOutOfRange
+	}
+	b, present, err := c.bytesOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if !present {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	start, end, ok := r.normalize(len(b))
+	if !ok {
+		return -1, nil
+	}
+	for offset := start; offset <= end; offset++ {
+		if getBit(b, offset) == bit {
+			return int(offset), nil
+		}
+	}
+	// Looking for a clear bit on a string full of ones returns the first bit after the string,
+	// unless the user explicitly asked for a range
+	if bit == 0 && !r.EndSet {
+		return int(end) + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp performs AND, OR, XOR or NOT between strings and stores the result in dest.
+// Missing keys are taken as strings full of zeros, and shorter strings are zero-padded.
+func (c *Cache) BitOp(op string, dest string, keys ...string) (int, error) {
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return 0, redigoerr.SyntaxError
+		}
+	default:
+		return 0, redigoerr.SyntaxError
+	}
+	sources := make([][]byte, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		b, _, err := c.bytesOf(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = b
+		maxLen = max(maxLen, len(b))
+	}
+	if maxLen == 0 {
+		delete(c.dict, dest)
+		return 0, nil
+	}
+
+	result := make([]byte, maxLen)
+	for i := range result {
+		byteAt := func(src []byte) byte {
+			if i < len(src) {
+				return src[i]
+			}
+			return 0
+		}
+		result[i] = byteAt(sources[0])
+		for _, src := range sources[1:] {
+			switch op {
+			case "AND":
+				result[i] &= byteAt(src)
+			case "OR":
+				result[i] |= byteAt(src)
+			case "XOR":
+				result[i] ^= byteAt(src)
+			}
+		}
+		if op == "NOT" {
+			result[i] = ^result[i]
+		}
+	}
+	c.dict[dest] = string(result)
+	return maxLen, nil
+}
+
+// BitField runs every operation in order over the string stored at key.
+// A nil result means that operation failed because of an OverflowFail policy.
+func (c *Cache) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	b, _, err := c.bytesOf(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if (op.Signed && (op.Width < 1 || op.Width > 64)) || (!op.Signed && (op.Width < 1 || op.Width > 63)) {
+			return nil, redigoerr.InvalidBitfieldType
+		}
+		if err := checkBitOffset(op.Offset); err != nil {
+			return nil, err
+		}
+		if err := checkBitOffset(op.Offset + uint64(op.Width) - 1); err != nil {
+			return nil, err
+		}
+	}
+
+	written := false
+	results := make([]*int64, 0, len(ops))
+	for _, op := range ops {
+		old := readField(b, op)
+		if op.Kind == BitFieldGet {
+			results = append(results, &old)
+			continue
+		}
+
+		var (
+			value int64
+			ok    bool
+		)
+		if op.Kind == BitFieldSet {
+			value, ok = fitField(0, op.Value, op)
+		} else {
+			value, ok = fitField(old, op.Value, op)
+		}
+		if !ok {
+			results = append(results, nil)
+			continue
+		}
+		b = growTo(b, op.Offset+uint64(op.Width)-1)
+		writeField(b, op, value)
+		written = true
+		if op.Kind == BitFieldSet {
+			results = append(results, &old)
+		} else {
+			results = append(results, &value)
+		}
+	}
+	if written {
+		c.dict[key] = string(b)
+	}
+	return results, nil
+}
+
+func readField(b []byte, op BitFieldOp) int64 {
+	var raw uint64
+	for i := range uint64(op.Width) {
+		raw = raw<<1 | uint64(getBit(b, op.Offset+i))
+	}
+	if op.Signed && op.Width < 64 && raw&(1<<(op.Width-1)) != 0 {
+		// Sign extension
+		raw |= ^uint64(0) << op.Width
+	}
+	return int64(raw)
+}
+
+func writeField(b []byte, op BitFieldOp, value int64) {
+	raw := uint64(value)
+	for i := range uint64(op.Width) {
+		setBit(b, op.Offset+i, byte(raw>>(uint64(op.Width)-1-i))&1)
+	}
+}
+
+// fitField adds incr to old and applies the overflow policy of op to the result.
+// It returns false when the policy is OverflowFail and the result does not fit.
+func fitField(old int64, incr int64, op BitFieldOp) (int64, bool) {
+	var minValue, maxValue int64
+	if op.Signed {
+		minValue = -1 << (op.Width - 1)
+		maxValue = 1<<(op.Width-1) - 1
+	} else {
+		minValue = 0
+		maxValue = 1<<op.Width - 1
+	}
+
+	overflow := incr > 0 && old > maxValue-incr
+	underflow := incr < 0 && old < minValue-incr
+	if !overflow && !underflow {
+		return old + incr, true
+	}
+
+	switch op.Overflow {
+	case OverflowSat:
+		if overflow {
+			return maxValue, true
+		}
+		return minValue, true
+	case OverflowFail:
+		return 0, false
+	default:
+		raw := uint64(old) + uint64(incr)
+		if op.Width < 64 {
+			raw &= 1<<op.Width - 1
+			if op.Signed && raw&(1<<(op.Width-1)) != 0 {
+				raw |= ^uint64(0) << op.Width
+			}
+		}
+		return int64(raw), true
+	}
+}
@@ -0,0 +1,179 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func TestSetBit_Should_Zero_Extend_String_When_Offset_Is_Beyond_Length(t *testing.T) {
+	cs := New()
+	if previous, err := cs.SetBit("KEY", 17, 1); err != nil || previous != 0 {
+		t.Errorf("An error occurred! %v - %v", err, previous)
+	}
+	if val, err := cs.Get("KEY"); err != nil || val != "\x00\x00\x40" {
+		t.Errorf("Unexpected value stored! %v - %q", err, val)
+	}
+	if previous, err := cs.SetBit("KEY", 17, 0); err != nil || previous != 1 {
+		t.Errorf("Previous bit was not returned! %v - %v", err, previous)
+	}
+}
+
+func TestGetBit_Should_Return_Zero_When_Offset_Or_Key_Not_Present(t *testing.T) {
+	cs := New()
+	if bit, err := cs.GetBit("KEY", 100); err != nil || bit != 0 {
+		t.Errorf("Unexpected bit! %v - %v", err, bit)
+	}
+	cs.Set("KEY", "a")
+	if bit, err := cs.GetBit("KEY", 1); err != nil || bit != 1 {
+		t.Errorf("Unexpected bit! %v - %v", err, bit)
+	}
+}
+
+func TestGetBit_Should_Return_Error_When_Key_Holds_A_List(t *testing.T) {
+	cs := New()
+	cs.LPush("KEYVECTOR", "REDIGO")
+	if _, err := cs.GetBit("KEYVECTOR", 0); err == nil {
+		t.Errorf("Expected error but obtained nil!")
+	}
+}
+
+func TestBitCount_Should_Count_Using_Byte_And_Bit_Ranges(t *testing.T) {
+	cs := New()
+	cs.Set("KEY", "foobar")
+	if count, err := cs.BitCount("KEY", BitRange{}); err != nil || count != 26 {
+		t.Errorf("Unexpected count for whole string! %v - %d", err, count)
+	}
+	if count, err := cs.BitCount("KEY", BitRange{Start: 1, End: 1, EndSet: true}); err != nil || count != 6 {
+		t.Errorf("Unexpected count for byte range! %v - %d", err, count)
+	}
+	if count, err := cs.BitCount("KEY", BitRange{Start: -2, End: -1, EndSet: true}); err != nil || count != 7 {
+		t.Errorf("Unexpected count for negative byte range! %v - %d", err, count)
+	}
+	if count, err := cs.BitCount("KEY", BitRange{Start: 5, End: 30, EndSet: true, InBits: true}); err != nil || count != 17 {
+		t.Errorf("Unexpected count for bit range! %v - %d", err, count)
+	}
+}
+
+func TestBitPos_Should_Find_First_Bit_When_Present(t *testing.T) {
+	cs := New()
+	cs.Set("KEY", "\xff\xf0\x00")
+	if pos, err := cs.BitPos("KEY", 0, BitRange{}); err != nil || pos != 12 {
+		t.Errorf("Unexpected position! %v - %d", err, pos)
+	}
+	cs.Set("KEY", "\x00\xff\xf0")
+	if pos, err := cs.BitPos("KEY", 1, BitRange{Start: 2, End: -1, EndSet: true}); err != nil || pos != 16 {
+		t.Errorf("Unexpected position! %v - %d", err, pos)
+	}
+	if pos, err := cs.BitPos("KEY", 1, BitRange{Start: 7, End: 15, EndSet: true, InBits: true}); err != nil || pos != 8 {
+		t.Errorf("Unexpected position! %v - %d", err, pos)
+	}
+}
+
+func TestBitPos_Should_Return_Position_After_String_When_Looking_For_Clear_Bit_In_Ones(t *testing.T) {
+	cs := New()
+	cs.Set("KEY", "\xff\xff")
+	if pos, err := cs.BitPos("KEY", 0, BitRange{}); err != nil || pos != 16 {
+		t.Errorf("Unexpected position! %v - %d", err, pos)
+	}
+	if pos, err := cs.BitPos("KEY", 0, BitRange{Start: 0, End: -1, EndSet: true}); err != nil || pos != -1 {
+		t.Errorf("Unexpected position with explicit range! %v - %d", err, pos)
+	}
+	if pos, err := cs.BitPos("MISSING", 1, BitRange{}); err != nil || pos != -1 {
+		t.Errorf("Unexpected position for missing key! %v - %d", err, pos)
+	}
+}
+
+func TestBitOp_Should_Combine_Strings_Of_Different_Length(t *testing.T) {
+	cs := New()
+	cs.Set("A", "\xf0\x0f")
+	cs.Set("B", "\x3c")
+	if n, err := cs.BitOp("AND", "DEST", "A", "B"); err != nil || n != 2 {
+		t.Errorf("An error occurred! %v - %d", err, n)
+	}
+	if val, _ := cs.Get("DEST"); val != "\x30\x00" {
+		t.Errorf("Unexpected AND result! %q", val)
+	}
+	cs.BitOp("OR", "DEST", "A", "B", "MISSING")
+	if val, _ := cs.Get("DEST"); val != "\xfc\x0f" {
+		t.Errorf("Unexpected OR result! %q", val)
+	}
+	cs.BitOp("XOR", "DEST", "A", "B")
+	if val, _ := cs.Get("DEST"); val != "\xcc\x0f" {
+		t.Errorf("Unexpected XOR result! %q", val)
+	}
+	cs.BitOp("NOT", "DEST", "A")
+	if val, _ := cs.Get("DEST"); val != "\x0f\xf0" {
+		t.Errorf("Unexpected NOT result! %q", val)
+	}
+}
+
+func TestBitOp_Should_Delete_Destination_When_All_Sources_Are_Empty(t *testing.T) {
+	cs := New()
+	cs.Set("DEST", "REDIGO")
+	if n, err := cs.BitOp("OR", "DEST", "MISSING"); err != nil || n != 0 {
+		t.Errorf("An error occurred! %v - %d", err, n)
+	}
+	if _, err := cs.Get("DEST"); !redigoerr.KeyNotFound(err) {
+		t.Errorf("Destination was not deleted! %v", err)
+	}
+	if _, err := cs.BitOp("NOT", "DEST", "A", "B"); err == nil {
+		t.Errorf("Expected error for NOT with multiple keys but obtained nil!")
+	}
+}
+
+func TestBitField_Should_Get_And_Set_Signed_And_Unsigned_Integers(t *testing.T) {
+	cs := New()
+	results, err := cs.BitField("KEY", []BitFieldOp{
+		{Kind: BitFieldSet, Width: 8, Offset: 0, Value: 255},
+		{Kind: BitFieldGet, Signed: true, Width: 8, Offset: 0},
+		{Kind: BitFieldGet, Width: 4, Offset: 4},
+		{Kind: BitFieldSet, Signed: true, Width: 64, Offset: 8, Value: -2},
+		{Kind: BitFieldGet, Signed: true, Width: 64, Offset: 8},
+	})
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	expected := []int64{0, -1, 15, 0, -2}
+	for i := range expected {
+		if results[i] == nil || *results[i] != expected[i] {
+			t.Errorf("Unexpected result at %d! %v", i, results[i])
+		}
+	}
+}
+
+func TestBitField_Should_Apply_Overflow_Policies_When_Incrementing(t *testing.T) {
+	cs := New()
+	results, err := cs.BitField("KEY", []BitFieldOp{
+		{Kind: BitFieldIncrBy, Width: 2, Offset: 0, Value: 5, Overflow: OverflowWrap},
+		{Kind: BitFieldIncrBy, Signed: true, Width: 4, Offset: 8, Value: 100, Overflow: OverflowSat},
+		{Kind: BitFieldIncrBy, Signed: true, Width: 4, Offset: 8, Value: -100, Overflow: OverflowSat},
+		{Kind: BitFieldIncrBy, Width: 8, Offset: 16, Value: 256, Overflow: OverflowFail},
+		{Kind: BitFieldIncrBy, Signed: true, Width: 8, Offset: 24, Value: 130, Overflow: OverflowWrap},
+	})
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	expected := []int64{1, 7, -8, 0, -126}
+	for i := range expected {
+		if i == 3 {
+			if results[i] != nil {
+				t.Errorf("Expected nil for FAIL overflow but got %v!", *results[i])
+			}
+			continue
+		}
+		if results[i] == nil || *results[i] != expected[i] {
+			t.Errorf("Unexpected result at %d! %v", i, results[i])
+		}
+	}
+}
+
+func TestBitField_Should_Return_Error_When_Type_Is_Invalid(t *testing.T) {
+	cs := New()
+	if _, err := cs.BitField("KEY", []BitFieldOp{{Kind: BitFieldGet, Width: 64, Offset: 0}}); err == nil {
+		t.Errorf("Expected error for u64 but obtained nil!")
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"container/list"
 
@@ -12,16 +13,86 @@ import (
 type Cache struct {
 	internalLock sync.Mutex
 	dict         map[string]any
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+}
+
+// Stats is a snapshot of the keyspace: amount of keys (also by type) and how many reads found their key
+type Stats struct {
+	Keys       int
+	KeysByType map[string]int
+	Hits       uint64
+	Misses     uint64
 }
 
 func New() *Cache {
 	return &Cache{
-		sync.Mutex{},
-		make(map[string]any),
+		internalLock: sync.Mutex{},
+		dict:         make(map[string]any),
+	}
+}
+
+// lookup reads the value of a key, counting it as a keyspace hit or miss
+func (c *Cache) lookup(key string) (any, bool) {
+	v, ok := c.dict[key]
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return v, ok
+}
+
+// typeName returns the name REDIS gives to the type of a value
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case *list.List:
+		return "list"
+	case *sortedSet:
+		return "zset"
+	case *hyperLogLog:
+		// HyperLogLogs are strings in REDIS
+		return "string"
+	case *jsonDocument:
+		return "ReJSON-RL"
+	case hash:
+		return "hash"
+	default:
+		return "none"
+	}
+}
+
+// Stats returns the current keyspace statistics. As every other method, it expects the cache to be locked.
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Keys:       len(c.dict),
+		KeysByType: make(map[string]int),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+	}
+	for _, v := range c.dict {
+		stats.KeysByType[typeName(v)]++
 	}
+	return stats
 }
 
 func (c *Cache) Get(key string) (string, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		err := redigoerr.KeyNotFoundInDictionary
+		err.ExtraContext = map[string]string{"key": key}
+		return "", err
+	}
+	if v, ok := v.(string); ok {
+		return v, nil
+	}
+	return "", redigoerr.WrongType
+}
+
+// Peek returns the string value of a key without counting as a keyspace hit or miss, to inspect the cache from outside
+func (c *Cache) Peek(key string) (string, error) {
 	v, ok := c.dict[key]
 	if !ok {
 		err := redigoerr.KeyNotFoundInDictionary
@@ -34,6 +105,25 @@ func (c *Cache) Get(key string) (string, error) {
 	return "", redigoerr.WrongType
 }
 
+// PeekList returns every value of a list without counting as a keyspace hit or miss, to inspect the cache from outside
+func (c *Cache) PeekList(key string) ([]string, error) {
+	v, ok := c.dict[key]
+	if !ok {
+		err := redigoerr.KeyNotFoundInDictionary
+		err.ExtraContext = map[string]string{"key": key}
+		return nil, err
+	}
+	vAsList, ok := v.(*list.List)
+	if !ok {
+		return nil, redigoerr.WrongType
+	}
+	values := make([]string, 0, vAsList.Len())
+	for e := vAsList.Front(); e != nil; e = e.Next() {
+		values = append(values, e.Value.(string))
+	}
+	return values, nil
+}
+
 func (c *Cache) Set(key string, value string) error {
 	c.dict[key] = value
 	return nil
@@ -117,7 +207,7 @@ func (c *Cache) LPop(key string) (string, error) {
 }
 
 func (c *Cache) LIndex(key string, index int) (string, error) {
-	v, ok := c.dict[key]
+	v, ok := c.lookup(key)
 	if !ok {
 		err := redigoerr.KeyNotFoundInDictionary
 		err.ExtraContext = map[string]string{"key": key}
@@ -141,7 +231,8 @@ func (c *Cache) LIndex(key string, index int) (string, error) {
 }
 
 func (c *Cache) LLen(key string) (int, error) {
-	if v, ok := c.dict[key].(*list.List); ok {
+	v, _ := c.lookup(key)
+	if v, ok := v.(*list.List); ok {
 		return v.Len(), nil
 	}
 	return 0, redigoerr.WrongType
@@ -152,6 +243,39 @@ func (c *Cache) Del(key string) error {
 	return nil
 }
 
+// Move transfers a key to another cache, returning false when the key does not exist or is already present in dst.
+// Both caches are expected to be locked.
+func (c *Cache) Move(key string, dst *Cache) bool {
+	v, ok := c.dict[key]
+	if !ok {
+		return false
+	}
+	if _, exists := dst.dict[key]; exists {
+		return false
+	}
+	dst.dict[key] = v
+	delete(c.dict, key)
+	return true
+}
+
+// Swap exchanges the contents of two caches, which are expected to be locked.
+// Statistics are not exchanged since they describe the accesses to each cache.
+func (c *Cache) Swap(other *Cache) {
+	c.dict, other.dict = other.dict, c.dict
+}
+
+// Flush removes every key. With async the keys are released in the background, so the cache
+// can be used again right away no matter how big it was.
+func (c *Cache) Flush(async bool) {
+	old := c.dict
+	c.dict = make(map[string]any)
+	if async {
+		go clear(old)
+	} else {
+		clear(old)
+	}
+}
+
 func (c *Cache) Lock() {
 	c.internalLock.Lock()
 }
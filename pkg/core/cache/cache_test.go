@@ -197,3 +197,71 @@ func TestLIndex_Should_Return_Error_When_Index_Not_Present_In_List(t *testing.T)
 		t.Errorf("Was able to retrieve unexistant value! %v - %s", err, s)
 	}
 }
+
+func TestStats_Should_Count_Keys_By_Type_And_Keyspace_Hits(t *testing.T) {
+	cs := New()
+	cs.Set("a", "1")
+	cs.RPush("l", "1")
+	cs.PFAdd("h", "x")
+	cs.Get("a")
+	cs.Get("missing")
+	cs.LIndex("l", 0)
+	stats := cs.Stats()
+	if stats.Keys != 3 || stats.KeysByType["string"] != 2 || stats.KeysByType["list"] != 1 {
+		t.Errorf("Unexpected key counts! %v", stats)
+	}
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Unexpected keyspace hits or misses! %v", stats)
+	}
+}
+
+func TestMove_Should_Transfer_Key_When_Not_Present_In_Destination(t *testing.T) {
+	src, dst := New(), New()
+	src.Set("KEY", "REDIGO")
+	if !src.Move("KEY", dst) {
+		t.Errorf("Key was not moved!")
+	}
+	if _, err := src.Get("KEY"); err == nil {
+		t.Errorf("Key is still present in the source!")
+	}
+	if v, err := dst.Get("KEY"); err != nil || v != "REDIGO" {
+		t.Errorf("An error occurred! %v - %v", err, v)
+	}
+}
+
+func TestMove_Should_Return_False_When_Key_Is_Present_In_Destination(t *testing.T) {
+	src, dst := New(), New()
+	src.Set("KEY", "SOURCE")
+	dst.Set("KEY", "DESTINATION")
+	if src.Move("KEY", dst) || src.Move("MISSING", dst) {
+		t.Errorf("Key was moved when it should not be!")
+	}
+	if v, _ := dst.Get("KEY"); v != "DESTINATION" {
+		t.Errorf("Destination was overwritten! %v", v)
+	}
+}
+
+func TestSwap_Should_Exchange_Keys_When_Called(t *testing.T) {
+	a, b := New(), New()
+	a.Set("A", "1")
+	b.Set("B", "2")
+	a.Swap(b)
+	if _, err := a.Get("B"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if _, err := b.Get("A"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+}
+
+func TestFlush_Should_Remove_Every_Key_When_Called(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		cs := New()
+		cs.Set("A", "1")
+		cs.RPush("B", "2")
+		cs.Flush(async)
+		if stats := cs.Stats(); stats.Keys != 0 {
+			t.Errorf("Keys remain after flushing! async = %v - %d", async, stats.Keys)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/crc32"
+	"iter"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// dumpVersion is written at the end of every payload, so that payloads of a format no longer understood are rejected
+const dumpVersion = 1
+
+// Types of the values in a payload
+const (
+	dumpString byte = iota
+	dumpList
+	dumpHash
+	dumpSortedSet
+	dumpHyperLogLog
+	dumpJSON
+)
+
+// Exists tells if a key exists, without counting as a keyspace hit or miss
+func (c *Cache) Exists(key string) bool {
+	_, ok := c.dict[key]
+	return ok
+}
+
+// Keys iterates over every key, in no particular order. The cache has to stay locked while iterating.
+func (c *Cache) Keys() iter.Seq[string] {
+	return maps.Keys(c.dict)
+}
+
+// Dump serializes the value of a key, whatever its type, so that Restore can recreate it in another cache
+// (or server). The payload ends with its format version and a checksum. False is returned when the key does not exist.
+func (c *Cache) Dump(key string) ([]byte, bool) {
+	v, ok := c.dict[key]
+	if !ok {
+		return nil, false
+	}
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = append([]byte{dumpString}, v...)
+	case *list.List:
+		b = binary.AppendUvarint([]byte{dumpList}, uint64(v.Len()))
+		for e := v.Front(); e != nil; e = e.Next() {
+			b = appendDumpString(b, e.Value.(string))
+		}
+	case hash:
+		b = binary.AppendUvarint([]byte{dumpHash}, uint64(len(v)))
+		for _, field := range slices.Sorted(maps.Keys(v)) {
+			b = appendDumpString(appendDumpString(b, field), v[field])
+		}
+	case *sortedSet:
+		b = binary.AppendUvarint([]byte{dumpSortedSet}, uint64(len(v.entries)))
+		for _, entry := range v.entries {
+			b = binary.LittleEndian.AppendUint64(appendDumpString(b, entry.member), math.Float64bits(entry.score))
+		}
+	case *hyperLogLog:
+		b = []byte{dumpHyperLogLog}
+		if v.isSparse() {
+			b = binary.AppendUvarint(append(b, 0), uint64(len(v.sparse)))
+			for index, value := range v.sparse {
+				b = append(binary.LittleEndian.AppendUint16(b, index), value)
+			}
+		} else {
+			b = append(append(b, 1), v.dense...)
+		}
+	case *jsonDocument:
+		b = append([]byte{dumpJSON}, encodeJSON(v.root)...)
+	}
+	b = append(b, dumpVersion)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), true
+}
+
+func appendDumpString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+// Restore creates a key from a payload made by Dump. An existing key is only replaced when asked to,
+// failing with redigoerr.BusyKey otherwise.
+func (c *Cache) Restore(key string, payload []byte, replace bool) error {
+	if _, ok := c.dict[key]; ok && !replace {
+		redigoError := redigoerr.BusyKey
+		redigoError.ExtraContext = map[string]string{"key": key}
+		return redigoError
+	}
+	v, err := undump(payload)
+	if err != nil {
+		redigoError := redigoerr.InvalidDumpPayload
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"key": key}
+		return redigoError
+	}
+	c.dict[key] = v
+	return nil
+}
+
+// dumpReader reads the body of a payload, remembering the first error found
+type dumpReader struct {
+	b   []byte
+	err error
+}
+
+func (r *dumpReader) fail() {
+	if r.err == nil {
+		r.err = redigoerr.InvalidDumpPayload
+	}
+	r.b = nil
+}
+
+func (r *dumpReader) uvarint() uint64 {
+	n, size := binary.Uvarint(r.b)
+	if size <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[size:]
+	return n
+}
+
+func (r *dumpReader) bytes(n uint64) []byte {
+	if uint64(len(r.b)) < n {
+		r.fail()
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *dumpReader) string() string {
+	return string(r.bytes(r.uvarint()))
+}
+
+// count reads the amount of elements that follow, each one taking at least a byte
+func (r *dumpReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.b)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func undump(payload []byte) (any, error) {
+	if len(payload) < 6 {
+		return nil, redigoerr.InvalidDumpPayload
+	}
+	body, trailer := payload[:len(payload)-4], payload[len(payload)-4:]
+	if body[len(body)-1] != dumpVersion || crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(trailer) {
+		return nil, redigoerr.InvalidDumpPayload
+	}
+	r := &dumpReader{b: body[1 : len(body)-1]}
+	var v any
+	switch body[0] {
+	case dumpString:
+		v = string(r.b)
+		r.b = nil
+	case dumpList:
+		l := list.New()
+		for n := r.count(); n > 0; n-- {
+			l.PushBack(r.string())
+		}
+		v = l
+	case dumpHash:
+		n := r.count()
+		h := make(hash, n)
+		for ; n > 0; n-- {
+			field := r.string()
+			h[field] = r.string()
+		}
+		v = h
+	case dumpSortedSet:
+		z := newSortedSet()
+		for n := r.count(); n > 0 && r.err == nil; n-- {
+			member := r.string()
+			score := r.bytes(8)
+			if r.err == nil {
+				z.add(member, math.Float64frombits(binary.LittleEndian.Uint64(score)))
+			}
+		}
+		v = z
+	case dumpHyperLogLog:
+		h := newHyperLogLog()
+		if dense := r.bytes(1); len(dense) == 1 && dense[0] == 1 {
+			h.sparse = nil
+			h.dense = slices.Clone(r.bytes(hllRegisters*hllBits/8 + 1))
+		} else {
+			for n := r.count(); n > 0 && r.err == nil; n-- {
+				register := r.bytes(3)
+				if r.err == nil {
+					h.sparse[binary.LittleEndian.Uint16(register)] = register[2]
+				}
+			}
+		}
+		v = h
+	case dumpJSON:
+		root, err := decodeJSON(string(r.b))
+		if err != nil {
+			return nil, err
+		}
+		v = &jsonDocument{root: root}
+		r.b = nil
+	default:
+		return nil, redigoerr.InvalidDumpPayload
+	}
+	if r.err == nil && len(r.b) != 0 {
+		r.fail()
+	}
+	return v, r.err
+}
@@ -0,0 +1,80 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func TestRestore_Should_Recreate_Every_Type_When_Given_A_Dump(t *testing.T) {
+	src, dst := New(), New()
+	src.Set("string", "Niji\r\n\x00")
+	src.RPush("list", "mouse", "", "laser")
+	src.HSet("hash", "name", "Niji", "age", "3")
+	src.GeoAdd("geo", GeoAddOptions{}, GeoPoint{"Palermo", 13.361389, 38.115556}, GeoPoint{"Catania", 15.087269, 37.502669})
+	src.PFAdd("sparse", "a", "b", "c")
+	for i := range 5000 {
+		src.PFAdd("dense", fmt.Sprint(i))
+	}
+	src.JSONSet("json", "$", `{"name":"Niji","toys":["mouse",1.50]}`, false, false)
+
+	for _, key := range []string{"string", "list", "hash", "geo", "sparse", "dense", "json"} {
+		payload, ok := src.Dump(key)
+		if !ok {
+			t.Fatalf("Unable to dump %s!", key)
+		}
+		if err := dst.Restore(key, payload, false); err != nil {
+			t.Fatalf("An error occurred restoring %s! %v", key, err)
+		}
+		if again, _ := dst.Dump(key); string(again) != string(payload) && key != "sparse" {
+			t.Errorf("Unexpected dump of %s once restored!", key)
+		}
+	}
+	if v, _ := dst.Get("string"); v != "Niji\r\n\x00" {
+		t.Errorf("Unexpected value! %q", v)
+	}
+	if v, _ := dst.LIndex("list", 2); v != "laser" {
+		t.Errorf("Unexpected value! %s", v)
+	}
+	if v, _ := dst.HGet("hash", "age"); v != "3" {
+		t.Errorf("Unexpected value! %s", v)
+	}
+	if points, _ := dst.GeoPos("geo", "Catania"); points[0] == nil || points[0].Member != "Catania" {
+		t.Errorf("Unexpected value! %v", points)
+	}
+	for _, key := range []string{"sparse", "dense"} {
+		expected, _ := src.PFCount(key)
+		if count, _ := dst.PFCount(key); count != expected {
+			t.Errorf("Unexpected count for %s! %d", key, count)
+		}
+	}
+	if v, _ := dst.JSONGet("json", "$.toys"); v != `[["mouse",1.50]]` {
+		t.Errorf("Unexpected value! %s", v)
+	}
+}
+
+func TestRestore_Should_Return_Error_When_Key_Exists_Or_Payload_Is_Invalid(t *testing.T) {
+	cs := New()
+	cs.Set("cat", "Niji")
+	payload, _ := cs.Dump("cat")
+	if err := cs.Restore("cat", payload, false); err == nil || err.(redigoerr.Error).Code != redigoerr.BusyKey.Code {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err := cs.Restore("cat", payload, true); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	payload[1] = 'B'
+	if err := cs.Restore("other", payload, false); err == nil || err.(redigoerr.Error).Code != redigoerr.InvalidDumpPayload.Code {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if err := cs.Restore("other", []byte("Niji"), false); err == nil || err.(redigoerr.Error).Code != redigoerr.InvalidDumpPayload.Code {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, ok := cs.Dump("missing"); ok || cs.Exists("other") {
+		t.Errorf("Unexpected key found!")
+	}
+}
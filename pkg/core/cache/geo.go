@@ -0,0 +1,471 @@
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// Geospatial indexes are sorted sets whose scores are 52 bit geohashes, exactly like in Redis.
+// Latitude is limited to the range covered by Web Mercator (EPSG:3857).
+const (
+	GeoLongitudeMin = -180.0
+	GeoLongitudeMax = 180.0
+	GeoLatitudeMin  = -85.05112878
+	GeoLatitudeMax  = 85.05112878
+
+	geoStepMax          = 26
+	geoEarthRadius      = 6372797.560856
+	geoMercatorMax      = 20037726.37
+	geoHashStringLength = 11
+	geoAlphabet         = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// GeoPoint is a member of a geospatial index with its coordinates
+type GeoPoint struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoAddOptions mirror the NX, XX and CH flags of GEOADD
+type GeoAddOptions struct {
+	NX bool
+	XX bool
+	CH bool
+}
+
+// GeoSort is the order of GeoSearch results
+type GeoSort int
+
+const (
+	GeoUnsorted GeoSort = iota
+	GeoAsc
+	GeoDesc
+)
+
+// GeoQuery describes a GEOSEARCH.
+// The center is either the position of FromMember (when UseMember is true) or Longitude and Latitude.
+// The area is a circle of Radius or, when ByBox is true, a Width x Height box. All sizes are in Unit.
+// A Count of zero means no limit, and Any stops as soon as Count results are found.
+type GeoQuery struct {
+	UseMember  bool
+	FromMember string
+	Longitude  float64
+	Latitude   float64
+	ByBox      bool
+	Radius     float64
+	Width      float64
+	Height     float64
+	Unit       string
+	Sort       GeoSort
+	Count      int
+	Any        bool
+}
+
+// GeoResult is a single match of GeoSearch. Distance is expressed in the unit of the query.
+type GeoResult struct {
+	GeoPoint
+	Distance float64
+	Hash     uint64
+}
+
+// GeoUnitToMeters converts a unit name (m, km, mi or ft) into its size in meters
+func GeoUnitToMeters(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "mi":
+		return 1609.34, nil
+	case "ft":
+		return 0.3048, nil
+	default:
+		err := redigoerr.UnsupportedUnit
+		err.ExtraContext = map[string]string{"unit": unit}
+		return 0, err
+	}
+}
+
+func validCoordinates(longitude float64, latitude float64) error {
+	if longitude < GeoLongitudeMin || longitude > GeoLongitudeMax || latitude < GeoLatitudeMin || latitude > GeoLatitudeMax {
+		err := redigoerr.InvalidLongitudeLatitude
+		err.ExtraContext = map[string]string{"longitude": fmt.Sprintf("%v", longitude), "latitude": fmt.Sprintf("%v", latitude)}
+		return err
+	}
+	return nil
+}
+
+// geoHash is a cell of the grid at a given precision (step), with bits interleaved
+// so that longitude takes the odd positions and latitude the even ones.
+type geoHash struct {
+	bits uint64
+	step uint
+}
+
+type geoArea struct {
+	longitudeMin, longitudeMax float64
+	latitudeMin, latitudeMax   float64
+}
+
+func interleave(latitudeIndex uint32, longitudeIndex uint32) uint64 {
+	var result uint64
+	for i := range 32 {
+		result |= uint64(latitudeIndex>>i&1) << (2 * i)
+		result |= uint64(longitudeIndex>>i&1) << (2*i + 1)
+	}
+	return result
+}
+
+func deinterleave(bits uint64) (uint32, uint32) {
+	var latitudeIndex, longitudeIndex uint32
+	for i := range 32 {
+		latitudeIndex |= uint32(bits>>(2*i)&1) << i
+		longitudeIndex |= uint32(bits>>(2*i+1)&1) << i
+	}
+	return latitudeIndex, longitudeIndex
+}
+
+func geoEncode(longitude float64, latitude float64, latitudeMin float64, latitudeMax float64, step uint) geoHash {
+	cells := float64(uint64(1) << step)
+	index := func(value float64, low float64, high float64) uint32 {
+		offset := (value - low) / (high - low) * cells
+		return uint32(math.Min(offset, cells-1))
+	}
+	return geoHash{
+		bits: interleave(index(latitude, latitudeMin, latitudeMax), index(longitude, GeoLongitudeMin, GeoLongitudeMax)),
+		step: step,
+	}
+}
+
+func (h geoHash) area() geoArea {
+	latitudeIndex, longitudeIndex := deinterleave(h.bits)
+	cells := float64(uint64(1) << h.step)
+	latitudeScale := GeoLatitudeMax - GeoLatitudeMin
+	longitudeScale := GeoLongitudeMax - GeoLongitudeMin
+	return geoArea{
+		longitudeMin: GeoLongitudeMin + float64(longitudeIndex)/cells*longitudeScale,
+		longitudeMax: GeoLongitudeMin + float64(longitudeIndex+1)/cells*longitudeScale,
+		latitudeMin:  GeoLatitudeMin + float64(latitudeIndex)/cells*latitudeScale,
+		latitudeMax:  GeoLatitudeMin + float64(latitudeIndex+1)/cells*latitudeScale,
+	}
+}
+
+// move returns the cell dx columns east and dy rows north of h, wrapping around the grid
+func (h geoHash) move(dx int, dy int) geoHash {
+	latitudeIndex, longitudeIndex := deinterleave(h.bits)
+	mask := uint32(1)<<h.step - 1
+	latitudeIndex = uint32(int64(latitudeIndex)+int64(dy)) & mask
+	longitudeIndex = uint32(int64(longitudeIndex)+int64(dx)) & mask
+	return geoHash{interleave(latitudeIndex, longitudeIndex), h.step}
+}
+
+// scoreRange returns the scores (52 bit hashes) covered by h
+func (h geoHash) scoreRange() (float64, float64) {
+	shift := 2 * (geoStepMax - h.step)
+	return float64(h.bits << shift), float64((h.bits + 1) << shift)
+}
+
+func geoScore(longitude float64, latitude float64) float64 {
+	return float64(geoEncode(longitude, latitude, GeoLatitudeMin, GeoLatitudeMax, geoStepMax).bits)
+}
+
+// geoDecode returns the center of the cell represented by a score
+func geoDecode(score float64) (float64, float64) {
+	area := geoHash{uint64(score), geoStepMax}.area()
+	longitude := math.Max(GeoLongitudeMin, math.Min(GeoLongitudeMax, (area.longitudeMin+area.longitudeMax)/2))
+	latitude := math.Max(GeoLatitudeMin, math.Min(GeoLatitudeMax, (area.latitudeMin+area.latitudeMax)/2))
+	return longitude, latitude
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// geoDistance is the haversine distance in meters between two points
+func geoDistance(longitude1 float64, latitude1 float64, longitude2 float64, latitude2 float64) float64 {
+	lat1, lat2 := degreesToRadians(latitude1), degreesToRadians(latitude2)
+	u := math.Sin((lat2 - lat1) / 2)
+	v := math.Sin((degreesToRadians(longitude2) - degreesToRadians(longitude1)) / 2)
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1)*math.Cos(lat2)*v*v))
+}
+
+// geoStepsByRadius estimates the precision at which a cell and its neighbours cover the radius
+func geoStepsByRadius(radius float64, latitude float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for radius < geoMercatorMax {
+		radius *= 2
+		step++
+	}
+	// Make sure the range is included in most of the base cases
+	step -= 2
+	// Cells get narrower towards the poles
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	return uint(max(1, min(geoStepMax, step)))
+}
+
+// searchCells returns the cells that have to be scanned to find every point inside the area of the query.
+// The area is given by its half width and half height in meters (both equal to the radius for circles).
+func searchCells(longitude float64, latitude float64, halfWidth float64, halfHeight float64, radiusMeters float64) []geoHash {
+	latitudeDelta := radiansToDegrees(halfHeight / geoEarthRadius)
+	longitudeDeltaTop := radiansToDegrees(halfWidth / geoEarthRadius / math.Cos(degreesToRadians(latitude+latitudeDelta)))
+	longitudeDeltaBottom := radiansToDegrees(halfWidth / geoEarthRadius / math.Cos(degreesToRadians(latitude-latitudeDelta)))
+	longitudeDelta := longitudeDeltaTop
+	if latitude < 0 {
+		longitudeDelta = longitudeDeltaBottom
+	}
+	bounds := geoArea{
+		longitudeMin: longitude - longitudeDelta,
+		longitudeMax: longitude + longitudeDelta,
+		latitudeMin:  latitude - latitudeDelta,
+		latitudeMax:  latitude + latitudeDelta,
+	}
+
+	step := geoStepsByRadius(radiusMeters, latitude)
+	center := geoEncode(longitude, latitude, GeoLatitudeMin, GeoLatitudeMax, step)
+	// When the area is near the edge of the neighbours the step may not be small enough
+	north, south, east, west := center.move(0, 1).area(), center.move(0, -1).area(), center.move(1, 0).area(), center.move(-1, 0).area()
+	if step > 1 && (north.latitudeMax < bounds.latitudeMax || south.latitudeMin > bounds.latitudeMin ||
+		east.longitudeMax < bounds.longitudeMax || west.longitudeMin > bounds.longitudeMin) {
+		step--
+		center = geoEncode(longitude, latitude, GeoLatitudeMin, GeoLatitudeMax, step)
+	}
+
+	// Exclude neighbours that cannot contain anything
+	area := center.area()
+	cells := []geoHash{}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if step >= 2 && ((dy == -1 && area.latitudeMin < bounds.latitudeMin) ||
+				(dy == 1 && area.latitudeMax > bounds.latitudeMax) ||
+				(dx == -1 && area.longitudeMin < bounds.longitudeMin) ||
+				(dx == 1 && area.longitudeMax > bounds.longitudeMax)) {
+				continue
+			}
+			cell := center.move(dx, dy)
+			if !slices.Contains(cells, cell) {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+func (c *Cache) GeoAdd(key string, options GeoAddOptions, points ...GeoPoint) (int, error) {
+	if options.NX && options.XX {
+		return 0, redigoerr.SyntaxError
+	}
+	for _, point := range points {
+		if err := validCoordinates(point.Longitude, point.Latitude); err != nil {
+			return 0, err
+		}
+	}
+	z, err := c.sortedSetOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if z == nil {
+		if options.XX {
+			return 0, nil
+		}
+		z = newSortedSet()
+		c.dict[key] = z
+	}
+
+	changed := 0
+	for _, point := range points {
+		score := geoScore(point.Longitude, point.Latitude)
+		previous, present := z.score(point.Member)
+		if (present && options.NX) || (!present && options.XX) {
+			continue
+		}
+		if !present || (options.CH && previous != score) {
+			changed++
+		}
+		z.add(point.Member, score)
+	}
+	if z.len() == 0 {
+		delete(c.dict, key)
+	}
+	return changed, nil
+}
+
+// GeoPos returns the position of each member, or nil if not present
+func (c *Cache) GeoPos(key string, members ...string) ([]*GeoPoint, error) {
+	z, err := c.sortedSetOf(key)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*GeoPoint, len(members))
+	if z == nil {
+		return points, nil
+	}
+	for i, member := range members {
+		if score, ok := z.score(member); ok {
+			longitude, latitude := geoDecode(score)
+			points[i] = &GeoPoint{member, longitude, latitude}
+		}
+	}
+	return points, nil
+}
+
+// GeoDist returns the distance between two members in the given unit.
+// The boolean is false if any of them is not present.
+func (c *Cache) GeoDist(key string, member1 string, member2 string, unit string) (float64, bool, error) {
+	conversion, err := GeoUnitToMeters(unit)
+	if err != nil {
+		return 0, false, err
+	}
+	points, err := c.GeoPos(key, member1, member2)
+	if err != nil || points[0] == nil || points[1] == nil {
+		return 0, false, err
+	}
+	return geoDistance(points[0].Longitude, points[0].Latitude, points[1].Longitude, points[1].Latitude) / conversion, true, nil
+}
+
+// GeoHash returns the standard 11 characters geohash of each member, or nil if not present
+func (c *Cache) GeoHash(key string, members ...string) ([]*string, error) {
+	points, err := c.GeoPos(key, members...)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]*string, len(points))
+	for i, point := range points {
+		if point == nil {
+			continue
+		}
+		// Internally latitudes go from -85 to 85, standard geohashes use -90 to 90
+		hash := geoEncode(point.Longitude, point.Latitude, -90, 90, geoStepMax)
+		hashString := make([]byte, geoHashStringLength)
+		for j := range hashString {
+			index := 0
+			if j < geoHashStringLength-1 {
+				index = int(hash.bits>>(52-(j+1)*5)) & 0x1f
+			}
+			hashString[j] = geoAlphabet[index]
+		}
+		s := string(hashString)
+		hashes[i] = &s
+	}
+	return hashes, nil
+}
+
+func (c *Cache) GeoSearch(key string, query GeoQuery) ([]GeoResult, error) {
+	conversion, err := GeoUnitToMeters(query.Unit)
+	if err != nil {
+		return nil, err
+	}
+	z, err := c.sortedSetOf(key)
+	if err != nil || z == nil {
+		return []GeoResult{}, err
+	}
+
+	longitude, latitude := query.Longitude, query.Latitude
+	if query.UseMember {
+		score, ok := z.score(query.FromMember)
+		if !ok {
+			err := redigoerr.GeoMemberNotFound
+			err.ExtraContext = map[string]string{"member": query.FromMember}
+			return nil, err
+		}
+		longitude, latitude = geoDecode(score)
+	} else if err := validCoordinates(longitude, latitude); err != nil {
+		return nil, err
+	}
+
+	var cells []geoHash
+	if query.ByBox {
+		width, height := query.Width*conversion, query.Height*conversion
+		cells = searchCells(longitude, latitude, width/2, height/2, math.Sqrt(width*width+height*height)/2)
+	} else {
+		radius := query.Radius * conversion
+		cells = searchCells(longitude, latitude, radius, radius, radius)
+	}
+
+	results := []GeoResult{}
+search:
+	for _, cell := range cells {
+		lowScore, highScore := cell.scoreRange()
+		for _, entry := range z.rangeByScore(lowScore, highScore) {
+			pointLongitude, pointLatitude := geoDecode(entry.score)
+			var distance float64
+			if query.ByBox {
+				// Latitude distance is cheaper, check it first
+				if geoEarthRadius*math.Abs(degreesToRadians(pointLatitude)-degreesToRadians(latitude)) > query.Height*conversion/2 ||
+					geoDistance(longitude, pointLatitude, pointLongitude, pointLatitude) > query.Width*conversion/2 {
+					continue
+				}
+				distance = geoDistance(longitude, latitude, pointLongitude, pointLatitude)
+			} else {
+				distance = geoDistance(longitude, latitude, pointLongitude, pointLatitude)
+				if distance > query.Radius*conversion {
+					continue
+				}
+			}
+			results = append(results, GeoResult{
+				GeoPoint: GeoPoint{entry.member, pointLongitude, pointLatitude},
+				Distance: distance / conversion,
+				Hash:     uint64(entry.score),
+			})
+			if query.Any && query.Count > 0 && len(results) == query.Count {
+				break search
+			}
+		}
+	}
+
+	// A COUNT without ANY returns the closest results
+	sortOrder := query.Sort
+	if sortOrder == GeoUnsorted && query.Count > 0 && !query.Any {
+		sortOrder = GeoAsc
+	}
+	switch sortOrder {
+	case GeoAsc:
+		slices.SortStableFunc(results, func(a GeoResult, b GeoResult) int { return cmp.Compare(a.Distance, b.Distance) })
+	case GeoDesc:
+		slices.SortStableFunc(results, func(a GeoResult, b GeoResult) int { return cmp.Compare(b.Distance, a.Distance) })
+	}
+	if query.Count > 0 && len(results) > query.Count {
+		results = results[:query.Count]
+	}
+	return results, nil
+}
+
+// GeoSearchStore saves the results of a search in dest, using distances as scores when storeDist is true.
+// It returns the number of elements stored.
+func (c *Cache) GeoSearchStore(dest string, key string, query GeoQuery, storeDist bool) (int, error) {
+	results, err := c.GeoSearch(key, query)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		delete(c.dict, dest)
+		return 0, nil
+	}
+	z := newSortedSet()
+	for _, result := range results {
+		if storeDist {
+			z.add(result.Member, result.Distance)
+		} else {
+			z.add(result.Member, float64(result.Hash))
+		}
+	}
+	c.dict[dest] = z
+	return z.len(), nil
+}
@@ -0,0 +1,164 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func newSicily() *Cache {
+	cs := New()
+	cs.GeoAdd("Sicily", GeoAddOptions{},
+		GeoPoint{"Palermo", 13.361389, 38.115556},
+		GeoPoint{"Catania", 15.087269, 37.502669},
+	)
+	return cs
+}
+
+func TestGeoAdd_Should_Return_Added_Or_Changed_Members_Depending_On_Flags(t *testing.T) {
+	cs := newSicily()
+	if n, err := cs.GeoAdd("Sicily", GeoAddOptions{}, GeoPoint{"Palermo", 13, 38}); err != nil || n != 0 {
+		t.Errorf("Updating a member should not count as added! %v - %d", err, n)
+	}
+	if n, err := cs.GeoAdd("Sicily", GeoAddOptions{CH: true}, GeoPoint{"Palermo", 13.361389, 38.115556}); err != nil || n != 1 {
+		t.Errorf("Changed member was not counted! %v - %d", err, n)
+	}
+	if n, err := cs.GeoAdd("Sicily", GeoAddOptions{XX: true}, GeoPoint{"Agrigento", 13.583333, 37.316667}); err != nil || n != 0 {
+		t.Errorf("XX should not add new members! %v - %d", err, n)
+	}
+	if n, err := cs.GeoAdd("Sicily", GeoAddOptions{NX: true}, GeoPoint{"Agrigento", 13.583333, 37.316667}); err != nil || n != 1 {
+		t.Errorf("NX should add new members! %v - %d", err, n)
+	}
+	if _, err := cs.GeoAdd("Sicily", GeoAddOptions{}, GeoPoint{"North Pole", 0, 90}); err == nil {
+		t.Errorf("Expected error for invalid latitude but obtained nil!")
+	}
+}
+
+func TestGeoPos_Should_Return_Decoded_Coordinates_When_Member_Is_Present(t *testing.T) {
+	cs := newSicily()
+	points, err := cs.GeoPos("Sicily", "Palermo", "Atlantis")
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if points[0] == nil || math.Abs(points[0].Longitude-13.361389) > 1e-5 || math.Abs(points[0].Latitude-38.115556) > 1e-5 {
+		t.Errorf("Unexpected position! %v", points[0])
+	}
+	if points[1] != nil {
+		t.Errorf("Missing member should have no position! %v", points[1])
+	}
+}
+
+func TestGeoDist_Should_Return_Distance_In_Requested_Unit(t *testing.T) {
+	cs := newSicily()
+	expected := map[string]string{"m": "166274.1516", "km": "166.2742", "mi": "103.3182", "ft": "545518.8700"}
+	for unit, distance := range expected {
+		d, found, err := cs.GeoDist("Sicily", "Palermo", "Catania", unit)
+		if err != nil || !found || fmt.Sprintf("%.4f", d) != distance {
+			t.Errorf("Unexpected distance in %s! %v - %.4f", unit, err, d)
+		}
+	}
+	if _, found, err := cs.GeoDist("Sicily", "Palermo", "Atlantis", "m"); err != nil || found {
+		t.Errorf("Distance to missing member should not be found! %v", err)
+	}
+	if _, _, err := cs.GeoDist("Sicily", "Palermo", "Catania", "parsec"); err == nil {
+		t.Errorf("Expected error for unsupported unit but obtained nil!")
+	}
+}
+
+func TestGeoHash_Should_Return_Standard_Geohash_Strings(t *testing.T) {
+	cs := newSicily()
+	hashes, err := cs.GeoHash("Sicily", "Palermo", "Catania", "Atlantis")
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if hashes[0] == nil || *hashes[0] != "sqc8b49rny0" {
+		t.Errorf("Unexpected geohash for Palermo! %v", hashes[0])
+	}
+	if hashes[1] == nil || *hashes[1] != "sqdtr74hyu0" {
+		t.Errorf("Unexpected geohash for Catania! %v", hashes[1])
+	}
+	if hashes[2] != nil {
+		t.Errorf("Missing member should have no geohash! %v", *hashes[2])
+	}
+}
+
+func TestGeoSearch_Should_Return_Members_Inside_Radius_Sorted(t *testing.T) {
+	cs := newSicily()
+	results, err := cs.GeoSearch("Sicily", GeoQuery{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km", Sort: GeoAsc})
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if len(results) != 2 || results[0].Member != "Catania" || results[1].Member != "Palermo" {
+		t.Errorf("Unexpected results! %v", results)
+	}
+	if fmt.Sprintf("%.4f", results[0].Distance) != "56.4413" || fmt.Sprintf("%.4f", results[1].Distance) != "190.4424" {
+		t.Errorf("Unexpected distances! %v", results)
+	}
+	results, _ = cs.GeoSearch("Sicily", GeoQuery{Longitude: 15, Latitude: 37, Radius: 100, Unit: "km"})
+	if len(results) != 1 || results[0].Member != "Catania" {
+		t.Errorf("Unexpected results for smaller radius! %v", results)
+	}
+}
+
+func TestGeoSearch_Should_Return_Members_Inside_Box_Limited_By_Count(t *testing.T) {
+	cs := newSicily()
+	cs.GeoAdd("Sicily", GeoAddOptions{}, GeoPoint{"edge1", 12.758489, 38.788135}, GeoPoint{"edge2", 17.241510, 38.788135})
+	results, err := cs.GeoSearch("Sicily", GeoQuery{Longitude: 15, Latitude: 37, ByBox: true, Width: 400, Height: 400, Unit: "km", Sort: GeoDesc})
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if len(results) != 4 || results[0].Member != "edge1" || results[3].Member != "Catania" {
+		t.Errorf("Unexpected results! %v", results)
+	}
+	results, _ = cs.GeoSearch("Sicily", GeoQuery{UseMember: true, FromMember: "Catania", ByBox: true, Width: 400, Height: 400, Unit: "km", Count: 2})
+	if len(results) != 2 || results[0].Member != "Catania" || results[0].Distance != 0 {
+		t.Errorf("Unexpected results with count! %v", results)
+	}
+	if _, err := cs.GeoSearch("Sicily", GeoQuery{UseMember: true, FromMember: "Atlantis", Radius: 1, Unit: "m"}); err == nil {
+		t.Errorf("Expected error for missing member but obtained nil!")
+	}
+}
+
+func TestGeoSearch_Should_Find_Every_Close_Point_When_Index_Is_Dense(t *testing.T) {
+	cs := New()
+	points := []GeoPoint{}
+	for i := range 50 {
+		for j := range 50 {
+			points = append(points, GeoPoint{fmt.Sprintf("driver:%d:%d", i, j), -99.2 + float64(i)*0.004, 19.3 + float64(j)*0.004})
+		}
+	}
+	cs.GeoAdd("drivers", GeoAddOptions{}, points...)
+	center := GeoPoint{Longitude: -99.1, Latitude: 19.4}
+	results, err := cs.GeoSearch("drivers", GeoQuery{Longitude: center.Longitude, Latitude: center.Latitude, Radius: 1.5, Unit: "km"})
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	// Brute force the expected amount to make sure no cell was skipped
+	expected := 0
+	for _, point := range points {
+		if geoDistance(center.Longitude, center.Latitude, point.Longitude, point.Latitude) <= 1500 {
+			expected++
+		}
+	}
+	if len(results) != expected || expected == 0 {
+		t.Errorf("Unexpected number of results! %d != %d", len(results), expected)
+	}
+}
+
+func TestGeoSearchStore_Should_Store_Results_As_Sorted_Set(t *testing.T) {
+	cs := newSicily()
+	n, err := cs.GeoSearchStore("Near", "Sicily", GeoQuery{Longitude: 15, Latitude: 37, Radius: 100, Unit: "km"}, false)
+	if err != nil || n != 1 {
+		t.Errorf("An error occurred! %v - %d", err, n)
+	}
+	if points, _ := cs.GeoPos("Near", "Catania"); points[0] == nil {
+		t.Errorf("Stored member should keep its position!")
+	}
+	n, _ = cs.GeoSearchStore("Near", "Sicily", GeoQuery{Longitude: 0, Latitude: 0, Radius: 1, Unit: "km"}, true)
+	if _, err := cs.Get("Near"); n != 0 || err == nil {
+		t.Errorf("Empty results should delete the destination! %v - %d", err, n)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"slices"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// hash maps fields to values, like a small dictionary stored under a single key
+type hash map[string]string
+
+func (c *Cache) hashOf(key string) (hash, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	vAsHash, ok := v.(hash)
+	if !ok {
+		return nil, redigoerr.WrongType
+	}
+	return vAsHash, nil
+}
+
+// HSet sets fields of the hash at key, given as field value pairs, creating it if needed.
+// It returns the amount of fields that did not exist before.
+func (c *Cache) HSet(key string, pairs ...string) (int, error) {
+	h, err := c.hashOf(key)
+	if err != nil {
+		return 0, err
+	}
+	if h == nil {
+		h = make(hash, len(pairs)/2)
+		c.dict[key] = h
+	}
+	added := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if _, ok := h[pairs[i]]; !ok {
+			added++
+		}
+		h[pairs[i]] = pairs[i+1]
+	}
+	return added, nil
+}
+
+// HGet returns the value of a field, failing with redigoerr.KeyNotFoundInDictionary when either the key
+// or the field do not exist
+func (c *Cache) HGet(key string, field string) (string, error) {
+	h, err := c.hashOf(key)
+	if err != nil {
+		return "", err
+	}
+	value, ok := h[field]
+	if !ok {
+		err := redigoerr.KeyNotFoundInDictionary
+		err.ExtraContext = map[string]string{"key": key, "field": field}
+		return "", err
+	}
+	return value, nil
+}
+
+// HGetAll returns every field and value of the hash at key as pairs, ordered by field.
+// An absent key is an empty hash.
+func (c *Cache) HGetAll(key string) ([]string, error) {
+	h, err := c.hashOf(key)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	pairs := make([]string, 0, 2*len(h))
+	for _, field := range fields {
+		pairs = append(pairs, field, h[field])
+	}
+	return pairs, nil
+}
+
+// HDel removes fields of the hash at key, removing the key once it has none.
+// It returns the amount of fields removed.
+func (c *Cache) HDel(key string, fields ...string) (int, error) {
+	h, err := c.hashOf(key)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, field := range fields {
+		if _, ok := h[field]; ok {
+			delete(h, field)
+			removed++
+		}
+	}
+	if h != nil && len(h) == 0 {
+		delete(c.dict, key)
+	}
+	return removed, nil
+}
+
+// HLen returns the amount of fields of the hash at key
+func (c *Cache) HLen(key string) (int, error) {
+	h, err := c.hashOf(key)
+	if err != nil {
+		return 0, err
+	}
+	return len(h), nil
+}
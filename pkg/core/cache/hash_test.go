@@ -0,0 +1,57 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func TestHSet_Should_Count_Only_New_Fields_When_Some_Already_Exist(t *testing.T) {
+	cs := New()
+	if added, err := cs.HSet("CAT", "name", "Niji", "age", "3"); err != nil || added != 2 {
+		t.Errorf("Unexpected value! %d - %v", added, err)
+	}
+	if added, err := cs.HSet("CAT", "age", "4", "color", "black"); err != nil || added != 1 {
+		t.Errorf("Unexpected value! %d - %v", added, err)
+	}
+	if v, err := cs.HGet("CAT", "age"); err != nil || v != "4" {
+		t.Errorf("Unexpected value! %s - %v", v, err)
+	}
+	if _, err := cs.HGet("CAT", "owner"); !redigoerr.KeyNotFound(err) {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	pairs, err := cs.HGetAll("CAT")
+	if err != nil || !slices.Equal(pairs, []string{"age", "4", "color", "black", "name", "Niji"}) {
+		t.Errorf("Unexpected value! %v - %v", pairs, err)
+	}
+	if stats := cs.Stats(); stats.KeysByType["hash"] != 1 {
+		t.Errorf("Unexpected stats! %v", stats)
+	}
+}
+
+func TestHDel_Should_Delete_Hash_When_Last_Field_Is_Removed(t *testing.T) {
+	cs := New()
+	cs.HSet("CAT", "name", "Niji", "age", "3")
+	if removed, err := cs.HDel("CAT", "name", "owner"); err != nil || removed != 1 {
+		t.Errorf("Unexpected value! %d - %v", removed, err)
+	}
+	if n, err := cs.HLen("CAT"); err != nil || n != 1 {
+		t.Errorf("Unexpected value! %d - %v", n, err)
+	}
+	cs.HDel("CAT", "age")
+	if pairs, err := cs.HGetAll("CAT"); err != nil || len(pairs) != 0 || len(cs.dict) != 0 {
+		t.Errorf("Unexpected value! %v - %v", pairs, err)
+	}
+}
+
+func TestHSet_Should_Return_Error_When_Key_Is_Not_A_Hash(t *testing.T) {
+	cs := New()
+	cs.Set("CAT", "Niji")
+	if _, err := cs.HSet("CAT", "name", "Niji"); err == nil || err.(redigoerr.Error).Code != redigoerr.WrongType.Code {
+		t.Errorf("Unexpected error! %v", err)
+	}
+}
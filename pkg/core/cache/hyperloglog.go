@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// HyperLogLog parameters are the same as the ones used by Redis, which gives
+// a standard error of 1.04/sqrt(16384) = 0.81%.
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllPMask     = hllRegisters - 1
+	hllBits      = 6
+	hllMaxValue  = 1<<hllBits - 1
+	hllAlphaInf  = 0.721347520444481703680 // 0.5/ln(2)
+	hllHashSeed  = 0xadc83b19
+)
+
+// A sparse HyperLogLog is promoted to the dense representation after holding this many registers,
+// which is roughly where it stops being cheaper, or when a register value is bigger than what Redis
+// allows in its sparse encoding.
+const (
+	hllSparseMaxRegisters = 1024
+	hllSparseMaxValue     = 32
+)
+
+// hyperLogLog is a probabilistic counter of unique elements.
+//
+// It starts with a sparse representation holding only non-zero registers, which is very cheap for small cardinalities,
+// and is promoted to a dense one (16384 registers of 6 bits packed in 12KB) when it grows.
+type hyperLogLog struct {
+	sparse map[uint16]uint8
+	dense  []byte
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{sparse: make(map[uint16]uint8)}
+}
+
+func (h *hyperLogLog) isSparse() bool {
+	return h.dense == nil
+}
+
+func (h *hyperLogLog) register(index uint16) uint8 {
+	if h.isSparse() {
+		return h.sparse[index]
+	}
+	pos := int(index) * hllBits / 8
+	shift := uint(index) * hllBits & 7
+	return uint8((uint16(h.dense[pos])>>shift | uint16(h.dense[pos+1])<<(8-shift)) & hllMaxValue)
+}
+
+func (h *hyperLogLog) setRegister(index uint16, value uint8) {
+	if h.isSparse() {
+		_, present := h.sparse[index]
+		if value > hllSparseMaxValue || (!present && len(h.sparse) >= hllSparseMaxRegisters) {
+			h.promote()
+		} else {
+			h.sparse[index] = value
+			return
+		}
+	}
+	pos := int(index) * hllBits / 8
+	shift := uint(index) * hllBits & 7
+	h.dense[pos] &^= byte(hllMaxValue << shift)
+	h.dense[pos] |= byte(uint16(value) << shift)
+	h.dense[pos+1] &^= byte(hllMaxValue >> (8 - shift))
+	h.dense[pos+1] |= byte(uint16(value) >> (8 - shift))
+}
+
+// promote converts a sparse representation into a dense one
+func (h *hyperLogLog) promote() {
+	// One extra byte so that the last register can always be read as two bytes
+	h.dense = make([]byte, hllRegisters*hllBits/8+1)
+	sparse := h.sparse
+	h.sparse = nil
+	for index, value := range sparse {
+		h.setRegister(index, value)
+	}
+}
+
+// add registers the element and returns true if any register was modified
+func (h *hyperLogLog) add(element string) bool {
+	hash := murmurHash64A([]byte(element), hllHashSeed)
+	index := uint16(hash & hllPMask)
+	// Position of the first set bit in the remaining Q bits, the extra bit guarantees termination
+	count := uint8(bits.TrailingZeros64(hash>>hllP|1<<hllQ) + 1)
+	if count > h.register(index) {
+		h.setRegister(index, count)
+		return true
+	}
+	return false
+}
+
+// merge makes every register of h the maximum between itself and the one in other
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	if other.isSparse() {
+		for index, value := range other.sparse {
+			if value > h.register(index) {
+				h.setRegister(index, value)
+			}
+		}
+		return
+	}
+	for i := range hllRegisters {
+		index := uint16(i)
+		if value := other.register(index); value > h.register(index) {
+			h.setRegister(index, value)
+		}
+	}
+}
+
+// count estimates the cardinality using the improved estimator by Otmar Ertl,
+// the same one Redis has been using since version 5.
+//
+// See https://arxiv.org/abs/1702.01284
+func (h *hyperLogLog) count() int {
+	histogram := [hllQ + 2]int{}
+	if h.isSparse() {
+		histogram[0] = hllRegisters - len(h.sparse)
+		for _, value := range h.sparse {
+			histogram[value]++
+		}
+	} else {
+		for i := range hllRegisters {
+			histogram[h.register(uint16(i))]++
+		}
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return int(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64 bit hash function used by Redis for HyperLogLogs
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+	h := seed ^ (uint64(len(data)) * m)
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+func (c *Cache) hyperLogLogOf(key string) (*hyperLogLog, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	vAsHLL, ok := v.(*hyperLogLog)
+	if !ok {
+		return nil, redigoerr.WrongType
+	}
+	return vAsHLL, nil
+}
+
+// PFAdd adds elements to the HyperLogLog at key, creating it if needed.
+// It returns true if the estimated cardinality may have changed.
+func (c *Cache) PFAdd(key string, elements ...string) (bool, error) {
+	h, err := c.hyperLogLogOf(key)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	if h == nil {
+		h = newHyperLogLog()
+		c.dict[key] = h
+		changed = true
+	}
+	for _, element := range elements {
+		if h.add(element) {
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// PFCount estimates the cardinality of the union of the HyperLogLogs at the given keys.
+// Missing keys count as empty sets.
+func (c *Cache) PFCount(keys ...string) (int, error) {
+	if len(keys) == 1 {
+		h, err := c.hyperLogLogOf(keys[0])
+		if err != nil || h == nil {
+			return 0, err
+		}
+		return h.count(), nil
+	}
+	union := newHyperLogLog()
+	for _, key := range keys {
+		h, err := c.hyperLogLogOf(key)
+		if err != nil {
+			return 0, err
+		}
+		if h != nil {
+			union.merge(h)
+		}
+	}
+	return union.count(), nil
+}
+
+// PFMerge stores at dest the union of dest (if present) and every source key
+func (c *Cache) PFMerge(dest string, keys ...string) error {
+	sources := make([]*hyperLogLog, 0, len(keys))
+	for _, key := range keys {
+		h, err := c.hyperLogLogOf(key)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			sources = append(sources, h)
+		}
+	}
+	h, err := c.hyperLogLogOf(dest)
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		h = newHyperLogLog()
+		c.dict[dest] = h
+	}
+	for _, source := range sources {
+		if source != h {
+			h.merge(source)
+		}
+	}
+	return nil
+}
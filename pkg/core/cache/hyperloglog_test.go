@@ -0,0 +1,114 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// Three times the standard error of a HyperLogLog with 16384 registers
+const hllTolerance = 3 * 0.0081
+
+func TestPFCount_Should_Estimate_Within_Error_Bounds_When_Given_Large_Inputs(t *testing.T) {
+	for _, cardinality := range []int{100, 1000, 10000, 100000, 1000000} {
+		cs := New()
+		key := fmt.Sprintf("VISITORS%d", cardinality)
+		for i := range cardinality {
+			cs.PFAdd(key, fmt.Sprintf("user:%d", i))
+		}
+		count, err := cs.PFCount(key)
+		if err != nil {
+			t.Errorf("An error occurred! %v", err)
+		}
+		relativeError := math.Abs(float64(count)-float64(cardinality)) / float64(cardinality)
+		if relativeError > hllTolerance {
+			t.Errorf("Estimate out of bounds! cardinality = %d - estimate = %d - error = %f", cardinality, count, relativeError)
+		}
+	}
+}
+
+func TestPFAdd_Should_Return_False_When_Element_Was_Already_Added(t *testing.T) {
+	cs := New()
+	if changed, err := cs.PFAdd("KEY", "NIJI", "ANUBIS"); err != nil || !changed {
+		t.Errorf("Expected a change! %v - %v", err, changed)
+	}
+	if changed, err := cs.PFAdd("KEY", "NIJI"); err != nil || changed {
+		t.Errorf("Unexpected change! %v - %v", err, changed)
+	}
+	if count, err := cs.PFCount("KEY"); err != nil || count != 2 {
+		t.Errorf("Unexpected count! %v - %d", err, count)
+	}
+}
+
+func TestPFAdd_Should_Promote_To_Dense_When_Sparse_Grows(t *testing.T) {
+	cs := New()
+	cs.PFAdd("KEY", "REDIGO")
+	if h, _ := cs.hyperLogLogOf("KEY"); !h.isSparse() {
+		t.Errorf("HyperLogLog should start as sparse!")
+	}
+	for i := range 5000 {
+		cs.PFAdd("KEY", fmt.Sprintf("%d", i))
+	}
+	h, _ := cs.hyperLogLogOf("KEY")
+	if h.isSparse() {
+		t.Errorf("HyperLogLog should have been promoted to dense!")
+	}
+	if h.register(0) > hllMaxValue {
+		t.Errorf("Unexpected register value! %d", h.register(0))
+	}
+}
+
+func TestHyperLogLog_Dense_Registers_Should_Not_Overlap(t *testing.T) {
+	h := newHyperLogLog()
+	h.promote()
+	for i := range hllRegisters {
+		h.setRegister(uint16(i), uint8(i%(hllMaxValue+1)))
+	}
+	for i := range hllRegisters {
+		if value := h.register(uint16(i)); value != uint8(i%(hllMaxValue+1)) {
+			t.Errorf("Register %d holds %d!", i, value)
+		}
+	}
+}
+
+func TestPFCount_Should_Estimate_Union_When_Given_Multiple_Keys(t *testing.T) {
+	cs := New()
+	for i := range 20000 {
+		cs.PFAdd("A", fmt.Sprintf("%d", i))
+	}
+	for i := 10000; i < 10500; i++ {
+		cs.PFAdd("B", fmt.Sprintf("%d", i))
+	}
+	for i := 20000; i < 20100; i++ {
+		cs.PFAdd("B", fmt.Sprintf("%d", i))
+	}
+	count, err := cs.PFCount("A", "B", "MISSING")
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if math.Abs(float64(count)-20100)/20100 > hllTolerance {
+		t.Errorf("Estimate out of bounds! %d", count)
+	}
+}
+
+func TestPFMerge_Should_Store_Union_In_Destination(t *testing.T) {
+	cs := New()
+	for i := range 3000 {
+		cs.PFAdd("A", fmt.Sprintf("a%d", i))
+		cs.PFAdd("B", fmt.Sprintf("b%d", i))
+	}
+	if err := cs.PFMerge("DEST", "A", "B"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	count, _ := cs.PFCount("DEST")
+	if math.Abs(float64(count)-6000)/6000 > hllTolerance {
+		t.Errorf("Estimate out of bounds! %d", count)
+	}
+	cs.Set("STRING", "REDIGO")
+	if err := cs.PFMerge("DEST", "STRING"); err == nil {
+		t.Errorf("Expected error but obtained nil!")
+	}
+}
@@ -0,0 +1,780 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// jsonDocument is the value stored for JSON keys. Keeping the root behind a pointer
+// allows replacing the whole document without touching the dictionary.
+//
+// Values inside the document are *jsonObject, *jsonArray, string, json.Number, bool or nil.
+type jsonDocument struct {
+	root any
+}
+
+// jsonObject is a JSON object that remembers the insertion order of its keys
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+type jsonArray struct {
+	items []any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{keys: []string{}, values: make(map[string]any)}
+}
+
+func (o *jsonObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) remove(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	o.keys = slices.DeleteFunc(o.keys, func(k string) bool { return k == key })
+	return true
+}
+
+func invalidJSON(err error) error {
+	redigoError := redigoerr.InvalidJSON
+	redigoError.From = err
+	return redigoError
+}
+
+// decodeJSON parses a single JSON value, preserving key order and number representation
+func decodeJSON(s string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	v, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, invalidJSON(err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, invalidJSON(err)
+	}
+	return v, nil
+}
+
+func decodeJSONValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		obj := newJSONObject()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), value)
+		}
+		_, err := decoder.Token()
+		return obj, err
+	case '[':
+		arr := &jsonArray{items: []any{}}
+		for decoder.More() {
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			arr.items = append(arr.items, value)
+		}
+		_, err := decoder.Token()
+		return arr, err
+	default:
+		return nil, invalidJSON(nil)
+	}
+}
+
+// encodeJSON serializes a value in its compact form
+func encodeJSON(v any) string {
+	b := &strings.Builder{}
+	writeJSON(b, v)
+	return b.String()
+}
+
+func writeJSON(b *strings.Builder, v any) {
+	switch value := v.(type) {
+	case *jsonObject:
+		b.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, key)
+			b.WriteByte(':')
+			writeJSON(b, value.values[key])
+		}
+		b.WriteByte('}')
+	case *jsonArray:
+		b.WriteByte('[')
+		for i, item := range value.items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSON(b, item)
+		}
+		b.WriteByte(']')
+	case string:
+		writeJSONString(b, value)
+	case json.Number:
+		b.WriteString(string(value))
+	case bool:
+		b.WriteString(strconv.FormatBool(value))
+	default:
+		b.WriteString("null")
+	}
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	// Encoding a string never fails
+	encoder.Encode(s)
+	b.Write(bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}))
+}
+
+func cloneJSON(v any) any {
+	switch value := v.(type) {
+	case *jsonObject:
+		obj := newJSONObject()
+		for _, key := range value.keys {
+			obj.set(key, cloneJSON(value.values[key]))
+		}
+		return obj
+	case *jsonArray:
+		arr := &jsonArray{items: make([]any, len(value.items))}
+		for i, item := range value.items {
+			arr.items[i] = cloneJSON(item)
+		}
+		return arr
+	default:
+		return v
+	}
+}
+
+func jsonTypeName(v any) string {
+	switch value := v.(type) {
+	case *jsonObject:
+		return "object"
+	case *jsonArray:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+type jsonStepKind int
+
+const (
+	jsonStepField jsonStepKind = iota
+	jsonStepIndex
+	jsonStepWildcard
+)
+
+// jsonPathStep is a single selector of a path. Recursive steps apply to every descendant.
+type jsonPathStep struct {
+	kind      jsonStepKind
+	name      string
+	index     int
+	recursive bool
+}
+
+// jsonPath is a parsed JSONPath. Paths starting with '$' return every match, while legacy
+// paths (such as '.' or 'a.b[0]') work only on the first one, as in RedisJSON.
+type jsonPath struct {
+	legacy bool
+	steps  []jsonPathStep
+}
+
+// IsLegacyJSONPath tells if a path is in the legacy syntax, which replies with a single value instead of an array of matches
+func IsLegacyJSONPath(path string) bool {
+	return !strings.HasPrefix(path, "$")
+}
+
+// parseJSONPath understands the following subset of JSONPath: $, .name, ['name'], [index], [*], .* and recursive descent (..)
+func parseJSONPath(path string) (jsonPath, error) {
+	p := jsonPath{legacy: IsLegacyJSONPath(path), steps: []jsonPathStep{}}
+	invalid := redigoerr.InvalidJSONPath
+	invalid.ExtraContext = map[string]string{"path": path}
+
+	rest := path
+	switch {
+	case !p.legacy:
+		rest = path[1:]
+	case path == ".":
+		rest = ""
+	case !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "["):
+		rest = "." + path
+	}
+
+	recursive := false
+	for i := 0; i < len(rest); {
+		switch rest[i] {
+		case '.':
+			i++
+			if i < len(rest) && rest[i] == '.' {
+				recursive = true
+				i++
+				if i < len(rest) && rest[i] == '[' {
+					continue
+				}
+			}
+			if i < len(rest) && rest[i] == '*' {
+				p.steps = append(p.steps, jsonPathStep{kind: jsonStepWildcard, recursive: recursive})
+				i++
+			} else {
+				end := i
+				for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+					end++
+				}
+				if end == i {
+					return p, invalid
+				}
+				p.steps = append(p.steps, jsonPathStep{kind: jsonStepField, name: rest[i:end], recursive: recursive})
+				i = end
+			}
+			recursive = false
+		case '[':
+			i++
+			if i >= len(rest) {
+				return p, invalid
+			}
+			step := jsonPathStep{recursive: recursive}
+			switch rest[i] {
+			case '\'', '"':
+				end := strings.IndexByte(rest[i+1:], rest[i])
+				if end < 0 {
+					return p, invalid
+				}
+				step.kind, step.name = jsonStepField, rest[i+1:i+1+end]
+				i += end + 2
+			case '*':
+				step.kind = jsonStepWildcard
+				i++
+			default:
+				end := strings.IndexByte(rest[i:], ']')
+				if end < 0 {
+					return p, invalid
+				}
+				index, err := strconv.Atoi(strings.TrimSpace(rest[i : i+end]))
+				if err != nil {
+					return p, invalid
+				}
+				step.kind, step.index = jsonStepIndex, index
+				i += end
+			}
+			if i >= len(rest) || rest[i] != ']' {
+				return p, invalid
+			}
+			i++
+			p.steps = append(p.steps, step)
+			recursive = false
+		default:
+			return p, invalid
+		}
+	}
+	if recursive {
+		return p, invalid
+	}
+	return p, nil
+}
+
+// jsonMatch is a value found by a path together with the place it is stored at, so that it can be replaced or removed
+type jsonMatch struct {
+	parent any
+	key    string
+	index  int
+	value  any
+}
+
+func (m jsonMatch) replace(doc *jsonDocument, value any) {
+	switch parent := m.parent.(type) {
+	case *jsonObject:
+		parent.set(m.key, value)
+	case *jsonArray:
+		parent.items[m.index] = value
+	default:
+		doc.root = value
+	}
+}
+
+func jsonChildren(m jsonMatch) []jsonMatch {
+	children := []jsonMatch{}
+	switch value := m.value.(type) {
+	case *jsonObject:
+		for _, key := range value.keys {
+			children = append(children, jsonMatch{parent: value, key: key, value: value.values[key]})
+		}
+	case *jsonArray:
+		for i, item := range value.items {
+			children = append(children, jsonMatch{parent: value, index: i, value: item})
+		}
+	}
+	return children
+}
+
+func (step jsonPathStep) apply(m jsonMatch) []jsonMatch {
+	switch step.kind {
+	case jsonStepField:
+		if obj, ok := m.value.(*jsonObject); ok {
+			if value, ok := obj.values[step.name]; ok {
+				return []jsonMatch{{parent: obj, key: step.name, value: value}}
+			}
+		}
+	case jsonStepIndex:
+		if arr, ok := m.value.(*jsonArray); ok {
+			index := step.index
+			if index < 0 {
+				index += len(arr.items)
+			}
+			if index >= 0 && index < len(arr.items) {
+				return []jsonMatch{{parent: arr, index: index, value: arr.items[index]}}
+			}
+		}
+	case jsonStepWildcard:
+		return jsonChildren(m)
+	}
+	return []jsonMatch{}
+}
+
+func (p jsonPath) eval(root any) []jsonMatch {
+	current := []jsonMatch{{value: root}}
+	for _, step := range p.steps {
+		next := []jsonMatch{}
+		for _, m := range current {
+			if !step.recursive {
+				next = append(next, step.apply(m)...)
+				continue
+			}
+			// Recursive descent applies the step to the node itself and to every descendant
+			pending := []jsonMatch{m}
+			for len(pending) > 0 {
+				node := pending[0]
+				pending = pending[1:]
+				next = append(next, step.apply(node)...)
+				pending = append(pending, jsonChildren(node)...)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func (c *Cache) jsonDocumentOf(key string) (*jsonDocument, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		err := redigoerr.KeyNotFoundInDictionary
+		err.ExtraContext = map[string]string{"key": key}
+		return nil, err
+	}
+	doc, ok := v.(*jsonDocument)
+	if !ok {
+		return nil, redigoerr.WrongType
+	}
+	return doc, nil
+}
+
+// jsonMatches parses the path and evaluates it over the document stored at key
+func (c *Cache) jsonMatches(key string, path string) (*jsonDocument, jsonPath, []jsonMatch, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, p, nil, err
+	}
+	doc, err := c.jsonDocumentOf(key)
+	if err != nil {
+		return nil, p, nil, err
+	}
+	matches := p.eval(doc.root)
+	if p.legacy && len(matches) == 0 {
+		err := redigoerr.JSONPathNotFound
+		err.ExtraContext = map[string]string{"path": path}
+		return nil, p, nil, err
+	}
+	return doc, p, matches, nil
+}
+
+// JSONSet stores value at path, which has to be the root for new keys.
+// A path whose last step is a field name can create that field in existing objects.
+// It returns false when nothing was set because of the nx or xx conditions or because the path did not match.
+func (c *Cache) JSONSet(key string, path string, value string, nx bool, xx bool) (bool, error) {
+	if nx && xx {
+		return false, redigoerr.SyntaxError
+	}
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return false, err
+	}
+	v, err := decodeJSON(value)
+	if err != nil {
+		return false, err
+	}
+	doc, err := c.jsonDocumentOf(key)
+	if redigoerr.KeyNotFound(err) {
+		if len(p.steps) != 0 {
+			return false, redigoerr.JSONNewObjectsAtRoot
+		}
+		if xx {
+			return false, nil
+		}
+		c.dict[key] = &jsonDocument{v}
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if len(p.steps) == 0 {
+		if nx {
+			return false, nil
+		}
+		doc.root = v
+		return true, nil
+	}
+
+	last := p.steps[len(p.steps)-1]
+	if last.kind != jsonStepField || last.recursive {
+		// Only existing values can be replaced
+		matches := p.eval(doc.root)
+		if nx || len(matches) == 0 {
+			return false, nil
+		}
+		for _, m := range matches {
+			m.replace(doc, cloneJSON(v))
+		}
+		return true, nil
+	}
+
+	set := false
+	parents := jsonPath{steps: p.steps[:len(p.steps)-1]}.eval(doc.root)
+	for _, parent := range parents {
+		obj, ok := parent.value.(*jsonObject)
+		if !ok {
+			continue
+		}
+		_, exists := obj.values[last.name]
+		if (exists && nx) || (!exists && xx) {
+			continue
+		}
+		obj.set(last.name, cloneJSON(v))
+		set = true
+	}
+	return set, nil
+}
+
+// JSONGet returns the JSON serialization of the values at the given paths (the root if none).
+// With a single path, legacy paths return the first match and JSONPaths an array with every match.
+// With multiple paths an object mapping each path to its result is returned.
+func (c *Cache) JSONGet(key string, paths ...string) (string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	results := newJSONObject()
+	for _, path := range paths {
+		_, p, matches, err := c.jsonMatches(key, path)
+		if err != nil {
+			return "", err
+		}
+		if p.legacy {
+			results.set(path, matches[0].value)
+			continue
+		}
+		arr := &jsonArray{items: make([]any, len(matches))}
+		for i, m := range matches {
+			arr.items[i] = m.value
+		}
+		results.set(path, arr)
+	}
+	if len(paths) == 1 {
+		return encodeJSON(results.values[paths[0]]), nil
+	}
+	return encodeJSON(results), nil
+}
+
+// JSONDel removes every value matched by path and returns how many were removed.
+// Deleting the root deletes the key.
+func (c *Cache) JSONDel(key string, path string) (int, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+	doc, err := c.jsonDocumentOf(key)
+	if redigoerr.KeyNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(p.steps) == 0 {
+		delete(c.dict, key)
+		return 1, nil
+	}
+
+	matches := p.eval(doc.root)
+	// Removing from the end keeps the remaining array indexes valid
+	slices.SortStableFunc(matches, func(a jsonMatch, b jsonMatch) int { return b.index - a.index })
+	deleted := 0
+	for _, m := range matches {
+		switch parent := m.parent.(type) {
+		case *jsonObject:
+			if parent.remove(m.key) {
+				deleted++
+			}
+		case *jsonArray:
+			if m.index < len(parent.items) && parent.items[m.index] == m.value {
+				parent.items = slices.Delete(parent.items, m.index, m.index+1)
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// jsonArrayMutation applies f to every array matched by path. Results are nil for values that are not arrays.
+// Legacy paths fail when their first match is not an array.
+func (c *Cache) jsonArrayMutation(key string, path string, f func(arr *jsonArray) (any, error)) ([]any, error) {
+	_, p, matches, err := c.jsonMatches(key, path)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]any, len(matches))
+	for i, m := range matches {
+		arr, ok := m.value.(*jsonArray)
+		if !ok {
+			if p.legacy {
+				return nil, redigoerr.WrongType
+			}
+			continue
+		}
+		if results[i], err = f(arr); err != nil {
+			return nil, err
+		}
+		if p.legacy {
+			return results[:1], nil
+		}
+	}
+	return results, nil
+}
+
+func decodeJSONValues(values []string) ([]any, error) {
+	decoded := make([]any, len(values))
+	for i, value := range values {
+		v, err := decodeJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		decoded[i] = v
+	}
+	return decoded, nil
+}
+
+func toIntPointers(results []any) []*int {
+	pointers := make([]*int, len(results))
+	for i, result := range results {
+		if n, ok := result.(int); ok {
+			pointers[i] = &n
+		}
+	}
+	return pointers
+}
+
+// JSONArrAppend appends values to every array matched by path and returns their new lengths
+func (c *Cache) JSONArrAppend(key string, path string, values ...string) ([]*int, error) {
+	decoded, err := decodeJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.jsonArrayMutation(key, path, func(arr *jsonArray) (any, error) {
+		for _, v := range decoded {
+			arr.items = append(arr.items, cloneJSON(v))
+		}
+		return len(arr.items), nil
+	})
+	return toIntPointers(results), err
+}
+
+// JSONArrInsert inserts values before index (negative values count from the end) in every array matched by path
+// and returns their new lengths
+func (c *Cache) JSONArrInsert(key string, path string, index int, values ...string) ([]*int, error) {
+	decoded, err := decodeJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.jsonArrayMutation(key, path, func(arr *jsonArray) (any, error) {
+		position := index
+		if position < 0 {
+			position += len(arr.items)
+		}
+		if position < 0 || position > len(arr.items) {
+			err := redigoerr.IndexOutOfRangeErr
+			err.ExtraContext = map[string]string{"index": strconv.Itoa(index)}
+			return nil, err
+		}
+		items := make([]any, len(decoded))
+		for i, v := range decoded {
+			items[i] = cloneJSON(v)
+		}
+		arr.items = slices.Insert(arr.items, position, items...)
+		return len(arr.items), nil
+	})
+	return toIntPointers(results), err
+}
+
+// JSONArrPop removes the element at index (clamped to the array bounds) from every array matched by path
+// and returns them serialized. Results are nil for empty arrays and values that are not arrays.
+func (c *Cache) JSONArrPop(key string, path string, index int) ([]*string, error) {
+	results, err := c.jsonArrayMutation(key, path, func(arr *jsonArray) (any, error) {
+		if len(arr.items) == 0 {
+			return nil, nil
+		}
+		position := index
+		if position < 0 {
+			position += len(arr.items)
+		}
+		position = max(0, min(len(arr.items)-1, position))
+		popped := encodeJSON(arr.items[position])
+		arr.items = slices.Delete(arr.items, position, position+1)
+		return popped, nil
+	})
+	pointers := make([]*string, len(results))
+	for i, result := range results {
+		if s, ok := result.(string); ok {
+			pointers[i] = &s
+		}
+	}
+	return pointers, err
+}
+
+// JSONNumIncrBy adds incr to every number matched by path and returns the new values serialized.
+// Integers stay integers unless the result overflows or incr has a fractional part.
+func (c *Cache) JSONNumIncrBy(key string, path string, incr string) ([]*string, error) {
+	decoded, err := decodeJSON(incr)
+	if err != nil {
+		return nil, err
+	}
+	increment, ok := decoded.(json.Number)
+	if !ok {
+		return nil, redigoerr.NotAFloat
+	}
+	doc, p, matches, err := c.jsonMatches(key, path)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*string, len(matches))
+	for i, m := range matches {
+		current, ok := m.value.(json.Number)
+		if !ok {
+			if p.legacy {
+				return nil, redigoerr.WrongType
+			}
+			continue
+		}
+		next, err := addJSONNumbers(current, increment)
+		if err != nil {
+			return nil, err
+		}
+		m.replace(doc, next)
+		s := string(next)
+		results[i] = &s
+		if p.legacy {
+			return results[:1], nil
+		}
+	}
+	return results, nil
+}
+
+func addJSONNumbers(a json.Number, b json.Number) (json.Number, error) {
+	x, errX := a.Int64()
+	y, errY := b.Int64()
+	if errX == nil && errY == nil {
+		sum := x + y
+		// Same sign operands whose sum changes sign overflowed
+		if (x >= 0) != (y >= 0) || (sum >= 0) == (x >= 0) {
+			return json.Number(strconv.FormatInt(sum, 10)), nil
+		}
+	}
+	fx, errX := a.Float64()
+	fy, errY := b.Float64()
+	if errX != nil || errY != nil || math.IsInf(fx+fy, 0) {
+		return "", redigoerr.NotAFloat
+	}
+	encoded, err := json.Marshal(fx + fy)
+	if err != nil {
+		return "", redigoerr.NotAFloat
+	}
+	return json.Number(encoded), nil
+}
+
+// JSONType returns the type name of every value matched by path (only the first one for legacy paths).
+// A legacy path without matches returns an empty slice.
+func (c *Cache) JSONType(key string, path string) ([]string, error) {
+	_, _, matches, err := c.jsonMatches(key, path)
+	if redigoerr.JSONPathMissing(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	types := make([]string, len(matches))
+	for i, m := range matches {
+		types[i] = jsonTypeName(m.value)
+	}
+	if IsLegacyJSONPath(path) {
+		return types[:1], nil
+	}
+	return types, nil
+}
+
+// JSONObjKeys returns the keys of every object matched by path (only the first one for legacy paths).
+// The keys of a value that is not an object are nil.
+func (c *Cache) JSONObjKeys(key string, path string) ([][]string, error) {
+	_, p, matches, err := c.jsonMatches(key, path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][]string, len(matches))
+	for i, m := range matches {
+		if obj, ok := m.value.(*jsonObject); ok {
+			keys[i] = slices.Clone(obj.keys)
+		} else if p.legacy {
+			return nil, redigoerr.WrongType
+		}
+		if p.legacy {
+			return keys[:1], nil
+		}
+	}
+	return keys, nil
+}
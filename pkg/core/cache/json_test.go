@@ -0,0 +1,176 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func newStore(t *testing.T) *Cache {
+	cs := New()
+	_, err := cs.JSONSet("store", "$", `{"name":"corner","books":[{"title":"Dune","price":8.99,"stock":3},{"title":"Emma","price":12,"stock":0}],"open":true,"owner":null}`, false, false)
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	return cs
+}
+
+func TestJSONSet_Should_Create_Documents_Only_At_Root(t *testing.T) {
+	cs := New()
+	if _, err := cs.JSONSet("doc", "$.a", `1`, false, false); err == nil {
+		t.Errorf("Expected error creating a document outside the root but obtained nil!")
+	}
+	if _, err := cs.JSONSet("doc", "$", `{"a":`, false, false); err == nil {
+		t.Errorf("Expected error for invalid JSON but obtained nil!")
+	}
+	if set, err := cs.JSONSet("doc", ".", `{"b":1,"a":2}`, false, true); err != nil || set {
+		t.Errorf("XX should not create documents! %v", err)
+	}
+	if set, err := cs.JSONSet("doc", ".", `{"b":1,"a":2}`, true, false); err != nil || !set {
+		t.Errorf("NX should create documents! %v", err)
+	}
+	if document, _ := cs.JSONGet("doc"); document != `{"b":1,"a":2}` {
+		t.Errorf("Document should keep key order! %s", document)
+	}
+}
+
+func TestJSONSet_Should_Replace_And_Add_Nested_Fields(t *testing.T) {
+	cs := newStore(t)
+	if set, err := cs.JSONSet("store", "$.books[*].stock", `10`, false, false); err != nil || !set {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if set, _ := cs.JSONSet("store", "$.books[0].author", `"Herbert"`, false, true); set {
+		t.Errorf("XX should not add new fields!")
+	}
+	if set, _ := cs.JSONSet("store", "$.books[0].author", `"Herbert"`, true, false); !set {
+		t.Errorf("NX should add new fields!")
+	}
+	if set, _ := cs.JSONSet("store", "$.missing.field", `1`, false, false); set {
+		t.Errorf("Fields should not be created inside missing parents!")
+	}
+	document, err := cs.JSONGet("store", "$.books[*].stock", "$.books[0].author")
+	if err != nil || document != `{"$.books[*].stock":[10,10],"$.books[0].author":["Herbert"]}` {
+		t.Errorf("Unexpected document! %v - %s", err, document)
+	}
+}
+
+func TestJSONGet_Should_Return_Matches_Depending_On_Path_Syntax(t *testing.T) {
+	cs := newStore(t)
+	tests := map[string]string{
+		".name":               `"corner"`,
+		"books[1].title":      `"Emma"`,
+		"$.books[-1].price":   `[12]`,
+		"$['name']":           `["corner"]`,
+		`$.books[0]["title"]`: `["Dune"]`,
+		"$..title":            `["Dune","Emma"]`,
+		"$.owner":             `[null]`,
+		"$.nothing":           `[]`,
+	}
+	for path, expected := range tests {
+		document, err := cs.JSONGet("store", path)
+		if err != nil || document != expected {
+			t.Errorf("Unexpected result for %s! %v - %s != %s", path, err, document, expected)
+		}
+	}
+	if _, err := cs.JSONGet("store", ".nothing"); err == nil {
+		t.Errorf("Expected error for missing legacy path but obtained nil!")
+	}
+	if _, err := cs.JSONGet("store", "$.books["); err == nil {
+		t.Errorf("Expected error for invalid path but obtained nil!")
+	}
+}
+
+func TestJSONDel_Should_Remove_Every_Match(t *testing.T) {
+	cs := newStore(t)
+	if n, err := cs.JSONDel("store", "$.books[*]"); err != nil || n != 2 {
+		t.Errorf("An error occurred! %v - %d", err, n)
+	}
+	if document, _ := cs.JSONGet("store", "$.books"); document != `[[]]` {
+		t.Errorf("Books should be empty! %s", document)
+	}
+	if n, _ := cs.JSONDel("store", "$"); n != 1 {
+		t.Errorf("Deleting root should delete the key!")
+	}
+	if _, err := cs.Get("store"); err == nil {
+		t.Errorf("Key should not exist!")
+	}
+}
+
+func TestJSONArr_Should_Append_Insert_And_Pop_Elements(t *testing.T) {
+	cs := New()
+	cs.JSONSet("doc", "$", `{"a":[1],"b":"text"}`, false, false)
+	lengths, err := cs.JSONArrAppend("doc", "$.*", `2`, `"three"`)
+	if err != nil || *lengths[0] != 3 || lengths[1] != nil {
+		t.Errorf("Unexpected lengths! %v - %v", err, lengths)
+	}
+	lengths, err = cs.JSONArrInsert("doc", ".a", -1, `{"x":1}`)
+	if err != nil || *lengths[0] != 4 {
+		t.Errorf("Unexpected length! %v - %v", err, lengths)
+	}
+	if _, err := cs.JSONArrInsert("doc", ".a", 10, `0`); err == nil {
+		t.Errorf("Expected error for index out of range but obtained nil!")
+	}
+	if _, err := cs.JSONArrAppend("doc", ".b", `0`); err == nil {
+		t.Errorf("Expected error appending to a string with a legacy path but obtained nil!")
+	}
+	if document, _ := cs.JSONGet("doc", ".a"); document != `[1,2,{"x":1},"three"]` {
+		t.Errorf("Unexpected array! %s", document)
+	}
+	popped, err := cs.JSONArrPop("doc", ".a", 100)
+	if err != nil || *popped[0] != `"three"` {
+		t.Errorf("Unexpected popped value! %v - %v", err, popped)
+	}
+	popped, _ = cs.JSONArrPop("doc", "$.a", 0)
+	if *popped[0] != `1` {
+		t.Errorf("Unexpected popped value! %v", *popped[0])
+	}
+}
+
+func TestJSONNumIncrBy_Should_Keep_Integers_When_Possible(t *testing.T) {
+	cs := newStore(t)
+	values, err := cs.JSONNumIncrBy("store", "$.books[*].price", "1")
+	if err != nil || *values[0] != "9.99" || *values[1] != "13" {
+		t.Errorf("Unexpected values! %v - %v", err, values)
+	}
+	values, _ = cs.JSONNumIncrBy("store", "$.books[1].price", "0.5")
+	if *values[0] != "13.5" {
+		t.Errorf("Unexpected value! %v", *values[0])
+	}
+	values, _ = cs.JSONNumIncrBy("store", "$.name", "1")
+	if values[0] != nil {
+		t.Errorf("Strings should not be incremented! %v", *values[0])
+	}
+	if _, err := cs.JSONNumIncrBy("store", ".name", "1"); err == nil {
+		t.Errorf("Expected error incrementing a string with a legacy path but obtained nil!")
+	}
+	cs.JSONSet("big", "$", `9223372036854775807`, false, false)
+	if values, _ := cs.JSONNumIncrBy("big", ".", "1"); *values[0] != "9223372036854776000" {
+		t.Errorf("Overflowing integers should become floats! %v", *values[0])
+	}
+}
+
+func TestJSONType_And_ObjKeys_Should_Describe_Values(t *testing.T) {
+	cs := newStore(t)
+	types, err := cs.JSONType("store", "$.*")
+	if err != nil || !slices.Equal(types, []string{"string", "array", "boolean", "null"}) {
+		t.Errorf("Unexpected types! %v - %v", err, types)
+	}
+	types, _ = cs.JSONType("store", ".books[0].stock")
+	if !slices.Equal(types, []string{"integer"}) {
+		t.Errorf("Unexpected types! %v", types)
+	}
+	keys, err := cs.JSONObjKeys("store", "$..books[*]")
+	if err != nil || len(keys) != 2 || !slices.Equal(keys[0], []string{"title", "price", "stock"}) {
+		t.Errorf("Unexpected keys! %v - %v", err, keys)
+	}
+	keys, _ = cs.JSONObjKeys("store", "$.name")
+	if keys[0] != nil {
+		t.Errorf("Strings should have no keys! %v", keys)
+	}
+	cs.Set("plain", "value")
+	if _, err := cs.JSONGet("plain"); err == nil {
+		t.Errorf("Expected wrong type error but obtained nil!")
+	}
+}
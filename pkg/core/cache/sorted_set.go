@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+type sortedSetEntry struct {
+	member string
+	score  float64
+}
+
+// sortedSet keeps members ordered by score (and lexicographically between equal scores).
+// Lookups by member go through a map, while ranges by score are binary searches over the ordered slice.
+type sortedSet struct {
+	scores  map[string]float64
+	entries []sortedSetEntry
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{scores: make(map[string]float64), entries: []sortedSetEntry{}}
+}
+
+func (z *sortedSet) len() int {
+	return len(z.entries)
+}
+
+func (z *sortedSet) score(member string) (float64, bool) {
+	score, ok := z.scores[member]
+	return score, ok
+}
+
+// position returns where an entry is (or would be) in the ordered slice
+func (z *sortedSet) position(member string, score float64) int {
+	return sort.Search(len(z.entries), func(i int) bool {
+		e := z.entries[i]
+		return e.score > score || (e.score == score && e.member >= member)
+	})
+}
+
+// add inserts member or updates its score, returning true when the member is new
+func (z *sortedSet) add(member string, score float64) bool {
+	isNew := !z.remove(member)
+	i := z.position(member, score)
+	z.entries = slices.Insert(z.entries, i, sortedSetEntry{member, score})
+	z.scores[member] = score
+	return isNew
+}
+
+// remove deletes member, returning true if it was present
+func (z *sortedSet) remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+	i := z.position(member, score)
+	z.entries = slices.Delete(z.entries, i, i+1)
+	delete(z.scores, member)
+	return true
+}
+
+// rangeByScore returns every entry with low <= score < high, in order
+func (z *sortedSet) rangeByScore(low float64, high float64) []sortedSetEntry {
+	start := sort.Search(len(z.entries), func(i int) bool { return z.entries[i].score >= low })
+	end := sort.Search(len(z.entries), func(i int) bool { return z.entries[i].score >= high })
+	if start >= end {
+		return []sortedSetEntry{}
+	}
+	return z.entries[start:end]
+}
+
+func (c *Cache) sortedSetOf(key string) (*sortedSet, error) {
+	v, ok := c.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	vAsSortedSet, ok := v.(*sortedSet)
+	if !ok {
+		return nil, redigoerr.WrongType
+	}
+	return vAsSortedSet, nil
+}
@@ -0,0 +1,296 @@
+package respparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// selectBitmapFunction returns the commands operating on strings as arrays of bits:
+// SETBIT, GETBIT, BITCOUNT, BITPOS, BITOP and BITFIELD.
+func selectBitmapFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "SETBIT":
+		if len(arr) != 4 {
+			return f, insufficientLength("4", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			offset, err := parseBitOffset(arr[2])
+			if err != nil {
+				return []byte{}, err
+			}
+			value, err := parseBit(arr[3])
+			if err != nil {
+				return []byte{}, err
+			}
+			previous, err := d.SetBit(arr[1], offset, value)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(int(previous)), nil
+		}, nil
+	case "GETBIT":
+		if len(arr) != 3 {
+			return f, insufficientLength("3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			offset, err := parseBitOffset(arr[2])
+			if err != nil {
+				return []byte{}, err
+			}
+			bit, err := d.GetBit(arr[1], offset)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(int(bit)), nil
+		}, nil
+	case "BITCOUNT":
+		if len(arr) != 2 && len(arr) != 4 && len(arr) != 5 {
+			return f, insufficientLength("2, 4 or 5", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			r := cache.BitRange{}
+			if len(arr) > 2 {
+				var err error
+				if r, err = parseBitRange(arr[2:]); err != nil {
+					return []byte{}, err
+				}
+			}
+			count, err := d.BitCount(arr[1], r)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(count), nil
+		}, nil
+	case "BITPOS":
+		if len(arr) < 3 || len(arr) > 6 {
+			return f, insufficientLength("3 to 6", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			bit, err := parseBit(arr[2])
+			if err != nil {
+				return []byte{}, err
+			}
+			r := cache.BitRange{}
+			if len(arr) > 3 {
+				if r, err = parseBitRange(arr[3:]); err != nil {
+					return []byte{}, err
+				}
+			}
+			pos, err := d.BitPos(arr[1], bit, r)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(pos), nil
+		}, nil
+	case "BITOP":
+		if len(arr) < 4 {
+			return f, insufficientLength(">= 4", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			n, err := d.BitOp(strings.ToUpper(arr[1]), arr[2], arr[3:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(n), nil
+		}, nil
+	case "BITFIELD":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			ops, err := parseBitFieldOps(arr[2:])
+			if err != nil {
+				return []byte{}, err
+			}
+			results, err := d.BitField(arr[1], ops)
+			if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(results))
+			for i, res := range results {
+				if res == nil {
+					elements[i] = tobytes.Null()
+				} else {
+					elements[i] = tobytes.Int(int(*res))
+				}
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
+
+func insufficientLength(expected string, obtained int) error {
+	redigoError := redigoerr.InsufficientLength
+	redigoError.ExtraContext = map[string]string{"expected": expected, "obtained": fmt.Sprintf("%v", obtained)}
+	return redigoError
+}
+
+func parseInt(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		redigoError := redigoerr.NotAnInteger
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return i, nil
+}
+
+func parseBitOffset(s string) (uint64, error) {
+	offset, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || offset >= cache.MaxBitOffset {
+		redigoError := redigoerr.BitOffsetOutOfRange
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return offset, nil
+}
+
+func parseBit(s string) (byte, error) {
+	switch s {
+	case "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	default:
+		redigoError := redigoerr.BitValueOutOfRange
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+}
+
+// parseBitRange reads "start end [BYTE|BIT]" or just "start" (only allowed by BITPOS)
+func parseBitRange(args []string) (cache.BitRange, error) {
+	r := cache.BitRange{}
+	start, err := parseInt(args[0])
+	if err != nil {
+		return r, err
+	}
+	r.Start = int(start)
+	if len(args) == 1 {
+		return r, nil
+	}
+	end, err := parseInt(args[1])
+	if err != nil {
+		return r, err
+	}
+	r.End = int(end)
+	r.EndSet = true
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			r.InBits = true
+		default:
+			return r, redigoerr.SyntaxError
+		}
+	}
+	return r, nil
+}
+
+// parseBitFieldOps reads the subcommands of BITFIELD. OVERFLOW changes the policy of every
+// SET and INCRBY after it.
+func parseBitFieldOps(args []string) ([]cache.BitFieldOp, error) {
+	ops := []cache.BitFieldOp{}
+	overflow := cache.OverflowWrap
+	for i := 0; i < len(args); {
+		subcommand := strings.ToUpper(args[i])
+		switch subcommand {
+		case "OVERFLOW":
+			if i+1 >= len(args) {
+				return nil, redigoerr.SyntaxError
+			}
+			switch strings.ToUpper(args[i+1]) {
+			case "WRAP":
+				overflow = cache.OverflowWrap
+			case "SAT":
+				overflow = cache.OverflowSat
+			case "FAIL":
+				overflow = cache.OverflowFail
+			default:
+				return nil, redigoerr.SyntaxError
+			}
+			i += 2
+		case "GET", "SET", "INCRBY":
+			needed := 3
+			if subcommand != "GET" {
+				needed = 4
+			}
+			if i+needed > len(args) {
+				return nil, redigoerr.SyntaxError
+			}
+			op := cache.BitFieldOp{Overflow: overflow}
+			signed, width, err := parseBitFieldType(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			op.Signed, op.Width = signed, width
+			if op.Offset, err = parseBitFieldOffset(args[i+2], width); err != nil {
+				return nil, err
+			}
+			switch subcommand {
+			case "GET":
+				op.Kind = cache.BitFieldGet
+			case "SET":
+				op.Kind = cache.BitFieldSet
+			case "INCRBY":
+				op.Kind = cache.BitFieldIncrBy
+			}
+			if subcommand != "GET" {
+				if op.Value, err = parseInt(args[i+3]); err != nil {
+					return nil, err
+				}
+			}
+			ops = append(ops, op)
+			i += needed
+		default:
+			return nil, redigoerr.SyntaxError
+		}
+	}
+	return ops, nil
+}
+
+// parseBitFieldType reads types such as i8 or u16
+func parseBitFieldType(s string) (bool, uint, error) {
+	redigoError := redigoerr.InvalidBitfieldType
+	redigoError.ExtraContext = map[string]string{"provided": s}
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return false, 0, redigoError
+	}
+	signed := s[0] == 'i'
+	width, err := strconv.ParseUint(s[1:], 10, 8)
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		redigoError.From = err
+		return false, 0, redigoError
+	}
+	return signed, uint(width), nil
+}
+
+// parseBitFieldOffset reads an offset in bits, or in multiples of the type width when prefixed with '#'
+func parseBitFieldOffset(s string, width uint) (uint64, error) {
+	if strings.HasPrefix(s, "#") {
+		offset, err := parseBitOffset(s[1:])
+		if err != nil {
+			return 0, err
+		}
+		offset *= uint64(width)
+		if offset >= cache.MaxBitOffset {
+			redigoError := redigoerr.BitOffsetOutOfRange
+			redigoError.ExtraContext = map[string]string{"provided": s}
+			return 0, redigoError
+		}
+		return offset, nil
+	}
+	return parseBitOffset(s)
+}
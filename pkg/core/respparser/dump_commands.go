@@ -0,0 +1,54 @@
+package respparser
+
+import (
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// selectDumpFunction returns DUMP and RESTORE, which move keys of any type between servers (see MIGRATE).
+// Keys never expire, so the ttl given to RESTORE is only checked to be an integer.
+func selectDumpFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "DUMP":
+		if len(arr) != 2 {
+			return f, insufficientLength("2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			payload, ok := d.Dump(arr[1])
+			if !ok {
+				return tobytes.Null(), nil
+			}
+			return tobytes.BlobString(string(payload)), nil
+		}, nil
+	case "RESTORE":
+		if len(arr) < 4 {
+			return f, insufficientLength(">= 4", len(arr))
+		}
+		if _, err := parseInt(arr[2]); err != nil {
+			return f, err
+		}
+		replace := false
+		for _, option := range arr[4:] {
+			if strings.ToUpper(option) != "REPLACE" {
+				redigoError := redigoerr.SyntaxError
+				redigoError.ExtraContext = map[string]string{"provided": option}
+				return f, redigoError
+			}
+			replace = true
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			if err := d.Restore(arr[1], []byte(arr[3]), replace); err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Null(), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
@@ -0,0 +1,300 @@
+package respparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// geoReplyOptions holds the WITH* flags of GEOSEARCH, which only change the shape of the response
+type geoReplyOptions struct {
+	withCoord bool
+	withDist  bool
+	withHash  bool
+}
+
+// selectGeoFunction returns the commands operating on geospatial indexes:
+// GEOADD, GEOPOS, GEODIST, GEOHASH, GEOSEARCH and GEOSEARCHSTORE.
+func selectGeoFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "GEOADD":
+		if len(arr) < 5 {
+			return f, insufficientLength(">= 5", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			options := cache.GeoAddOptions{}
+			i := 2
+		flags:
+			for ; i < len(arr); i++ {
+				switch strings.ToUpper(arr[i]) {
+				case "NX":
+					options.NX = true
+				case "XX":
+					options.XX = true
+				case "CH":
+					options.CH = true
+				default:
+					break flags
+				}
+			}
+			if len(arr[i:]) == 0 || len(arr[i:])%3 != 0 {
+				return []byte{}, redigoerr.SyntaxError
+			}
+			points := []cache.GeoPoint{}
+			for ; i < len(arr); i += 3 {
+				longitude, err := parseFloat(arr[i])
+				if err != nil {
+					return []byte{}, err
+				}
+				latitude, err := parseFloat(arr[i+1])
+				if err != nil {
+					return []byte{}, err
+				}
+				points = append(points, cache.GeoPoint{Member: arr[i+2], Longitude: longitude, Latitude: latitude})
+			}
+			n, err := d.GeoAdd(arr[1], options, points...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(n), nil
+		}, nil
+	case "GEOPOS":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			points, err := d.GeoPos(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(points))
+			for i, point := range points {
+				if point == nil {
+					elements[i] = tobytes.Null()
+					continue
+				}
+				elements[i] = geoCoordinates(point.Longitude, point.Latitude)
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	case "GEODIST":
+		if len(arr) != 4 && len(arr) != 5 {
+			return f, insufficientLength("4 or 5", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			unit := "m"
+			if len(arr) == 5 {
+				unit = arr[4]
+			}
+			distance, found, err := d.GeoDist(arr[1], arr[2], arr[3], unit)
+			if err != nil {
+				return []byte{}, err
+			}
+			if !found {
+				return tobytes.Null(), nil
+			}
+			return tobytes.BlobString(fmt.Sprintf("%.4f", distance)), nil
+		}, nil
+	case "GEOHASH":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			hashes, err := d.GeoHash(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(hashes))
+			for i, hash := range hashes {
+				if hash == nil {
+					elements[i] = tobytes.Null()
+				} else {
+					elements[i] = tobytes.BlobString(*hash)
+				}
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	case "GEOSEARCH":
+		if len(arr) < 7 {
+			return f, insufficientLength(">= 7", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			query, reply, _, err := parseGeoQuery(arr[2:], false)
+			if err != nil {
+				return []byte{}, err
+			}
+			results, err := d.GeoSearch(arr[1], query)
+			if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(results))
+			for i, result := range results {
+				if !reply.withCoord && !reply.withDist && !reply.withHash {
+					elements[i] = tobytes.BlobString(result.Member)
+					continue
+				}
+				item := [][]byte{tobytes.BlobString(result.Member)}
+				if reply.withDist {
+					item = append(item, tobytes.BlobString(fmt.Sprintf("%.4f", result.Distance)))
+				}
+				if reply.withHash {
+					item = append(item, tobytes.Int(int(result.Hash)))
+				}
+				if reply.withCoord {
+					item = append(item, geoCoordinates(result.Longitude, result.Latitude))
+				}
+				elements[i] = tobytes.Array(item...)
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	case "GEOSEARCHSTORE":
+		if len(arr) < 8 {
+			return f, insufficientLength(">= 8", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			query, _, storeDist, err := parseGeoQuery(arr[3:], true)
+			if err != nil {
+				return []byte{}, err
+			}
+			n, err := d.GeoSearchStore(arr[1], arr[2], query, storeDist)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(n), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
+
+func geoCoordinates(longitude float64, latitude float64) []byte {
+	return tobytes.Array(
+		tobytes.BlobString(strconv.FormatFloat(longitude, 'f', -1, 64)),
+		tobytes.BlobString(strconv.FormatFloat(latitude, 'f', -1, 64)),
+	)
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		redigoError := redigoerr.NotAFloat
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return f, nil
+}
+
+// parseGeoQuery reads the arguments of GEOSEARCH (or GEOSEARCHSTORE when store is true) after the key(s).
+// A center (FROMMEMBER or FROMLONLAT) and a shape (BYRADIUS or BYBOX) are mandatory.
+func parseGeoQuery(args []string, store bool) (cache.GeoQuery, geoReplyOptions, bool, error) {
+	query := cache.GeoQuery{}
+	reply := geoReplyOptions{}
+	storeDist := false
+	hasCenter, hasShape := false, false
+	var err error
+
+	// needs checks there are at least n arguments after the option at position i
+	needs := func(i int, n int) error {
+		if i+n >= len(args) {
+			return redigoerr.SyntaxError
+		}
+		return nil
+	}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "FROMMEMBER":
+			if hasCenter || needs(i, 1) != nil {
+				return query, reply, false, redigoerr.SyntaxError
+			}
+			query.UseMember, query.FromMember = true, args[i+1]
+			hasCenter = true
+			i++
+		case "FROMLONLAT":
+			if hasCenter || needs(i, 2) != nil {
+				return query, reply, false, redigoerr.SyntaxError
+			}
+			if query.Longitude, err = parseFloat(args[i+1]); err != nil {
+				return query, reply, false, err
+			}
+			if query.Latitude, err = parseFloat(args[i+2]); err != nil {
+				return query, reply, false, err
+			}
+			hasCenter = true
+			i += 2
+		case "BYRADIUS":
+			if hasShape || needs(i, 2) != nil {
+				return query, reply, false, redigoerr.SyntaxError
+			}
+			if query.Radius, err = parseFloat(args[i+1]); err != nil {
+				return query, reply, false, err
+			}
+			query.Unit = args[i+2]
+			hasShape = true
+			i += 2
+		case "BYBOX":
+			if hasShape || needs(i, 3) != nil {
+				return query, reply, false, redigoerr.SyntaxError
+			}
+			query.ByBox = true
+			if query.Width, err = parseFloat(args[i+1]); err != nil {
+				return query, reply, false, err
+			}
+			if query.Height, err = parseFloat(args[i+2]); err != nil {
+				return query, reply, false, err
+			}
+			query.Unit = args[i+3]
+			hasShape = true
+			i += 3
+		case "ASC":
+			query.Sort = cache.GeoAsc
+		case "DESC":
+			query.Sort = cache.GeoDesc
+		case "COUNT":
+			if needs(i, 1) != nil {
+				return query, reply, false, redigoerr.SyntaxError
+			}
+			count, err := parseInt(args[i+1])
+			if err != nil {
+				return query, reply, false, err
+			}
+			if count <= 0 {
+				return query, reply, false, redigoerr.NotAnInteger
+			}
+			query.Count = int(count)
+			i++
+			if i+1 < len(args) && strings.ToUpper(args[i+1]) == "ANY" {
+				query.Any = true
+				i++
+			}
+		case "WITHCOORD":
+			reply.withCoord = true
+		case "WITHDIST":
+			reply.withDist = true
+		case "WITHHASH":
+			reply.withHash = true
+		case "STOREDIST":
+			storeDist = true
+		default:
+			return query, reply, false, redigoerr.SyntaxError
+		}
+	}
+	if !hasCenter || !hasShape || query.Radius < 0 || query.Width < 0 || query.Height < 0 {
+		return query, reply, false, redigoerr.SyntaxError
+	}
+	if (store && (reply.withCoord || reply.withDist || reply.withHash)) || (!store && storeDist) {
+		return query, reply, false, redigoerr.SyntaxError
+	}
+	if _, err := cache.GeoUnitToMeters(query.Unit); err != nil {
+		return query, reply, false, err
+	}
+	return query, reply, storeDist, nil
+}
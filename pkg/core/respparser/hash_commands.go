@@ -0,0 +1,80 @@
+package respparser
+
+import (
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// selectHashFunction returns the commands operating on hashes: HSET, HGET, HGETALL, HDEL and HLEN.
+// HGETALL replies with an array of field value pairs, ordered by field.
+func selectHashFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "HSET":
+		if len(arr) < 4 || len(arr)%2 != 0 {
+			return f, insufficientLength(">= 4 and even", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			added, err := d.HSet(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(added), nil
+		}, nil
+	case "HGET":
+		if len(arr) != 3 {
+			return f, insufficientLength("3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			value, err := d.HGet(arr[1], arr[2])
+			if redigoerr.KeyNotFound(err) {
+				return tobytes.Null(), nil
+			} else if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.BlobString(value), nil
+		}, nil
+	case "HGETALL":
+		if len(arr) != 2 {
+			return f, insufficientLength("2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			pairs, err := d.HGetAll(arr[1])
+			if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(pairs))
+			for i, s := range pairs {
+				elements[i] = tobytes.BlobString(s)
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	case "HDEL":
+		if len(arr) < 3 {
+			return f, insufficientLength(">= 3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			removed, err := d.HDel(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(removed), nil
+		}, nil
+	case "HLEN":
+		if len(arr) != 2 {
+			return f, insufficientLength("2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			n, err := d.HLen(arr[1])
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(n), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
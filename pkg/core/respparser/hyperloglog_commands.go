@@ -0,0 +1,54 @@
+package respparser
+
+import (
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// selectHyperLogLogFunction returns the commands operating on HyperLogLogs: PFADD, PFCOUNT and PFMERGE.
+func selectHyperLogLogFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "PFADD":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			changed, err := d.PFAdd(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			if changed {
+				return tobytes.Int(1), nil
+			}
+			return tobytes.Int(0), nil
+		}, nil
+	case "PFCOUNT":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			count, err := d.PFCount(arr[1:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(count), nil
+		}, nil
+	case "PFMERGE":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			err := d.PFMerge(arr[1], arr[2:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Null(), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
@@ -0,0 +1,234 @@
+package respparser
+
+import (
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// selectJSONFunction returns the commands operating on JSON documents: JSON.SET, JSON.GET, JSON.DEL,
+// JSON.ARRAPPEND, JSON.ARRINSERT, JSON.ARRPOP, JSON.NUMINCRBY, JSON.TYPE and JSON.OBJKEYS.
+// Legacy paths reply with a single value, while JSONPaths (starting with '$') reply with one value per match.
+func selectJSONFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error) {
+	var f func(d *cache.Cache) ([]byte, error)
+	switch arr[0] {
+	case "JSON.SET":
+		if len(arr) != 4 && len(arr) != 5 {
+			return f, insufficientLength("4 or 5", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			nx, xx := false, false
+			if len(arr) == 5 {
+				switch strings.ToUpper(arr[4]) {
+				case "NX":
+					nx = true
+				case "XX":
+					xx = true
+				default:
+					return []byte{}, redigoerr.SyntaxError
+				}
+			}
+			_, err := d.JSONSet(arr[1], arr[2], arr[3], nx, xx)
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Null(), nil
+		}, nil
+	case "JSON.GET":
+		if len(arr) < 2 {
+			return f, insufficientLength(">= 2", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			document, err := d.JSONGet(arr[1], arr[2:]...)
+			if redigoerr.KeyNotFound(err) {
+				return tobytes.Null(), nil
+			} else if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.BlobString(document), nil
+		}, nil
+	case "JSON.DEL":
+		if len(arr) != 2 && len(arr) != 3 {
+			return f, insufficientLength("2 or 3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			n, err := d.JSONDel(arr[1], jsonPathArgument(arr, 2))
+			if err != nil {
+				return []byte{}, err
+			}
+			return tobytes.Int(n), nil
+		}, nil
+	case "JSON.ARRAPPEND":
+		if len(arr) < 4 {
+			return f, insufficientLength(">= 4", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			lengths, err := d.JSONArrAppend(arr[1], arr[2], arr[3:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return jsonIntegers(arr[2], lengths), nil
+		}, nil
+	case "JSON.ARRINSERT":
+		if len(arr) < 5 {
+			return f, insufficientLength(">= 5", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			index, err := parseInt(arr[3])
+			if err != nil {
+				return []byte{}, err
+			}
+			lengths, err := d.JSONArrInsert(arr[1], arr[2], int(index), arr[4:]...)
+			if err != nil {
+				return []byte{}, err
+			}
+			return jsonIntegers(arr[2], lengths), nil
+		}, nil
+	case "JSON.ARRPOP":
+		if len(arr) < 2 || len(arr) > 4 {
+			return f, insufficientLength("2 to 4", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			index := int64(-1)
+			if len(arr) == 4 {
+				var err error
+				if index, err = parseInt(arr[3]); err != nil {
+					return []byte{}, err
+				}
+			}
+			path := jsonPathArgument(arr, 2)
+			popped, err := d.JSONArrPop(arr[1], path, int(index))
+			if err != nil {
+				return []byte{}, err
+			}
+			return jsonStrings(path, popped), nil
+		}, nil
+	case "JSON.NUMINCRBY":
+		if len(arr) != 4 {
+			return f, insufficientLength("4", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			values, err := d.JSONNumIncrBy(arr[1], arr[2], arr[3])
+			if err != nil {
+				return []byte{}, err
+			}
+			if cache.IsLegacyJSONPath(arr[2]) {
+				return tobytes.BlobString(*values[0]), nil
+			}
+			// JSONPaths reply with a serialized array, where non numeric matches are null
+			b := &strings.Builder{}
+			b.WriteByte('[')
+			for i, value := range values {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				if value == nil {
+					b.WriteString("null")
+				} else {
+					b.WriteString(*value)
+				}
+			}
+			b.WriteByte(']')
+			return tobytes.BlobString(b.String()), nil
+		}, nil
+	case "JSON.TYPE":
+		if len(arr) != 2 && len(arr) != 3 {
+			return f, insufficientLength("2 or 3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			path := jsonPathArgument(arr, 2)
+			types, err := d.JSONType(arr[1], path)
+			if redigoerr.KeyNotFound(err) {
+				return tobytes.Null(), nil
+			} else if err != nil {
+				return []byte{}, err
+			}
+			if cache.IsLegacyJSONPath(path) {
+				if len(types) == 0 {
+					return tobytes.Null(), nil
+				}
+				return tobytes.BlobString(types[0]), nil
+			}
+			elements := make([][]byte, len(types))
+			for i, t := range types {
+				elements[i] = tobytes.BlobString(t)
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	case "JSON.OBJKEYS":
+		if len(arr) != 2 && len(arr) != 3 {
+			return f, insufficientLength("2 or 3", len(arr))
+		}
+		return func(d *cache.Cache) ([]byte, error) {
+			path := jsonPathArgument(arr, 2)
+			keys, err := d.JSONObjKeys(arr[1], path)
+			if redigoerr.KeyNotFound(err) {
+				return tobytes.Null(), nil
+			} else if err != nil {
+				return []byte{}, err
+			}
+			elements := make([][]byte, len(keys))
+			for i, objectKeys := range keys {
+				if objectKeys == nil {
+					elements[i] = tobytes.Null()
+					continue
+				}
+				names := make([][]byte, len(objectKeys))
+				for j, name := range objectKeys {
+					names[j] = tobytes.BlobString(name)
+				}
+				elements[i] = tobytes.Array(names...)
+			}
+			if cache.IsLegacyJSONPath(path) {
+				return elements[0], nil
+			}
+			return tobytes.Array(elements...), nil
+		}, nil
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": arr[0]}
+		return f, redigoError
+	}
+}
+
+// jsonPathArgument returns the optional path at position i, defaulting to the root
+func jsonPathArgument(arr []string, i int) string {
+	if len(arr) > i {
+		return arr[i]
+	}
+	return "."
+}
+
+// jsonIntegers replies with the first integer for legacy paths or an array of integers (null for non matching values)
+func jsonIntegers(path string, values []*int) []byte {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		if value == nil {
+			elements[i] = tobytes.Null()
+		} else {
+			elements[i] = tobytes.Int(*value)
+		}
+	}
+	if cache.IsLegacyJSONPath(path) {
+		return elements[0]
+	}
+	return tobytes.Array(elements...)
+}
+
+// jsonStrings replies with the first string for legacy paths or an array of strings (null for non matching values)
+func jsonStrings(path string, values []*string) []byte {
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		if value == nil {
+			elements[i] = tobytes.Null()
+		} else {
+			elements[i] = tobytes.BlobString(*value)
+		}
+	}
+	if cache.IsLegacyJSONPath(path) {
+		return elements[0]
+	}
+	return tobytes.Array(elements...)
+}
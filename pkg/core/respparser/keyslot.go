@@ -0,0 +1,35 @@
+package respparser
+
+// ClusterSlots is the amount of hash slots keys are spread among in cluster mode
+const ClusterSlots = 16384
+
+// crc16Table holds the CRC16-CCITT (XModem) of every byte, the checksum REDIS uses for hash slots
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// KeySlot returns the hash slot of a key in cluster mode, the same one REDIS gives it.
+// Only the hash tag of the key is hashed (see HashTag), so keys sharing one share a slot.
+func KeySlot(key string) int {
+	return int(crc16(HashTag(key))) % ClusterSlots
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/Arthur-phys/redigo/pkg/core/cache"
 	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
@@ -34,10 +35,31 @@ type RESPParser struct {
 	buffer                 *bufio.Reader
 	lastCommand            []byte
 	lastCommandUnprocessed bool
+	delegated              map[string]struct{}
+}
+
+// Command is a single parsed command. Args holds the name of the command followed by its arguments.
+// Run is nil for delegated commands, which have to be evaluated by the caller instead of on the cache.
+type Command struct {
+	Args []string
+	Run  func(d *cache.Cache) ([]byte, error)
 }
 
 func New(conn *net.Conn, maxBytesAllowed int) *RESPParser {
-	return &RESPParser{conn, []byte{}, 0, 0, 0, maxBytesAllowed, &bufio.Reader{}, []byte{}, false}
+	return &RESPParser{conn, []byte{}, 0, 0, 0, maxBytesAllowed, &bufio.Reader{}, []byte{}, false, make(map[string]struct{})}
+}
+
+// Delegate marks commands that do not operate on the cache (like INFO) so that ParseCommand
+// returns them without a function, letting the caller (usually a worker) answer them.
+func (r *RESPParser) Delegate(commands ...string) {
+	for _, command := range commands {
+		r.delegated[command] = struct{}{}
+	}
+}
+
+// SetMessageSizeLimit changes the size allowed for a single message, starting with the next Read
+func (r *RESPParser) SetMessageSizeLimit(maxBytesAllowed int) {
+	r.messageSizeLimit = maxBytesAllowed
 }
 
 func (r *RESPParser) NewConnection(conn *net.Conn) {
@@ -55,18 +77,36 @@ func (r *RESPParser) NewConnection(conn *net.Conn) {
 // previous commands not parsed.
 func (r *RESPParser) Read() (int, error) {
 	// Read in chunks of 4 kilobytes
-	r.rawBuffer = make([]byte, 4096)
-	r.rawBufferPosition = 0
-	n, err := (*r.conn).Read(r.rawBuffer)
+	buffer := make([]byte, 4096)
+	n, err := (*r.conn).Read(buffer)
 	if err != nil {
+		r.rawBuffer = buffer
+		r.rawBufferPosition = 0
 		return n, err
 	}
-	// From 4096 bytes, how many are actually non empty?
+	return n, r.load(buffer, n)
+}
+
+// Feed takes bytes the caller already read from the connection, as if they were returned by Read.
+// It lets callers multiplexing many connections keep a parser per connection without it blocking on a read.
+// data is copied, so the caller can reuse it.
+func (r *RESPParser) Feed(data []byte) error {
+	buffer := make([]byte, len(data))
+	copy(buffer, data)
+	return r.load(buffer, len(data))
+}
+
+// load places the first n bytes of a buffer in the internal bufio.Reader, after any previous command not parsed.
+func (r *RESPParser) load(buffer []byte, n int) error {
+	r.rawBuffer = buffer
+	r.rawBufferPosition = 0
+	// From the buffer, how many bytes are actually non empty?
 	r.rawBufferEffectiveSize = n
 
 	// Whenever the last command was not complete, add that to the buffer
 	if r.lastCommandUnprocessed {
-		r.totalBytesRead += n
+		// Only the incomplete command counts, so many complete commands sent at once never add up
+		r.totalBytesRead = len(r.lastCommand) + n
 		r.rawBuffer = append(r.lastCommand, r.rawBuffer[:n]...)
 		r.rawBufferEffectiveSize += len(r.lastCommand)
 		// Make sure to remove the last command and also the unprocessed flag!
@@ -89,19 +129,19 @@ func (r *RESPParser) Read() (int, error) {
 		redigoError := redigoerr.MaxSizePerCallExceeded
 		redigoError.ExtraContext["maxSize"] = fmt.Sprintf("%d", r.messageSizeLimit)
 		redigoError.ExtraContext["currentSize"] = fmt.Sprintf("%d", r.totalBytesRead)
-		return n, redigoError
+		return redigoError
 	}
-	return n, nil
+	return nil
 }
 
 // ParseCommand will use the RESPParser to parse as many commands as possible from the given internal buffer.
 //
 // It returns all commands able to be parsed at once to the client, incluiding any errors.
-func (r *RESPParser) ParseCommand() ([]func(d *cache.Cache) ([]byte, error), error) {
+func (r *RESPParser) ParseCommand() ([]Command, error) {
 	var (
 		// To create the array of strings this function needs to call itself
 		internalParser func() error
-		commands       []func(d *cache.Cache) ([]byte, error)
+		commands       []Command
 	)
 
 	internalParser = func() error {
@@ -121,11 +161,17 @@ func (r *RESPParser) ParseCommand() ([]func(d *cache.Cache) ([]byte, error), err
 		// Now for every blobString array representing a command, we select the function and
 		// Call the parser again
 		r.rawBufferPosition += n
+		if len(blobStrings) > 0 {
+			if _, ok := r.delegated[blobStrings[0]]; ok {
+				commands = append(commands, Command{Args: blobStrings})
+				return internalParser()
+			}
+		}
 		f, err := selectFunction(blobStrings)
 		if err != nil {
 			return err
 		}
-		commands = append(commands, f)
+		commands = append(commands, Command{Args: blobStrings, Run: f})
 		// Now go for the next command in the same buffer
 		return internalParser()
 	}
@@ -348,6 +394,68 @@ func (r *RESPParser) readUntilSliceFound(delim []byte) ([]byte, int, error) {
 	return bytes, totalBytesRead, err
 }
 
+// writeCommands are the commands that may modify the cache
+var writeCommands = map[string]struct{}{
+	"SET": {}, "DEL": {}, "RPUSH": {}, "RPOP": {}, "LPUSH": {}, "LPOP": {},
+	"SETBIT": {}, "BITOP": {}, "BITFIELD": {},
+	"PFADD": {}, "PFMERGE": {},
+	"GEOADD": {}, "GEOSEARCHSTORE": {},
+	"JSON.SET": {}, "JSON.DEL": {}, "JSON.ARRAPPEND": {}, "JSON.ARRINSERT": {}, "JSON.ARRPOP": {}, "JSON.NUMINCRBY": {},
+	"HSET": {}, "HDEL": {}, "RESTORE": {},
+	"MOVE": {}, "SWAPDB": {}, "FLUSHDB": {}, "FLUSHALL": {},
+}
+
+// IsWriteCommand tells if a command may modify the cache. Remember to add new commands that do.
+func IsWriteCommand(command string) bool {
+	_, ok := writeCommands[command]
+	return ok
+}
+
+// CommandKeys returns the keys a command reads or modifies, none for commands not working on keys.
+// Remember to add new commands working on keys.
+func CommandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	switch args[0] {
+	case "PFCOUNT", "PFMERGE":
+		return args[1:]
+	case "BITOP":
+		if len(args) < 3 {
+			return nil
+		}
+		return args[2:]
+	case "GEOSEARCHSTORE":
+		if len(args) < 3 {
+			return args[1:]
+		}
+		return args[1:3]
+	case "GET", "SET", "DEL", "RPUSH", "RPOP", "LPUSH", "LPOP", "LLEN", "LINDEX",
+		"SETBIT", "GETBIT", "BITCOUNT", "BITPOS", "BITFIELD",
+		"PFADD", "GEOADD", "GEOPOS", "GEODIST", "GEOHASH", "GEOSEARCH",
+		"JSON.SET", "JSON.GET", "JSON.DEL", "JSON.ARRAPPEND", "JSON.ARRINSERT", "JSON.ARRPOP", "JSON.NUMINCRBY", "JSON.TYPE", "JSON.OBJKEYS",
+		"HSET", "HGET", "HGETALL", "HDEL", "HLEN", "DUMP", "RESTORE", "MOVE":
+		return args[1:2]
+	default:
+		return nil
+	}
+}
+
+// HashTag returns the part of a key deciding where it is stored when keys are spread among servers: the content
+// of its first {...} when not empty, the whole key otherwise. Keys like {user:1}:name and {user:1}:age share a tag,
+// so they are always stored together.
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
 // selectFunction will read an array of strings and return a command to be run on the cache.
 //
 // Here's where you would implement a new command.
@@ -499,6 +607,18 @@ func selectFunction(arr []string) (func(d *cache.Cache) ([]byte, error), error)
 		return func(d *cache.Cache) ([]byte, error) {
 			return tobytes.Pong(), nil
 		}, nil
+	case "SETBIT", "GETBIT", "BITCOUNT", "BITPOS", "BITOP", "BITFIELD":
+		return selectBitmapFunction(arr)
+	case "PFADD", "PFCOUNT", "PFMERGE":
+		return selectHyperLogLogFunction(arr)
+	case "GEOADD", "GEOPOS", "GEODIST", "GEOHASH", "GEOSEARCH", "GEOSEARCHSTORE":
+		return selectGeoFunction(arr)
+	case "JSON.SET", "JSON.GET", "JSON.DEL", "JSON.ARRAPPEND", "JSON.ARRINSERT", "JSON.ARRPOP", "JSON.NUMINCRBY", "JSON.TYPE", "JSON.OBJKEYS":
+		return selectJSONFunction(arr)
+	case "HSET", "HGET", "HGETALL", "HDEL", "HLEN":
+		return selectHashFunction(arr)
+	case "DUMP", "RESTORE":
+		return selectDumpFunction(arr)
 	default:
 		redigoError := redigoerr.FunctionNotFound
 		redigoError.ExtraContext["function"] = arr[0]
@@ -7,7 +7,10 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"slices"
 	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
 )
 
 func Test_ParseCommand_Should_Not_Return_Err_When_Passed_Valid_Command_As_Bytes(t *testing.T) {
@@ -91,6 +94,25 @@ func Test_ParseCommand_Should_Return_Multiple_Functions_When_Passed_Multiple_Com
 	}
 }
 
+func Test_ParseCommand_Should_Return_Command_Without_Function_When_Delegated(t *testing.T) {
+	incomingBytes := fmt.Appendf([]byte{}, "*2\r\n$4\r\nINFO\r\n$5\r\nstats\r\n*2\r\n$3\r\nGET\r\n$1\r\nB\r\n")
+	parser := New(nil, 10240)
+	parser.Delegate("INFO")
+	parser.rawBuffer = incomingBytes
+	parser.buffer = bufio.NewReader(bytes.NewReader(incomingBytes))
+	parser.rawBufferEffectiveSize = len(incomingBytes)
+	commands, _ := parser.ParseCommand()
+	if len(commands) != 2 {
+		t.Fatalf("Unexpected len for commands! %d", len(commands))
+	}
+	if commands[0].Run != nil || len(commands[0].Args) != 2 || commands[0].Args[1] != "stats" {
+		t.Errorf("Delegated command should only have arguments! %v", commands[0].Args)
+	}
+	if commands[1].Run == nil || commands[1].Args[0] != "GET" {
+		t.Errorf("Regular command should have a function! %v", commands[1].Args)
+	}
+}
+
 func Test_ParseBlobString_Should_Return_String_When_Passed_Valid_Bytes(t *testing.T) {
 	incomingBytes := fmt.Appendf([]byte{}, "$9\r\npingüino\r\n")
 	parser := RESPParser{}
@@ -242,3 +264,126 @@ func TestReadUntilSliceFound_Should_Return_Whole_Slice_From_Reader_When_Slice_Lo
 		}
 	}
 }
+
+func Test_ParseBitFieldOps_Should_Apply_Overflow_To_Following_Subcommands(t *testing.T) {
+	ops, err := parseBitFieldOps([]string{"GET", "u4", "0", "OVERFLOW", "SAT", "INCRBY", "i8", "#2", "10", "set", "u8", "3", "1"})
+	if err != nil {
+		t.Errorf("An unexpected error happened! %v", err)
+	}
+	if len(ops) != 3 {
+		t.Errorf("Unexpected len for operations! %d", len(ops))
+	}
+	if ops[0].Overflow != cache.OverflowWrap || ops[1].Overflow != cache.OverflowSat || ops[2].Overflow != cache.OverflowSat {
+		t.Errorf("Overflow policy was not applied correctly! %v", ops)
+	}
+	if ops[1].Offset != 16 || !ops[1].Signed || ops[1].Width != 8 || ops[1].Value != 10 {
+		t.Errorf("Unexpected INCRBY operation! %v", ops[1])
+	}
+}
+
+func Test_ParseBitFieldOps_Should_Return_Err_When_Type_Is_Invalid(t *testing.T) {
+	if _, err := parseBitFieldOps([]string{"GET", "u64", "0"}); err == nil {
+		t.Errorf("Error did not happen!")
+	}
+	if _, err := parseBitFieldOps([]string{"SET", "i8", "0"}); err == nil {
+		t.Errorf("Error did not happen!")
+	}
+}
+
+func Test_IsWriteCommand_Should_Tell_Writes_From_Reads(t *testing.T) {
+	for _, command := range []string{"SET", "LPUSH", "PFADD", "GEOSEARCHSTORE", "JSON.ARRPOP", "HSET", "HDEL"} {
+		if !IsWriteCommand(command) {
+			t.Errorf("Command should be a write! %s", command)
+		}
+	}
+	for _, command := range []string{"GET", "LLEN", "PFCOUNT", "GEOSEARCH", "JSON.GET", "HGETALL", "INFO"} {
+		if IsWriteCommand(command) {
+			t.Errorf("Command should not be a write! %s", command)
+		}
+	}
+}
+
+func Test_CommandKeys_Should_Return_Every_Key_When_Command_Works_On_Many(t *testing.T) {
+	cases := []struct {
+		args []string
+		keys []string
+	}{
+		{[]string{"GET", "cat"}, []string{"cat"}},
+		{[]string{"LPUSH", "cats", "Niji", "Anubis"}, []string{"cats"}},
+		{[]string{"PFCOUNT", "a", "b"}, []string{"a", "b"}},
+		{[]string{"BITOP", "AND", "dest", "a", "b"}, []string{"dest", "a", "b"}},
+		{[]string{"GEOSEARCHSTORE", "dest", "src", "FROMMEMBER", "m", "BYRADIUS", "1", "km"}, []string{"dest", "src"}},
+		{[]string{"HSET", "cat", "name", "Niji"}, []string{"cat"}},
+		{[]string{"INFO", "keyspace"}, nil},
+		{[]string{"PING"}, nil},
+	}
+	for _, c := range cases {
+		if keys := CommandKeys(c.args); !slices.Equal(keys, c.keys) {
+			t.Errorf("Unexpected keys for %v! %v", c.args, keys)
+		}
+	}
+}
+
+func Test_HashTag_Should_Return_Content_Of_First_Braces_When_Not_Empty(t *testing.T) {
+	cases := map[string]string{
+		"user:1":          "user:1",
+		"{user:1}:name":   "user:1",
+		"cart:{user:1}":   "user:1",
+		"{a}{b}":          "a",
+		"{}:name":         "{}:name",
+		"{user:1":         "{user:1",
+		"}user{:1}{name}": ":1",
+	}
+	for key, tag := range cases {
+		if HashTag(key) != tag {
+			t.Errorf("Unexpected tag for %s! %s", key, HashTag(key))
+		}
+	}
+}
+
+func Test_KeySlot_Should_Match_Redis_When_Hashing_Keys(t *testing.T) {
+	cases := map[string]int{
+		"123456789":            12739,
+		"foo":                  12182,
+		"{user1000}.following": 3443,
+		"{user1000}.followers": 3443,
+		"user1000":             3443,
+		"":                     0,
+	}
+	for key, slot := range cases {
+		if KeySlot(key) != slot {
+			t.Errorf("Unexpected slot for %s! %d", key, KeySlot(key))
+		}
+	}
+}
+
+func Test_Feed_Should_Keep_Incomplete_Command_When_Fed_In_Pieces(t *testing.T) {
+	parser := New(nil, 10240)
+	if err := parser.Feed([]byte("*2\r\n$3\r\nGET\r")); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if commands, err := parser.ParseCommand(); len(commands) != 0 {
+		t.Errorf("Incomplete command was parsed! %d %v", len(commands), err)
+	}
+	if err := parser.Feed([]byte("\n$1\r\nB\r\n")); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	commands, _ := parser.ParseCommand()
+	if len(commands) != 1 || commands[0].Args[1] != "B" {
+		t.Errorf("Unexpected commands! %v", commands)
+	}
+}
+
+func Test_Feed_Should_Not_Exceed_Limit_When_Only_Complete_Commands_Add_Up(t *testing.T) {
+	parser := New(nil, 64)
+	command := []byte("*2\r\n$3\r\nGET\r\n$1\r\nB\r\n")
+	parser.Feed(command[:10])
+	parser.ParseCommand()
+	for range 10 {
+		// Every chunk finishes the previous command and leaves another one incomplete
+		if err := parser.Feed(append(command[10:], command[:10]...)); err != nil {
+			t.Fatalf("An error occurred! %v", err)
+		}
+		parser.ParseCommand()
+	}
+}
@@ -5,17 +5,24 @@
 package tobytes
 
 import (
-	"fmt"
+	"strconv"
 
 	"github.com/Arthur-phys/redigo/pkg/redigoerr"
 )
 
+// BlobString copies s as it is, so any content (like \r\n, NUL or %) is sent unchanged
 func BlobString(s string) []byte {
-	return fmt.Appendf([]byte{'$'}, fmt.Sprintf("%d\r\n%v\r\n", len(s), s))
+	b := make([]byte, 0, len(s)+16)
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, '\r', '\n')
+	b = append(b, s...)
+	return append(b, '\r', '\n')
 }
 
 func Int(i int) []byte {
-	return fmt.Appendf([]byte{':'}, fmt.Sprintf("%v\r\n", i))
+	b := strconv.AppendInt([]byte{':'}, int64(i), 10)
+	return append(b, '\r', '\n')
 }
 
 func Null() []byte {
@@ -25,11 +32,31 @@ func Null() []byte {
 func Err(err error) []byte {
 	redigoError, ok := err.(redigoerr.Error)
 	if !ok {
-		return fmt.Appendf([]byte{'-'}, "Internal Server Error\r\n")
+		return []byte("-Internal Server Error\r\n")
 	}
-	return fmt.Appendf([]byte{'-'}, fmt.Sprintf("%v\r\n", redigoError.ClientContext))
+	b := append([]byte{'-'}, redigoError.ClientContext...)
+	return append(b, '\r', '\n')
 }
 
 func Pong() []byte {
-	return fmt.Appendf([]byte{'$'}, "4\r\nPONG\r\n")
+	return []byte("$4\r\nPONG\r\n")
+}
+
+func Array(elements ...[]byte) []byte {
+	arr := strconv.AppendInt([]byte{'*'}, int64(len(elements)), 10)
+	arr = append(arr, '\r', '\n')
+	for _, element := range elements {
+		arr = append(arr, element...)
+	}
+	return arr
+}
+
+// Push is an out of band message, which clients tell apart from the responses to their commands
+func Push(elements ...[]byte) []byte {
+	push := strconv.AppendInt([]byte{'>'}, int64(len(elements)), 10)
+	push = append(push, '\r', '\n')
+	for _, element := range elements {
+		push = append(push, element...)
+	}
+	return push
 }
@@ -53,3 +53,32 @@ func TestErrToBytes_Should_Return_Expected_Formatted_Bytes(t *testing.T) {
 		}
 	}
 }
+
+func TestArray_Should_Return_Expected_Formatted_Bytes(t *testing.T) {
+	byteString := Array(Int(1), Null(), BlobString("ab"))
+	arr := fmt.Appendf([]byte{}, "*3\r\n:1\r\n_\r\n$2\r\nab\r\n")
+	if len(byteString) != len(arr) {
+		t.Errorf("Lengths did not match! %d != %d", len(byteString), len(arr))
+	}
+	for i := range byteString {
+		if byteString[i] != arr[i] {
+			t.Errorf("Bytes did not match! %v != %v", byteString[i], arr[i])
+		}
+	}
+}
+
+func TestPush_Should_Return_Expected_Formatted_Bytes(t *testing.T) {
+	byteString := Push(BlobString("invalidate"), Array(BlobString("ab")))
+	arr := fmt.Appendf([]byte{}, ">2\r\n$10\r\ninvalidate\r\n*1\r\n$2\r\nab\r\n")
+	if string(byteString) != string(arr) {
+		t.Errorf("Bytes did not match! %q != %q", byteString, arr)
+	}
+}
+
+func TestBlobString_Should_Keep_Content_Unchanged_When_It_Holds_Special_Bytes(t *testing.T) {
+	sample := "100%d\r\n\x00%v"
+	expected := "$10\r\n" + sample + "\r\n"
+	if byteString := BlobString(sample); string(byteString) != expected {
+		t.Errorf("Bytes did not match! %q != %q", byteString, expected)
+	}
+}
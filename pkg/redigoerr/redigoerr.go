@@ -14,11 +14,13 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"os"
+	"syscall"
 )
 
 var (
-	KeyNotFoundInDictionary        = Error{"Key not found in dictionary", "", 1, nil, make(map[string]string)}
+	KeyNotFoundInDictionary        = Error{"Key not found in dictionary", "no such key", 1, nil, make(map[string]string)}
 	IndexOutOfRangeErr             = Error{"Index set is out of range", "", 2, nil, make(map[string]string)}
 	UnableToReadFirstByte          = Error{"Unable to read first byte", "", 3, nil, make(map[string]string)}
 	UnableToFindPattern            = Error{"Unable to find byte pattern in byte stream", "", 4, nil, make(map[string]string)}
@@ -36,6 +38,63 @@ var (
 	MaxSizePerCallExceeded         = Error{"Max size per call exceeded the marked threshold", "Call exceeded size allowed", 17, nil, make(map[string]string)}
 	WrongType                      = Error{"Operation against a key holding the wrong kind of value", "Operation against a key holding the wrong kind of value", 18, nil, make(map[string]string)}
 	UnableToCreateServer           = Error{"Unable to create the redigo server", "", 19, nil, make(map[string]string)}
+	SyntaxError                    = Error{"Syntax error in command arguments", "syntax error", 20, nil, make(map[string]string)}
+	NotAnInteger                   = Error{"Unable to convert the provided value to an integer", "value is not an integer or out of range", 21, nil, make(map[string]string)}
+	BitOffsetOutOfRange            = Error{"Bit offset provided is not an integer or out of range", "bit offset is not an integer or out of range", 22, nil, make(map[string]string)}
+	BitValueOutOfRange             = Error{"Bit value provided is not 0 or 1", "bit is not an integer or out of range", 23, nil, make(map[string]string)}
+	InvalidBitfieldType            = Error{"Bitfield type provided is not valid", "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.", 24, nil, make(map[string]string)}
+	NotAFloat                      = Error{"Unable to convert the provided value to a float", "value is not a valid float", 25, nil, make(map[string]string)}
+	InvalidLongitudeLatitude       = Error{"Longitude or latitude provided is out of range", "invalid longitude,latitude pair", 26, nil, make(map[string]string)}
+	UnsupportedUnit                = Error{"Distance unit provided is not supported", "unsupported unit provided. please use M, KM, FT, MI", 27, nil, make(map[string]string)}
+	GeoMemberNotFound              = Error{"Member used as center of a search was not found", "could not decode requested zset member", 28, nil, make(map[string]string)}
+	InvalidJSON                    = Error{"Value provided is not valid JSON", "expected a valid JSON value", 29, nil, make(map[string]string)}
+	InvalidJSONPath                = Error{"JSON path provided is not valid", "invalid JSON path", 30, nil, make(map[string]string)}
+	JSONPathNotFound               = Error{"JSON path provided does not exist in the document", "path does not exist", 31, nil, make(map[string]string)}
+	JSONNewObjectsAtRoot           = Error{"New JSON documents must be created at the root path", "new objects must be created at the root", 32, nil, make(map[string]string)}
+	InvalidClientName              = Error{"Client name provided contains spaces, newlines or special characters", "Client names cannot contain spaces, newlines or special characters.", 33, nil, make(map[string]string)}
+	NoSuchClient                   = Error{"No client matches the one provided", "No such client", 34, nil, make(map[string]string)}
+	UnknownConfigParameter         = Error{"Configuration parameter provided does not exist", "Unknown option or number of arguments for CONFIG SET", 35, nil, make(map[string]string)}
+	InvalidConfigValue             = Error{"Value provided for a configuration parameter is not valid", "CONFIG SET failed, invalid argument", 36, nil, make(map[string]string)}
+	ImmutableConfigParameter       = Error{"Configuration parameter provided can only be set at startup", "can't set immutable config", 37, nil, make(map[string]string)}
+	NoConfigFile                   = Error{"There is no configuration file to rewrite", "The server is running without a config file", 38, nil, make(map[string]string)}
+	UnableToUseConfigFile          = Error{"Unable to read or write the configuration file", "Rewriting config file failed", 39, nil, make(map[string]string)}
+	OutOfMemory                    = Error{"Used memory exceeds maxmemory", "OOM command not allowed when used memory > 'maxmemory'.", 40, nil, make(map[string]string)}
+	InvalidDBIndex                 = Error{"Database index provided is out of range", "DB index is out of range", 41, nil, make(map[string]string)}
+	SameObject                     = Error{"Source and destination provided are the same", "source and destination objects are the same", 42, nil, make(map[string]string)}
+	MaxClientsReached              = Error{"Connection rejected since maxclients was reached", "max number of clients reached", 43, nil, make(map[string]string)}
+	EventLoopUnavailable           = Error{"Connections can not be multiplexed with an event loop", "", 44, nil, make(map[string]string)}
+	ServerAlreadyStarted           = Error{"Server was already started", "", 45, nil, make(map[string]string)}
+	ServerClosed                   = Error{"Server was shut down and can not be started again", "", 46, nil, make(map[string]string)}
+	ShutdownTimedOut               = Error{"Unable to close every connection before the shutdown deadline", "", 47, nil, make(map[string]string)}
+	PipelineNotExecuted            = Error{"Pipeline was not executed yet", "", 48, nil, make(map[string]string)}
+	InvalidPoolOptions             = Error{"Pool options provided are invalid", "", 49, nil, make(map[string]string)}
+	PoolTimeout                    = Error{"Timed out waiting for a connection of the pool", "", 50, nil, make(map[string]string)}
+	PoolClosed                     = Error{"Pool is closed", "", 51, nil, make(map[string]string)}
+	UnsupportedArgument            = Error{"Argument type can not be sent to the server", "", 52, nil, make(map[string]string)}
+	ConnectionBroken               = Error{"Connection was left in an unknown state by a previous command and can not be used", "", 53, nil, make(map[string]string)}
+	ClientClosed                   = Error{"Client is closed", "", 54, nil, make(map[string]string)}
+	PrefixWithoutBCAST             = Error{"Tracking prefixes were given without BCAST mode", "PREFIX option requires BCAST mode to be enabled", 55, nil, make(map[string]string)}
+	UnableToEncodeValue            = Error{"Value could not be encoded by the codec", "", 56, nil, make(map[string]string)}
+	UnableToDecodeValue            = Error{"Value could not be decoded by the codec", "", 57, nil, make(map[string]string)}
+	InvalidShardNodes              = Error{"Nodes given to the sharded client are invalid", "", 58, nil, make(map[string]string)}
+	ClusterDisabled                = Error{"Cluster commands were sent to a server without cluster mode", "This instance has cluster support disabled", 59, nil, make(map[string]string)}
+	Moved                          = Error{"Key belongs to a hash slot served by another node", "MOVED", 60, nil, make(map[string]string)}
+	Ask                            = Error{"Key belongs to a hash slot being migrated to another node", "ASK", 61, nil, make(map[string]string)}
+	CrossSlot                      = Error{"Keys provided belong to different hash slots", "CROSSSLOT Keys in request don't hash to the same slot", 62, nil, make(map[string]string)}
+	ClusterDown                    = Error{"Hash slot is not served by any node", "CLUSTERDOWN Hash slot not served", 63, nil, make(map[string]string)}
+	TryAgain                       = Error{"Keys provided are split among nodes while their slot is migrated", "TRYAGAIN Multiple keys request during rehashing of slot", 64, nil, make(map[string]string)}
+	InvalidSlot                    = Error{"Hash slot provided is not an integer or out of range", "Invalid or out of range slot", 65, nil, make(map[string]string)}
+	SlotAlreadyAssigned            = Error{"Hash slot provided is already served by a node", "Slot is already busy", 66, nil, make(map[string]string)}
+	UnknownNode                    = Error{"Node provided is not known by the cluster", "Unknown node", 67, nil, make(map[string]string)}
+	SelectInClusterMode            = Error{"Only the database 0 can be selected in cluster mode", "SELECT is not allowed in cluster mode", 68, nil, make(map[string]string)}
+	BusyKey                        = Error{"Key to restore already exists", "BUSYKEY Target key name already exists.", 69, nil, make(map[string]string)}
+	InvalidDumpPayload             = Error{"Payload to restore is not one created by DUMP", "DUMP payload version or checksum are wrong", 70, nil, make(map[string]string)}
+	UnableToMigrate                = Error{"Keys could not be sent to the target node", "IOERR error or timeout writing to target instance", 71, nil, make(map[string]string)}
+	TooManyRedirects               = Error{"Command was redirected more times than allowed", "", 72, nil, make(map[string]string)}
+	SlotNotOwned                   = Error{"Hash slot provided is not served by this node", "I'm not the owner of hash slot", 73, nil, make(map[string]string)}
+	InvalidNodeAddress             = Error{"Address of the node to meet is not valid", "Invalid node address specified", 74, nil, make(map[string]string)}
+	SlotNotEmpty                   = Error{"Hash slot still holds keys in this node", "Can't assign hashslot to a different node while I still hold keys for this hash slot.", 75, nil, make(map[string]string)}
+	ShuttingDown                   = Error{"Command was not executed since the server started shutting down while clients were paused", "Server is shutting down", 76, nil, make(map[string]string)}
 )
 
 type Error struct {
@@ -50,6 +109,11 @@ func (e Error) Error() string {
 	return fmt.Sprintf("{CODE: %d -- CONTENT: %v -- FROM: %e -- INFORMATION: %v}", e.Code, e.Content, e.From, e.ExtraContext)
 }
 
+// Unwrap returns the error this one was created from, so errors.Is and errors.As look through it
+func (e Error) Unwrap() error {
+	return e.From
+}
+
 func (e Error) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.String("ERROR", e.Content),
@@ -59,8 +123,18 @@ func (e Error) LogValue() slog.Value {
 	)
 }
 
+// ErrorCode returns the code of a redigo error and whether the error was one
+func ErrorCode(e error) (uint16, bool) {
+	err, ok := e.(Error)
+	return err.Code, ok
+}
+
 func ConnectionRelated(err error) bool {
-	return err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, os.ErrDeadlineExceeded) || err == io.ErrClosedPipe
+	if code, ok := ErrorCode(err); ok && code == ConnectionBroken.Code {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
 }
 
 func IndexOutOfRange(e error) bool {
@@ -73,6 +147,11 @@ func KeyNotFound(e error) bool {
 	return err.Code == 1 && ok
 }
 
+func JSONPathMissing(e error) bool {
+	err, ok := e.(Error)
+	return err.Code == 31 && ok
+}
+
 func ExceededMaxSize(e error) bool {
 	err, ok := e.(Error)
 	return err.Code == 17 && ok
@@ -0,0 +1,331 @@
+// redigotest package starts a REDIGO server to be used as a fake REDIS inside go test.
+//
+// The server listens on a random port, gives a connected client and is shut down when the test finishes.
+// Keys can be seeded and inspected directly, without going through the network. Inspecting keys does not count
+// as keyspace hits or misses, and clients with CLIENT TRACKING on are told about keys seeded:
+//
+//	func TestSomething(t *testing.T) {
+//		s := redigotest.NewServer(t)
+//		s.Seed(map[string]string{"user": "Arturo"})
+//		res, err := s.Client().Get("user")
+//		...
+//		if v, _ := s.Get("user"); v != "..." {
+//			t.Errorf(...)
+//		}
+//	}
+//
+// There is no helper to fast-forward time for TTLs: keys never expire in REDIGO, since EXPIRE and friends are not
+// implemented and RESTORE ignores its ttl. Such a helper is left for when expiry exists.
+//
+// NewCluster starts a few servers in cluster mode instead, splitting the hash slots among them:
+//
+//	c := redigotest.NewCluster(t, 3)
+//	err := c.NewClient().Set("user", "Arturo")
+package redigotest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/client"
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+	"github.com/Arthur-phys/redigo/pkg/server"
+)
+
+// shutdownTimeout is the time given to the server to close its connections when the test finishes
+const shutdownTimeout = time.Second
+
+// clusterTimeout is the time nodes of a cluster are given to learn about each other
+const clusterTimeout = 10 * time.Second
+
+// Server is a REDIGO server living as long as the test that started it
+type Server struct {
+	tb     testing.TB
+	server *server.Server
+	addr   string
+	client *client.Client
+}
+
+// NewServer starts a server with settings fit for tests: a random port, no slow log and no logs
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+	return NewServerWithConfig(tb, &server.Configuration{
+		MinWorkers:        1,
+		MaxWorkers:        64,
+		KeepAlive:         60,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+	})
+}
+
+// NewServerWithConfig starts a server with the given configuration, except for the address which is always
+// a random port on 127.0.0.1. Logs are discarded unless a Logger is given.
+func NewServerWithConfig(tb testing.TB, serverConfig *server.Configuration) *Server {
+	tb.Helper()
+	config := *serverConfig
+	config.IpAddress = "127.0.0.1"
+	config.Port = 0
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	s, err := server.New(&config)
+	if err != nil {
+		tb.Fatalf("Unable to create server! %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		tb.Fatalf("Unable to start server! %v", err)
+	}
+	testServer := &Server{tb: tb, server: s, addr: s.Addr().String()}
+	tb.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		// Connections left open by the test are closed after the timeout, which is fine
+		s.Shutdown(ctx)
+	})
+	testServer.client = testServer.NewClient()
+	return testServer
+}
+
+// Addr returns the address (ip:port) the server listens on
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Server returns the server itself
+func (s *Server) Server() *server.Server {
+	return s.server
+}
+
+// Client returns a client connected when the server started
+func (s *Server) Client() *client.Client {
+	return s.client
+}
+
+// NewClient connects a new client, closing its connection when the test finishes
+func (s *Server) NewClient() *client.Client {
+	s.tb.Helper()
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		s.tb.Fatalf("Unable to connect to server! %v", err)
+	}
+	s.tb.Cleanup(func() { conn.Close() })
+	return client.New(&conn)
+}
+
+// withDB runs f with a database locked, failing the test if the index is out of range
+func (s *Server) withDB(index int, f func(db *cache.Cache)) {
+	s.tb.Helper()
+	db := s.server.Database(index)
+	if db == nil {
+		s.tb.Fatalf("Database %d does not exist!", index)
+	}
+	db.Lock()
+	defer db.Unlock()
+	f(db)
+}
+
+// Seed sets string keys in the database 0
+func (s *Server) Seed(values map[string]string) {
+	s.tb.Helper()
+	s.SeedDB(0, values)
+}
+
+// SeedDB sets string keys in a database
+func (s *Server) SeedDB(index int, values map[string]string) {
+	s.tb.Helper()
+	keys := make([]string, 0, len(values))
+	s.withDB(index, func(db *cache.Cache) {
+		for key, value := range values {
+			db.Set(key, value)
+			keys = append(keys, key)
+		}
+	})
+	s.server.Invalidate(keys...)
+}
+
+// SeedList appends values to a list in the database 0, creating it when needed
+func (s *Server) SeedList(key string, values ...string) {
+	s.tb.Helper()
+	var err error
+	s.withDB(0, func(db *cache.Cache) {
+		err = db.RPush(key, values...)
+	})
+	if err != nil {
+		s.tb.Fatalf("Unable to seed list %s! %v", key, err)
+	}
+	s.server.Invalidate(key)
+}
+
+// Get returns the string value of a key in the database 0, and whether it exists.
+// The test fails if the key holds something else.
+func (s *Server) Get(key string) (string, bool) {
+	s.tb.Helper()
+	return s.GetDB(0, key)
+}
+
+// GetDB returns the string value of a key in a database, and whether it exists.
+// The test fails if the key holds something else.
+func (s *Server) GetDB(index int, key string) (string, bool) {
+	s.tb.Helper()
+	var (
+		value string
+		err   error
+	)
+	s.withDB(index, func(db *cache.Cache) {
+		value, err = db.Peek(key)
+	})
+	if notFound(err) {
+		return "", false
+	} else if err != nil {
+		s.tb.Fatalf("Unable to get %s! %v", key, err)
+	}
+	return value, true
+}
+
+// List returns every value of a list in the database 0, which is empty when the key does not exist
+func (s *Server) List(key string) []string {
+	s.tb.Helper()
+	var (
+		values []string
+		err    error
+	)
+	s.withDB(0, func(db *cache.Cache) {
+		values, err = db.PeekList(key)
+	})
+	if notFound(err) {
+		return []string{}
+	} else if err != nil {
+		s.tb.Fatalf("Unable to read list %s! %v", key, err)
+	}
+	return values
+}
+
+// Keys returns the amount of keys in the database 0
+func (s *Server) Keys() int {
+	s.tb.Helper()
+	keys := 0
+	s.withDB(0, func(db *cache.Cache) {
+		keys = db.Stats().Keys
+	})
+	return keys
+}
+
+// FlushAll removes every key of every database
+func (s *Server) FlushAll() {
+	s.tb.Helper()
+	for index := 0; s.server.Database(index) != nil; index++ {
+		s.withDB(index, func(db *cache.Cache) {
+			db.Flush(false)
+		})
+	}
+	s.server.Invalidate()
+}
+
+func notFound(err error) bool {
+	code, ok := redigoerr.ErrorCode(err)
+	return ok && code == redigoerr.KeyNotFoundInDictionary.Code
+}
+
+// Cluster is a few servers in cluster mode living as long as the test that started them
+type Cluster struct {
+	tb    testing.TB
+	nodes []*Server
+}
+
+// NewCluster starts size servers in cluster mode with the settings of NewServer, meets them and splits the hash slots
+// evenly among them in order, waiting until every node knows who serves each slot
+func NewCluster(tb testing.TB, size int) *Cluster {
+	tb.Helper()
+	c := &Cluster{tb: tb, nodes: make([]*Server, size)}
+	for i := range c.nodes {
+		c.nodes[i] = NewServerWithConfig(tb, &server.Configuration{
+			MinWorkers:         1,
+			MaxWorkers:         64,
+			KeepAlive:          60,
+			MessageSizeLimit:   10240,
+			ShutdownTolerance:  1,
+			SlowLogThreshold:   -1,
+			ClusterEnabled:     true,
+			ClusterNodeTimeout: clusterTimeout.Milliseconds(),
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	for i, node := range c.nodes {
+		if i > 0 {
+			_, port, _ := net.SplitHostPort(node.Addr())
+			busPort := node.Server().ClusterBusAddr().(*net.TCPAddr).Port
+			if _, err := c.nodes[0].Client().Do(ctx, "CLUSTER", "MEET", "127.0.0.1", port, busPort); err != nil {
+				tb.Fatalf("Unable to meet node %d! %v", i, err)
+			}
+		}
+		start, end := i*respparser.ClusterSlots/size, (i+1)*respparser.ClusterSlots/size-1
+		if _, err := node.Client().Do(ctx, "CLUSTER", "ADDSLOTSRANGE", start, end); err != nil {
+			tb.Fatalf("Unable to assign slots to node %d! %v", i, err)
+		}
+	}
+	c.Converge()
+	return c
+}
+
+// Nodes returns every server of the cluster, in the order of the slots they were given
+func (c *Cluster) Nodes() []*Server {
+	return c.nodes
+}
+
+// Node returns the server listening on an address, nil when none of the cluster does
+func (c *Cluster) Node(address string) *Server {
+	for _, node := range c.nodes {
+		if node.Addr() == address {
+			return node
+		}
+	}
+	return nil
+}
+
+// Converge waits until every node knows every other one and who serves each slot, failing the test after a while
+func (c *Cluster) Converge() {
+	c.tb.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	for _, node := range c.nodes {
+		for {
+			rep, err := node.Client().Do(ctx, "CLUSTER", "INFO")
+			info, _ := rep.String()
+			if err == nil && strings.Contains(info, "cluster_state:ok") && strings.Contains(info, "cluster_known_nodes:"+strconv.Itoa(len(c.nodes))+"\r\n") {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				c.tb.Fatalf("The cluster did not converge in time! %v %s", err, info)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+}
+
+// NewClient creates a cluster client knowing every node, closed when the test finishes
+func (c *Cluster) NewClient() *client.ClusterClient {
+	c.tb.Helper()
+	addresses := make([]string, len(c.nodes))
+	for i, node := range c.nodes {
+		addresses[i] = node.Addr()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	clusterClient, err := client.NewCluster(ctx, client.ClusterOptions{Addresses: addresses})
+	if err != nil {
+		c.tb.Fatalf("Unable to create cluster client! %v", err)
+	}
+	c.tb.Cleanup(func() { clusterClient.Close() })
+	return clusterClient
+}
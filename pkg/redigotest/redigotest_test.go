@@ -0,0 +1,114 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package redigotest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func TestNewServer_Should_Serve_Seeded_Keys_When_Client_Reads_Them(t *testing.T) {
+	s := NewServer(t)
+	s.Seed(map[string]string{"Arturo": "26"})
+	res, err := s.Client().Get("Arturo")
+	if err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if res != "26" {
+		t.Errorf("Unexpected value received! %s", res)
+	}
+}
+
+func TestNewServer_Should_Expose_Keys_When_Client_Writes_Them(t *testing.T) {
+	s := NewServer(t)
+	if err := s.NewClient().Set("Gene", "Le gustan los gatos"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, ok := s.Get("Gene"); !ok || v != "Le gustan los gatos" {
+		t.Errorf("Unexpected value found! %s - %v", v, ok)
+	}
+	if _, ok := s.Get("Missing"); ok {
+		t.Errorf("Missing key was found!")
+	}
+}
+
+func TestNewServer_Should_Inspect_Lists_When_Seeded_And_Popped(t *testing.T) {
+	s := NewServer(t)
+	s.SeedList("Gatos", "Niji", "Anubis", "Don Bigos")
+	res, err := s.Client().LPop("Gatos")
+	if err != nil || res != "Niji" {
+		t.Errorf("Unexpected value received! %s - %v", res, err)
+	}
+	if values := s.List("Gatos"); len(values) != 2 || values[0] != "Anubis" || values[1] != "Don Bigos" {
+		t.Errorf("Unexpected list found! %v", values)
+	}
+	if values := s.List("Missing"); len(values) != 0 {
+		t.Errorf("Unexpected list found! %v", values)
+	}
+}
+
+func TestNewServer_Should_Not_Count_Keyspace_Hits_When_Inspecting_Keys(t *testing.T) {
+	s := NewServer(t)
+	s.Seed(map[string]string{"Arturo": "26"})
+	s.SeedList("Gatos", "Niji")
+	s.Get("Arturo")
+	s.Get("Missing")
+	s.List("Gatos")
+	s.List("Missing")
+	info, err := s.Client().Do(context.Background(), "INFO", "stats")
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	text, _ := info.String()
+	if !strings.Contains(text, "keyspace_hits:0\r\n") || !strings.Contains(text, "keyspace_misses:0\r\n") {
+		t.Errorf("Inspecting keys counted as hits or misses! %s", text)
+	}
+}
+
+func TestNewServer_Should_Isolate_Servers_When_Started_In_The_Same_Test(t *testing.T) {
+	first, second := NewServer(t), NewServer(t)
+	if first.Addr() == second.Addr() {
+		t.Errorf("Servers share an address! %s", first.Addr())
+	}
+	first.Seed(map[string]string{"a": "1", "b": "2"})
+	if first.Keys() != 2 || second.Keys() != 0 {
+		t.Errorf("Unexpected amount of keys! %d - %d", first.Keys(), second.Keys())
+	}
+	first.SeedDB(3, map[string]string{"c": "3"})
+	first.FlushAll()
+	if _, ok := first.GetDB(3, "c"); ok || first.Keys() != 0 {
+		t.Errorf("Keys remained after flushing!")
+	}
+}
+
+func TestNewCluster_Should_Redirect_Clients_When_Keys_Belong_To_Another_Node(t *testing.T) {
+	c := NewCluster(t, 3)
+	first, last := c.Nodes()[0], c.Nodes()[2]
+	ctx := context.Background()
+	// foo hashes to the slot 12182, in the last third of the slots
+	if _, err := first.Client().Do(ctx, "SET", "foo", "bar"); err == nil ||
+		err.(redigoerr.Error).ExtraContext["text"] != "MOVED 12182 "+last.Addr() {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err := last.Client().Do(ctx, "SET", "foo", "bar"); err != nil {
+		t.Errorf("An error occurred! %v", err)
+	}
+	if v, _ := last.Get("foo"); v != "bar" {
+		t.Errorf("Unexpected value received! %s", v)
+	}
+	if _, err := last.Client().Do(ctx, "PFCOUNT", "foo", "bar"); err == nil ||
+		!strings.HasPrefix(err.(redigoerr.Error).ExtraContext["text"], "CROSSSLOT") {
+		t.Errorf("Unexpected error! %v", err)
+	}
+	if _, err := last.Client().Do(ctx, "SELECT", 1); err == nil {
+		t.Errorf("A database other than 0 was selected in cluster mode!")
+	}
+	rep, err := first.Client().Do(ctx, "CLUSTER", "NODES")
+	if nodes, _ := rep.String(); err != nil || strings.Count(nodes, "\n") != 3 || strings.Count(nodes, "myself") != 1 {
+		t.Errorf("Unexpected nodes! %v - %s", err, nodes)
+	}
+}
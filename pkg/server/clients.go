@@ -0,0 +1,310 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// protocolVersion is the RESP version spoken by the server, there is no handshake to change it
+const protocolVersion = 3
+
+// client is a connection known to the registry.
+// It is updated by the worker attending it and read by any worker answering CLIENT commands.
+type client struct {
+	id        uint64
+	conn      net.Conn
+	addr      string
+	laddr     string
+	workerID  uint64
+	createdAt time.Time
+
+	lock            sync.Mutex
+	name            string
+	lastCommand     string
+	lastInteraction time.Time
+	db              int
+	monitoring      bool
+	killed          bool
+	// onKill is called once the client is killed, so that an event loop closes the connection right away
+	onKill   func()
+	tracking *clientTracking
+	// asking lets the next command reach a slot being imported, see ASKING
+	asking bool
+
+	// outLock orders push messages with responses, which an event loop may write in pieces
+	outLock  sync.Mutex
+	partial  bool
+	deferred []byte
+}
+
+func (cl *client) getName() string {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.name
+}
+
+func (cl *client) setName(name string) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.name = name
+}
+
+// getDB returns the index of the database selected by the client
+func (cl *client) getDB() int {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.db
+}
+
+func (cl *client) setDB(db int) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.db = db
+}
+
+func (cl *client) setMonitoring() {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.monitoring = true
+}
+
+// setAsking lets the next command of the client reach a slot being imported
+func (cl *client) setAsking() {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.asking = true
+}
+
+// takeAsking tells if the client sent ASKING right before, which only lasts for a single command
+func (cl *client) takeAsking() bool {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	asking := cl.asking
+	cl.asking = false
+	return asking
+}
+
+// touch records the command the client is executing
+func (cl *client) touch(args []string) {
+	name := strings.ToLower(args[0])
+	// Container commands are shown along their subcommand
+	if (args[0] == "CLIENT" || args[0] == "SLOWLOG" || args[0] == "CONFIG" || args[0] == "CLUSTER") && len(args) > 1 {
+		name += "|" + strings.ToLower(args[1])
+	}
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.lastCommand = name
+	cl.lastInteraction = time.Now()
+}
+
+// kill makes the next (or current) read of the connection fail, so that its worker closes it.
+// Event loops ignore deadlines, so they are told through onKill instead.
+func (cl *client) kill() {
+	cl.lock.Lock()
+	cl.killed = true
+	cl.conn.SetDeadline(time.Now())
+	onKill := cl.onKill
+	cl.lock.Unlock()
+	if onKill != nil {
+		onKill()
+	}
+}
+
+func (cl *client) setOnKill(onKill func()) {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	cl.onKill = onKill
+}
+
+func (cl *client) isKilled() bool {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.killed
+}
+
+// setDeadline changes the deadline of the connection unless it was killed, in which case false is returned
+func (cl *client) setDeadline(t time.Time) bool {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	if cl.killed {
+		return false
+	}
+	cl.conn.SetDeadline(t)
+	return true
+}
+
+// info describes the client in the format of CLIENT LIST
+func (cl *client) info() string {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	flags := "N"
+	if cl.monitoring {
+		flags = "O"
+	} else if cl.tracking != nil {
+		flags = "t"
+	}
+	now := time.Now()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d worker=%d cmd=%s user=default resp=%d\n",
+		cl.id, cl.addr, cl.laddr, cl.name, int64(now.Sub(cl.createdAt).Seconds()), int64(now.Sub(cl.lastInteraction).Seconds()),
+		flags, cl.db, cl.workerID, cl.lastCommand, protocolVersion)
+}
+
+type pauseMode int32
+
+const (
+	pauseNone pauseMode = iota
+	pauseWrite
+	pauseAll
+)
+
+// clientRegistry tracks every connection attended by workers, and whether clients are paused.
+//
+// Methods are safe to call on a nil *clientRegistry, in which case clients are not tracked.
+type clientRegistry struct {
+	lock    sync.RWMutex
+	clients map[uint64]*client
+	nextID  atomic.Uint64
+
+	pauseLock  sync.Mutex
+	pauseMode  atomic.Int32
+	pauseUntil time.Time
+	// Closed whenever the current pause ends
+	unpaused chan struct{}
+}
+
+func newClientRegistry() *clientRegistry {
+	return &clientRegistry{clients: make(map[uint64]*client)}
+}
+
+// register creates a client for a connection that starts being attended by a worker
+func (r *clientRegistry) register(conn net.Conn, workerID uint64) *client {
+	now := time.Now()
+	cl := &client{
+		conn:            conn,
+		addr:            conn.RemoteAddr().String(),
+		laddr:           conn.LocalAddr().String(),
+		workerID:        workerID,
+		createdAt:       now,
+		lastInteraction: now,
+	}
+	if r == nil {
+		return cl
+	}
+	cl.id = r.nextID.Add(1)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.clients[cl.id] = cl
+	return cl
+}
+
+func (r *clientRegistry) unregister(cl *client) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.clients, cl.id)
+}
+
+// list returns the clients ordered by id
+func (r *clientRegistry) list() []*client {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	ids := slices.Sorted(maps.Keys(r.clients))
+	clients := make([]*client, len(ids))
+	for i, id := range ids {
+		clients[i] = r.clients[id]
+	}
+	return clients
+}
+
+// pause stops clients from executing commands (only writes with pauseWrite) until the given time or unpause is called.
+// Pausing again extends the pause only if it lasts longer, and a pauseAll is never reduced to pauseWrite.
+func (r *clientRegistry) pause(mode pauseMode, until time.Time) {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	current := pauseMode(r.pauseMode.Load())
+	if current == pauseNone {
+		r.unpaused = make(chan struct{})
+		r.pauseUntil = until
+	} else if until.After(r.pauseUntil) {
+		r.pauseUntil = until
+	}
+	r.pauseMode.Store(int32(max(current, mode)))
+	go func(unpaused chan struct{}) {
+		select {
+		case <-time.After(time.Until(until)):
+			r.pauseLock.Lock()
+			expired := r.unpaused == unpaused && !time.Now().Before(r.pauseUntil)
+			r.pauseLock.Unlock()
+			if expired {
+				r.unpause()
+			}
+		case <-unpaused:
+		}
+	}(r.unpaused)
+}
+
+func (r *clientRegistry) unpause() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if pauseMode(r.pauseMode.Load()) == pauseNone {
+		return
+	}
+	r.pauseMode.Store(int32(pauseNone))
+	close(r.unpaused)
+}
+
+// pauseExempt tells if a command is answered even while every client is paused, so that a pause can always be lifted
+func pauseExempt(args []string) bool {
+	if args[0] != "CLIENT" || len(args) < 2 {
+		return false
+	}
+	switch strings.ToUpper(args[1]) {
+	case "PAUSE", "UNPAUSE", "KILL":
+		return true
+	}
+	return false
+}
+
+// pausedFor returns a channel closed once the pause ends when a command has to wait for it, nil otherwise.
+// When nothing is paused it costs a single atomic load.
+func (r *clientRegistry) pausedFor(write bool) chan struct{} {
+	if r == nil {
+		return nil
+	}
+	mode := pauseMode(r.pauseMode.Load())
+	if mode == pauseNone || (mode == pauseWrite && !write) {
+		return nil
+	}
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.unpaused
+}
+
+// waitWhilePaused blocks while the clients are paused for a command, or until notifications is closed to stop the worker.
+// It returns whether it had to wait.
+// Stopping while still paused returns redigoerr.ShuttingDown, since the command must not run.
+func (r *clientRegistry) waitWhilePaused(write bool, notifications chan struct{}) (bool, error) {
+	waited := false
+	for {
+		unpaused := r.pausedFor(write)
+		if unpaused == nil {
+			return waited, nil
+		}
+		waited = true
+		select {
+		case <-unpaused:
+		case <-notifications:
+			return waited, redigoerr.ShuttingDown
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func TestWaitWhilePaused_Should_Let_Reads_Through_When_Only_Writes_Are_Paused(t *testing.T) {
+	registry := newClientRegistry()
+	registry.pause(pauseWrite, time.Now().Add(time.Minute))
+	defer registry.unpause()
+	if waited, err := registry.waitWhilePaused(false, make(chan struct{})); waited || err != nil {
+		t.Errorf("Unexpected result! %v - %v", waited, err)
+	}
+}
+
+func TestWaitWhilePaused_Should_Return_Error_When_Stopped_While_Paused(t *testing.T) {
+	registry := newClientRegistry()
+	registry.pause(pauseWrite, time.Now().Add(time.Minute))
+	defer registry.unpause()
+	notifications := make(chan struct{})
+	close(notifications)
+	waited, err := registry.waitWhilePaused(true, notifications)
+	if code, _ := redigoerr.ErrorCode(err); !waited || code != redigoerr.ShuttingDown.Code {
+		t.Errorf("Unexpected result! %v - %v", waited, err)
+	}
+}
+
+func TestWaitWhilePaused_Should_Return_When_Unpaused(t *testing.T) {
+	registry := newClientRegistry()
+	registry.pause(pauseAll, time.Now().Add(20*time.Millisecond))
+	waited, err := registry.waitWhilePaused(false, make(chan struct{}))
+	if !waited || err != nil {
+		t.Errorf("Unexpected result! %v - %v", waited, err)
+	}
+}
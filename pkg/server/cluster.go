@@ -0,0 +1,596 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// defaultClusterNodeTimeout is the time (in milliseconds) a node can go without answering pings before being flagged as failing
+const defaultClusterNodeTimeout = 15000
+
+// clusterBusPortOffset is added to the port of a node to find its bus when not told otherwise, like in REDIS
+const clusterBusPortOffset = 10000
+
+// clusterNode is a node of the cluster as known by this one
+type clusterNode struct {
+	id      string
+	ip      string
+	port    int
+	busPort int
+	// epoch orders the claims of nodes over slots, the one with the highest epoch wins
+	epoch uint64
+	// pongReceived is when the node was last heard of through the bus
+	pongReceived time.Time
+}
+
+func (n *clusterNode) addr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.port))
+}
+
+func (n *clusterNode) busAddr() string {
+	return net.JoinHostPort(n.ip, strconv.Itoa(n.busPort))
+}
+
+// cluster is the view this node has of the cluster: the nodes known and who serves each of the 16384 hash slots.
+// Nodes learn about each other and about the slots they serve through a bus of their own (see cluster_bus.go).
+type cluster struct {
+	lock   sync.RWMutex
+	myself *clusterNode
+	nodes  map[string]*clusterNode
+	// slots holds the node serving every slot, nil while nobody does
+	slots [respparser.ClusterSlots]*clusterNode
+	// migrating and importing hold the node a slot is being moved to or from
+	migrating map[int]*clusterNode
+	importing map[int]*clusterNode
+	// sending holds the keys a MIGRATE is sending elsewhere, which are not written until they are gone
+	sending      map[string]struct{}
+	currentEpoch uint64
+	// meetings are the bus addresses given to CLUSTER MEET, until they answer
+	meetings    map[string]struct{}
+	nodeTimeout time.Duration
+
+	listener net.Listener
+	logger   *slog.Logger
+	done     chan struct{}
+	wg       sync.WaitGroup
+	// conns are the bus connections accepted, closed when the cluster stops. Nil once stopped.
+	connsLock sync.Mutex
+	conns     map[net.Conn]struct{}
+}
+
+// newCluster creates a cluster made of this node alone, which serves no slot until told to
+func newCluster(ip string, port int, busListener net.Listener, nodeTimeout time.Duration, logger *slog.Logger) *cluster {
+	id := make([]byte, 20)
+	rand.Read(id)
+	myself := &clusterNode{
+		id:      hex.EncodeToString(id),
+		ip:      ip,
+		port:    port,
+		busPort: busListener.Addr().(*net.TCPAddr).Port,
+	}
+	return &cluster{
+		myself:      myself,
+		nodes:       map[string]*clusterNode{myself.id: myself},
+		migrating:   make(map[int]*clusterNode),
+		importing:   make(map[int]*clusterNode),
+		sending:     make(map[string]struct{}),
+		meetings:    make(map[string]struct{}),
+		nodeTimeout: nodeTimeout,
+		listener:    busListener,
+		logger:      logger.With(slog.String("NODEID", myself.id)),
+		done:        make(chan struct{}),
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// failing tells if a node has not been heard of for longer than the node timeout. The lock has to be held.
+func (c *cluster) failing(n *clusterNode) bool {
+	return n != c.myself && time.Since(n.pongReceived) > c.nodeTimeout
+}
+
+// ranges returns the slots served by a node as ranges of consecutive slots. The lock has to be held.
+func (c *cluster) ranges(n *clusterNode) [][2]int {
+	ranges := [][2]int{}
+	for slot := 0; slot < respparser.ClusterSlots; slot++ {
+		if c.slots[slot] != n {
+			continue
+		}
+		if last := len(ranges) - 1; last >= 0 && ranges[last][1] == slot-1 {
+			ranges[last][1] = slot
+		} else {
+			ranges = append(ranges, [2]int{slot, slot})
+		}
+	}
+	return ranges
+}
+
+// sortedNodes returns every node known, sorted by id. The lock has to be held.
+func (c *cluster) sortedNodes() []*clusterNode {
+	nodes := make([]*clusterNode, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		nodes = append(nodes, n)
+	}
+	slices.SortFunc(nodes, func(a, b *clusterNode) int { return strings.Compare(a.id, b.id) })
+	return nodes
+}
+
+// route tells whether a command may run in this node, returning the error that redirects the client otherwise:
+// MOVED when another node serves the slot of its keys, ASK when they were already migrated to another node.
+// The database has to be locked, so that keys are not migrated meanwhile.
+func (c *cluster) route(db *cache.Cache, args []string, asking bool) error {
+	if c == nil {
+		return nil
+	}
+	keys := respparser.CommandKeys(args)
+	if len(keys) == 0 {
+		return nil
+	}
+	slot := respparser.KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if respparser.KeySlot(key) != slot {
+			return redigoerr.CrossSlot
+		}
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	owner, migrating, importing := c.slots[slot], c.migrating[slot], c.importing[slot]
+	switch {
+	case owner == c.myself:
+		if migrating == nil {
+			return nil
+		}
+		// Keys missing here may already be in the node the slot is migrated to
+		missing := 0
+		for _, key := range keys {
+			if !db.Exists(key) {
+				missing++
+			}
+		}
+		if missing == 0 {
+			// A key being sent would be changed after its value was, so the client has to ask again later
+			if respparser.IsWriteCommand(args[0]) && slices.ContainsFunc(keys, c.isSending) {
+				return redigoerr.TryAgain
+			}
+			return nil
+		} else if missing < len(keys) {
+			return redigoerr.TryAgain
+		}
+		return redirect(redigoerr.Ask, slot, migrating)
+	case importing != nil && asking:
+		return nil
+	case owner == nil:
+		redigoError := redigoerr.ClusterDown
+		redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot)}
+		return redigoError
+	default:
+		return redirect(redigoerr.Moved, slot, owner)
+	}
+}
+
+// startSending marks keys as being sent by a MIGRATE
+func (c *cluster) startSending(keys []string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, key := range keys {
+		c.sending[key] = struct{}{}
+	}
+}
+
+// stopSending lets keys be written again once a MIGRATE is done with them
+func (c *cluster) stopSending(keys []string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, key := range keys {
+		delete(c.sending, key)
+	}
+}
+
+// isSending tells if a key is being sent by a MIGRATE. The lock has to be held.
+func (c *cluster) isSending(key string) bool {
+	_, ok := c.sending[key]
+	return ok
+}
+
+// redirect completes a MOVED or ASK error with the slot and the address of the node to ask instead
+func redirect(redigoError redigoerr.Error, slot int, n *clusterNode) error {
+	redigoError.ClientContext = fmt.Sprintf("%s %d %s", redigoError.ClientContext, slot, n.addr())
+	redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot), "node": n.id}
+	return redigoError
+}
+
+// meet starts gossiping with the node listening on a bus address, which joins the cluster once it answers
+func (c *cluster) meet(busAddr string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if busAddr != c.myself.busAddr() {
+		c.meetings[busAddr] = struct{}{}
+	}
+}
+
+// addSlots makes this node serve slots nobody serves
+func (c *cluster) addSlots(slots []int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, slot := range slots {
+		if c.slots[slot] != nil {
+			redigoError := redigoerr.SlotAlreadyAssigned
+			redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot)}
+			return redigoError
+		}
+	}
+	for _, slot := range slots {
+		c.slots[slot] = c.myself
+	}
+	return nil
+}
+
+// setSlot answers the forms of CLUSTER SETSLOT used to migrate a slot.
+// Taking over a slot bumps the epoch of this node, so that its claim wins over the previous owner's.
+func (c *cluster) setSlot(slot int, state string, id string, keysLeft bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var n *clusterNode
+	if state != "STABLE" {
+		var ok bool
+		if n, ok = c.nodes[id]; !ok {
+			redigoError := redigoerr.UnknownNode
+			redigoError.ExtraContext = map[string]string{"node": id}
+			return redigoError
+		}
+	}
+	switch state {
+	case "MIGRATING":
+		if c.slots[slot] != c.myself {
+			redigoError := redigoerr.SlotNotOwned
+			redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot)}
+			return redigoError
+		}
+		c.migrating[slot] = n
+	case "IMPORTING":
+		if c.slots[slot] == c.myself {
+			redigoError := redigoerr.SlotAlreadyAssigned
+			redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot)}
+			return redigoError
+		}
+		c.importing[slot] = n
+	case "STABLE":
+		delete(c.migrating, slot)
+		delete(c.importing, slot)
+	case "NODE":
+		if n == c.myself {
+			if c.slots[slot] != c.myself {
+				c.currentEpoch++
+				c.myself.epoch = c.currentEpoch
+			}
+			delete(c.importing, slot)
+		} else if c.slots[slot] == c.myself {
+			if keysLeft {
+				redigoError := redigoerr.SlotNotEmpty
+				redigoError.ExtraContext = map[string]string{"slot": strconv.Itoa(slot)}
+				return redigoError
+			}
+			delete(c.migrating, slot)
+		}
+		c.slots[slot] = n
+	}
+	return nil
+}
+
+// nodesInfo describes every node in the format of CLUSTER NODES
+func (c *cluster) nodesInfo() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	b := &strings.Builder{}
+	for _, n := range c.sortedNodes() {
+		flags, pongReceived, link := "master", n.pongReceived.UnixMilli(), "connected"
+		if n == c.myself {
+			flags, pongReceived = "myself,master", 0
+		} else if c.failing(n) {
+			flags, link = "master,fail?", "disconnected"
+		}
+		fmt.Fprintf(b, "%s %s@%d %s - 0 %d %d %s", n.id, n.addr(), n.busPort, flags, pongReceived, n.epoch, link)
+		for _, r := range c.ranges(n) {
+			if r[0] == r[1] {
+				fmt.Fprintf(b, " %d", r[0])
+			} else {
+				fmt.Fprintf(b, " %d-%d", r[0], r[1])
+			}
+		}
+		if n == c.myself {
+			for _, slot := range slices.Sorted(maps.Keys(c.migrating)) {
+				fmt.Fprintf(b, " [%d->-%s]", slot, c.migrating[slot].id)
+			}
+			for _, slot := range slices.Sorted(maps.Keys(c.importing)) {
+				fmt.Fprintf(b, " [%d-<-%s]", slot, c.importing[slot].id)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// slotsReply answers CLUSTER SLOTS: every range of slots along the address and id of the node serving it
+func (c *cluster) slotsReply() []byte {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	elements := [][]byte{}
+	for _, n := range c.sortedNodes() {
+		node := tobytes.Array(tobytes.BlobString(n.ip), tobytes.Int(n.port), tobytes.BlobString(n.id))
+		for _, r := range c.ranges(n) {
+			elements = append(elements, tobytes.Array(tobytes.Int(r[0]), tobytes.Int(r[1]), node))
+		}
+	}
+	return tobytes.Array(elements...)
+}
+
+// shardsReply answers CLUSTER SHARDS: the slots of every node along its details, as maps flattened into arrays
+func (c *cluster) shardsReply() []byte {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	shards := [][]byte{}
+	for _, n := range c.sortedNodes() {
+		slots := [][]byte{}
+		for _, r := range c.ranges(n) {
+			slots = append(slots, tobytes.Int(r[0]), tobytes.Int(r[1]))
+		}
+		health := "online"
+		if c.failing(n) {
+			health = "fail"
+		}
+		node := tobytes.Array(
+			tobytes.BlobString("id"), tobytes.BlobString(n.id),
+			tobytes.BlobString("port"), tobytes.Int(n.port),
+			tobytes.BlobString("ip"), tobytes.BlobString(n.ip),
+			tobytes.BlobString("endpoint"), tobytes.BlobString(n.ip),
+			tobytes.BlobString("role"), tobytes.BlobString("master"),
+			tobytes.BlobString("replication-offset"), tobytes.Int(0),
+			tobytes.BlobString("health"), tobytes.BlobString(health),
+		)
+		shards = append(shards, tobytes.Array(
+			tobytes.BlobString("slots"), tobytes.Array(slots...),
+			tobytes.BlobString("nodes"), tobytes.Array(node),
+		))
+	}
+	return tobytes.Array(shards...)
+}
+
+// info describes the state of the cluster in the format of CLUSTER INFO
+func (c *cluster) info() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	assigned, failing := 0, 0
+	serving := map[*clusterNode]struct{}{}
+	for _, n := range c.slots {
+		if n == nil {
+			continue
+		}
+		assigned++
+		serving[n] = struct{}{}
+		if c.failing(n) {
+			failing++
+		}
+	}
+	state := "ok"
+	if assigned < respparser.ClusterSlots {
+		state = "fail"
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "cluster_state:%s\r\n", state)
+	fmt.Fprintf(b, "cluster_slots_assigned:%d\r\n", assigned)
+	fmt.Fprintf(b, "cluster_slots_ok:%d\r\n", assigned-failing)
+	fmt.Fprintf(b, "cluster_slots_pfail:%d\r\n", failing)
+	fmt.Fprintf(b, "cluster_slots_fail:0\r\n")
+	fmt.Fprintf(b, "cluster_known_nodes:%d\r\n", len(c.nodes))
+	fmt.Fprintf(b, "cluster_size:%d\r\n", len(serving))
+	fmt.Fprintf(b, "cluster_current_epoch:%d\r\n", c.currentEpoch)
+	fmt.Fprintf(b, "cluster_my_epoch:%d\r\n", c.myself.epoch)
+	return b.String()
+}
+
+// parseSlot reads a hash slot, checking it is in range
+func parseSlot(s string) (int, error) {
+	slot, err := strconv.Atoi(s)
+	if err != nil || slot < 0 || slot >= respparser.ClusterSlots {
+		redigoError := redigoerr.InvalidSlot
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return slot, nil
+}
+
+// parsePort reads the port of a node
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > int(^uint16(0)) {
+		redigoError := redigoerr.InvalidNodeAddress
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"port": s}
+		return 0, redigoError
+	}
+	return port, nil
+}
+
+// clusterCommand answers CLUSTER MYID, MEET, ADDSLOTS, ADDSLOTSRANGE, NODES, SLOTS, SHARDS, INFO, KEYSLOT,
+// SETSLOT, GETKEYSINSLOT and COUNTKEYSINSLOT
+func (w *worker) clusterCommand(args []string) ([]byte, error) {
+	c := w.cluster
+	if c == nil {
+		return []byte{}, redigoerr.ClusterDisabled
+	}
+	if len(args) < 2 {
+		return []byte{}, insufficientLength(">= 2", len(args))
+	}
+	switch strings.ToUpper(args[1]) {
+	case "MYID":
+		return tobytes.BlobString(c.myself.id), nil
+	case "MEET":
+		if len(args) != 4 && len(args) != 5 {
+			return []byte{}, insufficientLength("4 or 5", len(args))
+		}
+		if net.ParseIP(args[2]) == nil {
+			redigoError := redigoerr.InvalidNodeAddress
+			redigoError.ExtraContext = map[string]string{"ip": args[2]}
+			return []byte{}, redigoError
+		}
+		port, err := parsePort(args[3])
+		if err != nil {
+			return []byte{}, err
+		}
+		busPort := port + clusterBusPortOffset
+		if len(args) == 5 {
+			if busPort, err = parsePort(args[4]); err != nil {
+				return []byte{}, err
+			}
+		}
+		c.meet(net.JoinHostPort(args[2], strconv.Itoa(busPort)))
+		return tobytes.Null(), nil
+	case "ADDSLOTS", "ADDSLOTSRANGE":
+		if len(args) < 3 {
+			return []byte{}, insufficientLength(">= 3", len(args))
+		}
+		slots := []int{}
+		for _, s := range args[2:] {
+			slot, err := parseSlot(s)
+			if err != nil {
+				return []byte{}, err
+			}
+			slots = append(slots, slot)
+		}
+		if strings.ToUpper(args[1]) == "ADDSLOTSRANGE" {
+			if len(slots)%2 != 0 {
+				return []byte{}, redigoerr.SyntaxError
+			}
+			ranges := slots
+			slots = []int{}
+			for i := 0; i < len(ranges); i += 2 {
+				for slot := ranges[i]; slot <= ranges[i+1]; slot++ {
+					slots = append(slots, slot)
+				}
+			}
+		}
+		if err := c.addSlots(slots); err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Null(), nil
+	case "NODES":
+		return tobytes.BlobString(c.nodesInfo()), nil
+	case "SLOTS":
+		return c.slotsReply(), nil
+	case "SHARDS":
+		return c.shardsReply(), nil
+	case "INFO":
+		return tobytes.BlobString(c.info()), nil
+	case "KEYSLOT":
+		if len(args) != 3 {
+			return []byte{}, insufficientLength("3", len(args))
+		}
+		return tobytes.Int(respparser.KeySlot(args[2])), nil
+	case "SETSLOT":
+		if len(args) < 4 {
+			return []byte{}, insufficientLength(">= 4", len(args))
+		}
+		slot, err := parseSlot(args[2])
+		if err != nil {
+			return []byte{}, err
+		}
+		state, id := strings.ToUpper(args[3]), ""
+		switch {
+		case state == "STABLE" && len(args) == 4:
+		case (state == "IMPORTING" || state == "MIGRATING" || state == "NODE") && len(args) == 5:
+			id = args[4]
+		default:
+			return []byte{}, redigoerr.SyntaxError
+		}
+		keysLeft := len(w.keysInSlot(slot, 1)) > 0
+		if err := c.setSlot(slot, state, id, keysLeft); err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Null(), nil
+	case "GETKEYSINSLOT":
+		if len(args) != 4 {
+			return []byte{}, insufficientLength("4", len(args))
+		}
+		slot, err := parseSlot(args[2])
+		if err != nil {
+			return []byte{}, err
+		}
+		count, err := parseInt(args[3])
+		if err != nil {
+			return []byte{}, err
+		}
+		if count < 0 {
+			return []byte{}, redigoerr.NotAnInteger
+		}
+		keys := w.keysInSlot(slot, int(count))
+		elements := make([][]byte, len(keys))
+		for i, key := range keys {
+			elements[i] = tobytes.BlobString(key)
+		}
+		return tobytes.Array(elements...), nil
+	case "COUNTKEYSINSLOT":
+		if len(args) != 3 {
+			return []byte{}, insufficientLength("3", len(args))
+		}
+		slot, err := parseSlot(args[2])
+		if err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Int(len(w.keysInSlot(slot, -1))), nil
+	default:
+		redigoError := redigoerr.SyntaxError
+		redigoError.ExtraContext = map[string]string{"subcommand": args[1]}
+		return []byte{}, redigoError
+	}
+}
+
+// keysInSlot returns up to count keys of the database 0 (the only one in cluster mode) hashing to a slot,
+// sorted. A negative count returns all of them.
+func (w *worker) keysInSlot(slot int, count int) []string {
+	db := w.databases[0]
+	db.Lock()
+	keys := []string{}
+	for key := range db.Keys() {
+		if respparser.KeySlot(key) == slot {
+			keys = append(keys, key)
+		}
+	}
+	db.Unlock()
+	slices.Sort(keys)
+	if count >= 0 && len(keys) > count {
+		keys = keys[:count]
+	}
+	return keys
+}
+
+// askingCommand answers ASKING, letting the next command of the client reach a slot being imported
+func (w *worker) askingCommand(cl *client, args []string) ([]byte, error) {
+	if w.cluster == nil {
+		return []byte{}, redigoerr.ClusterDisabled
+	}
+	if len(args) != 1 {
+		return []byte{}, insufficientLength("1", len(args))
+	}
+	cl.setAsking()
+	return tobytes.Null(), nil
+}
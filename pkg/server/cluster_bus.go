@@ -0,0 +1,304 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// clusterGossipInterval is how often every node pings the rest through the bus
+const clusterGossipInterval = 100 * time.Millisecond
+
+// clusterBusTimeout bounds dialing a node and waiting for its pong, so that a dead node never stalls gossip for long
+const clusterBusTimeout = time.Second
+
+// Types of the messages sent through the bus. Every meet or ping is answered with a pong.
+const (
+	busMeet = "meet"
+	busPing = "ping"
+	busPong = "pong"
+)
+
+// busNode describes a node inside a bus message
+type busNode struct {
+	ID      string `json:"id"`
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	BusPort int    `json:"busPort"`
+	Epoch   uint64 `json:"epoch"`
+}
+
+// busMessage is what nodes tell each other through the bus, one JSON document after another:
+// who the sender is, the slots it serves and the nodes it knows, so that every node ends up knowing every other one.
+type busMessage struct {
+	Type         string    `json:"type"`
+	Sender       busNode   `json:"sender"`
+	CurrentEpoch uint64    `json:"currentEpoch"`
+	Slots        [][2]int  `json:"slots,omitempty"`
+	Known        []busNode `json:"known,omitempty"`
+}
+
+// busLink is the connection used to ping a node, opened again whenever it fails
+type busLink struct {
+	addr string
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+func (l *busLink) close() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+// unspecified tells if an ip does not tell where a node can be reached, in which case it is learned from the bus
+func unspecified(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed == nil || parsed.IsUnspecified()
+}
+
+// start accepts connections through the bus and gossips with every node in the background
+func (c *cluster) start() {
+	c.wg.Add(2)
+	go c.serve()
+	go c.gossip()
+}
+
+// stop closes the bus and every connection through it, waiting for the gossip to end
+func (c *cluster) stop() {
+	close(c.done)
+	c.listener.Close()
+	c.connsLock.Lock()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = nil
+	c.connsLock.Unlock()
+	c.wg.Wait()
+}
+
+// message describes this node and what it knows to another one
+func (c *cluster) message(kind string) busMessage {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	known := make([]busNode, 0, len(c.nodes)-1)
+	for _, n := range c.nodes {
+		if n != c.myself {
+			known = append(known, toBusNode(n))
+		}
+	}
+	return busMessage{
+		Type:         kind,
+		Sender:       toBusNode(c.myself),
+		CurrentEpoch: c.currentEpoch,
+		Slots:        c.ranges(c.myself),
+		Known:        known,
+	}
+}
+
+func toBusNode(n *clusterNode) busNode {
+	return busNode{ID: n.id, IP: n.ip, Port: n.port, BusPort: n.busPort, Epoch: n.epoch}
+}
+
+// receive learns from a message got through conn. Pings from unknown nodes are ignored, since only a meet
+// (or another node of the cluster telling about it) makes a node part of the cluster.
+func (c *cluster) receive(msg busMessage, conn net.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if unspecified(c.myself.ip) {
+		if local, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+			c.myself.ip = local.IP.String()
+		}
+	}
+	sender := msg.Sender
+	if unspecified(sender.IP) {
+		if remote, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			sender.IP = remote.IP.String()
+		}
+	}
+	if sender.ID == c.myself.id {
+		return
+	}
+	n, ok := c.nodes[sender.ID]
+	if !ok && msg.Type == busPing {
+		return
+	} else if !ok {
+		n = c.addNode(sender)
+	}
+	n.ip, n.port, n.busPort, n.epoch = sender.IP, sender.Port, sender.BusPort, sender.Epoch
+	n.pongReceived = time.Now()
+	c.currentEpoch = max(c.currentEpoch, msg.CurrentEpoch, n.epoch)
+
+	// A slot goes to whoever claims it with the highest epoch, ties going to the lowest id
+	for _, r := range msg.Slots {
+		for slot := max(r[0], 0); slot <= r[1] && slot < len(c.slots); slot++ {
+			owner := c.slots[slot]
+			if owner == n {
+				continue
+			}
+			if owner == nil || owner.epoch < n.epoch || (owner.epoch == n.epoch && n.id < owner.id) {
+				if owner == c.myself {
+					delete(c.migrating, slot)
+				}
+				c.slots[slot] = n
+			}
+		}
+	}
+
+	for _, other := range msg.Known {
+		if _, ok := c.nodes[other.ID]; !ok && other.ID != "" && !unspecified(other.IP) {
+			c.addNode(other)
+		}
+	}
+}
+
+// addNode makes a node part of the cluster. The lock has to be held.
+func (c *cluster) addNode(node busNode) *clusterNode {
+	n := &clusterNode{id: node.ID, ip: node.IP, port: node.Port, busPort: node.BusPort, epoch: node.Epoch, pongReceived: time.Now()}
+	c.nodes[n.id] = n
+	delete(c.meetings, n.busAddr())
+	c.logger.Info("A node joined the cluster", slog.String("NODE", n.id), slog.String("ADDRESS", n.addr()))
+	return n
+}
+
+// serve accepts connections through the bus, answering each one in its own goroutine
+func (c *cluster) serve() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			c.logger.Error("An error occurred while accepting a connection through the cluster bus", "ERROR", err)
+			continue
+		}
+		c.connsLock.Lock()
+		if c.conns == nil {
+			c.connsLock.Unlock()
+			conn.Close()
+			return
+		}
+		c.conns[conn] = struct{}{}
+		c.connsLock.Unlock()
+		c.wg.Add(1)
+		go c.answer(conn)
+	}
+}
+
+// answer replies every message received through a connection with a pong, until it closes
+func (c *cluster) answer(conn net.Conn) {
+	defer c.wg.Done()
+	defer func() {
+		c.connsLock.Lock()
+		delete(c.conns, conn)
+		c.connsLock.Unlock()
+		conn.Close()
+	}()
+	dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+	for {
+		var msg busMessage
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		c.receive(msg, conn)
+		if err := enc.Encode(c.message(busPong)); err != nil {
+			return
+		}
+	}
+}
+
+// gossip pings every node known (and meets the ones given to CLUSTER MEET) every clusterGossipInterval,
+// all of them at once, until the cluster stops
+func (c *cluster) gossip() {
+	defer c.wg.Done()
+	links := map[string]*busLink{}
+	defer func() {
+		for _, link := range links {
+			link.close()
+		}
+	}()
+	ticker := time.NewTicker(clusterGossipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		targets := c.gossipTargets()
+		for addr, link := range links {
+			if _, ok := targets[addr]; !ok {
+				link.close()
+				delete(links, addr)
+			}
+		}
+		var wg sync.WaitGroup
+		for addr, meet := range targets {
+			link, ok := links[addr]
+			if !ok {
+				link = &busLink{addr: addr}
+				links[addr] = link
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.ping(link, meet)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// gossipTargets returns the bus address of every other node, telling whether it has to be met first
+func (c *cluster) gossipTargets() map[string]bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	targets := make(map[string]bool, len(c.nodes)+len(c.meetings))
+	for addr := range c.meetings {
+		targets[addr] = true
+	}
+	for _, n := range c.nodes {
+		if n != c.myself {
+			targets[n.busAddr()] = false
+		}
+	}
+	return targets
+}
+
+// ping sends a ping (or a meet) through a link, learning from the pong received
+func (c *cluster) ping(link *busLink, meet bool) {
+	if link.conn == nil {
+		conn, err := net.DialTimeout("tcp", link.addr, clusterBusTimeout)
+		if err != nil {
+			c.logger.Debug("Unable to reach a node through the cluster bus", "ERROR", err, slog.String("BUSADDRESS", link.addr))
+			return
+		}
+		link.conn, link.enc, link.dec = conn, json.NewEncoder(conn), json.NewDecoder(conn)
+	}
+	kind := busPing
+	if meet {
+		kind = busMeet
+	}
+	link.conn.SetDeadline(time.Now().Add(clusterBusTimeout))
+	var pong busMessage
+	err := link.enc.Encode(c.message(kind))
+	if err == nil {
+		err = link.dec.Decode(&pong)
+	}
+	if err != nil {
+		c.logger.Debug("A node did not answer through the cluster bus", "ERROR", err, slog.String("BUSADDRESS", link.addr))
+		link.close()
+		return
+	}
+	c.receive(pong, link.conn)
+	if meet {
+		c.lock.Lock()
+		delete(c.meetings, link.addr)
+		c.lock.Unlock()
+	}
+}
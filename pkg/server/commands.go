@@ -0,0 +1,302 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// delegatedCommands are answered by workers instead of the cache, since they need the server's state
+var delegatedCommands = []string{"INFO", "SLOWLOG", "MONITOR", "CLIENT", "CONFIG", "SELECT", "MOVE", "SWAPDB", "FLUSHDB", "FLUSHALL", "CLUSTER", "ASKING", "MIGRATE"}
+
+func insufficientLength(expected string, obtained int) error {
+	redigoError := redigoerr.InsufficientLength
+	redigoError.ExtraContext = map[string]string{"expected": expected, "obtained": fmt.Sprintf("%d", obtained)}
+	return redigoError
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		redigoError := redigoerr.NotAnInteger
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return n, nil
+}
+
+// runDelegated answers a command delegated by the parser, sent by cl
+func (w *worker) runDelegated(cl *client, args []string) ([]byte, error) {
+	switch args[0] {
+	case "INFO":
+		return tobytes.BlobString(w.stats.info(keyspaceStats(w.databases), args[1:]...)), nil
+	case "SLOWLOG":
+		return w.slowLogCommand(args)
+	case "CLIENT":
+		return w.clientCommand(cl, args)
+	case "CONFIG":
+		return w.configCommand(args)
+	case "SELECT":
+		return w.selectCommand(cl, args)
+	case "MOVE":
+		return w.moveCommand(cl, args)
+	case "SWAPDB":
+		return w.swapDBCommand(args)
+	case "FLUSHDB", "FLUSHALL":
+		return w.flushCommand(cl, args)
+	case "CLUSTER":
+		return w.clusterCommand(args)
+	case "ASKING":
+		return w.askingCommand(cl, args)
+	case "MIGRATE":
+		return w.migrateCommand(cl, args)
+	default:
+		redigoError := redigoerr.FunctionNotFound
+		redigoError.ExtraContext = map[string]string{"function": args[0]}
+		return []byte{}, redigoError
+	}
+}
+
+// slowLogCommand answers SLOWLOG GET [count], SLOWLOG LEN and SLOWLOG RESET
+func (w *worker) slowLogCommand(args []string) ([]byte, error) {
+	if len(args) < 2 {
+		return []byte{}, insufficientLength(">= 2", len(args))
+	}
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		if len(args) > 3 {
+			return []byte{}, insufficientLength("2 or 3", len(args))
+		}
+		count := int64(10)
+		if len(args) == 3 {
+			var err error
+			if count, err = parseInt(args[2]); err != nil {
+				return []byte{}, err
+			}
+			if count < -1 {
+				return []byte{}, redigoerr.NotAnInteger
+			}
+		}
+		entries := w.slowLog.latest(int(count))
+		elements := make([][]byte, len(entries))
+		for i, entry := range entries {
+			elements[i] = entry.toBytes()
+		}
+		return tobytes.Array(elements...), nil
+	case "LEN":
+		return tobytes.Int(w.slowLog.len()), nil
+	case "RESET":
+		w.slowLog.reset()
+		return tobytes.Null(), nil
+	default:
+		return []byte{}, redigoerr.SyntaxError
+	}
+}
+
+// clientCommand answers CLIENT ID, INFO, LIST, KILL, SETNAME, GETNAME, PAUSE, UNPAUSE and TRACKING
+func (w *worker) clientCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) < 2 {
+		return []byte{}, insufficientLength(">= 2", len(args))
+	}
+	switch strings.ToUpper(args[1]) {
+	case "ID":
+		return tobytes.Int(int(cl.id)), nil
+	case "INFO":
+		return tobytes.BlobString(cl.info()), nil
+	case "LIST":
+		ids := []uint64{}
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "TYPE":
+				// Every client is a normal one
+				if i+1 >= len(args) {
+					return []byte{}, redigoerr.SyntaxError
+				}
+				if strings.ToLower(args[i+1]) != "normal" {
+					return tobytes.BlobString(""), nil
+				}
+				i++
+			case "ID":
+				if i+1 >= len(args) {
+					return []byte{}, redigoerr.SyntaxError
+				}
+				for i++; i < len(args); i++ {
+					id, err := parseInt(args[i])
+					if err != nil {
+						return []byte{}, err
+					}
+					ids = append(ids, uint64(id))
+				}
+			default:
+				return []byte{}, redigoerr.SyntaxError
+			}
+		}
+		b := &strings.Builder{}
+		for _, other := range w.clients.list() {
+			if len(ids) == 0 || slices.Contains(ids, other.id) {
+				b.WriteString(other.info())
+			}
+		}
+		return tobytes.BlobString(b.String()), nil
+	case "KILL":
+		return w.clientKill(cl, args)
+	case "SETNAME":
+		if len(args) != 3 {
+			return []byte{}, insufficientLength("3", len(args))
+		}
+		if strings.ContainsFunc(args[2], func(r rune) bool { return r <= ' ' || r > '~' }) {
+			redigoError := redigoerr.InvalidClientName
+			redigoError.ExtraContext = map[string]string{"name": args[2]}
+			return []byte{}, redigoError
+		}
+		cl.setName(args[2])
+		return tobytes.Null(), nil
+	case "GETNAME":
+		if name := cl.getName(); name != "" {
+			return tobytes.BlobString(name), nil
+		}
+		return tobytes.Null(), nil
+	case "PAUSE":
+		if len(args) != 3 && len(args) != 4 {
+			return []byte{}, insufficientLength("3 or 4", len(args))
+		}
+		timeout, err := parseInt(args[2])
+		if err != nil {
+			return []byte{}, err
+		}
+		if timeout < 0 {
+			return []byte{}, redigoerr.NotAnInteger
+		}
+		mode := pauseAll
+		if len(args) == 4 {
+			switch strings.ToUpper(args[3]) {
+			case "WRITE":
+				mode = pauseWrite
+			case "ALL":
+			default:
+				return []byte{}, redigoerr.SyntaxError
+			}
+		}
+		w.clients.pause(mode, time.Now().Add(time.Duration(timeout)*time.Millisecond))
+		return tobytes.Null(), nil
+	case "UNPAUSE":
+		w.clients.unpause()
+		return tobytes.Null(), nil
+	case "TRACKING":
+		return w.trackingCommand(cl, args)
+	default:
+		return []byte{}, redigoerr.SyntaxError
+	}
+}
+
+// clientKill answers both forms of CLIENT KILL: the old one with just an address,
+// which fails if nothing matches, and the one with filters, which returns the amount of clients killed
+func (w *worker) clientKill(cl *client, args []string) ([]byte, error) {
+	if len(args) == 3 {
+		for _, other := range w.clients.list() {
+			if other.addr == args[2] {
+				other.kill()
+				return tobytes.Null(), nil
+			}
+		}
+		redigoError := redigoerr.NoSuchClient
+		redigoError.ExtraContext = map[string]string{"addr": args[2]}
+		return []byte{}, redigoError
+	}
+	if len(args) < 4 || len(args)%2 != 0 {
+		return []byte{}, redigoerr.SyntaxError
+	}
+
+	matches := []func(other *client) bool{}
+	skipMe := true
+	for i := 2; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			id, err := parseInt(value)
+			if err != nil {
+				return []byte{}, err
+			}
+			matches = append(matches, func(other *client) bool { return other.id == uint64(id) })
+		case "ADDR":
+			matches = append(matches, func(other *client) bool { return other.addr == value })
+		case "LADDR":
+			matches = append(matches, func(other *client) bool { return other.laddr == value })
+		case "USER":
+			// Every client is authenticated as the default user
+			matches = append(matches, func(other *client) bool { return value == "default" })
+		case "TYPE":
+			matches = append(matches, func(other *client) bool { return strings.ToLower(value) == "normal" })
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return []byte{}, redigoerr.SyntaxError
+			}
+		default:
+			return []byte{}, redigoerr.SyntaxError
+		}
+	}
+
+	killed := 0
+	for _, other := range w.clients.list() {
+		if skipMe && other.id == cl.id {
+			continue
+		}
+		if !slices.ContainsFunc(matches, func(match func(other *client) bool) bool { return !match(other) }) {
+			other.kill()
+			killed++
+		}
+	}
+	return tobytes.Int(killed), nil
+}
+
+// configCommand answers CONFIG GET pattern [pattern ...], CONFIG SET parameter value [parameter value ...] and CONFIG REWRITE
+func (w *worker) configCommand(args []string) ([]byte, error) {
+	if len(args) < 2 {
+		return []byte{}, insufficientLength(">= 2", len(args))
+	}
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		if len(args) < 3 {
+			return []byte{}, insufficientLength(">= 3", len(args))
+		}
+		pairs := w.settings.get(args[2:])
+		values := make([][]byte, len(pairs))
+		for i, value := range pairs {
+			values[i] = tobytes.BlobString(value)
+		}
+		return tobytes.Array(values...), nil
+	case "SET":
+		if len(args) < 4 || len(args)%2 != 0 {
+			redigoError := redigoerr.UnknownConfigParameter
+			redigoError.ExtraContext = map[string]string{"obtained": strconv.Itoa(len(args))}
+			return []byte{}, redigoError
+		}
+		if err := w.settings.set(args[2:]); err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Null(), nil
+	case "REWRITE":
+		if len(args) != 2 {
+			return []byte{}, insufficientLength("2", len(args))
+		}
+		if err := w.settings.rewrite(); err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Null(), nil
+	default:
+		redigoError := redigoerr.SyntaxError
+		redigoError.ExtraContext = map[string]string{"subcommand": args[1]}
+		return []byte{}, redigoError
+	}
+}
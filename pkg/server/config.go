@@ -0,0 +1,570 @@
+package server
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// logLevels maps the REDIS log levels to slog levels. "nothing" is above any level used.
+var logLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"verbose": slog.LevelInfo - 2,
+	"notice":  slog.LevelInfo,
+	"warning": slog.LevelWarn,
+	"nothing": slog.LevelError + 4,
+}
+
+// connectionHandlings are the names of the ConnectionHandling values in configuration files
+var connectionHandlings = map[string]ConnectionHandling{
+	"workers":   WorkerPerConnection,
+	"eventloop": EventLoop,
+}
+
+// freeingCommands are write commands still allowed once maxmemory is reached, since they only remove data
+var freeingCommands = map[string]struct{}{
+	"DEL": {}, "LPOP": {}, "RPOP": {}, "JSON.DEL": {}, "JSON.ARRPOP": {},
+	"MOVE": {}, "SWAPDB": {}, "FLUSHDB": {}, "FLUSHALL": {},
+}
+
+// configParameter is a configuration value known by the configuration file and CONFIG.
+// Immutable parameters can only be given at startup.
+type configParameter struct {
+	name    string
+	mutable bool
+	parse   func(c *Configuration, value string) error
+	format  func(c *Configuration) string
+}
+
+// configParameters are listed in the order CONFIG REWRITE appends them to a file
+var configParameters = []configParameter{
+	{"bind", false,
+		func(c *Configuration, value string) error { c.IpAddress = value; return nil },
+		func(c *Configuration) string { return c.IpAddress }},
+	{"port", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("port", value, 0, int64(^uint16(0)))
+			c.Port = uint16(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatUint(uint64(c.Port), 10) }},
+	{"worker-amount", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("worker-amount", value, 1, 1<<20)
+			c.WorkerAmount = uint64(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatUint(c.WorkerAmount, 10) }},
+	{"min-workers", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("min-workers", value, 1, 1<<20)
+			c.MinWorkers = uint64(n)
+			return err
+		},
+		func(c *Configuration) string {
+			minWorkers, _ := workerBounds(c)
+			return strconv.FormatUint(minWorkers, 10)
+		}},
+	{"max-workers", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("max-workers", value, 1, 1<<20)
+			c.MaxWorkers = uint64(n)
+			return err
+		},
+		func(c *Configuration) string {
+			_, maxWorkers := workerBounds(c)
+			return strconv.FormatUint(maxWorkers, 10)
+		}},
+	{"worker-idle-timeout", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("worker-idle-timeout", value, 1, 1<<31)
+			c.WorkerIdleTimeout = n
+			return err
+		},
+		func(c *Configuration) string {
+			return strconv.FormatInt(cmp.Or(c.WorkerIdleTimeout, defaultWorkerIdleTimeout), 10)
+		}},
+	{"io-mode", false,
+		func(c *Configuration, value string) error {
+			mode, ok := connectionHandlings[strings.ToLower(value)]
+			if !ok {
+				return invalidConfigValue("io-mode", value, "argument must be one of workers, eventloop")
+			}
+			c.ConnectionHandling = mode
+			return nil
+		},
+		func(c *Configuration) string {
+			if c.ConnectionHandling == EventLoop {
+				return "eventloop"
+			}
+			return "workers"
+		}},
+	{"event-loops", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("event-loops", value, 0, 1<<10)
+			c.EventLoops = int(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.Itoa(eventLoopAmount(c)) }},
+	{"maxclients", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("maxclients", value, 0, 1<<31)
+			c.MaxClients = uint64(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatUint(c.MaxClients, 10) }},
+	{"databases", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("databases", value, 1, 1<<20)
+			c.Databases = int(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.Itoa(cmp.Or(c.Databases, defaultDatabases)) }},
+	{"timeout", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("timeout", value, 1, 1<<31)
+			c.KeepAlive = n
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatInt(c.KeepAlive, 10) }},
+	{"client-query-buffer-limit", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigMemory("client-query-buffer-limit", value, 1)
+			c.MessageSizeLimit = int(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.Itoa(c.MessageSizeLimit) }},
+	{"maxmemory", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigMemory("maxmemory", value, 0)
+			c.MaxMemory = n
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatInt(c.MaxMemory, 10) }},
+	{"tracking-table-max-keys", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("tracking-table-max-keys", value, 1, 1<<31)
+			c.TrackingTableMaxKeys = int(n)
+			return err
+		},
+		func(c *Configuration) string {
+			return strconv.Itoa(cmp.Or(c.TrackingTableMaxKeys, defaultTrackingMaxKeys))
+		}},
+	{"shutdown-timeout", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("shutdown-timeout", value, 0, 1<<31)
+			c.ShutdownTolerance = n
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatInt(c.ShutdownTolerance, 10) }},
+	{"metrics-address", false,
+		func(c *Configuration, value string) error { c.MetricsAddress = value; return nil },
+		func(c *Configuration) string { return c.MetricsAddress }},
+	{"slowlog-log-slower-than", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("slowlog-log-slower-than", value, -1<<62, 1<<62)
+			c.SlowLogThreshold = n
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatInt(c.SlowLogThreshold, 10) }},
+	{"slowlog-max-len", true,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("slowlog-max-len", value, 0, 1<<31)
+			c.SlowLogMaxLen = int(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.Itoa(c.SlowLogMaxLen) }},
+	{"loglevel", true,
+		func(c *Configuration, value string) error {
+			value = strings.ToLower(value)
+			if _, ok := logLevels[value]; !ok {
+				return invalidConfigValue("loglevel", value, "argument must be one of debug, verbose, notice, warning, nothing")
+			}
+			c.LogLevel = value
+			return nil
+		},
+		func(c *Configuration) string { return c.LogLevel }},
+	{"cluster-enabled", false,
+		func(c *Configuration, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				c.ClusterEnabled = true
+			case "no":
+				c.ClusterEnabled = false
+			default:
+				return invalidConfigValue("cluster-enabled", value, "argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+		func(c *Configuration) string {
+			if c.ClusterEnabled {
+				return "yes"
+			}
+			return "no"
+		}},
+	{"cluster-port", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("cluster-port", value, 0, int64(^uint16(0)))
+			c.ClusterBusPort = uint16(n)
+			return err
+		},
+		func(c *Configuration) string { return strconv.FormatUint(uint64(c.ClusterBusPort), 10) }},
+	{"cluster-node-timeout", false,
+		func(c *Configuration, value string) error {
+			n, err := parseConfigInt("cluster-node-timeout", value, 1, 1<<31)
+			c.ClusterNodeTimeout = n
+			return err
+		},
+		func(c *Configuration) string {
+			return strconv.FormatInt(cmp.Or(c.ClusterNodeTimeout, defaultClusterNodeTimeout), 10)
+		}},
+}
+
+func findConfigParameter(name string) (configParameter, bool) {
+	name = strings.ToLower(name)
+	for _, parameter := range configParameters {
+		if parameter.name == name {
+			return parameter, true
+		}
+	}
+	return configParameter{}, false
+}
+
+func invalidConfigValue(name string, value string, reason string) error {
+	redigoError := redigoerr.InvalidConfigValue
+	redigoError.ClientContext = fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - %s", name, reason)
+	redigoError.ExtraContext = map[string]string{"parameter": name, "provided": value}
+	return redigoError
+}
+
+func parseConfigInt(name string, value string, minimum int64, maximum int64) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, invalidConfigValue(name, value, "argument couldn't be parsed into an integer")
+	}
+	if n < minimum || n > maximum {
+		return 0, invalidConfigValue(name, value, fmt.Sprintf("argument must be between %d and %d inclusive", minimum, maximum))
+	}
+	return n, nil
+}
+
+// memoryUnits are the suffixes accepted for memory amounts, like 100mb or 1g
+var memoryUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseConfigMemory reads an amount of bytes, allowing the units used in redis.conf
+func parseConfigMemory(name string, value string, minimum int64) (int64, error) {
+	lower := strings.ToLower(value)
+	multiplier := int64(1)
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSuffix(lower, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n > (1<<62)/multiplier {
+		return 0, invalidConfigValue(name, value, "argument must be a memory value")
+	}
+	if n*multiplier < minimum {
+		return 0, invalidConfigValue(name, value, fmt.Sprintf("argument must be a memory value of at least %d", minimum))
+	}
+	return n * multiplier, nil
+}
+
+// splitConfigLine separates a line of a configuration file into its directive and value.
+// Values may be quoted to hold spaces or be empty.
+func splitConfigLine(line string) (string, string, error) {
+	directive, value := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		directive, value = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	if strings.HasPrefix(value, "\"") {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", "", err
+		}
+		value = unquoted
+	}
+	return strings.ToLower(directive), value, nil
+}
+
+// formatConfigValue quotes values which would not be read back as they are
+func formatConfigValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"\\#") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// LoadConfigurationFile reads a redis.conf-style file into serverConfig. Every line holds a directive followed by its value,
+// while empty lines and lines starting with # are ignored:
+//
+//	# Listen on every interface
+//	bind 0.0.0.0
+//	port 6543
+//	maxmemory 512mb
+//
+// Values missing from the file are left untouched. The file is remembered so that CONFIG REWRITE can update it later.
+func LoadConfigurationFile(configFile string, serverConfig *Configuration) error {
+	file, err := os.Open(configFile)
+	if err != nil {
+		redigoError := redigoerr.UnableToUseConfigFile
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"file": configFile}
+		return redigoError
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		directive, value, err := splitConfigLine(line)
+		if err != nil {
+			redigoError := redigoerr.InvalidConfigValue
+			redigoError.From = err
+			redigoError.ExtraContext = map[string]string{"file": configFile, "line": strconv.Itoa(lineNumber)}
+			return redigoError
+		}
+		parameter, ok := findConfigParameter(directive)
+		if !ok {
+			redigoError := redigoerr.UnknownConfigParameter
+			redigoError.ExtraContext = map[string]string{"file": configFile, "line": strconv.Itoa(lineNumber), "parameter": directive}
+			return redigoError
+		}
+		if err := parameter.parse(serverConfig, value); err != nil {
+			redigoError := err.(redigoerr.Error)
+			redigoError.ExtraContext["file"] = configFile
+			redigoError.ExtraContext["line"] = strconv.Itoa(lineNumber)
+			return redigoError
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		redigoError := redigoerr.UnableToUseConfigFile
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"file": configFile}
+		return redigoError
+	}
+	serverConfig.ConfigFile = configFile
+	return nil
+}
+
+// settings holds the configuration of a running server, shared by every worker.
+// Values tunable with CONFIG SET are mirrored in atomics so workers read them without locking.
+type settings struct {
+	lock             sync.Mutex
+	current          Configuration
+	keepAlive        atomic.Int64
+	messageSizeLimit atomic.Int64
+	maxMemory        atomic.Int64
+	trackingMaxKeys  atomic.Int64
+	maxClients       atomic.Int64
+	idleTimeout      atomic.Int64
+	logLevel         slog.LevelVar
+	slowLog          *slowLog
+	// logger is used by the whole server, filtered by logLevel
+	logger *slog.Logger
+}
+
+func newSettings(serverConfig *Configuration, slowLog *slowLog) *settings {
+	s := &settings{current: *serverConfig, slowLog: slowLog}
+	if s.current.LogLevel == "" {
+		s.current.LogLevel = "debug"
+	}
+	if serverConfig.Logger != nil {
+		s.logger = slog.New(levelHandler{Handler: serverConfig.Logger.Handler(), level: &s.logLevel})
+	} else {
+		s.logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &s.logLevel}))
+	}
+	s.publish()
+	return s
+}
+
+// levelHandler filters the records of a handler given by the user with the level set through CONFIG SET loglevel
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// publish makes the current values visible to workers
+func (s *settings) publish() {
+	s.keepAlive.Store(s.current.KeepAlive)
+	s.messageSizeLimit.Store(int64(s.current.MessageSizeLimit))
+	s.maxMemory.Store(s.current.MaxMemory)
+	s.trackingMaxKeys.Store(int64(cmp.Or(s.current.TrackingTableMaxKeys, defaultTrackingMaxKeys)))
+	s.maxClients.Store(int64(s.current.MaxClients))
+	s.idleTimeout.Store(cmp.Or(s.current.WorkerIdleTimeout, defaultWorkerIdleTimeout))
+	s.logLevel.Set(logLevels[s.current.LogLevel])
+	s.slowLog.configure(s.current.SlowLogThreshold, s.current.SlowLogMaxLen)
+}
+
+// keepAliveDeadline is the time at which an idle connection is closed if it stays idle from now on
+func (s *settings) keepAliveDeadline() time.Time {
+	return time.Now().Add(time.Second * time.Duration(s.keepAlive.Load()))
+}
+
+// workerIdleTimeout is the time a worker waits for a connection before stopping, if there are more than the minimum
+func (s *settings) workerIdleTimeout() time.Duration {
+	return time.Second * time.Duration(s.idleTimeout.Load())
+}
+
+// deniedByMaxMemory tells if a command has to be rejected because the memory used went over maxmemory
+func (s *settings) deniedByMaxMemory(command string, write bool, usedMemory uint64) bool {
+	maxMemory := s.maxMemory.Load()
+	if maxMemory == 0 || !write || usedMemory <= uint64(maxMemory) {
+		return false
+	}
+	_, freeing := freeingCommands[command]
+	return !freeing
+}
+
+// get returns the name and value of every parameter matching any of the glob-style patterns
+func (s *settings) get(patterns []string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pairs := []string{}
+	for _, parameter := range configParameters {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(strings.ToLower(pattern), parameter.name); matched {
+				pairs = append(pairs, parameter.name, parameter.format(&s.current))
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// set changes parameters given as name value pairs. Either every parameter is changed or none is.
+func (s *settings) set(pairs []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	updated := s.current
+	for i := 0; i < len(pairs); i += 2 {
+		parameter, ok := findConfigParameter(pairs[i])
+		if !ok {
+			redigoError := redigoerr.UnknownConfigParameter
+			redigoError.ClientContext = fmt.Sprintf("%s - '%s'", redigoError.ClientContext, pairs[i])
+			redigoError.ExtraContext = map[string]string{"parameter": pairs[i]}
+			return redigoError
+		}
+		if !parameter.mutable {
+			redigoError := redigoerr.ImmutableConfigParameter
+			redigoError.ClientContext = fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - %s", parameter.name, redigoError.ClientContext)
+			redigoError.ExtraContext = map[string]string{"parameter": parameter.name}
+			return redigoError
+		}
+		if err := parameter.parse(&updated, pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	s.current = updated
+	s.publish()
+	return nil
+}
+
+// rewrite updates the configuration file with the current values. Comments and the order of directives are kept,
+// while parameters missing from the file are appended at the end.
+func (s *settings) rewrite() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	configFile := s.current.ConfigFile
+	if configFile == "" {
+		redigoError := redigoerr.NoConfigFile
+		redigoError.ExtraContext = map[string]string{}
+		return redigoError
+	}
+	fileError := func(err error) error {
+		redigoError := redigoerr.UnableToUseConfigFile
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"file": configFile}
+		return redigoError
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fileError(err)
+	}
+	lines := []string{}
+	written := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, line)
+			continue
+		}
+		directive, _, _ := splitConfigLine(trimmed)
+		parameter, ok := findConfigParameter(directive)
+		if !ok {
+			lines = append(lines, line)
+			continue
+		}
+		// Later occurrences of a directive were overridden anyway
+		if !written[parameter.name] {
+			lines = append(lines, parameter.name+" "+formatConfigValue(parameter.format(&s.current)))
+			written[parameter.name] = true
+		}
+	}
+	appended := false
+	for _, parameter := range configParameters {
+		if written[parameter.name] {
+			continue
+		}
+		if !appended {
+			lines = append(lines, "", "# Generated by CONFIG REWRITE")
+			appended = true
+		}
+		lines = append(lines, parameter.name+" "+formatConfigValue(parameter.format(&s.current)))
+	}
+
+	// Writing a temporary file first keeps the original intact if anything fails
+	temporary, err := os.CreateTemp(filepath.Dir(configFile), ".redigo-config-*")
+	if err != nil {
+		return fileError(err)
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		temporary.Close()
+		return fileError(err)
+	}
+	if err := temporary.Close(); err != nil {
+		return fileError(err)
+	}
+	if err := os.Rename(temporary.Name(), configFile); err != nil {
+		return fileError(err)
+	}
+	return nil
+}
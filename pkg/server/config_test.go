@@ -0,0 +1,163 @@
+//go:build !integration && !e2e
+// +build !integration,!e2e
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "redigo.conf")
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	return configFile
+}
+
+func TestLoadConfigurationFile_Should_Set_Directives_When_File_Is_Valid(t *testing.T) {
+	configFile := writeConfigFile(t, "# A comment\n\nbind 0.0.0.0\nport 7000\nmaxmemory 2mb\nclient-query-buffer-limit 1k\nmetrics-address \"\"\nLOGLEVEL warning\n")
+	serverConfig := Configuration{IpAddress: "127.0.0.1", KeepAlive: 15, MetricsAddress: "127.0.0.1:8002"}
+	if err := LoadConfigurationFile(configFile, &serverConfig); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	expected := Configuration{
+		IpAddress:        "0.0.0.0",
+		Port:             7000,
+		KeepAlive:        15,
+		MaxMemory:        2 << 20,
+		MessageSizeLimit: 1000,
+		LogLevel:         "warning",
+		ConfigFile:       configFile,
+	}
+	if serverConfig != expected {
+		t.Errorf("Configuration differs from the expected one! %+v", serverConfig)
+	}
+}
+
+func TestLoadConfigurationFile_Should_Return_Error_When_Directive_Is_Unknown(t *testing.T) {
+	configFile := writeConfigFile(t, "port 7000\nappendonly yes\n")
+	err := LoadConfigurationFile(configFile, &Configuration{})
+	if code, ok := redigoerr.ErrorCode(err); !ok || code != redigoerr.UnknownConfigParameter.Code {
+		t.Errorf("Expected an unknown parameter error! %v", err)
+	}
+}
+
+func TestLoadConfigurationFile_Should_Return_Error_When_Value_Is_Invalid(t *testing.T) {
+	configFile := writeConfigFile(t, "port 70000\n")
+	err := LoadConfigurationFile(configFile, &Configuration{})
+	if code, ok := redigoerr.ErrorCode(err); !ok || code != redigoerr.InvalidConfigValue.Code {
+		t.Errorf("Expected an invalid value error! %v", err)
+	}
+}
+
+func TestGet_Should_Return_Every_Parameter_Matching_When_Patterns_Are_Given(t *testing.T) {
+	s := newSettings(&Configuration{SlowLogThreshold: 10, SlowLogMaxLen: 5, Port: 6543}, nil)
+	pairs := s.get([]string{"slowlog-*", "PORT", "slowlog-max-len"})
+	expected := []string{"port", "6543", "slowlog-log-slower-than", "10", "slowlog-max-len", "5"}
+	if !slices.Equal(pairs, expected) {
+		t.Errorf("Unexpected parameters! %v", pairs)
+	}
+}
+
+func TestSet_Should_Change_Runtime_Values_When_Parameters_Are_Mutable(t *testing.T) {
+	slowLog := newSlowLog(100, 4)
+	s := newSettings(&Configuration{KeepAlive: 15, MessageSizeLimit: 10240}, slowLog)
+	if err := s.set([]string{"timeout", "3", "maxmemory", "1gb", "slowlog-log-slower-than", "-1", "loglevel", "notice"}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if s.keepAlive.Load() != 3 || s.maxMemory.Load() != 1<<30 || slowLog.threshold.Load() != -1 || s.logLevel.Level().String() != "INFO" {
+		t.Errorf("Values were not applied! %+v", s.current)
+	}
+	if deadline := s.keepAliveDeadline(); time.Until(deadline) > 3*time.Second {
+		t.Errorf("Keep alive was not applied! %v", deadline)
+	}
+}
+
+func TestSet_Should_Change_Nothing_When_Any_Parameter_Fails(t *testing.T) {
+	s := newSettings(&Configuration{KeepAlive: 15, Port: 6543}, nil)
+	err := s.set([]string{"timeout", "3", "port", "7000"})
+	if code, ok := redigoerr.ErrorCode(err); !ok || code != redigoerr.ImmutableConfigParameter.Code {
+		t.Errorf("Expected an immutable parameter error! %v", err)
+	}
+	err = s.set([]string{"timeout", "3", "maxmemory", "lots"})
+	if code, ok := redigoerr.ErrorCode(err); !ok || code != redigoerr.InvalidConfigValue.Code {
+		t.Errorf("Expected an invalid value error! %v", err)
+	}
+	if s.keepAlive.Load() != 15 {
+		t.Errorf("Timeout changed even though CONFIG SET failed! %d", s.keepAlive.Load())
+	}
+}
+
+func TestDeniedByMaxMemory_Should_Only_Deny_Commands_Adding_Data_When_Limit_Is_Exceeded(t *testing.T) {
+	s := newSettings(&Configuration{MaxMemory: 100}, nil)
+	if !s.deniedByMaxMemory("SET", true, 101) {
+		t.Errorf("SET was allowed over maxmemory!")
+	}
+	if s.deniedByMaxMemory("DEL", true, 101) || s.deniedByMaxMemory("GET", false, 101) || s.deniedByMaxMemory("SET", true, 100) {
+		t.Errorf("A command was denied when it should not be!")
+	}
+}
+
+func TestRewrite_Should_Keep_Comments_And_Append_Missing_Parameters_When_File_Exists(t *testing.T) {
+	configFile := writeConfigFile(t, "# Keep me\nport 7000\ntimeout 15\ntimeout 20\n")
+	serverConfig := Configuration{IpAddress: "127.0.0.1", WorkerAmount: 2, MessageSizeLimit: 10240}
+	if err := LoadConfigurationFile(configFile, &serverConfig); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	s := newSettings(&serverConfig, nil)
+	if err := s.set([]string{"timeout", "30", "metrics-address", ""}); err == nil {
+		t.Errorf("An immutable parameter was set!")
+	}
+	if err := s.set([]string{"timeout", "30"}); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if err := s.rewrite(); err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("An error occurred! %v", err)
+	}
+	if !strings.HasPrefix(string(content), "# Keep me\nport 7000\ntimeout 30\n\n# Generated by CONFIG REWRITE\nbind 127.0.0.1\n") ||
+		!strings.Contains(string(content), "\nmetrics-address \"\"\nslowlog-log-slower-than 0\n") {
+		t.Errorf("Unexpected rewritten file! %q", content)
+	}
+
+	// The rewritten file has to be readable again
+	reloaded := Configuration{}
+	if err := LoadConfigurationFile(configFile, &reloaded); err != nil || reloaded.KeepAlive != 30 || reloaded.Port != 7000 {
+		t.Errorf("Unable to read the rewritten file! %v - %+v", err, reloaded)
+	}
+}
+
+func TestRewrite_Should_Return_Error_When_There_Is_No_File(t *testing.T) {
+	s := newSettings(&Configuration{}, nil)
+	if code, ok := redigoerr.ErrorCode(s.rewrite()); !ok || code != redigoerr.NoConfigFile.Code {
+		t.Errorf("Expected a missing file error!")
+	}
+}
+
+func TestConfigure_Should_Keep_Newest_Entries_When_Slow_Log_Shrinks(t *testing.T) {
+	slowLog := newSlowLog(0, 4)
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		slowLog.record([]string{name}, time.Now(), time.Millisecond, "", "")
+	}
+	slowLog.configure(0, 2)
+	entries := slowLog.latest(-1)
+	if len(entries) != 2 || entries[0].args[0] != "E" || entries[1].args[0] != "D" {
+		t.Errorf("Unexpected entries after shrinking! %v", entries)
+	}
+	slowLog.record([]string{"F"}, time.Now(), time.Millisecond, "", "")
+	if entries := slowLog.latest(-1); len(entries) != 2 || entries[0].args[0] != "F" || entries[1].args[0] != "E" {
+		t.Errorf("Unexpected entries after recording! %v", entries)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// defaultDatabases is the amount of logical databases created when the configuration does not say otherwise
+const defaultDatabases = 16
+
+func newDatabases(amount int) []*cache.Cache {
+	if amount <= 0 {
+		amount = defaultDatabases
+	}
+	databases := make([]*cache.Cache, amount)
+	for i := range databases {
+		databases[i] = cache.New()
+	}
+	return databases
+}
+
+// keyspaceStats returns the statistics of every database, indexed like them
+func keyspaceStats(databases []*cache.Cache) []cache.Stats {
+	keyspaces := make([]cache.Stats, len(databases))
+	for i, db := range databases {
+		db.Lock()
+		keyspaces[i] = db.Stats()
+		db.Unlock()
+	}
+	return keyspaces
+}
+
+// lockDatabases locks two databases, always in the same order so that two workers never wait on each other.
+// The returned function unlocks them.
+func lockDatabases(databases []*cache.Cache, a int, b int) func() {
+	first, second := min(a, b), max(a, b)
+	databases[first].Lock()
+	if first != second {
+		databases[second].Lock()
+	}
+	return func() {
+		if first != second {
+			databases[second].Unlock()
+		}
+		databases[first].Unlock()
+	}
+}
+
+// databaseIndex reads the index of a database, checking it exists
+func (w *worker) databaseIndex(s string) (int, error) {
+	index, err := parseInt(s)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= int64(len(w.databases)) {
+		redigoError := redigoerr.InvalidDBIndex
+		redigoError.ExtraContext = map[string]string{"provided": s}
+		return 0, redigoError
+	}
+	return int(index), nil
+}
+
+// selectCommand answers SELECT index, changing the database used by the client
+func (w *worker) selectCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) != 2 {
+		return []byte{}, insufficientLength("2", len(args))
+	}
+	index, err := w.databaseIndex(args[1])
+	if err != nil {
+		return []byte{}, err
+	}
+	if w.cluster != nil && index != 0 {
+		return []byte{}, redigoerr.SelectInClusterMode
+	}
+	cl.setDB(index)
+	return tobytes.Null(), nil
+}
+
+// moveCommand answers MOVE key db, returning 1 when the key was moved from the selected database
+func (w *worker) moveCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) != 3 {
+		return []byte{}, insufficientLength("3", len(args))
+	}
+	dst, err := w.databaseIndex(args[2])
+	if err != nil {
+		return []byte{}, err
+	}
+	src := cl.getDB()
+	if src == dst {
+		redigoError := redigoerr.SameObject
+		redigoError.ExtraContext = map[string]string{"db": args[2]}
+		return []byte{}, redigoError
+	}
+	unlock := lockDatabases(w.databases, src, dst)
+	defer unlock()
+	if w.databases[src].Move(args[1], w.databases[dst]) {
+		return tobytes.Int(1), nil
+	}
+	return tobytes.Int(0), nil
+}
+
+// swapDBCommand answers SWAPDB index1 index2. Clients using either database see the other one's keys right away.
+func (w *worker) swapDBCommand(args []string) ([]byte, error) {
+	if len(args) != 3 {
+		return []byte{}, insufficientLength("3", len(args))
+	}
+	a, err := w.databaseIndex(args[1])
+	if err != nil {
+		return []byte{}, err
+	}
+	b, err := w.databaseIndex(args[2])
+	if err != nil {
+		return []byte{}, err
+	}
+	if a != b {
+		unlock := lockDatabases(w.databases, a, b)
+		defer unlock()
+		w.databases[a].Swap(w.databases[b])
+	}
+	return tobytes.Null(), nil
+}
+
+// flushCommand answers FLUSHDB [ASYNC|SYNC] for the selected database and FLUSHALL [ASYNC|SYNC] for every database
+func (w *worker) flushCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) > 2 {
+		return []byte{}, insufficientLength("<= 2", len(args))
+	}
+	async := false
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "ASYNC":
+			async = true
+		case "SYNC":
+		default:
+			redigoError := redigoerr.SyntaxError
+			redigoError.ExtraContext = map[string]string{"provided": args[1]}
+			return []byte{}, redigoError
+		}
+	}
+	databases := w.databases
+	if args[0] == "FLUSHDB" {
+		selected := cl.getDB()
+		databases = databases[selected : selected+1]
+	}
+	for _, db := range databases {
+		db.Lock()
+		db.Flush(async)
+		db.Unlock()
+	}
+	return tobytes.Null(), nil
+}
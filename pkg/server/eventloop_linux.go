@@ -0,0 +1,582 @@
+//go:build linux
+
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+const (
+	// eventLoopWait is the time an event loop waits for events before checking whether it has to stop
+	eventLoopWait = 100 * time.Millisecond
+	// eventLoopSweepInterval is how often connections over keep alive or killed are closed
+	eventLoopSweepInterval = time.Second
+	eventLoopReadSize      = 16 * 1024
+	eventLoopMaxEvents     = 256
+)
+
+// loopConn is a connection attended by an event loop. Since the loop reads whatever is available,
+// each connection keeps its own parser with the commands not received completely yet.
+type loopConn struct {
+	fd           int
+	conn         net.Conn
+	client       *client
+	parser       *respparser.RESPParser
+	output       []byte
+	waitingWrite bool
+	lastActivity time.Time
+	// parked holds the commands waiting for CLIENT PAUSE to end, during which the connection is not read
+	parked []respparser.Command
+}
+
+// events returns what the loop waits for from a connection
+func (lc *loopConn) events() uint32 {
+	var events uint32
+	if lc.parked == nil {
+		events = syscall.EPOLLIN | syscall.EPOLLRDHUP
+	}
+	if lc.waitingWrite {
+		events |= syscall.EPOLLOUT
+	}
+	return events
+}
+
+// eventLoop attends many connections from a single goroutine using epoll.
+// Commands are executed in the loop itself, so a command held by CLIENT PAUSE parks its connection instead of waiting:
+// it is not read again until the pause ends, when the loop is woken up to execute the commands left.
+//
+// The epoll instance is itself waited on through the runtime poller, so an idle loop parks its goroutine
+// instead of holding a thread in a blocking syscall, which would delay waking it up.
+// Other goroutines wake the loop up through an eventfd watched along with the connections.
+type eventLoop struct {
+	group     *eventLoops
+	worker    *worker
+	epollFD   int
+	epoll     *os.File
+	rawEpoll  syscall.RawConn
+	wakeFD    int
+	lock      sync.Mutex
+	conns     map[int]*loopConn
+	woken     []*loopConn
+	closed    bool
+	events    []syscall.EpollEvent
+	buffer    []byte
+	lastSweep time.Time
+}
+
+// eventLoops spreads connections over a fixed amount of event loops, instead of dedicating a worker to each one
+type eventLoops struct {
+	loops          []*eventLoop
+	next           atomic.Uint64
+	settings       *settings
+	notifications  chan struct{}
+	shutdownWaiter *sync.WaitGroup
+	// Connections accepted and not closed yet
+	clients atomic.Int64
+}
+
+func newEventLoops(amount int, settings *settings, shutdownWaiter *sync.WaitGroup, newWorker func(id uint64) *worker) (dispatcher, error) {
+	group := &eventLoops{
+		loops:          make([]*eventLoop, 0, amount),
+		settings:       settings,
+		notifications:  make(chan struct{}),
+		shutdownWaiter: shutdownWaiter,
+	}
+	for i := range amount {
+		epollFD, epoll, rawEpoll, err := newEpoll()
+		wakeFD := -1
+		if err == nil {
+			if wakeFD, err = newWakeFD(epollFD); err != nil {
+				epoll.Close()
+			}
+		}
+		if err != nil {
+			for _, loop := range group.loops {
+				loop.epoll.Close()
+				syscall.Close(loop.wakeFD)
+			}
+			redigoError := redigoerr.EventLoopUnavailable
+			redigoError.From = err
+			return nil, redigoError
+		}
+		w := newWorker(uint64(i))
+		w.notifications = group.notifications
+		w.parkPaused = true
+		group.loops = append(group.loops, &eventLoop{
+			group:     group,
+			worker:    w,
+			epollFD:   epollFD,
+			epoll:     epoll,
+			rawEpoll:  rawEpoll,
+			wakeFD:    wakeFD,
+			conns:     make(map[int]*loopConn),
+			events:    make([]syscall.EpollEvent, eventLoopMaxEvents),
+			buffer:    make([]byte, eventLoopReadSize),
+			lastSweep: time.Now(),
+		})
+	}
+	return group, nil
+}
+
+func (e *eventLoops) start() {
+	for _, loop := range e.loops {
+		e.shutdownWaiter.Add(1)
+		go loop.run()
+	}
+}
+
+// dispatch adds a connection to the next event loop.
+// It returns false when the connection has to be rejected because of maxclients.
+func (e *eventLoops) dispatch(conn net.Conn) bool {
+	maxClients := e.settings.maxClients.Load()
+	if clients := e.clients.Add(1); maxClients > 0 && clients > maxClients {
+		e.clients.Add(-1)
+		return false
+	}
+	loop := e.loops[e.next.Add(1)%uint64(len(e.loops))]
+	if err := loop.add(conn); err != nil {
+		e.settings.logger.Error("Unable to add connection to the event loop", "ERROR", err,
+			slog.Uint64("WORKERID", loop.worker.id),
+			slog.String("CLIENT", conn.RemoteAddr().String()),
+		)
+	}
+	return true
+}
+
+// stop signals every event loop to close its connections and exit
+func (e *eventLoops) stop() {
+	close(e.notifications)
+}
+
+// newEpoll creates an epoll instance the runtime poller can wait on.
+// File.Fd is never used on it, since that would put it back in blocking mode.
+func newEpoll() (int, *os.File, syscall.RawConn, error) {
+	epollFD, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if err := syscall.SetNonblock(epollFD, true); err != nil {
+		syscall.Close(epollFD)
+		return 0, nil, nil, err
+	}
+	epoll := os.NewFile(uintptr(epollFD), "epoll")
+	rawEpoll, err := epoll.SyscallConn()
+	if err != nil {
+		epoll.Close()
+		return 0, nil, nil, err
+	}
+	return epollFD, epoll, rawEpoll, nil
+}
+
+// newWakeFD creates an eventfd watched by an epoll instance, written to wake its loop up
+func newWakeFD(epollFD int) (int, error) {
+	fd, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err := syscall.EpollCtl(epollFD, syscall.EPOLL_CTL_ADD, int(fd), &event); err != nil {
+		syscall.Close(int(fd))
+		return -1, err
+	}
+	return int(fd), nil
+}
+
+// connFD returns the file descriptor of a connection, so that it can be watched with epoll
+func connFD(conn net.Conn) (int, error) {
+	syscallConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, redigoerr.EventLoopUnavailable
+	}
+	rawConn, err := syscallConn.SyscallConn()
+	if err != nil {
+		redigoError := redigoerr.EventLoopUnavailable
+		redigoError.From = err
+		return 0, redigoError
+	}
+	fd := -1
+	if err := rawConn.Control(func(descriptor uintptr) { fd = int(descriptor) }); err != nil {
+		redigoError := redigoerr.EventLoopUnavailable
+		redigoError.From = err
+		return 0, redigoError
+	}
+	return fd, nil
+}
+
+// add starts watching a connection. On error the connection is closed.
+func (l *eventLoop) add(conn net.Conn) error {
+	w := l.worker
+	w.stats.recordConnection()
+	lc := &loopConn{
+		fd:           -1,
+		conn:         conn,
+		client:       w.clients.register(conn, w.id),
+		parser:       respparser.New(nil, int(l.group.settings.messageSizeLimit.Load())),
+		lastActivity: time.Now(),
+	}
+	lc.parser.Delegate(delegatedCommands...)
+	lc.client.setOnKill(func() { l.wake(lc) })
+	fd, err := connFD(conn)
+	if err != nil {
+		l.release(lc)
+		return err
+	}
+	lc.fd = fd
+	l.lock.Lock()
+	l.conns[fd] = lc
+	l.lock.Unlock()
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN | syscall.EPOLLRDHUP, Fd: int32(fd)}
+	if err := syscall.EpollCtl(l.epollFD, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		l.lock.Lock()
+		delete(l.conns, fd)
+		l.lock.Unlock()
+		l.release(lc)
+		redigoError := redigoerr.EventLoopUnavailable
+		redigoError.From = err
+		return redigoError
+	}
+	return nil
+}
+
+// run waits for connections to be readable (or writable when a response did not fit) until stopped
+func (l *eventLoop) run() {
+	defer l.group.shutdownWaiter.Done()
+	defer l.closeAll()
+	l.worker.settings.logger.Info("Starting event loop", slog.Uint64("WORKERID", l.worker.id))
+
+	for {
+		select {
+		case <-l.worker.notifications:
+			l.worker.settings.logger.Debug("Starting shutdown for event loop, closing connections", slog.Uint64("WORKERID", l.worker.id))
+			return
+		default:
+		}
+		n, err := l.wait()
+		if err != nil {
+			l.worker.settings.logger.Error("An error occurred while waiting for events", "ERROR", err, slog.Uint64("WORKERID", l.worker.id))
+			return
+		}
+		for _, event := range l.events[:n] {
+			if int(event.Fd) == l.wakeFD {
+				l.handleWake()
+				continue
+			}
+			l.lock.Lock()
+			lc := l.conns[int(event.Fd)]
+			l.lock.Unlock()
+			if lc == nil {
+				continue
+			}
+			if event.Events&syscall.EPOLLOUT != 0 && !l.flush(lc) {
+				continue
+			}
+			if lc.parked != nil {
+				// Only a connection hung up is noticed while parked
+				if event.Events&(syscall.EPOLLHUP|syscall.EPOLLERR) != 0 {
+					l.close(lc)
+				}
+				continue
+			}
+			if event.Events&(syscall.EPOLLIN|syscall.EPOLLRDHUP|syscall.EPOLLHUP|syscall.EPOLLERR) != 0 {
+				l.handleRead(lc)
+			}
+		}
+		if time.Since(l.lastSweep) >= eventLoopSweepInterval {
+			l.sweep()
+		}
+	}
+}
+
+// wait returns the amount of events received, or zero if none arrived in eventLoopWait
+func (l *eventLoop) wait() (int, error) {
+	n := 0
+	var waitErr error
+	l.epoll.SetReadDeadline(time.Now().Add(eventLoopWait))
+	// Returning false parks the goroutine until the epoll instance has events
+	err := l.rawEpoll.Read(func(fd uintptr) bool {
+		n, waitErr = syscall.EpollWait(int(fd), l.events, 0)
+		return n > 0 || (waitErr != nil && waitErr != syscall.EINTR)
+	})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(n, 0), waitErr
+}
+
+// wake makes the loop look again at a connection from another goroutine, since it was killed or its pause ended
+func (l *eventLoop) wake(lc *loopConn) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	// Once closed, the eventfd may belong to another file
+	if l.closed {
+		return
+	}
+	l.woken = append(l.woken, lc)
+	var counter [8]byte
+	binary.NativeEndian.PutUint64(counter[:], 1)
+	syscall.Write(l.wakeFD, counter[:])
+}
+
+// handleWake closes the connections killed since the loop was last woken up, and resumes the parked ones
+func (l *eventLoop) handleWake() {
+	var counter [8]byte
+	syscall.Read(l.wakeFD, counter[:])
+	l.lock.Lock()
+	woken := l.woken
+	l.woken = nil
+	l.lock.Unlock()
+	for _, lc := range woken {
+		l.lock.Lock()
+		// The connection may have been closed meanwhile, and its descriptor reused
+		current := l.conns[lc.fd] == lc
+		l.lock.Unlock()
+		if !current {
+			continue
+		}
+		if lc.client.isKilled() {
+			l.worker.settings.logger.Debug("The connection was killed", slog.Uint64("WORKERID", l.worker.id), slog.String("CLIENT", lc.client.addr))
+			l.close(lc)
+		} else if commands := lc.parked; commands != nil {
+			// Reading resumes right away, anything sent meanwhile waits in the socket
+			lc.parked = nil
+			lc.lastActivity = time.Now()
+			l.watch(lc, lc.events())
+			l.serve(lc, commands)
+		}
+	}
+}
+
+// handleRead reads what a connection sent and answers every complete command in it
+func (l *eventLoop) handleRead(lc *loopConn) {
+	w := l.worker
+	if lc.client.isKilled() {
+		l.close(lc)
+		return
+	}
+	n, err := syscall.Read(lc.fd, l.buffer)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return
+	}
+	if err != nil || n == 0 {
+		l.worker.settings.logger.Debug("The connection was closed", "REASON", err,
+			slog.Uint64("WORKERID", w.id),
+			slog.String("CLIENT", lc.client.addr))
+		l.close(lc)
+		return
+	}
+	w.stats.recordBytes(n, 0)
+	lc.lastActivity = time.Now()
+
+	lc.parser.SetMessageSizeLimit(int(l.group.settings.messageSizeLimit.Load()))
+	if err := lc.parser.Feed(l.buffer[:n]); redigoerr.ExceededMaxSize(err) {
+		// Too big of a command
+		w.stats.recordError(err)
+		lc.output = append(lc.output, tobytes.Err(err)...)
+		l.flush(lc)
+		return
+	}
+	commands, err := lc.parser.ParseCommand()
+	// If the buffer was exhausted, do not return an error
+	if !redigoerr.BufferExhausted(err) && err != nil {
+		// Command malformed, answer and close the connection
+		w.stats.recordError(err)
+		l.worker.settings.logger.Error("An error occurred while parsing the command", "ERROR", err,
+			slog.Uint64("WORKERID", w.id),
+			slog.String("CLIENT", lc.client.addr),
+		)
+		lc.output = append(lc.output, tobytes.Err(err)...)
+		if l.flush(lc) {
+			l.close(lc)
+		}
+		return
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	l.serve(lc, commands)
+}
+
+// serve executes commands of a connection and writes the responses, parking the connection when CLIENT PAUSE holds one
+func (l *eventLoop) serve(lc *loopConn, commands []respparser.Command) {
+	w := l.worker
+	response, paused, monitorID, monitorLines := w.execute(lc.client, commands)
+	w.stats.recordBytes(0, len(response))
+	lc.output = append(lc.output, response...)
+	if monitorLines != nil {
+		l.handOverMonitor(lc, monitorID, monitorLines)
+		return
+	}
+	if paused != nil {
+		l.park(lc, paused)
+	}
+	if l.flush(lc) && lc.client.isKilled() {
+		l.worker.settings.logger.Debug("The connection was killed", slog.Uint64("WORKERID", w.id), slog.String("CLIENT", lc.client.addr))
+		l.close(lc)
+	}
+}
+
+// park stops reading a connection until the pause holding its commands ends (or the server stops),
+// waking the loop up then to execute them
+func (l *eventLoop) park(lc *loopConn, paused *pausedCommands) {
+	lc.parked = paused.commands
+	l.watch(lc, lc.events())
+	go func() {
+		select {
+		case <-paused.unpaused:
+		case <-l.worker.notifications:
+		}
+		l.wake(lc)
+	}()
+}
+
+// flush writes as much of the pending output as the connection accepts, waiting for it to be writable
+// when something is left. It returns false when the connection was closed.
+func (l *eventLoop) flush(lc *loopConn) bool {
+	if err := l.write(lc); err != nil {
+		l.worker.settings.logger.Error("An error occurred while returning a response to the client", "ERROR", err,
+			slog.Uint64("WORKERID", l.worker.id),
+			slog.String("CLIENT", lc.client.addr),
+		)
+		l.close(lc)
+		return false
+	}
+	return true
+}
+
+// write writes the pending output followed by any push message that arrived meanwhile.
+// While something is left, push messages wait for it instead of being written in between.
+func (l *eventLoop) write(lc *loopConn) error {
+	cl := lc.client
+	cl.outLock.Lock()
+	defer cl.outLock.Unlock()
+	for {
+		for len(lc.output) > 0 {
+			n, err := syscall.Write(lc.fd, lc.output)
+			if err == syscall.EAGAIN {
+				cl.partial = true
+				if !lc.waitingWrite {
+					lc.waitingWrite = true
+					l.watch(lc, lc.events())
+				}
+				return nil
+			}
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			lc.output = lc.output[n:]
+		}
+		if len(cl.deferred) == 0 {
+			break
+		}
+		lc.output, cl.deferred = cl.deferred, nil
+	}
+	cl.partial = false
+	lc.output = nil
+	if lc.waitingWrite {
+		lc.waitingWrite = false
+		l.watch(lc, lc.events())
+	}
+	return nil
+}
+
+func (l *eventLoop) watch(lc *loopConn, events uint32) {
+	event := syscall.EpollEvent{Events: events, Fd: int32(lc.fd)}
+	if err := syscall.EpollCtl(l.epollFD, syscall.EPOLL_CTL_MOD, lc.fd, &event); err != nil {
+		l.worker.settings.logger.Error("An error occurred while watching the connection", "ERROR", err,
+			slog.Uint64("WORKERID", l.worker.id),
+			slog.String("CLIENT", lc.client.addr),
+		)
+	}
+}
+
+// forget stops watching a connection without closing it
+func (l *eventLoop) forget(lc *loopConn) {
+	syscall.EpollCtl(l.epollFD, syscall.EPOLL_CTL_DEL, lc.fd, nil)
+	l.lock.Lock()
+	delete(l.conns, lc.fd)
+	l.lock.Unlock()
+}
+
+func (l *eventLoop) close(lc *loopConn) {
+	l.forget(lc)
+	l.release(lc)
+}
+
+// release closes a connection which is no longer watched
+func (l *eventLoop) release(lc *loopConn) {
+	lc.conn.Close()
+	l.worker.tracker.disable(lc.client)
+	l.worker.clients.unregister(lc.client)
+	l.worker.stats.recordDisconnection()
+	l.group.clients.Add(-1)
+}
+
+// handOverMonitor moves a connection in monitoring mode out of the loop, since streaming
+// commands to it would otherwise depend on the connection sending something
+func (l *eventLoop) handOverMonitor(lc *loopConn, monitorID uint64, monitorLines chan []byte) {
+	l.forget(lc)
+	go func() {
+		defer l.release(lc)
+		defer l.worker.monitor.unsubscribe(monitorID)
+		lc.conn.SetWriteDeadline(l.group.settings.keepAliveDeadline())
+		if _, err := lc.conn.Write(lc.output); err != nil {
+			return
+		}
+		l.worker.streamMonitor(lc.client, monitorLines)
+	}()
+}
+
+// sweep closes connections which have been quiet for longer than keep alive, or were killed without waking the loop
+func (l *eventLoop) sweep() {
+	now := time.Now()
+	l.lastSweep = now
+	keepAlive := time.Duration(l.group.settings.keepAlive.Load()) * time.Second
+	expired := []*loopConn{}
+	l.lock.Lock()
+	for _, lc := range l.conns {
+		// Parked connections are not quiet, they wait for the pause to end
+		if (lc.parked == nil && now.Sub(lc.lastActivity) > keepAlive) || lc.client.isKilled() {
+			expired = append(expired, lc)
+		}
+	}
+	l.lock.Unlock()
+	for _, lc := range expired {
+		l.worker.settings.logger.Debug("The connection expired or was killed", slog.Uint64("WORKERID", l.worker.id), slog.String("CLIENT", lc.client.addr))
+		l.close(lc)
+	}
+}
+
+// closeAll closes every connection left, the epoll instance and the eventfd
+func (l *eventLoop) closeAll() {
+	l.lock.Lock()
+	l.closed = true
+	conns := make([]*loopConn, 0, len(l.conns))
+	for _, lc := range l.conns {
+		conns = append(conns, lc)
+	}
+	l.lock.Unlock()
+	for _, lc := range conns {
+		l.close(lc)
+	}
+	l.epoll.Close()
+	syscall.Close(l.wakeFD)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package server
+
+import (
+	"sync"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// newEventLoops is only implemented with epoll, so any other platform has to use workers
+func newEventLoops(amount int, settings *settings, shutdownWaiter *sync.WaitGroup, newWorker func(id uint64) *worker) (dispatcher, error) {
+	return nil, redigoerr.EventLoopUnavailable
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+)
+
+// defaultInfoSections are returned by INFO when no section is requested, in order
+var defaultInfoSections = []string{"server", "clients", "memory", "persistence", "stats", "cluster", "keyspace"}
+
+// allInfoSections adds sections too verbose to be returned by default
+var allInfoSections = append(slices.Clone(defaultInfoSections), "commandstats")
+
+// info renders the requested sections in the REDIS INFO text format.
+// Unknown sections are ignored, and 'all', 'everything' and 'default' expand to several sections.
+func (s *stats) info(keyspaces []cache.Stats, requested ...string) string {
+	sections := []string{}
+	if len(requested) == 0 {
+		requested = []string{"default"}
+	}
+	for _, section := range requested {
+		switch section = strings.ToLower(section); section {
+		case "default":
+			sections = append(sections, defaultInfoSections...)
+		case "all", "everything":
+			sections = append(sections, allInfoSections...)
+		default:
+			sections = append(sections, section)
+		}
+	}
+
+	b := &strings.Builder{}
+	rendered := map[string]bool{}
+	for _, section := range sections {
+		if rendered[section] || !slices.Contains(allInfoSections, section) {
+			continue
+		}
+		if len(rendered) > 0 {
+			b.WriteString("\r\n")
+		}
+		rendered[section] = true
+		switch section {
+		case "server":
+			s.serverInfo(b)
+		case "clients":
+			s.clientsInfo(b)
+		case "memory":
+			memoryInfo(b)
+		case "persistence":
+			persistenceInfo(b)
+		case "stats":
+			s.statsInfo(b, totalKeyspace(keyspaces))
+		case "cluster":
+			s.clusterInfo(b)
+		case "keyspace":
+			keyspaceInfo(b, keyspaces)
+		case "commandstats":
+			s.commandStatsInfo(b)
+		}
+	}
+	return b.String()
+}
+
+func (s *stats) serverInfo(b *strings.Builder) {
+	uptime := time.Since(s.startTime)
+	b.WriteString("# Server\r\n")
+	mode := "standalone"
+	if s.clusterEnabled {
+		mode = "cluster"
+	}
+	fmt.Fprintf(b, "redigo_mode:%s\r\n", mode)
+	fmt.Fprintf(b, "os:%s %s\r\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(b, "go_version:%s\r\n", runtime.Version())
+	fmt.Fprintf(b, "process_id:%d\r\n", os.Getpid())
+	fmt.Fprintf(b, "tcp_port:%d\r\n", s.port)
+	fmt.Fprintf(b, "uptime_in_seconds:%d\r\n", int64(uptime.Seconds()))
+	fmt.Fprintf(b, "uptime_in_days:%d\r\n", int64(uptime.Hours()/24))
+}
+
+func (s *stats) clusterInfo(b *strings.Builder) {
+	enabled := 0
+	if s.clusterEnabled {
+		enabled = 1
+	}
+	b.WriteString("# Cluster\r\n")
+	fmt.Fprintf(b, "cluster_enabled:%d\r\n", enabled)
+}
+
+func (s *stats) clientsInfo(b *strings.Builder) {
+	workers, idle, queued := s.pool.counts()
+	b.WriteString("# Clients\r\n")
+	fmt.Fprintf(b, "connected_clients:%d\r\n", s.clients.Load())
+	fmt.Fprintf(b, "queued_clients:%d\r\n", queued)
+	fmt.Fprintf(b, "workers:%d\r\n", workers)
+	fmt.Fprintf(b, "idle_workers:%d\r\n", idle)
+	if count := s.queueWaitCount.Load(); count > 0 {
+		fmt.Fprintf(b, "queue_wait_usec_per_client:%.2f\r\n", float64(s.queueWaitNanoseconds.Load())/1000/float64(count))
+	}
+}
+
+func memoryInfo(b *strings.Builder) {
+	memory := runtime.MemStats{}
+	runtime.ReadMemStats(&memory)
+	b.WriteString("# Memory\r\n")
+	fmt.Fprintf(b, "used_memory:%d\r\n", memory.HeapAlloc)
+	fmt.Fprintf(b, "used_memory_human:%s\r\n", bytesToHuman(memory.HeapAlloc))
+	fmt.Fprintf(b, "used_memory_system:%d\r\n", memory.Sys)
+	fmt.Fprintf(b, "used_memory_system_human:%s\r\n", bytesToHuman(memory.Sys))
+	fmt.Fprintf(b, "mem_allocator:go\r\n")
+}
+
+// persistenceInfo is always empty-handed, since the cache only lives in memory
+func persistenceInfo(b *strings.Builder) {
+	b.WriteString("# Persistence\r\n")
+	fmt.Fprintf(b, "loading:0\r\n")
+	fmt.Fprintf(b, "rdb_enabled:0\r\n")
+	fmt.Fprintf(b, "aof_enabled:0\r\n")
+}
+
+func (s *stats) statsInfo(b *strings.Builder, keyspace cache.Stats) {
+	b.WriteString("# Stats\r\n")
+	fmt.Fprintf(b, "total_connections_received:%d\r\n", s.connectionsReceived.Load())
+	fmt.Fprintf(b, "total_commands_processed:%d\r\n", s.commandsProcessed.Load())
+	fmt.Fprintf(b, "instantaneous_ops_per_sec:%d\r\n", s.instantaneousOps())
+	fmt.Fprintf(b, "total_net_input_bytes:%d\r\n", s.netInputBytes.Load())
+	fmt.Fprintf(b, "total_net_output_bytes:%d\r\n", s.netOutputBytes.Load())
+	fmt.Fprintf(b, "rejected_connections:%d\r\n", s.rejectedConnections.Load())
+	fmt.Fprintf(b, "keyspace_hits:%d\r\n", keyspace.Hits)
+	fmt.Fprintf(b, "keyspace_misses:%d\r\n", keyspace.Misses)
+}
+
+// totalKeyspace adds up the statistics of every database
+func totalKeyspace(keyspaces []cache.Stats) cache.Stats {
+	total := cache.Stats{KeysByType: make(map[string]int)}
+	for _, keyspace := range keyspaces {
+		total.Keys += keyspace.Keys
+		total.Hits += keyspace.Hits
+		total.Misses += keyspace.Misses
+		for t, n := range keyspace.KeysByType {
+			total.KeysByType[t] += n
+		}
+	}
+	return total
+}
+
+// keyspaceInfo writes a line for every database holding keys, extended with the amount of keys of every type
+func keyspaceInfo(b *strings.Builder, keyspaces []cache.Stats) {
+	b.WriteString("# Keyspace\r\n")
+	for i, keyspace := range keyspaces {
+		if keyspace.Keys == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "db%d:keys=%d,expires=0,avg_ttl=0", i, keyspace.Keys)
+		types := make([]string, 0, len(keyspace.KeysByType))
+		for t := range keyspace.KeysByType {
+			types = append(types, t)
+		}
+		slices.Sort(types)
+		for _, t := range types {
+			fmt.Fprintf(b, ",%s=%d", t, keyspace.KeysByType[t])
+		}
+		b.WriteString("\r\n")
+	}
+}
+
+func (s *stats) commandStatsInfo(b *strings.Builder) {
+	b.WriteString("# Commandstats\r\n")
+	names := []string{}
+	s.commands.Range(func(key any, _ any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	slices.Sort(names)
+	for _, name := range names {
+		v, _ := s.commands.Load(name)
+		command := v.(*commandStats)
+		calls, usec := command.calls.Load(), command.nanoseconds.Load()/1000
+		fmt.Fprintf(b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=0,failed_calls=%d\r\n",
+			strings.ToLower(name), calls, usec, float64(usec)/float64(max(calls, 1)), command.failed.Load())
+	}
+}
+
+// bytesToHuman formats an amount of bytes the same way REDIS does (1.50K, 3.00M...)
+func bytesToHuman(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	value := float64(n)
+	for i, unit := range units {
+		if value < 1024 || i == len(units)-1 {
+			if i == 0 {
+				return fmt.Sprintf("%dB", n)
+			}
+			return fmt.Sprintf("%.2f%s", value, unit)
+		}
+		value /= 1024
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/cache"
+)
+
+// metricsHandler serves /metrics in the Prometheus text exposition format
+func (s *Server) metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.stats.writeMetrics(w, keyspaceStats(s.databases))
+	})
+	return mux
+}
+
+// metricHeader writes the HELP and TYPE lines preceding every metric
+func metricHeader(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'g', -1, 64)
+}
+
+func (s *stats) writeMetrics(w io.Writer, keyspaces []cache.Stats) {
+	keyspace := totalKeyspace(keyspaces)
+	workers, idle, queued := s.pool.counts()
+	busy := max(workers-idle, 0)
+
+	metricHeader(w, "redigo_uptime_seconds", "gauge", "Time since the server started.")
+	fmt.Fprintf(w, "redigo_uptime_seconds %s\n", formatFloat(time.Since(s.startTime).Seconds()))
+	metricHeader(w, "redigo_connected_clients", "gauge", "Connections currently attended by workers.")
+	fmt.Fprintf(w, "redigo_connected_clients %d\n", s.clients.Load())
+	metricHeader(w, "redigo_queued_clients", "gauge", "Connections accepted and waiting for a worker.")
+	fmt.Fprintf(w, "redigo_queued_clients %d\n", queued)
+	metricHeader(w, "redigo_workers", "gauge", "Workers by state.")
+	fmt.Fprintf(w, "redigo_workers{state=\"busy\"} %d\nredigo_workers{state=\"idle\"} %d\n", busy, workers-busy)
+	metricHeader(w, "redigo_worker_utilization_ratio", "gauge", "Fraction of workers attending a connection.")
+	fmt.Fprintf(w, "redigo_worker_utilization_ratio %s\n", formatFloat(float64(busy)/float64(max(workers, 1))))
+	metricHeader(w, "redigo_queue_wait_seconds", "histogram", "Time connections waited for a worker.")
+	cumulative := uint64(0)
+	for i, bound := range latencyBuckets {
+		cumulative += s.queueWaitBuckets[i].Load()
+		fmt.Fprintf(w, "redigo_queue_wait_seconds_bucket{le=%q} %d\n", formatFloat(bound), cumulative)
+	}
+	waits := s.queueWaitCount.Load()
+	fmt.Fprintf(w, "redigo_queue_wait_seconds_bucket{le=\"+Inf\"} %d\n", waits)
+	fmt.Fprintf(w, "redigo_queue_wait_seconds_sum %s\n", formatFloat(float64(s.queueWaitNanoseconds.Load())/1e9))
+	fmt.Fprintf(w, "redigo_queue_wait_seconds_count %d\n", waits)
+	metricHeader(w, "redigo_connections_received_total", "counter", "Connections accepted by the server.")
+	fmt.Fprintf(w, "redigo_connections_received_total %d\n", s.connectionsReceived.Load())
+	metricHeader(w, "redigo_connections_rejected_total", "counter", "Connections rejected by the server.")
+	fmt.Fprintf(w, "redigo_connections_rejected_total %d\n", s.rejectedConnections.Load())
+	metricHeader(w, "redigo_commands_processed_total", "counter", "Commands executed by the server.")
+	fmt.Fprintf(w, "redigo_commands_processed_total %d\n", s.commandsProcessed.Load())
+	metricHeader(w, "redigo_net_input_bytes_total", "counter", "Bytes read from clients.")
+	fmt.Fprintf(w, "redigo_net_input_bytes_total %d\n", s.netInputBytes.Load())
+	metricHeader(w, "redigo_net_output_bytes_total", "counter", "Bytes written to clients.")
+	fmt.Fprintf(w, "redigo_net_output_bytes_total %d\n", s.netOutputBytes.Load())
+	metricHeader(w, "redigo_keyspace_hits_total", "counter", "Key lookups that found their key.")
+	fmt.Fprintf(w, "redigo_keyspace_hits_total %d\n", keyspace.Hits)
+	metricHeader(w, "redigo_keyspace_misses_total", "counter", "Key lookups that did not find their key.")
+	fmt.Fprintf(w, "redigo_keyspace_misses_total %d\n", keyspace.Misses)
+
+	metricHeader(w, "redigo_keys", "gauge", "Keys stored by type.")
+	types := make([]string, 0, len(keyspace.KeysByType))
+	for t := range keyspace.KeysByType {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "redigo_keys{type=%q} %d\n", t, keyspace.KeysByType[t])
+	}
+
+	metricHeader(w, "redigo_db_keys", "gauge", "Keys stored by database, only for databases holding any.")
+	for i, db := range keyspaces {
+		if db.Keys > 0 {
+			fmt.Fprintf(w, "redigo_db_keys{db=\"%d\"} %d\n", i, db.Keys)
+		}
+	}
+
+	memory := runtime.MemStats{}
+	runtime.ReadMemStats(&memory)
+	metricHeader(w, "redigo_memory_used_bytes", "gauge", "Estimate of the memory used, as bytes allocated in the heap.")
+	fmt.Fprintf(w, "redigo_memory_used_bytes %d\n", memory.HeapAlloc)
+
+	metricHeader(w, "redigo_errors_total", "counter", "Errors returned to clients by redigo error code.")
+	codes := []uint16{}
+	s.errors.Range(func(key any, _ any) bool {
+		codes = append(codes, key.(uint16))
+		return true
+	})
+	slices.Sort(codes)
+	for _, code := range codes {
+		v, _ := s.errors.Load(code)
+		fmt.Fprintf(w, "redigo_errors_total{code=\"%d\"} %d\n", code, v.(*atomic.Uint64).Load())
+	}
+
+	metricHeader(w, "redigo_command_duration_seconds", "histogram", "Time spent executing commands.")
+	names := []string{}
+	s.commands.Range(func(key any, _ any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	slices.Sort(names)
+	for _, name := range names {
+		v, _ := s.commands.Load(name)
+		command := v.(*commandStats)
+		label := strings.ToLower(name)
+		cumulative := uint64(0)
+		for i, bound := range latencyBuckets {
+			cumulative += command.buckets[i].Load()
+			fmt.Fprintf(w, "redigo_command_duration_seconds_bucket{command=%q,le=%q} %d\n", label, formatFloat(bound), cumulative)
+		}
+		calls := command.calls.Load()
+		fmt.Fprintf(w, "redigo_command_duration_seconds_bucket{command=%q,le=\"+Inf\"} %d\n", label, calls)
+		fmt.Fprintf(w, "redigo_command_duration_seconds_sum{command=%q} %s\n", label, formatFloat(float64(command.nanoseconds.Load())/1e9))
+		fmt.Fprintf(w, "redigo_command_duration_seconds_count{command=%q} %d\n", label, calls)
+	}
+	metricHeader(w, "redigo_command_failures_total", "counter", "Commands that returned an error, by command name.")
+	for _, name := range names {
+		v, _ := s.commands.Load(name)
+		fmt.Fprintf(w, "redigo_command_failures_total{command=%q} %d\n", strings.ToLower(name), v.(*commandStats).failed.Load())
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// migrateCommand answers MIGRATE host port key|"" destination-db timeout [COPY] [REPLACE] [KEYS key [key ...]].
+// Keys are dumped, restored in the target (after ASKING in cluster mode, since the slot is being imported there)
+// and then removed, unless COPY is given. Keys the target refused are kept.
+// The database is only locked to dump and to remove keys, not while waiting for the target. A key changed (or removed)
+// meanwhile is left as it is, since the target holds an older value, and the command fails. In cluster mode writes to the keys
+// being sent are answered with TRYAGAIN instead, so they land in the target once the keys are gone.
+func (w *worker) migrateCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) < 6 {
+		return []byte{}, insufficientLength(">= 6", len(args))
+	}
+	port, err := parsePort(args[2])
+	if err != nil {
+		return []byte{}, err
+	}
+	destination, err := parseInt(args[4])
+	if err != nil {
+		return []byte{}, err
+	}
+	timeout, err := parseInt(args[5])
+	if err != nil {
+		return []byte{}, err
+	}
+	if timeout <= 0 {
+		timeout = 1000
+	}
+	keys := []string{args[3]}
+	copying, replace := false, false
+	for i := 6; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COPY":
+			copying = true
+		case "REPLACE":
+			replace = true
+		case "KEYS":
+			if args[3] != "" || i+1 >= len(args) {
+				return []byte{}, redigoerr.SyntaxError
+			}
+			keys = args[i+1:]
+			i = len(args)
+		default:
+			return []byte{}, redigoerr.SyntaxError
+		}
+	}
+
+	db := w.databases[cl.getDB()]
+	db.Lock()
+	dumped, payloads := []string{}, [][]byte{}
+	for _, key := range keys {
+		if payload, ok := db.Dump(key); ok {
+			dumped = append(dumped, key)
+			payloads = append(payloads, payload)
+		}
+	}
+	if len(dumped) == 0 {
+		db.Unlock()
+		return tobytes.BlobString("NOKEY"), nil
+	}
+	if !copying {
+		w.cluster.startSending(dumped)
+	}
+	db.Unlock()
+
+	restored, err := w.restoreIn(net.JoinHostPort(args[1], strconv.Itoa(port)), int(destination), time.Duration(timeout)*time.Millisecond, dumped, payloads, replace)
+	if copying {
+		if err != nil {
+			return []byte{}, err
+		}
+		return tobytes.Null(), nil
+	}
+	removed, changed := []string{}, []string{}
+	db.Lock()
+	for _, key := range restored {
+		// The payload of a key dumped again only differs when the key was changed meanwhile
+		if payload, ok := db.Dump(key); ok && bytes.Equal(payload, payloads[slices.Index(dumped, key)]) {
+			db.Del(key)
+			removed = append(removed, key)
+		} else {
+			changed = append(changed, key)
+		}
+	}
+	w.cluster.stopSending(dumped)
+	db.Unlock()
+	if len(removed) > 0 && !w.tracker.idle() {
+		w.tracker.modified(cl, removed)
+	}
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(changed) > 0 {
+		redigoError := redigoerr.UnableToMigrate
+		redigoError.ClientContext = "Keys changed while being migrated were left as they are: " + strings.Join(changed, " ")
+		redigoError.ExtraContext = map[string]string{"keys": strings.Join(changed, ",")}
+		return []byte{}, redigoError
+	}
+	return tobytes.Null(), nil
+}
+
+// restoreIn sends RESTORE for every key to another server at once, returning the keys it restored.
+// The database is selected beforehand, so that no key ends up in another one when the target refuses it.
+func (w *worker) restoreIn(address string, destination int, timeout time.Duration, keys []string, payloads [][]byte, replace bool) ([]string, error) {
+	unableToMigrate := func(err error) error {
+		redigoError := redigoerr.UnableToMigrate
+		redigoError.From = err
+		redigoError.ExtraContext = map[string]string{"target": address}
+		return redigoError
+	}
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, unableToMigrate(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	target := &migrationTarget{conn: conn, reader: bufio.NewReader(conn)}
+
+	if destination != 0 {
+		if err := target.send([]string{"SELECT", strconv.Itoa(destination)}); err != nil {
+			return nil, unableToMigrate(err)
+		}
+		text, err := target.reply()
+		if err != nil {
+			return nil, unableToMigrate(err)
+		} else if text != "" {
+			return nil, targetRefused(address, text, map[string]string{"db": strconv.Itoa(destination)})
+		}
+	}
+
+	commands := [][]string{}
+	for i, key := range keys {
+		if w.cluster != nil {
+			commands = append(commands, []string{"ASKING"})
+		}
+		restore := []string{"RESTORE", key, "0", string(payloads[i])}
+		if replace {
+			restore = append(restore, "REPLACE")
+		}
+		commands = append(commands, restore)
+	}
+	if err := target.send(commands...); err != nil {
+		return nil, unableToMigrate(err)
+	}
+
+	restored := []string{}
+	var refused error
+	for _, key := range keys {
+		if w.cluster != nil {
+			if _, err := target.reply(); err != nil {
+				return restored, unableToMigrate(err)
+			}
+		}
+		text, err := target.reply()
+		if err != nil {
+			// Keys whose reply never arrived may or may not be in the target, so they are kept
+			return restored, unableToMigrate(err)
+		}
+		if text == "" {
+			restored = append(restored, key)
+		} else if refused == nil {
+			// The error of the target is passed along, like BUSYKEY
+			refused = targetRefused(address, text, map[string]string{"key": key})
+		}
+	}
+	return restored, refused
+}
+
+// targetRefused passes along an error sent by the target of a MIGRATE
+func targetRefused(address string, text string, extraContext map[string]string) error {
+	redigoError := redigoerr.UnableToMigrate
+	redigoError.ClientContext = "Target instance replied with error: " + text
+	redigoError.ExtraContext = extraContext
+	redigoError.ExtraContext["target"] = address
+	return redigoError
+}
+
+// migrationTarget speaks just enough RESP to send commands to the target of a MIGRATE and read their replies
+type migrationTarget struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// send writes every command at once
+func (t *migrationTarget) send(commands ...[]string) error {
+	request := []byte{}
+	for _, command := range commands {
+		args := make([][]byte, len(command))
+		for i, arg := range command {
+			args[i] = tobytes.BlobString(arg)
+		}
+		request = append(request, tobytes.Array(args...)...)
+	}
+	_, err := t.conn.Write(request)
+	return err
+}
+
+// reply reads the reply to a command, returning the text of the error sent by the target or "" if there was none.
+// Only the replies MIGRATE expects are understood: statuses, nulls, integers and errors.
+func (t *migrationTarget) reply() (string, error) {
+	line, err := t.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", io.ErrUnexpectedEOF
+	}
+	switch line[0] {
+	case '-':
+		return line[1:], nil
+	case '+', '_', ':':
+		return "", nil
+	default:
+		redigoError := redigoerr.UnexpectedFirstByte
+		redigoError.ExtraContext = map[string]string{"reply": line}
+		return "", redigoError
+	}
+}
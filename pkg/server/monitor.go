@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// monitorBuffer is the amount of lines a monitoring connection can fall behind before losing lines
+const monitorBuffer = 1024
+
+// monitor broadcasts every command executed to the connections that issued MONITOR.
+// Publishing is a single atomic load while nobody is monitoring.
+//
+// Methods are safe to call on a nil *monitor, in which case nothing is published.
+type monitor struct {
+	lock        sync.RWMutex
+	subscribers map[uint64]chan []byte
+	nextID      uint64
+	active      atomic.Int64
+}
+
+func newMonitor() *monitor {
+	return &monitor{subscribers: make(map[uint64]chan []byte)}
+}
+
+func (m *monitor) subscribe() (uint64, chan []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := m.nextID
+	m.nextID++
+	lines := make(chan []byte, monitorBuffer)
+	m.subscribers[id] = lines
+	m.active.Add(1)
+	return id, lines
+}
+
+func (m *monitor) unsubscribe(id uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.subscribers[id]; ok {
+		delete(m.subscribers, id)
+		m.active.Add(-1)
+	}
+}
+
+// publish sends a command to every subscriber. Subscribers too slow to keep up miss lines instead of slowing workers down.
+func (m *monitor) publish(timestamp time.Time, db int, clientAddr string, args []string) {
+	if m == nil || m.active.Load() == 0 {
+		return
+	}
+	line := monitorLine(timestamp, db, clientAddr, args)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, lines := range m.subscribers {
+		select {
+		case lines <- line:
+		default:
+		}
+	}
+}
+
+// monitorLine formats a command like REDIS does: +<ts> [<db> <addr>] "CMD" "arg"
+func monitorLine(timestamp time.Time, db int, clientAddr string, args []string) []byte {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "+%d.%06d [%d %s]", timestamp.Unix(), timestamp.Nanosecond()/1000, db, clientAddr)
+	for _, arg := range args {
+		b.WriteByte(' ')
+		quoteArg(b, arg)
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// quoteArg writes an argument between quotes, escaping non printable bytes
+func quoteArg(b *strings.Builder, arg string) {
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			b.WriteString("\\r")
+		case '\t':
+			b.WriteString("\\t")
+		case '\a':
+			b.WriteString("\\a")
+		case '\b':
+			b.WriteString("\\b")
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(b, "\\x%02x", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// streamMonitor writes every line received to a connection in monitoring mode
+// until the client disconnects or the worker is stopped
+func (w *worker) streamMonitor(cl *client, lines chan []byte) {
+	// Monitoring connections are not expected to send anything, so keep alive does not apply to them
+	cl.setMonitoring()
+	if !cl.setDeadline(time.Time{}) {
+		return
+	}
+
+	// Reading is the only way to notice the client left
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		buffer := make([]byte, 512)
+		for {
+			if _, err := cl.conn.Read(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-w.notifications:
+			return
+		case <-closed:
+			return
+		case line := <-lines:
+			cl.conn.SetWriteDeadline(w.settings.keepAliveDeadline())
+			if _, err := cl.conn.Write(line); err != nil {
+				return
+			}
+		}
+	}
+}
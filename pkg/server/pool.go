@@ -0,0 +1,166 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultWorkerIdleTimeout is the time (in seconds) a worker above the minimum waits for a connection before stopping
+const defaultWorkerIdleTimeout = 60
+
+// acceptedConn is a connection waiting for a worker, along with the moment it was accepted
+type acceptedConn struct {
+	conn       net.Conn
+	acceptedAt time.Time
+}
+
+// pool keeps between a minimum and a maximum amount of workers alive. Workers are spawned whenever a
+// connection arrives and none is idle, and they retire after being idle for a while as long as the minimum holds.
+// Connections arriving while every worker is busy (and no more can be spawned) wait in a queue.
+//
+// Methods reading counters are safe to call on a nil *pool.
+type pool struct {
+	minWorkers     int64
+	maxWorkers     int64
+	connections    chan acceptedConn
+	notifications  chan struct{}
+	shutdownWaiter *sync.WaitGroup
+	settings       *settings
+	newWorker      func(id uint64) *worker
+
+	workers atomic.Int64
+	idle    atomic.Int64
+	// Connections accepted and not closed yet, queued ones included
+	clients atomic.Int64
+	queued  atomic.Int64
+	nextID  atomic.Uint64
+}
+
+func newPool(minWorkers uint64, maxWorkers uint64, settings *settings, shutdownWaiter *sync.WaitGroup, newWorker func(id uint64) *worker) *pool {
+	return &pool{
+		minWorkers:     int64(minWorkers),
+		maxWorkers:     int64(maxWorkers),
+		connections:    make(chan acceptedConn),
+		notifications:  make(chan struct{}),
+		shutdownWaiter: shutdownWaiter,
+		settings:       settings,
+		newWorker:      newWorker,
+	}
+}
+
+// start spawns the minimum amount of workers
+func (p *pool) start() {
+	for range p.minWorkers {
+		p.spawn()
+	}
+}
+
+// spawn starts a new worker unless the maximum was reached
+func (p *pool) spawn() bool {
+	for {
+		workers := p.workers.Load()
+		if workers >= p.maxWorkers {
+			return false
+		}
+		if p.workers.CompareAndSwap(workers, workers+1) {
+			break
+		}
+	}
+	w := p.newWorker(p.nextID.Add(1) - 1)
+	w.pool = p
+	w.connections = p.connections
+	w.notifications = p.notifications
+	// Allow the server to wait on this worker for some time when shutting down
+	p.shutdownWaiter.Add(1)
+	go w.run()
+	return true
+}
+
+// retire lets a worker stop unless that would leave less than the minimum
+func (p *pool) retire() bool {
+	for {
+		workers := p.workers.Load()
+		if workers <= p.minWorkers {
+			return false
+		}
+		if p.workers.CompareAndSwap(workers, workers-1) {
+			return true
+		}
+	}
+}
+
+// dispatch hands a connection to a worker, spawning one if every worker is busy.
+// It returns false when the connection has to be rejected because of maxclients.
+func (p *pool) dispatch(conn net.Conn) bool {
+	maxClients := p.settings.maxClients.Load()
+	if clients := p.clients.Add(1); maxClients > 0 && clients > maxClients {
+		p.clients.Add(-1)
+		return false
+	}
+	p.queued.Add(1)
+	accepted := acceptedConn{conn: conn, acceptedAt: time.Now()}
+	select {
+	case p.connections <- accepted:
+		return true
+	default:
+	}
+	p.spawn()
+	// Waiting here would stop the server from accepting (or rejecting) anyone else
+	go func() {
+		select {
+		case p.connections <- accepted:
+		case <-p.notifications:
+			conn.Close()
+			p.queued.Add(-1)
+			p.clients.Add(-1)
+		}
+	}()
+	return true
+}
+
+// stop signals every worker to finish its current connection and exit
+func (p *pool) stop() {
+	close(p.notifications)
+}
+
+// counts returns the amount of workers alive, how many of them are idle and the connections waiting for one
+func (p *pool) counts() (workers int64, idle int64, queued int64) {
+	if p == nil {
+		return 0, 0, 0
+	}
+	return p.workers.Load(), p.idle.Load(), p.queued.Load()
+}
+
+// run is the main process of a worker: attend connections one at a time until idle for too long or stopped
+func (w *worker) run() {
+	defer w.shutdownWaiter.Done()
+	w.settings.logger.Info("Starting worker", slog.Uint64("WORKERID", w.id))
+
+	idleTimer := time.NewTimer(w.pool.settings.workerIdleTimeout())
+	defer idleTimer.Stop()
+	for {
+		w.pool.idle.Add(1)
+		select {
+		case accepted := <-w.connections:
+			w.pool.idle.Add(-1)
+			w.pool.queued.Add(-1)
+			w.stats.recordQueueWait(time.Since(accepted.acceptedAt))
+			w.handleConnection(&accepted.conn)
+			w.pool.clients.Add(-1)
+		case <-idleTimer.C:
+			w.pool.idle.Add(-1)
+			if w.pool.retire() {
+				w.settings.logger.Debug("Stopping idle worker", slog.Uint64("WORKERID", w.id))
+				return
+			}
+		case <-w.notifications:
+			w.pool.idle.Add(-1)
+			w.pool.workers.Add(-1)
+			return
+		}
+		idleTimer.Reset(w.pool.settings.workerIdleTimeout())
+	}
+}
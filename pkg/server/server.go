@@ -30,74 +30,220 @@
 //		fmt.Printf("Fatal error occurred - %v\n", err)
 //		return
 //	  }
-//	  s.Run()
+//	  // The server never listens to OS signals on its own
+//	  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	  defer stop()
+//	  s.RunContext(ctx)
 //	}
+//
+// To embed the server elsewhere, use Start and Shutdown instead.
 package server
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
-	"os"
-	"os/signal"
+	"net/http"
+	"runtime"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"github.com/Arthur-phys/redigo/pkg/core/cache"
 	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
 	"github.com/Arthur-phys/redigo/pkg/redigoerr"
 )
 
 // Server holds all information related to a server.
 // Both accepts connections and orchestrates workers by initializing them and
-// stopping them when signailed like so by the OS or user (Using Ctrl+C for example)
+// stopping them when Shutdown is called or the context given to Start is done.
 type Server struct {
 	listener          net.Listener
-	cacheStore        *cache.Cache
-	connections       chan net.Conn
-	signals           chan os.Signal
-	workerNotifiers   []chan struct{}
+	databases         []*cache.Cache
+	dispatcher        dispatcher
+	settings          *settings
+	logger            *slog.Logger
+	clients           *clientRegistry
+	tracker           *tracker
+	state             atomic.Int32
+	stopOnce          sync.Once
 	shutdownWaiter    *sync.WaitGroup
 	shutdownTolerance int64
+	stats             *stats
+	done              chan struct{}
+	metricsListener   net.Listener
+	metricsServer     *http.Server
+	cluster           *cluster
 }
 
+// States of a server, which can only be started once and never after shutting down
+const (
+	serverCreated int32 = iota
+	serverStarted
+	serverClosed
+)
+
 func (s *Server) accept() {
 	for {
 		conn, err := s.listener.Accept()
 		if errors.Is(err, net.ErrClosed) {
 			// Whenever signailed to close the server, do so
-			slog.Info("Listener closed")
+			s.logger.Info("Listener closed")
 			break
 		} else if err != nil {
 			// Continue trying to accept connections even if one fails
-			slog.Error("An error occurred while accepting a new connection", "ERROR", err)
+			s.logger.Error("An error occurred while accepting a new connection", "ERROR", err)
 			continue
 		}
-		s.connections <- conn
+		s.stats.connectionsReceived.Add(1)
+		if !s.dispatcher.dispatch(conn) {
+			s.reject(conn)
+		}
 	}
 }
 
-func (s *Server) Run() {
-	// Ask to be notified when program is to be shutdown, disables go normal behaviour when Ctrl+C
-	signal.Notify(s.signals, syscall.SIGINT, syscall.SIGTERM)
+// reject answers a connection over maxclients with an error before closing it.
+// The answer is written in another goroutine, so a slow peer never stalls accepting connections.
+func (s *Server) reject(conn net.Conn) {
+	s.stats.rejectedConnections.Add(1)
+	s.logger.Debug("Rejecting connection, maxclients reached", slog.String("CLIENT", conn.RemoteAddr().String()))
+	redigoError := redigoerr.MaxClientsReached
+	redigoError.ExtraContext = map[string]string{"maxclients": fmt.Sprintf("%d", s.settings.maxClients.Load())}
+	go func() {
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write(tobytes.Err(redigoError))
+	}()
+}
 
-	// Delegate connection acceptance to another routine to listen for syscalls
+// Addr returns the address the server listens on, which tells the port picked when Port is 0
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// ClusterBusAddr returns the address other nodes of the cluster gossip with, nil when cluster mode is disabled
+func (s *Server) ClusterBusAddr() net.Addr {
+	if s.cluster == nil {
+		return nil
+	}
+	return s.cluster.listener.Addr()
+}
+
+// Database returns a logical database, so that whoever embeds the server can read or seed keys directly.
+// It has to be locked while used, as workers do. Nil is returned when the index is out of range.
+func (s *Server) Database(index int) *cache.Cache {
+	if index < 0 || index >= len(s.databases) {
+		return nil
+	}
+	return s.databases[index]
+}
+
+// Invalidate tells clients with CLIENT TRACKING on that keys changed, for whoever changes a Database directly.
+// Without keys, clients are told to forget every key, as after FLUSHALL.
+func (s *Server) Invalidate(keys ...string) {
+	if s.tracker.idle() {
+		return
+	}
+	if len(keys) == 0 {
+		s.tracker.flushed(nil, s.clients.list())
+		return
+	}
+	s.tracker.modified(nil, keys)
+}
+
+// Start spawns the workers (or event loops) and accepts connections in the background, returning right away.
+// Once ctx is done the server shuts down, giving connections ShutdownTolerance seconds to finish;
+// pass context.Background() to stop it only with Shutdown.
+func (s *Server) Start(ctx context.Context) error {
+	if !s.state.CompareAndSwap(serverCreated, serverStarted) {
+		redigoError := redigoerr.ServerAlreadyStarted
+		if s.state.Load() == serverClosed {
+			redigoError = redigoerr.ServerClosed
+		}
+		return redigoError
+	}
+	s.logger.Info("Starting server", slog.String("ADDRESS", s.Addr().String()))
+	s.dispatcher.start()
+	// Delegate connection acceptance to another routine
 	go s.accept()
+	go s.stats.sampleEvery(opsSampleInterval, s.done)
+	if s.cluster != nil {
+		s.cluster.start()
+	}
+	if s.metricsListener != nil {
+		go func() {
+			if err := s.metricsServer.Serve(s.metricsListener); !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("The metrics listener stopped unexpectedly", "ERROR", err)
+			}
+		}()
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.shutdownWithTolerance()
+			case <-s.done:
+			}
+		}()
+	}
+	return nil
+}
+
+// Run starts the server and blocks until it is shut down with Shutdown and every worker finished.
+// The server no longer listens to OS signals on its own, see RunContext for a way to stop it with them.
+//
+// Deprecated: Use RunContext, or Start and Shutdown, which report errors instead of only logging them.
+func (s *Server) Run() {
+	if err := s.Start(context.Background()); err != nil {
+		s.logger.Error("Unable to start server", "ERROR", err)
+		return
+	}
+	<-s.done
+	s.shutdownWaiter.Wait()
+}
 
-	// Waiting for a signal to close from os
-	<-s.signals
-	slog.Info("Shutting down server, signailing workers", slog.Int64("SHUTDOWNTOLERANCE", s.shutdownTolerance))
-	// Signailing every worker
-	for i := range s.workerNotifiers {
-		s.workerNotifiers[i] <- struct{}{}
+// RunContext starts the server and blocks until ctx is done, shutting it down afterwards.
+// Connections are given ShutdownTolerance seconds to finish.
+func (s *Server) RunContext(ctx context.Context) error {
+	if err := s.Start(context.Background()); err != nil {
+		return err
 	}
-	// Signailing connection goroutine to stop
-	s.listener.Close()
-	// Closing connection channel, which will completely terminate workers after the grace period to attend connections
-	close(s.connections)
+	<-ctx.Done()
+	return s.shutdownWithTolerance()
+}
+
+func (s *Server) shutdownWithTolerance() error {
+	// Give an extra second for workers to do stuff before being left behind
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.shutdownTolerance+1)*time.Second)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops accepting connections and signals every worker to finish its connection, waiting for them until ctx is done.
+// Connections still open by then are closed and an error is returned.
+// It can be called more than once (or before Start), every call waits for the workers.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		s.state.Store(serverClosed)
+		s.logger.Info("Shutting down server, signailing workers")
+		// Signailing connection goroutine to stop
+		s.listener.Close()
+		// Signailing every worker, they finish their current connection.
+		// Connections still waiting for a worker (or attended by an event loop) are closed.
+		s.dispatcher.stop()
+		close(s.done)
+		if s.metricsListener != nil {
+			s.metricsServer.Close()
+			s.metricsListener.Close()
+		}
+		if s.cluster != nil {
+			s.cluster.stop()
+		}
+	})
 
 	// Now wait for every worker to finish
 	shutdownSignailer := make(chan struct{})
@@ -105,25 +251,37 @@ func (s *Server) Run() {
 		defer close(shutdownSignailer)
 		s.shutdownWaiter.Wait()
 	}()
-	// In case a worker takes more than the tolerance, we end the program anyway
 	select {
 	case <-shutdownSignailer:
-		slog.Info("All workers closed, terminating server")
-		// Give an extra 5 secs for workers to do stuff before being left behind
-	case <-time.After(time.Duration(s.shutdownTolerance+1) * time.Second):
-		slog.Error("Unable to close all workers, terminating server anyway")
+		s.logger.Info("All workers closed, terminating server")
+		return nil
+	case <-ctx.Done():
+		// Workers still reading are left to fail on their own
+		s.logger.Error("Unable to close all workers in time, closing their connections")
+		for _, cl := range s.clients.list() {
+			cl.kill()
+		}
+		redigoError := redigoerr.ShutdownTimedOut
+		redigoError.From = ctx.Err()
+		return redigoError
 	}
 }
 
 func New(serverConfig *Configuration) (*Server, error) {
 
-	// Configure global logger to use ip, port and REDIGO as values in log output
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	logger = logger.With("[REDIGO]", "")
+	if _, ok := logLevels[serverConfig.LogLevel]; !ok && serverConfig.LogLevel != "" {
+		redigoError := redigoerr.UnableToCreateServer
+		redigoError.ExtraContext = map[string]string{"logLevel": serverConfig.LogLevel}
+		return &Server{}, redigoError
+	}
+	slowLog := newSlowLog(serverConfig.SlowLogThreshold, serverConfig.SlowLogMaxLen)
+	settings := newSettings(serverConfig, slowLog)
+
+	// Configure the server logger to use ip, port and REDIGO as values in log output.
+	// The level can be changed later with CONFIG SET loglevel.
+	logger := settings.logger.With("[REDIGO]", "")
 	logger = logger.With("IP", serverConfig.IpAddress)
-	logger = logger.With("PORT", serverConfig.Port)
-	slog.SetDefault(logger)
-	slog.Info("Initializing Server")
+	logger.Info("Initializing Server")
 
 	// keepalive via TCP probes is disabled, every connection checks it on its own
 	listenerConfig := net.ListenConfig{KeepAlive: -1}
@@ -133,50 +291,207 @@ func New(serverConfig *Configuration) (*Server, error) {
 		redigoError.From = err
 		return &Server{}, redigoError
 	}
-	slog.Debug("Listener created")
+	// With port 0 the listener picks one, which is the one reported from now on
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	logger = logger.With("PORT", port)
+	settings.logger = logger
+	logger.Debug("Listener created")
 
-	connections := make(chan net.Conn)
-	signals := make(chan os.Signal, 1)
-	workerNotifiers := make([]chan struct{}, serverConfig.WorkerAmount)
 	shutdownWaiter := &sync.WaitGroup{}
-	cacheStore := cache.New()
-
-	// Creating workers and running them
-	for i := range serverConfig.WorkerAmount {
-		notifications := make(chan struct{}, 1)
-		workerNotifiers[i] = notifications
-		worker := worker{
-			cacheStore:     cacheStore,
-			connections:    connections,
-			timeout:        serverConfig.KeepAlive,
-			notifications:  notifications,
-			id:             i,
-			parser:         respparser.New(nil, serverConfig.MessageSizeLimit),
+	databases := newDatabases(serverConfig.Databases)
+	stats := newStats(port)
+	monitor := newMonitor()
+	clients := newClientRegistry()
+	tracker := newTracker()
+
+	// The cluster bus is optional too, only opened in cluster mode
+	var nodes *cluster
+	if serverConfig.ClusterEnabled {
+		busPort := int(serverConfig.ClusterBusPort)
+		if busPort == 0 && serverConfig.Port != 0 {
+			busPort = int(serverConfig.Port) + clusterBusPortOffset
+		}
+		busListener, err := net.Listen("tcp", net.JoinHostPort(serverConfig.IpAddress, fmt.Sprintf("%d", busPort)))
+		if err != nil {
+			listener.Close()
+			redigoError := redigoerr.UnableToCreateServer
+			redigoError.From = err
+			redigoError.ExtraContext = map[string]string{"clusterBusPort": fmt.Sprintf("%d", busPort)}
+			return &Server{}, redigoError
+		}
+		nodeTimeout := time.Duration(cmp.Or(serverConfig.ClusterNodeTimeout, defaultClusterNodeTimeout)) * time.Millisecond
+		nodes = newCluster(serverConfig.IpAddress, int(port), busListener, nodeTimeout, logger)
+		stats.clusterEnabled = true
+		logger.Debug("Cluster bus created", slog.String("BUSADDRESS", busListener.Addr().String()))
+	}
+
+	newWorker := func(id uint64) *worker {
+		parser := respparser.New(nil, serverConfig.MessageSizeLimit)
+		parser.Delegate(delegatedCommands...)
+		return &worker{
+			databases:      databases,
+			settings:       settings,
+			id:             id,
+			parser:         parser,
 			shutdownWaiter: shutdownWaiter,
+			stats:          stats,
+			slowLog:        slowLog,
+			monitor:        monitor,
+			clients:        clients,
+			tracker:        tracker,
+			cluster:        nodes,
+		}
+	}
+	var connectionDispatcher dispatcher
+	switch serverConfig.ConnectionHandling {
+	case EventLoop:
+		loops, err := newEventLoops(eventLoopAmount(serverConfig), settings, shutdownWaiter, newWorker)
+		if err != nil {
+			listener.Close()
+			if nodes != nil {
+				nodes.listener.Close()
+			}
+			redigoError := redigoerr.UnableToCreateServer
+			redigoError.From = err
+			return &Server{}, redigoError
 		}
-		go worker.run()
+		connectionDispatcher = loops
+	default:
+		// Workers are created by the pool whenever needed
+		minWorkers, maxWorkers := workerBounds(serverConfig)
+		pool := newPool(minWorkers, maxWorkers, settings, shutdownWaiter, newWorker)
+		stats.pool = pool
+		connectionDispatcher = pool
 	}
 
 	// Creating server
 	server := Server{
 		listener:          listener,
-		cacheStore:        cacheStore,
-		connections:       connections,
-		signals:           signals,
-		workerNotifiers:   workerNotifiers,
+		databases:         databases,
+		dispatcher:        connectionDispatcher,
+		settings:          settings,
+		logger:            logger,
+		clients:           clients,
+		tracker:           tracker,
 		shutdownTolerance: serverConfig.ShutdownTolerance,
 		shutdownWaiter:    shutdownWaiter,
+		stats:             stats,
+		done:              make(chan struct{}),
+		cluster:           nodes,
 	}
+
+	// Metrics are optional, only exposed when an address is given
+	if serverConfig.MetricsAddress != "" {
+		metricsListener, err := net.Listen("tcp", serverConfig.MetricsAddress)
+		if err != nil {
+			listener.Close()
+			if nodes != nil {
+				nodes.listener.Close()
+			}
+			redigoError := redigoerr.UnableToCreateServer
+			redigoError.From = err
+			redigoError.ExtraContext = map[string]string{"metricsAddress": serverConfig.MetricsAddress}
+			return &Server{}, redigoError
+		}
+		server.metricsListener = metricsListener
+		server.metricsServer = &http.Server{Handler: server.metricsHandler(), ReadHeaderTimeout: 5 * time.Second}
+		logger.Debug("Metrics listener created", slog.String("METRICSADDRESS", serverConfig.MetricsAddress))
+	}
+
 	return &server, nil
 }
 
+// dispatcher hands accepted connections to whatever attends them: a pool of workers or a few event loops
+type dispatcher interface {
+	start()
+	// dispatch returns false when the connection has to be rejected because of maxclients
+	dispatch(conn net.Conn) bool
+	// stop signals every connection to be finished
+	stop()
+}
+
+// ConnectionHandling selects how connections are attended
+type ConnectionHandling int
+
+const (
+	// WorkerPerConnection dedicates a worker to each connection until it closes
+	WorkerPerConnection ConnectionHandling = iota
+	// EventLoop multiplexes every connection over a few goroutines using epoll. It is only available on Linux.
+	EventLoop
+)
+
+// eventLoopAmount returns the amount of event loops to use, one per CPU unless configured otherwise
+func eventLoopAmount(serverConfig *Configuration) int {
+	if serverConfig.EventLoops > 0 {
+		return serverConfig.EventLoops
+	}
+	return runtime.NumCPU()
+}
+
+// workerBounds returns the minimum and maximum amount of workers. Without bounds, WorkerAmount workers are kept at all times.
+func workerBounds(serverConfig *Configuration) (uint64, uint64) {
+	if serverConfig.MinWorkers == 0 && serverConfig.MaxWorkers == 0 {
+		return max(serverConfig.WorkerAmount, 1), max(serverConfig.WorkerAmount, 1)
+	}
+	minWorkers := max(serverConfig.MinWorkers, 1)
+	return minWorkers, max(serverConfig.MaxWorkers, minWorkers)
+}
+
 // Configuration is a helper struct to be more idiomatic when configuring a server.
 // You can see it in action in the cmd/redigo_server/ command.
+//
+// KeepAlive, MessageSizeLimit, MaxMemory, TrackingTableMaxKeys, the slow log and LogLevel can be changed while
+// the server runs using CONFIG SET.
 type Configuration struct {
-	IpAddress         string
-	Port              uint16
-	WorkerAmount      uint64
+	IpAddress string
+	// Port to listen on. With 0 a free one is picked, see Server.Addr.
+	Port uint16
+	// WorkerAmount is the fixed amount of workers used when neither MinWorkers nor MaxWorkers are given.
+	WorkerAmount uint64
+	// MinWorkers are kept alive even when idle, while up to MaxWorkers are spawned when every worker is busy.
+	// Each worker attends a single connection at a time, so MaxWorkers is the amount of clients attended at once.
+	MinWorkers uint64
+	MaxWorkers uint64
+	// WorkerIdleTimeout is the time (in seconds) a worker above MinWorkers waits for a connection before stopping. Defaults to 60.
+	WorkerIdleTimeout int64
+	// ConnectionHandling chooses between a worker per connection (the default) and event loops.
+	// With event loops the worker settings are ignored and thousands of mostly idle connections cost little memory.
+	ConnectionHandling ConnectionHandling
+	// EventLoops is the amount of event loops when ConnectionHandling is EventLoop. Defaults to the amount of CPUs.
+	EventLoops int
+	// MaxClients is the amount of connections (attended or waiting for a worker) from which new ones are rejected. Zero means no limit.
+	MaxClients        uint64
 	KeepAlive         int64
 	MessageSizeLimit  int
 	ShutdownTolerance int64
+	// MetricsAddress is where /metrics is served in the Prometheus format. Leave empty to disable it.
+	MetricsAddress string
+	// SlowLogThreshold is the execution time (in microseconds) from which commands are kept in the slow log.
+	// A negative value disables it.
+	SlowLogThreshold int64
+	// SlowLogMaxLen is the amount of entries kept in the slow log before replacing the oldest ones.
+	SlowLogMaxLen int
+	// Databases is the amount of logical databases clients can SELECT. Defaults to 16 when zero.
+	Databases int
+	// MaxMemory is the amount of bytes in use from which commands adding data are rejected. Zero means no limit.
+	MaxMemory int64
+	// TrackingTableMaxKeys is the amount of keys remembered for clients with CLIENT TRACKING on, past which
+	// clients are told to drop keys as if they changed. Defaults to 1000000 when zero.
+	TrackingTableMaxKeys int
+	// LogLevel is one of debug, verbose, notice, warning or nothing. Defaults to debug when empty.
+	LogLevel string
+	// ClusterEnabled makes the server a node of a cluster, serving only the hash slots assigned to it
+	// and redirecting clients elsewhere for the rest (see the CLUSTER commands).
+	ClusterEnabled bool
+	// ClusterBusPort is where other nodes gossip with this one. With 0 it is Port plus 10000,
+	// or a free one when Port is 0 too, see Server.ClusterBusAddr.
+	ClusterBusPort uint16
+	// ClusterNodeTimeout is the time (in milliseconds) a node can go without answering before being flagged as failing.
+	// Defaults to 15000.
+	ClusterNodeTimeout int64
+	// ConfigFile is the file updated by CONFIG REWRITE. It is set by LoadConfigurationFile.
+	ConfigFile string
+	// Logger receives every log of the server, still filtered by LogLevel.
+	// Defaults to JSON logs on the standard output. The global slog logger is never changed.
+	Logger *slog.Logger
 }
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+)
+
+const (
+	// slowLogMaxArgs is the amount of arguments kept for every entry, the last one summarizes the rest
+	slowLogMaxArgs = 32
+	// slowLogMaxArgLength is the amount of bytes kept for every argument
+	slowLogMaxArgLength = 128
+)
+
+type slowLogEntry struct {
+	id         uint64
+	timestamp  time.Time
+	duration   time.Duration
+	args       []string
+	clientAddr string
+	clientName string
+}
+
+// slowLog keeps the latest commands slower than a threshold in a ring buffer.
+//
+// Methods are safe to call on a nil *slowLog, in which case nothing is recorded.
+type slowLog struct {
+	lock    sync.Mutex
+	entries []slowLogEntry
+	// Position where the next entry is written
+	next   int
+	length int
+	nextID uint64
+	// Commands taking at least this amount of microseconds are logged, a negative threshold disables the log
+	threshold atomic.Int64
+}
+
+func newSlowLog(threshold int64, maxLen int) *slowLog {
+	s := &slowLog{entries: make([]slowLogEntry, max(maxLen, 0))}
+	s.threshold.Store(threshold)
+	return s
+}
+
+// truncateArgs shortens arguments the same way REDIS does before storing them
+func truncateArgs(args []string) []string {
+	truncated := make([]string, 0, min(len(args), slowLogMaxArgs))
+	for i, arg := range args {
+		if i == slowLogMaxArgs-1 && len(args) > slowLogMaxArgs {
+			truncated = append(truncated, fmt.Sprintf("... (%d more arguments)", len(args)-i))
+			break
+		}
+		if len(arg) > slowLogMaxArgLength {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:slowLogMaxArgLength], len(arg)-slowLogMaxArgLength)
+		}
+		truncated = append(truncated, arg)
+	}
+	return truncated
+}
+
+// record stores a command if its duration reaches the threshold
+func (s *slowLog) record(args []string, start time.Time, duration time.Duration, clientAddr string, clientName string) {
+	if s == nil {
+		return
+	}
+	threshold := s.threshold.Load()
+	if threshold < 0 || duration.Microseconds() < threshold || len(s.entries) == 0 {
+		return
+	}
+	entry := slowLogEntry{
+		timestamp:  start,
+		duration:   duration,
+		args:       truncateArgs(args),
+		clientAddr: clientAddr,
+		clientName: clientName,
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry.id = s.nextID
+	s.nextID++
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	s.length = min(s.length+1, len(s.entries))
+}
+
+// latest returns up to count entries, newest first. A negative count returns every entry.
+func (s *slowLog) latest(count int) []slowLogEntry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if count < 0 || count > s.length {
+		count = s.length
+	}
+	entries := make([]slowLogEntry, count)
+	for i := range count {
+		entries[i] = s.entries[(s.next-1-i+len(s.entries))%len(s.entries)]
+	}
+	return entries
+}
+
+func (s *slowLog) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.length
+}
+
+func (s *slowLog) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	clear(s.entries)
+	s.next = 0
+	s.length = 0
+}
+
+// configure changes the threshold and the amount of entries kept. When shrinking, only the newest entries survive.
+func (s *slowLog) configure(threshold int64, maxLen int) {
+	if s == nil {
+		return
+	}
+	s.threshold.Store(threshold)
+	maxLen = max(maxLen, 0)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if maxLen == len(s.entries) {
+		return
+	}
+	kept := min(s.length, maxLen)
+	entries := make([]slowLogEntry, maxLen)
+	// Oldest entries go first, like they would after recording them one by one
+	for i := range kept {
+		entries[kept-1-i] = s.entries[(s.next-1-i+len(s.entries))%len(s.entries)]
+	}
+	s.entries = entries
+	s.length = kept
+	s.next = 0
+	if maxLen > 0 {
+		s.next = kept % maxLen
+	}
+}
+
+func (e slowLogEntry) toBytes() []byte {
+	args := make([][]byte, len(e.args))
+	for i, arg := range e.args {
+		args[i] = tobytes.BlobString(arg)
+	}
+	return tobytes.Array(
+		tobytes.Int(int(e.id)),
+		tobytes.Int(int(e.timestamp.Unix())),
+		tobytes.Int(int(e.duration.Microseconds())),
+		tobytes.Array(args...),
+		tobytes.BlobString(e.clientAddr),
+		tobytes.BlobString(e.clientName),
+	)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// opsSamples is how many samples of processed commands are averaged to obtain the instantaneous ops/sec
+const opsSamples = 16
+
+// opsSampleInterval is the time between two samples of processed commands
+const opsSampleInterval = 100 * time.Millisecond
+
+// latencyBuckets are the upper bounds (in seconds) of the command latency histogram
+var latencyBuckets = [...]float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// commandStats accumulates the calls, time spent and failures of a single command.
+// Buckets are not cumulative: each call is counted only in the first bucket its latency fits in.
+type commandStats struct {
+	calls       atomic.Uint64
+	nanoseconds atomic.Uint64
+	failed      atomic.Uint64
+	buckets     [len(latencyBuckets)]atomic.Uint64
+}
+
+// stats holds the counters shared by the server and its workers.
+// Every counter is atomic, so recording never makes a worker wait for another one.
+//
+// Methods are safe to call on a nil *stats, in which case nothing is recorded.
+type stats struct {
+	startTime           time.Time
+	port                uint16
+	connectionsReceived atomic.Uint64
+	rejectedConnections atomic.Uint64
+	commandsProcessed   atomic.Uint64
+	netInputBytes       atomic.Uint64
+	netOutputBytes      atomic.Uint64
+	// Clients attended by workers, not counting the ones waiting for a worker
+	clients atomic.Int64
+	// Time connections waited for a worker, with the same buckets as commands
+	queueWaitNanoseconds atomic.Uint64
+	queueWaitCount       atomic.Uint64
+	queueWaitBuckets     [len(latencyBuckets)]atomic.Uint64
+	// Workers are reported as seen by the pool
+	pool *pool
+	// clusterEnabled is reported by the cluster section and the mode of the server
+	clusterEnabled bool
+	// Command name to *commandStats
+	commands sync.Map
+	// Error code to *atomic.Uint64
+	errors sync.Map
+	// Bytes allocated in the heap as of the latest sample, compared against maxmemory
+	usedMemory atomic.Uint64
+
+	samplesLock     sync.Mutex
+	samples         [opsSamples]uint64
+	sampleIndex     int
+	lastSampleOps   uint64
+	lastSampleTaken time.Time
+}
+
+func newStats(port uint16) *stats {
+	s := &stats{
+		startTime:       time.Now(),
+		port:            port,
+		lastSampleTaken: time.Now(),
+	}
+	s.sampleMemory()
+	return s
+}
+
+func (s *stats) recordConnection() {
+	if s == nil {
+		return
+	}
+	s.clients.Add(1)
+}
+
+func (s *stats) recordDisconnection() {
+	if s == nil {
+		return
+	}
+	s.clients.Add(-1)
+}
+
+// recordQueueWait stores the time a connection waited between being accepted and being attended by a worker
+func (s *stats) recordQueueWait(wait time.Duration) {
+	if s == nil {
+		return
+	}
+	s.queueWaitCount.Add(1)
+	s.queueWaitNanoseconds.Add(uint64(wait.Nanoseconds()))
+	for i, bound := range latencyBuckets {
+		if wait.Seconds() <= bound {
+			s.queueWaitBuckets[i].Add(1)
+			break
+		}
+	}
+}
+
+func (s *stats) recordBytes(in int, out int) {
+	if s == nil {
+		return
+	}
+	s.netInputBytes.Add(uint64(in))
+	s.netOutputBytes.Add(uint64(out))
+}
+
+func (s *stats) recordCommand(name string, duration time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	s.commandsProcessed.Add(1)
+	v, ok := s.commands.Load(name)
+	if !ok {
+		v, _ = s.commands.LoadOrStore(name, &commandStats{})
+	}
+	command := v.(*commandStats)
+	command.calls.Add(1)
+	command.nanoseconds.Add(uint64(duration.Nanoseconds()))
+	for i, bound := range latencyBuckets {
+		if duration.Seconds() <= bound {
+			command.buckets[i].Add(1)
+			break
+		}
+	}
+	if err != nil {
+		command.failed.Add(1)
+		s.recordError(err)
+	}
+}
+
+// recordError counts errors by their redigo code, other errors are ignored
+func (s *stats) recordError(err error) {
+	if s == nil {
+		return
+	}
+	code, ok := redigoerr.ErrorCode(err)
+	if !ok {
+		return
+	}
+	v, ok := s.errors.Load(code)
+	if !ok {
+		v, _ = s.errors.LoadOrStore(code, &atomic.Uint64{})
+	}
+	v.(*atomic.Uint64).Add(1)
+}
+
+// sample stores the amount of commands processed since the last sample, as ops/sec
+func (s *stats) sample() {
+	s.samplesLock.Lock()
+	defer s.samplesLock.Unlock()
+	now := time.Now()
+	ops := s.commandsProcessed.Load()
+	elapsed := now.Sub(s.lastSampleTaken).Milliseconds()
+	if elapsed > 0 {
+		s.samples[s.sampleIndex] = (ops - s.lastSampleOps) * 1000 / uint64(elapsed)
+		s.sampleIndex = (s.sampleIndex + 1) % opsSamples
+	}
+	s.lastSampleOps = ops
+	s.lastSampleTaken = now
+}
+
+// heapObjectsMetric matches runtime.MemStats.HeapAlloc, but reading it does not stop the world
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+func (s *stats) sampleMemory() {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		s.usedMemory.Store(sample[0].Value.Uint64())
+	}
+}
+
+// memoryUsed returns the bytes allocated in the heap as of the latest sample
+func (s *stats) memoryUsed() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.usedMemory.Load()
+}
+
+// instantaneousOps averages the latest samples of ops/sec
+func (s *stats) instantaneousOps() uint64 {
+	s.samplesLock.Lock()
+	defer s.samplesLock.Unlock()
+	total := uint64(0)
+	for _, sample := range s.samples {
+		total += sample
+	}
+	return total / opsSamples
+}
+
+// sampleEvery takes samples of ops/sec and memory until done is closed
+func (s *stats) sampleEvery(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+			s.sampleMemory()
+		case <-done:
+			return
+		}
+	}
+}
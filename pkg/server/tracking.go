@@ -0,0 +1,347 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Arthur-phys/redigo/pkg/core/respparser"
+	"github.com/Arthur-phys/redigo/pkg/core/tobytes"
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
+)
+
+// trackingBuffer is the amount of invalidations a client can fall behind before being told to drop its whole cache
+const trackingBuffer = 1024
+
+// clientTracking is the state of a client with CLIENT TRACKING on.
+// Invalidations are queued and written by a goroutine of its own, so that no worker waits for a slow client.
+type clientTracking struct {
+	bcast    bool
+	prefixes []string
+	noloop   bool
+	pushes   chan []byte
+	// overflow is set when pushes were lost, in which case the client is told to forget every key
+	overflow atomic.Bool
+	done     chan struct{}
+}
+
+// matches tells if a key is one of the prefixes a client in BCAST mode is interested in
+func (tr *clientTracking) matches(key string) bool {
+	if len(tr.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range tr.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultTrackingMaxKeys is the amount of keys tracked when tracking-table-max-keys is not given, as in REDIS
+const defaultTrackingMaxKeys = 1000000
+
+// tracker remembers the keys read by every client with tracking on, to tell them once those keys change
+// so they can drop them from their local caches. Keys are tracked by name, whatever the database.
+// Past tracking-table-max-keys, keys are forgotten and their clients told as if they changed.
+//
+// Methods are safe to call on a nil *tracker, in which case nothing is tracked.
+type tracker struct {
+	lock sync.Mutex
+	// keys read by clients in default mode, forgotten once they are told about a change
+	keys map[string]map[uint64]*client
+	// clientKeys holds the same keys by client, so that turning tracking off does not go through every key
+	clientKeys map[uint64]map[string]struct{}
+	// bcast holds the clients in BCAST mode, told about every key matching their prefixes
+	bcast  map[uint64]*client
+	active atomic.Int64
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		keys:       make(map[string]map[uint64]*client),
+		clientKeys: make(map[uint64]map[string]struct{}),
+		bcast:      make(map[uint64]*client),
+	}
+}
+
+// idle tells if no client has tracking on, which costs a single atomic load
+func (t *tracker) idle() bool {
+	return t == nil || t.active.Load() == 0
+}
+
+// enable turns tracking on for a client, replacing any previous mode
+func (t *tracker) enable(cl *client, bcast bool, prefixes []string, noloop bool) {
+	t.disable(cl)
+	tr := &clientTracking{
+		bcast:    bcast,
+		prefixes: prefixes,
+		noloop:   noloop,
+		pushes:   make(chan []byte, trackingBuffer),
+		done:     make(chan struct{}),
+	}
+	cl.lock.Lock()
+	cl.tracking = tr
+	cl.lock.Unlock()
+	if bcast {
+		t.lock.Lock()
+		t.bcast[cl.id] = cl
+		t.lock.Unlock()
+	}
+	t.active.Add(1)
+	go cl.deliverPushes(tr)
+}
+
+// disable turns tracking off for a client, forgetting every key it read
+func (t *tracker) disable(cl *client) {
+	if t == nil {
+		return
+	}
+	cl.lock.Lock()
+	tr := cl.tracking
+	cl.tracking = nil
+	cl.lock.Unlock()
+	if tr == nil {
+		return
+	}
+	close(tr.done)
+	t.lock.Lock()
+	delete(t.bcast, cl.id)
+	for key := range t.clientKeys[cl.id] {
+		t.forget(key, cl.id)
+	}
+	delete(t.clientKeys, cl.id)
+	t.lock.Unlock()
+	t.active.Add(-1)
+}
+
+// forget stops tracking a key for a client, which has to be done with the lock held
+func (t *tracker) forget(key string, id uint64) {
+	delete(t.keys[key], id)
+	if len(t.keys[key]) == 0 {
+		delete(t.keys, key)
+	}
+}
+
+// read remembers the keys a client in default mode read. Once more than maxKeys are tracked,
+// other keys are evicted and their clients told about them, so they stop relying on them.
+func (t *tracker) read(cl *client, keys []string, maxKeys int) {
+	tr := cl.getTracking()
+	if tr == nil || tr.bcast || len(keys) == 0 {
+		return
+	}
+	t.lock.Lock()
+	// Tracking may have been turned off meanwhile, in which case the keys would never be forgotten
+	if cl.getTracking() != tr {
+		t.lock.Unlock()
+		return
+	}
+	clientKeys, ok := t.clientKeys[cl.id]
+	if !ok {
+		clientKeys = make(map[string]struct{})
+		t.clientKeys[cl.id] = clientKeys
+	}
+	for _, key := range keys {
+		clients, ok := t.keys[key]
+		if !ok {
+			clients = make(map[uint64]*client)
+			t.keys[key] = clients
+		}
+		clients[cl.id] = cl
+		clientKeys[key] = struct{}{}
+	}
+	evicted := t.evict(maxKeys, keys)
+	t.lock.Unlock()
+	t.invalidate(nil, evicted)
+}
+
+// evict forgets keys until at most maxKeys are tracked, returning the clients that tracked them.
+// The keys just read are the last ones evicted. It has to be called with the lock held.
+func (t *tracker) evict(maxKeys int, read []string) map[*client][]string {
+	if len(t.keys) <= maxKeys {
+		return nil
+	}
+	evicted := map[*client][]string{}
+	evict := func(key string) {
+		for id, cl := range t.keys[key] {
+			evicted[cl] = append(evicted[cl], key)
+			delete(t.clientKeys[id], key)
+		}
+		delete(t.keys, key)
+	}
+	for key := range t.keys {
+		if len(t.keys) <= maxKeys {
+			return evicted
+		}
+		if !slices.Contains(read, key) {
+			evict(key)
+		}
+	}
+	for _, key := range read {
+		if len(t.keys) <= maxKeys {
+			break
+		}
+		evict(key)
+	}
+	return evicted
+}
+
+// modified tells every client that read the keys (or is interested in them) that they changed
+func (t *tracker) modified(by *client, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	invalidated := map[*client][]string{}
+	t.lock.Lock()
+	for _, key := range keys {
+		for id, cl := range t.keys[key] {
+			invalidated[cl] = append(invalidated[cl], key)
+			delete(t.clientKeys[id], key)
+		}
+		// Clients read the key again before being told about it once more
+		delete(t.keys, key)
+		for _, cl := range t.bcast {
+			if tr := cl.getTracking(); tr != nil && tr.matches(key) {
+				invalidated[cl] = append(invalidated[cl], key)
+			}
+		}
+	}
+	t.lock.Unlock()
+	t.invalidate(by, invalidated)
+}
+
+// invalidate pushes to every client the keys it has to drop
+func (t *tracker) invalidate(by *client, invalidated map[*client][]string) {
+	for cl, keys := range invalidated {
+		elements := make([][]byte, len(keys))
+		for i, key := range keys {
+			elements[i] = tobytes.BlobString(key)
+		}
+		cl.push(by, tobytes.Array(elements...))
+	}
+}
+
+// flushed tells every client to forget all of its keys, after databases were flushed or swapped
+func (t *tracker) flushed(by *client, clients []*client) {
+	t.lock.Lock()
+	clear(t.keys)
+	clear(t.clientKeys)
+	t.lock.Unlock()
+	for _, cl := range clients {
+		cl.push(by, tobytes.Null())
+	}
+}
+
+// track records the keys a command reads, or tells about the ones a command executed successfully changed,
+// if anybody is tracking them
+func (w *worker) track(cl *client, args []string, write bool) {
+	if w.tracker.idle() {
+		return
+	}
+	switch {
+	case args[0] == "FLUSHDB" || args[0] == "FLUSHALL" || args[0] == "SWAPDB":
+		w.tracker.flushed(cl, w.clients.list())
+	case write:
+		w.tracker.modified(cl, respparser.CommandKeys(args))
+	default:
+		w.tracker.read(cl, respparser.CommandKeys(args), int(w.settings.trackingMaxKeys.Load()))
+	}
+}
+
+// trackingCommand answers CLIENT TRACKING ON|OFF [BCAST] [PREFIX prefix ...] [NOLOOP]
+func (w *worker) trackingCommand(cl *client, args []string) ([]byte, error) {
+	if len(args) < 3 {
+		return []byte{}, insufficientLength(">= 3", len(args))
+	}
+	bcast, noloop := false, false
+	prefixes := []string{}
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BCAST":
+			bcast = true
+		case "NOLOOP":
+			noloop = true
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return []byte{}, redigoerr.SyntaxError
+			}
+			prefixes = append(prefixes, args[i+1])
+			i++
+		default:
+			redigoError := redigoerr.SyntaxError
+			redigoError.ExtraContext = map[string]string{"option": args[i]}
+			return []byte{}, redigoError
+		}
+	}
+	switch strings.ToUpper(args[2]) {
+	case "ON":
+		if len(prefixes) > 0 && !bcast {
+			redigoError := redigoerr.PrefixWithoutBCAST
+			redigoError.ExtraContext = map[string]string{"prefixes": strings.Join(prefixes, ",")}
+			return []byte{}, redigoError
+		}
+		w.tracker.enable(cl, bcast, prefixes, noloop)
+	case "OFF":
+		w.tracker.disable(cl)
+	default:
+		redigoError := redigoerr.SyntaxError
+		redigoError.ExtraContext = map[string]string{"mode": args[2]}
+		return []byte{}, redigoError
+	}
+	return tobytes.Null(), nil
+}
+
+func (cl *client) getTracking() *clientTracking {
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	return cl.tracking
+}
+
+// push queues an invalidation for the keys given (or every key, with a null) unless the client changed them itself
+// and asked not to be told. A client too slow to keep up is told to forget every key instead.
+func (cl *client) push(by *client, keys []byte) {
+	tr := cl.getTracking()
+	if tr == nil || (tr.noloop && cl == by) {
+		return
+	}
+	select {
+	case tr.pushes <- invalidation(keys):
+	default:
+		tr.overflow.Store(true)
+	}
+}
+
+func invalidation(keys []byte) []byte {
+	return tobytes.Push(tobytes.BlobString("invalidate"), keys)
+}
+
+// deliverPushes writes the invalidations of a client until tracking is turned off or the connection is closed
+func (cl *client) deliverPushes(tr *clientTracking) {
+	for {
+		select {
+		case <-tr.done:
+			return
+		case push := <-tr.pushes:
+			if cl.writePush(push) != nil {
+				return
+			}
+		}
+		if tr.overflow.Swap(false) && cl.writePush(invalidation(tobytes.Null())) != nil {
+			return
+		}
+	}
+}
+
+// writePush writes a push message between two responses. While a response is only partially written
+// (by an event loop), the message is left for the loop to write right after it.
+func (cl *client) writePush(push []byte) error {
+	cl.outLock.Lock()
+	defer cl.outLock.Unlock()
+	if cl.partial {
+		cl.deferred = append(cl.deferred, push...)
+		return nil
+	}
+	_, err := cl.conn.Write(push)
+	return err
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"fmt"
 	"log/slog"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,21 +17,37 @@ import (
 // worker accepts new tcp connections and responds to clients
 // by parsing their commands.
 type worker struct {
-	cacheStore     *cache.Cache
+	databases      []*cache.Cache
 	parser         *respparser.RESPParser
-	connections    chan net.Conn
-	timeout        int64
+	connections    chan acceptedConn
+	settings       *settings
 	id             uint64
 	notifications  chan struct{}
 	shutdownWaiter *sync.WaitGroup
+	stats          *stats
+	slowLog        *slowLog
+	monitor        *monitor
+	clients        *clientRegistry
+	tracker        *tracker
+	pool           *pool
+	// parkPaused makes execute hand back the commands held by CLIENT PAUSE instead of waiting,
+	// since an event loop waiting would hold every other connection it attends
+	parkPaused bool
+	// cluster is nil unless the server runs in cluster mode
+	cluster *cluster
 }
 
 // handleConnection answer a single client until the connection closes or a timeout happens
 func (w *worker) handleConnection(c *net.Conn) {
 	// Never forget to close the connection!
 	defer (*c).Close()
+	w.stats.recordConnection()
+	defer w.stats.recordDisconnection()
+	cl := w.clients.register(*c, w.id)
+	defer w.clients.unregister(cl)
+	defer w.tracker.disable(cl)
 	// Setting max deadline for reading or writing
-	(*c).SetDeadline(time.Now().Add(time.Second * time.Duration(w.timeout)))
+	cl.setDeadline(w.settings.keepAliveDeadline())
 	// Restarting parser for new connection
 	w.parser.NewConnection(c)
 
@@ -38,22 +56,26 @@ func (w *worker) handleConnection(c *net.Conn) {
 		select {
 		// When signailed to stop, give the connection a last chance to be read and receive an answer
 		case <-w.notifications:
-			slog.Debug("Starting shutdown for worker, finishing any active connections", slog.Uint64("WORKERID", w.id))
+			w.settings.logger.Debug("Starting shutdown for worker, finishing any active connections", slog.Uint64("WORKERID", w.id))
 			return
 
 		default:
 			finalResponse := []byte{}
-			_, err := w.parser.Read()
+			// CONFIG SET may have changed the limit since the last read
+			w.parser.SetMessageSizeLimit(int(w.settings.messageSizeLimit.Load()))
+			n, err := w.parser.Read()
+			w.stats.recordBytes(n, 0)
 			if redigoerr.ConnectionRelated(err) {
 				// Stopped any Conn error here, incluiding EOF, Broken Pipe, etc.
-				slog.Debug("The connection was closed", "REASON", err,
+				w.settings.logger.Debug("The connection was closed", "REASON", err,
 					slog.Uint64("WORKERID", w.id),
 					slog.String("CLIENT", (*c).RemoteAddr().String()))
 				return
 			} else if redigoerr.ExceededMaxSize(err) {
+				w.stats.recordError(err)
 				// Too big of a command
 				if _, err := (*c).Write(tobytes.Err(err)); err != nil {
-					slog.Error("An error occurred while sending error response to client", "ERROR", err,
+					w.settings.logger.Error("An error occurred while sending error response to client", "ERROR", err,
 						slog.Uint64("WORKERID", w.id),
 						slog.String("CLIENT", (*c).RemoteAddr().String()),
 					)
@@ -66,13 +88,14 @@ func (w *worker) handleConnection(c *net.Conn) {
 			// If the buffer was exhausted, do not return an error, which is true for cases 0,3,4 & 8
 			if !redigoerr.BufferExhausted(err) && err != nil {
 				// Command malformed, return immediately
-				slog.Error("An error occurred while parsing the command", "ERROR", err,
+				w.stats.recordError(err)
+				w.settings.logger.Error("An error occurred while parsing the command", "ERROR", err,
 					slog.Uint64("WORKERID", w.id),
 					slog.String("CLIENT", (*c).RemoteAddr().String()),
 				)
 				_, err := (*c).Write(tobytes.Err(err))
 				if err != nil {
-					slog.Error("An error occurred while sending error response to client", "ERROR", err,
+					w.settings.logger.Error("An error occurred while sending error response to client", "ERROR", err,
 						slog.Uint64("WORKERID", w.id),
 						slog.String("CLIENT", (*c).RemoteAddr().String()),
 					)
@@ -81,50 +104,131 @@ func (w *worker) handleConnection(c *net.Conn) {
 			}
 
 			// Interpret & evaluate commands
-			for _, command := range commands {
-				w.cacheStore.Lock()
-				res, err := command(w.cacheStore)
-				w.cacheStore.Unlock()
-				if err != nil {
-					slog.Error("An error occurred while executing client's command", "ERROR", err,
-						slog.Uint64("WORKERID", w.id),
-						slog.String("CLIENT", (*c).RemoteAddr().String()),
-					)
-					// Errors are delivered at the end for every command
-					finalResponse = append(finalResponse, tobytes.Err(err)...)
-					continue
-				}
-				finalResponse = append(finalResponse, res...)
-			}
+			finalResponse, _, monitorID, monitorLines := w.execute(cl, commands)
+			monitoring := monitorLines != nil
 
 			// Return all responses at once
 			_, nerr := (*c).Write(finalResponse)
+			w.stats.recordBytes(0, len(finalResponse))
+			if monitoring {
+				defer w.monitor.unsubscribe(monitorID)
+			}
 			if nerr != nil {
-				slog.Error("An error occurred while returning a response to the client", "ERROR", err,
+				w.settings.logger.Error("An error occurred while returning a response to the client", "ERROR", err,
 					slog.Uint64("WORKERID", w.id),
 					slog.String("CLIENT", (*c).RemoteAddr().String()),
 				)
 				return
 			}
+			if monitoring {
+				w.streamMonitor(cl, monitorLines)
+				return
+			}
 
-			(*c).SetDeadline(time.Now().Add(time.Second * time.Duration(w.timeout)))
+			if !cl.setDeadline(w.settings.keepAliveDeadline()) {
+				w.settings.logger.Debug("The connection was killed", slog.Uint64("WORKERID", w.id), slog.String("CLIENT", cl.addr))
+				return
+			}
 		}
 	}
 }
 
-// run is the main process of a worker
-func (w *worker) run() {
-	// Allow the server to wait on this worker for some time when shutting down
-	w.shutdownWaiter.Add(1)
-	defer w.shutdownWaiter.Done()
+// pausedCommands are the commands of a client left to execute once CLIENT PAUSE ends
+type pausedCommands struct {
+	commands []respparser.Command
+	unpaused chan struct{}
+}
 
-	// Accept connections until the connection channel is closed
-	slog.Info("Starting worker", slog.Uint64("WORKERID", w.id))
-	for {
-		if conn, ok := <-w.connections; ok {
-			w.handleConnection(&conn)
-		} else {
+// execute evaluates the commands sent at once by a client, returning every response in order.
+// Nothing after a MONITOR is evaluated: the client is subscribed and the lines to stream are returned.
+// With parkPaused, nothing from the first command held by CLIENT PAUSE is evaluated either: those commands are returned instead.
+func (w *worker) execute(cl *client, commands []respparser.Command) ([]byte, *pausedCommands, uint64, chan []byte) {
+	response := []byte{}
+	for i, command := range commands {
+		var (
+			res []byte
+			err error
+		)
+		// A killed client gets nothing else executed, not even commands sent along with the CLIENT KILL
+		if cl.isKilled() {
 			break
 		}
+		start := time.Now()
+		if command.Args[0] == "MONITOR" {
+			// Anything sent after MONITOR is ignored, the connection only receives commands from now on.
+			// Subscribing before answering makes sure no command is lost once the client gets the response.
+			monitorID, monitorLines := w.monitor.subscribe()
+			return append(response, tobytes.Null()...), nil, monitorID, monitorLines
+		}
+		write := respparser.IsWriteCommand(command.Args[0])
+		exempt := command.Run == nil && !write && pauseExempt(command.Args)
+		var pauseErr error
+		if w.parkPaused && !exempt {
+			if unpaused := w.clients.pausedFor(write); unpaused != nil {
+				select {
+				case <-w.notifications:
+					// The server stopped before the pause ended, so the command is skipped
+					pauseErr = redigoerr.ShuttingDown
+				default:
+					return response, &pausedCommands{commands: commands[i:], unpaused: unpaused}, 0, nil
+				}
+			}
+		}
+		w.monitor.publish(start, cl.getDB(), cl.addr, command.Args)
+		cl.touch(command.Args)
+		// ASKING only lasts for the command after it
+		asking := w.cluster != nil && command.Args[0] != "ASKING" && cl.takeAsking()
+		if !write {
+			// Reads are tracked before being executed, so a change made meanwhile is never missed
+			w.track(cl, command.Args, write)
+		}
+		if exempt {
+			res, err = w.runDelegated(cl, command.Args)
+		} else {
+			if !w.parkPaused {
+				// Paused clients keep waiting instead of timing out
+				var waited bool
+				waited, pauseErr = w.clients.waitWhilePaused(write, w.notifications)
+				if waited {
+					cl.setDeadline(w.settings.keepAliveDeadline())
+					start = time.Now()
+				}
+			}
+			if pauseErr != nil {
+				// The server stopped before the pause ended, so the command is skipped
+				err = pauseErr
+			} else if w.settings.deniedByMaxMemory(command.Args[0], write, w.stats.memoryUsed()) {
+				redigoError := redigoerr.OutOfMemory
+				redigoError.ExtraContext = map[string]string{"maxmemory": fmt.Sprintf("%d", w.settings.maxMemory.Load())}
+				err = redigoError
+			} else if command.Run == nil {
+				res, err = w.runDelegated(cl, command.Args)
+			} else {
+				db := w.databases[cl.getDB()]
+				db.Lock()
+				// Keys served by another node redirect the client there
+				if err = w.cluster.route(db, command.Args, asking); err == nil {
+					res, err = command.Run(db)
+				}
+				db.Unlock()
+			}
+		}
+		duration := time.Since(start)
+		w.stats.recordCommand(strings.ToLower(command.Args[0]), duration, err)
+		w.slowLog.record(command.Args, start, duration, cl.addr, cl.getName())
+		if err != nil {
+			w.settings.logger.Error("An error occurred while executing client's command", "ERROR", err,
+				slog.Uint64("WORKERID", w.id),
+				slog.String("CLIENT", cl.addr),
+			)
+			// Errors are delivered at the end for every command
+			response = append(response, tobytes.Err(err)...)
+			continue
+		}
+		if write {
+			w.track(cl, command.Args, write)
+		}
+		response = append(response, res...)
 	}
+	return response, nil, 0, nil
 }
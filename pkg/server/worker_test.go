@@ -24,11 +24,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -56,11 +56,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -88,11 +88,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -127,11 +127,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -168,11 +168,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -222,11 +222,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Error_To_Client_When_S
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 10240),
@@ -254,11 +254,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Error_To_Client_When_E
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 50}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 50),
@@ -286,11 +286,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Error_To_Client_When_E
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 36}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 36),
@@ -313,7 +313,8 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Error_To_Client_When_E
 	}
 
 	response = make([]byte, 1024)
-	newConnection.writeAsClient(fmt.Appendf([]byte{}, "\r\n$3\r\nGET\r\n$1\r\nB\r\n"))
+	// Only the incomplete command counts towards the limit, the SET already answered does not
+	newConnection.writeAsClient(fmt.Appendf([]byte{}, "\r\n$3\r\nGET\r\n$20\r\nBBBBBBBBBBBBBBBBBBBB\r\n"))
 	n, err := newConnection.readAsClient(response)
 	if string(response[:n]) != "-Call exceeded size allowed\r\n" {
 		t.Errorf("Unexpected message received! %v - %v", string(response), err)
@@ -326,11 +327,11 @@ func TestIntegration_WorkerhandleConnection_Should_Return_Message_To_Client_When
 	slog.SetDefault(logger)
 
 	cacheStore := cache.New()
-	channel := make(chan net.Conn)
+	channel := make(chan acceptedConn)
 	newWorker := worker{
-		cacheStore:     cacheStore,
+		databases:      []*cache.Cache{cacheStore},
 		connections:    channel,
-		timeout:        1,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 36}, nil),
 		notifications:  make(chan struct{}, 1),
 		id:             1,
 		parser:         respparser.New(nil, 36),
@@ -478,3 +479,45 @@ func (ma mockAddr) Network() string {
 func (ma mockAddr) String() string {
 	return "test"
 }
+
+func TestIntegration_WorkerhandleConnection_Should_Return_Error_To_Client_When_Exceeding_Command_Size_Lowered_With_CONFIG_SET(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	slog.SetDefault(logger)
+
+	cacheStore := cache.New()
+	channel := make(chan acceptedConn)
+	newWorker := worker{
+		databases:      []*cache.Cache{cacheStore},
+		connections:    channel,
+		settings:       newSettings(&Configuration{KeepAlive: 1, MessageSizeLimit: 10240}, nil),
+		notifications:  make(chan struct{}, 1),
+		id:             1,
+		parser:         respparser.New(nil, 10240),
+		shutdownWaiter: &sync.WaitGroup{},
+	}
+
+	newWorker.parser.Delegate(delegatedCommands...)
+
+	var genericConn net.Conn
+	newConnection := newMockConnection()
+	defer newConnection.Close()
+	genericConn = &newConnection
+	go func() {
+		newWorker.handleConnection(&genericConn)
+	}()
+
+	newConnection.writeAsClient(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$25\r\nclient-query-buffer-limit\r\n$2\r\n40\r\n"))
+	response := make([]byte, 1024)
+	n, _ := newConnection.readAsClient(response)
+	if string(response[:n]) != "_\r\n" {
+		t.Errorf("Unexpected message received! %v", string(response[:n]))
+	}
+
+	// The new limit applies to the next command without restarting
+	newConnection.writeAsClient(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$1\r\nB\r\n$40\r\nBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB\r\n"))
+	response = make([]byte, 1024)
+	n, err := newConnection.readAsClient(response)
+	if string(response[:n]) != "-Call exceeded size allowed\r\n" {
+		t.Errorf("Unexpected message received! %v - %v", string(response[:n]), err)
+	}
+}
@@ -4,6 +4,7 @@
 package e2e
 
 import (
+	"context"
 	"net"
 	"testing"
 
@@ -26,9 +27,10 @@ func TestE2E_Client_Full(t *testing.T) {
 	if err != nil {
 		t.Errorf("An unexpected error occurred! %v", err)
 	}
-	go func() {
-		s.Run()
-	}()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer stopServer(t, s)
 	t.Run("Command=GET,Response=Null", e2e_Client_That_Sends_A_GET_Should_Receive_Null_If_Key_Is_Not_Present)
 	t.Run("Command=SET,Response=Null", e2e_Client_That_Sends_A_SET_Should_Receive_Null_As_Response)
 	t.Run("Command=GET,Response=String", e2e_Client_That_Sends_A_GET_Should_Receive_String_If_Key_Is_Present)
@@ -5,8 +5,11 @@
 package e2e
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
 
 	"github.com/Arthur-phys/redigo/pkg/client"
 	"github.com/Arthur-phys/redigo/pkg/server"
@@ -63,5 +66,8 @@ func ExampleServer() {
 		fmt.Printf("Fatal error occurred - %v\n", err)
 		return
 	}
-	s.Run()
+	// The server never listens to OS signals on its own
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	s.RunContext(ctx)
 }
@@ -0,0 +1,97 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Arthur-phys/redigo/pkg/server"
+)
+
+// idleConnections is the amount of connections opened and left quiet while benchmarking
+const idleConnections = 2000
+
+// BenchmarkServer_Idle_Connections compares attending a single active client among many idle ones,
+// with a worker per connection and with event loops. Goroutines and heap per idle connection include
+// the client side of the connections, which is the same for both.
+func BenchmarkServer_Idle_Connections(b *testing.B) {
+	modes := []struct {
+		name   string
+		config server.Configuration
+	}{
+		{"Workers", server.Configuration{Port: 8010, MinWorkers: 1, MaxWorkers: idleConnections + 10}},
+		{"EventLoop", server.Configuration{Port: 8011, ConnectionHandling: server.EventLoop}},
+	}
+	for _, mode := range modes {
+		config := mode.config
+		config.IpAddress = "127.0.0.1"
+		config.KeepAlive = 60
+		config.MessageSizeLimit = 10240
+		config.ShutdownTolerance = 1
+		config.SlowLogThreshold = -1
+		config.LogLevel = "warning"
+		s, err := server.New(&config)
+		if err != nil {
+			b.Fatalf("An unexpected error occurred! %v", err)
+		}
+		if err := s.Start(context.Background()); err != nil {
+			b.Fatalf("An unexpected error occurred! %v", err)
+		}
+		address := fmt.Sprintf("127.0.0.1:%d", config.Port)
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			b.Fatalf("An unexpected error occurred! %v", err)
+		}
+		ping := fmt.Appendf([]byte{}, "*1\r\n$4\r\nPING\r\n")
+		response := make([]byte, 64)
+		roundTrip := func() error {
+			conn.Write(ping)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err := conn.Read(response)
+			return err
+		}
+		if err := roundTrip(); err != nil {
+			b.Fatalf("An unexpected error occurred! %v", err)
+		}
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		goroutines := runtime.NumGoroutine()
+		idle := make([]net.Conn, 0, idleConnections)
+		for range idleConnections {
+			idleConn, err := net.Dial("tcp", address)
+			if err != nil {
+				b.Fatalf("An unexpected error occurred! %v", err)
+			}
+			idle = append(idle, idleConn)
+		}
+		// Give the server a moment to attend every idle connection
+		time.Sleep(200 * time.Millisecond)
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		goroutinesPerConn := float64(runtime.NumGoroutine()-goroutines) / idleConnections
+		bytesPerConn := float64(int64(after.HeapInuse)+int64(after.StackInuse)-int64(before.HeapInuse)-int64(before.StackInuse)) / idleConnections
+
+		b.Run(mode.name, func(b *testing.B) {
+			for range b.N {
+				if err := roundTrip(); err != nil {
+					b.Fatalf("An unexpected error occurred! %v", err)
+				}
+			}
+			b.ReportMetric(goroutinesPerConn, "goroutines/conn")
+			b.ReportMetric(bytesPerConn, "bytes/conn")
+		})
+
+		conn.Close()
+		for _, idleConn := range idle {
+			idleConn.Close()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		s.Shutdown(ctx)
+		cancel()
+	}
+}
@@ -1,10 +1,19 @@
 package e2e
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Arthur-phys/redigo/pkg/redigoerr"
 	"github.com/Arthur-phys/redigo/pkg/server"
 )
 
@@ -13,19 +22,23 @@ func TestE2E_Server_Full(t *testing.T) {
 	serverConfig := server.Configuration{
 		IpAddress:         "127.0.0.1",
 		Port:              8000,
-		WorkerAmount:      1,
+		WorkerAmount:      2,
 		KeepAlive:         5,
 		MessageSizeLimit:  10240,
 		ShutdownTolerance: 1,
+		MetricsAddress:    "127.0.0.1:8002",
+		SlowLogThreshold:  0,
+		SlowLogMaxLen:     8,
 	}
 
 	s, err := server.New(&serverConfig)
 	if err != nil {
 		t.Errorf("An unexpected error occurred! %v", err)
 	}
-	go func() {
-		s.Run()
-	}()
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer stopServer(t, s)
 	t.Run("Command=GET,Response=Null", e2e_Connection_That_Sends_A_GET_Should_Receive_Null_If_Key_Is_Not_Present)
 	t.Run("Command=SET,Response=Null", e2e_Connection_That_Sends_A_SET_Should_Receive_Null_As_Response)
 	t.Run("Command=GET,Response=String", e2e_Connection_That_Sends_A_GET_Should_Receive_String_If_Key_Is_Present)
@@ -44,6 +57,477 @@ func TestE2E_Server_Full(t *testing.T) {
 	t.Run("Command=Multiple,Response=Multiple", e2e_Connection_That_Sends_Multiple_Messages_Will_Receive_Multiple_Responses)
 	t.Run("Command=Multiple,Response=Multiple_2", e2e_Connection_That_Sends_Multiple_Messages_Will_Receive_Multiple_Responses_Different_Commands)
 	t.Run("Command=DEL,Response=Null", e2e_Connection_That_Sends_A_DEL_Message_Should_Receive_Null_If_Key_Is_Present)
+	t.Run("Command=SETBIT,Response=Int", e2e_Connection_That_Sends_Bitmap_Commands_Should_Receive_Ints)
+	t.Run("Command=GEODIST,Response=String", e2e_Connection_That_Sends_A_GEODIST_Should_Receive_Distance_Between_Members)
+	t.Run("Command=JSON.GET,Response=String", e2e_Connection_That_Sends_A_JSON_GET_Should_Receive_Serialized_Matches)
+	t.Run("Command=HGETALL,Response=Array", e2e_Connection_That_Sends_An_HGETALL_Should_Receive_Fields_And_Values)
+	t.Run("Command=INFO,Response=String", e2e_Connection_That_Sends_An_INFO_Should_Receive_Requested_Sections)
+	t.Run("Metrics=Prometheus", e2e_Metrics_Endpoint_Should_Expose_Command_Histograms_And_Error_Counts)
+	t.Run("Command=SLOWLOG,Response=Array", e2e_Connection_That_Sends_A_SLOWLOG_GET_Should_Receive_Latest_Commands)
+	t.Run("Command=MONITOR,Response=Stream", e2e_Connection_That_Sends_A_MONITOR_Should_Receive_Commands_From_Other_Connections)
+	t.Run("Command=CLIENT,Response=Multiple", e2e_Connection_That_Sends_CLIENT_Commands_Should_Be_Able_To_Name_List_And_Kill_Clients)
+	t.Run("Command=CLIENT_PAUSE,Response=Delayed", e2e_Connection_That_Sends_A_CLIENT_PAUSE_WRITE_Should_Delay_Only_Writes)
+	t.Run("Command=CLIENT_PAUSE_ALL,Response=Delayed", e2e_Connection_That_Sends_A_CLIENT_PAUSE_ALL_Should_Delay_Every_Command_But_CLIENT_UNPAUSE)
+	t.Run("Command=CONFIG,Response=Multiple", e2e_Connection_That_Sends_CONFIG_SET_Should_Change_Behaviour_Without_Restart)
+	t.Run("Command=SELECT,Response=Multiple", e2e_Connection_That_Sends_A_SELECT_Should_Only_See_Keys_Of_The_Selected_Database)
+}
+
+func TestE2E_Server_Worker_Pool(t *testing.T) {
+	serverConfig := server.Configuration{
+		IpAddress:         "127.0.0.1",
+		Port:              8003,
+		MinWorkers:        1,
+		MaxWorkers:        2,
+		MaxClients:        3,
+		KeepAlive:         5,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+	}
+	s, err := server.New(&serverConfig)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer stopServer(t, s)
+
+	ping := fmt.Appendf([]byte{}, "*1\r\n$4\r\nPING\r\n")
+	response := make([]byte, 1024)
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", "127.0.0.1:8003")
+		if err != nil {
+			t.Fatalf("An unexpected error occurred! %v", err)
+		}
+		return conn
+	}
+
+	// A second worker is spawned for the second client
+	first, second := dial(), dial()
+	defer second.Close()
+	for _, conn := range []net.Conn{first, second} {
+		conn.Write(ping)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if n, err := conn.Read(response); err != nil || string(response[:n]) != "$4\r\nPONG\r\n" {
+			t.Fatalf("Unexpected response received! %v - %q", err, string(response[:n]))
+		}
+	}
+
+	// The third client waits for a worker, while the fourth goes over maxclients
+	queued := dial()
+	defer queued.Close()
+	queued.Write(ping)
+	queued.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := queued.Read(response); err == nil {
+		t.Errorf("A client was attended with every worker busy! %q", string(response[:n]))
+	}
+	rejected := dial()
+	defer rejected.Close()
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := rejected.Read(response); err != nil || string(response[:n]) != "-max number of clients reached\r\n" {
+		t.Errorf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+
+	// Once a worker is free, the queued client is attended
+	first.Close()
+	queued.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := queued.Read(response); err != nil || string(response[:n]) != "$4\r\nPONG\r\n" {
+		t.Errorf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+}
+
+func TestE2E_Server_Event_Loop(t *testing.T) {
+	serverConfig := server.Configuration{
+		IpAddress:          "127.0.0.1",
+		Port:               8004,
+		ConnectionHandling: server.EventLoop,
+		EventLoops:         2,
+		MaxClients:         102,
+		KeepAlive:          2,
+		MessageSizeLimit:   10240,
+		ShutdownTolerance:  1,
+		SlowLogThreshold:   -1,
+	}
+	s, err := server.New(&serverConfig)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer stopServer(t, s)
+
+	response := make([]byte, 1024)
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", "127.0.0.1:8004")
+		if err != nil {
+			t.Fatalf("An unexpected error occurred! %v", err)
+		}
+		return conn
+	}
+	expect := func(conn net.Conn, expected string) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		received := ""
+		for len(received) < len(expected) {
+			n, err := conn.Read(response)
+			if err != nil {
+				break
+			}
+			received += string(response[:n])
+		}
+		if received != expected {
+			t.Errorf("Unexpected response received! %q", received)
+		}
+	}
+
+	// Idle connections do not stop the rest from being attended
+	for range 100 {
+		defer dial().Close()
+	}
+	monitorConn := dial()
+	defer monitorConn.Close()
+	monitorConn.Write(fmt.Appendf([]byte{}, "*1\r\n$7\r\nMONITOR\r\n"))
+	expect(monitorConn, "_\r\n")
+
+	conn := dial()
+	defer conn.Close()
+	// Commands split between reads are kept by the connection until complete
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$4\r\nloop\r"))
+	time.Sleep(50 * time.Millisecond)
+	conn.Write(fmt.Appendf([]byte{}, "\n$3\r\none\r\n*2\r\n$3\r\nGET\r\n$4\r\nloop\r\n"))
+	expect(conn, "_\r\n$3\r\none\r\n")
+
+	monitorConn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := monitorConn.Read(response); err != nil || !strings.Contains(string(response[:n]), "\"SET\" \"loop\" \"one\"") {
+		t.Errorf("Unexpected line received! %v - %q", err, string(response[:n]))
+	}
+
+	// Monitor, active and idle connections count towards maxclients
+	rejected := dial()
+	defer rejected.Close()
+	expect(rejected, "-max number of clients reached\r\n")
+
+	// Quiet connections are closed after keep alive
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(response); err != io.EOF {
+		t.Errorf("Connection was not closed after keep alive! %v", err)
+	}
+}
+
+// startEventLoopServer starts a server with a single event loop on a port picked by the system, so every connection shares it
+func startEventLoopServer(t *testing.T) *server.Server {
+	t.Helper()
+	serverConfig := server.Configuration{
+		IpAddress:          "127.0.0.1",
+		Port:               0,
+		ConnectionHandling: server.EventLoop,
+		EventLoops:         1,
+		KeepAlive:          5,
+		MessageSizeLimit:   10240,
+		ShutdownTolerance:  1,
+		SlowLogThreshold:   -1,
+		Logger:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	s, err := server.New(&serverConfig)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	t.Cleanup(func() { stopServer(t, s) })
+	return s
+}
+
+func TestE2E_Server_Event_Loop_Should_Close_Killed_Connection_Right_Away(t *testing.T) {
+	s := startEventLoopServer(t)
+	response := make([]byte, 1024)
+	victim, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer victim.Close()
+	victim.Write(fmt.Appendf([]byte{}, "*2\r\n$6\r\nCLIENT\r\n$2\r\nID\r\n"))
+	victim.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := victim.Read(response)
+	var id int
+	if _, scanErr := fmt.Sscanf(string(response[:n]), ":%d\r\n", &id); err != nil || scanErr != nil {
+		t.Fatalf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer conn.Close()
+	idArg := fmt.Sprint(id)
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$4\r\nKILL\r\n$2\r\nID\r\n$%d\r\n%s\r\n", len(idArg), idArg))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != ":1\r\n" {
+		t.Fatalf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+
+	// Nothing the killed connection sends is executed, and it is closed without waiting for the next sweep
+	start := time.Now()
+	victim.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$6\r\nkilled\r\n$3\r\nyes\r\n"))
+	victim.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := victim.Read(response); err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Killed connection should be closed right away! %v - %v", err, time.Since(start))
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*2\r\n$3\r\nGET\r\n$6\r\nkilled\r\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n" {
+		t.Errorf("Killed connection executed a command! %v - %q", err, string(response[:n]))
+	}
+}
+
+func TestE2E_Server_Event_Loop_Should_Keep_Attending_Connections_When_One_Is_Paused(t *testing.T) {
+	s := startEventLoopServer(t)
+	response := make([]byte, 1024)
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatalf("An unexpected error occurred! %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	expect := func(conn net.Conn, expected string, within time.Duration) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(within))
+		received := ""
+		for len(received) < len(expected) {
+			n, err := conn.Read(response)
+			if err != nil {
+				break
+			}
+			received += string(response[:n])
+		}
+		if received != expected {
+			t.Errorf("Unexpected response received! %q", received)
+		}
+	}
+	admin, paused, other := dial(), dial(), dial()
+	admin.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$5\r\nPAUSE\r\n$4\r\n5000\r\n$5\r\nWRITE\r\n"))
+	expect(admin, "_\r\n", time.Second)
+
+	// The write waits for the pause along with the commands sent after it
+	paused.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$6\r\nparked\r\n$3\r\nyes\r\n*2\r\n$3\r\nGET\r\n$6\r\nparked\r\n"))
+	time.Sleep(50 * time.Millisecond)
+	// Meanwhile the rest of the connections of the loop are attended
+	other.Write(fmt.Appendf([]byte{}, "*2\r\n$3\r\nGET\r\n$6\r\nparked\r\n"))
+	expect(other, "_\r\n", 500*time.Millisecond)
+	admin.Write(fmt.Appendf([]byte{}, "*2\r\n$6\r\nCLIENT\r\n$7\r\nUNPAUSE\r\n"))
+	expect(admin, "_\r\n", 500*time.Millisecond)
+	expect(paused, "_\r\n$3\r\nyes\r\n", 500*time.Millisecond)
+
+	// Once resumed the connection is read again
+	paused.Write(fmt.Appendf([]byte{}, "*1\r\n$4\r\nPING\r\n"))
+	expect(paused, "$4\r\nPONG\r\n", 500*time.Millisecond)
+}
+
+// Push messages are written by the connection handling too, which differs between workers and event loops
+func TestE2E_Server_Tracking(t *testing.T) {
+	modes := []struct {
+		name          string
+		configuration server.Configuration
+	}{
+		{"Workers", server.Configuration{Port: 8005, MinWorkers: 1, MaxWorkers: 8}},
+		{"EventLoop", server.Configuration{Port: 8006, ConnectionHandling: server.EventLoop}},
+	}
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			serverConfig := mode.configuration
+			serverConfig.IpAddress = "127.0.0.1"
+			serverConfig.KeepAlive = 5
+			serverConfig.MessageSizeLimit = 10240
+			serverConfig.ShutdownTolerance = 1
+			serverConfig.SlowLogThreshold = -1
+			s, err := server.New(&serverConfig)
+			if err != nil {
+				t.Fatalf("An unexpected error occurred! %v", err)
+			}
+			if err := s.Start(context.Background()); err != nil {
+				t.Fatalf("An unexpected error occurred! %v", err)
+			}
+			defer stopServer(t, s)
+			e2e_Connection_That_Sends_A_CLIENT_TRACKING_Should_Be_Told_When_Keys_It_Read_Change(t, fmt.Sprintf("127.0.0.1:%d", serverConfig.Port))
+		})
+	}
+}
+
+func TestE2E_Server_Cluster(t *testing.T) {
+	// Every node gossips on its port plus 10000, 18007 and 18008
+	for _, port := range []uint16{8007, 8008} {
+		serverConfig := server.Configuration{
+			IpAddress:         "127.0.0.1",
+			Port:              port,
+			WorkerAmount:      2,
+			KeepAlive:         5,
+			MessageSizeLimit:  10240,
+			ShutdownTolerance: 1,
+			SlowLogThreshold:  -1,
+			ClusterEnabled:    true,
+		}
+		s, err := server.New(&serverConfig)
+		if err != nil {
+			t.Fatalf("An unexpected error occurred! %v", err)
+		}
+		if err := s.Start(context.Background()); err != nil {
+			t.Fatalf("An unexpected error occurred! %v", err)
+		}
+		defer stopServer(t, s)
+	}
+	e2e_Cluster_Nodes_That_Meet_Should_Redirect_Keys_They_Do_Not_Serve(t)
+	e2e_Cluster_Node_That_Migrates_A_Slot_Should_Answer_ASK_Until_It_Is_Done(t)
+}
+
+func TestE2E_Server_Lifecycle(t *testing.T) {
+	logs := &lockedBuffer{}
+	defaultLogger := slog.Default()
+	serverConfig := server.Configuration{
+		IpAddress:         "127.0.0.1",
+		Port:              0,
+		WorkerAmount:      1,
+		KeepAlive:         5,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+		LogLevel:          "notice",
+		Logger:            slog.New(slog.NewTextHandler(logs, nil)),
+	}
+	s, err := server.New(&serverConfig)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	if slog.Default() != defaultLogger {
+		t.Errorf("The global logger was replaced!")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	if code, ok := redigoerr.ErrorCode(s.Start(ctx)); !ok || code != redigoerr.ServerAlreadyStarted.Code {
+		t.Errorf("Server was started twice! %d", code)
+	}
+
+	// The port picked is the one to connect to
+	address := s.Addr().String()
+	if strings.HasSuffix(address, ":0") {
+		t.Fatalf("No port was picked! %s", address)
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*1\r\n$4\r\nPING\r\n"))
+	response := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "$4\r\nPONG\r\n" {
+		t.Errorf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+
+	// Cancelling the context shuts the server down, closing the connection left open
+	cancel()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(response); err != io.EOF {
+		t.Errorf("Connection was not closed on shutdown! %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("An unexpected error occurred! %v", err)
+	}
+	if _, err := net.Dial("tcp", address); err == nil {
+		t.Errorf("Server still accepts connections after shutting down!")
+	}
+	if code, ok := redigoerr.ErrorCode(s.Start(context.Background())); !ok || code != redigoerr.ServerClosed.Code {
+		t.Errorf("Server was started after shutting down! %d", code)
+	}
+	if !strings.Contains(logs.String(), "Shutting down server") || strings.Contains(logs.String(), "level=DEBUG") {
+		t.Errorf("Unexpected logs! %s", logs.String())
+	}
+}
+
+func TestE2E_Server_Run_Should_Return_When_Shut_Down(t *testing.T) {
+	serverConfig := server.Configuration{
+		IpAddress:         "127.0.0.1",
+		Port:              0,
+		WorkerAmount:      1,
+		KeepAlive:         5,
+		MessageSizeLimit:  10240,
+		ShutdownTolerance: 1,
+		SlowLogThreshold:  -1,
+		Logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	s, err := server.New(&serverConfig)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.Run()
+	}()
+
+	// Run keeps serving until Shutdown is called
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server never accepted connections! %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-stopped:
+		t.Fatalf("Run returned before shutting down!")
+	default:
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("An unexpected error occurred! %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Run did not return after shutting down!")
+	}
+}
+
+// lockedBuffer lets a test read logs written by the server's goroutines
+type lockedBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.String()
+}
+
+// stopServer shuts a server down, closing whatever connection a test left open
+func stopServer(t *testing.T, s *server.Server) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Logf("Connections were still open after shutting down: %v", err)
+	}
 }
 
 func e2e_Connection_That_Sends_A_GET_Should_Receive_Null_If_Key_Is_Not_Present(t *testing.T) {
@@ -516,3 +1000,507 @@ func e2e_Connection_That_Sends_A_DEL_Message_Should_Receive_Null_If_Key_Is_Prese
 		t.Errorf("An unexpected error occurred! %e", err)
 	}
 }
+
+func e2e_Connection_That_Sends_Bitmap_Commands_Should_Receive_Ints(t *testing.T) {
+	response := make([]byte, 50)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nSETBIT\r\n$2\r\nBM\r\n$2\r\n10\r\n$1\r\n1\r\n*2\r\n$8\r\nBITCOUNT\r\n$2\r\nBM\r\n*5\r\n$8\r\nBITFIELD\r\n$2\r\nBM\r\n$3\r\nGET\r\n$2\r\nu8\r\n$1\r\n8\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	if string(response[:n]) != ":0\r\n:1\r\n*1\r\n:32\r\n" {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, string(response))
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Connection_That_Sends_A_GEODIST_Should_Receive_Distance_Between_Members(t *testing.T) {
+	response := make([]byte, 50)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*8\r\n$6\r\nGEOADD\r\n$6\r\nSicily\r\n$9\r\n13.361389\r\n$9\r\n38.115556\r\n$7\r\nPalermo\r\n$9\r\n15.087269\r\n$9\r\n37.502669\r\n$7\r\nCatania\r\n*5\r\n$7\r\nGEODIST\r\n$6\r\nSicily\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n$2\r\nkm\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	if string(response[:n]) != ":2\r\n$8\r\n166.2742\r\n" {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, string(response))
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Connection_That_Sends_A_JSON_GET_Should_Receive_Serialized_Matches(t *testing.T) {
+	response := make([]byte, 50)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$8\r\nJSON.SET\r\n$3\r\ndoc\r\n$1\r\n$\r\n$17\r\n{\"a\":[1,{\"a\":2}]}\r\n*3\r\n$8\r\nJSON.GET\r\n$3\r\ndoc\r\n$4\r\n$..a\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	if string(response[:n]) != "_\r\n$15\r\n[[1,{\"a\":2}],2]\r\n" {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, string(response))
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Connection_That_Sends_An_HGETALL_Should_Receive_Fields_And_Values(t *testing.T) {
+	response := make([]byte, 50)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*6\r\n$4\r\nHSET\r\n$3\r\ncat\r\n$4\r\nname\r\n$4\r\nNiji\r\n$3\r\nage\r\n$1\r\n3\r\n*2\r\n$7\r\nHGETALL\r\n$3\r\ncat\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	if string(response[:n]) != ":2\r\n*4\r\n$3\r\nage\r\n$1\r\n3\r\n$4\r\nname\r\n$4\r\nNiji\r\n" {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, string(response))
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Connection_That_Sends_An_INFO_Should_Receive_Requested_Sections(t *testing.T) {
+	response := make([]byte, 1024)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$4\r\nINFO\r\n$7\r\nclients\r\n$12\r\ncommandstats\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	info := string(response[:n])
+	if !strings.Contains(info, "# Clients\r\nconnected_clients:") || !strings.Contains(info, "# Commandstats\r\n") ||
+		!strings.Contains(info, "cmdstat_get:calls=") || strings.Contains(info, "# Server") {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, info)
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Metrics_Endpoint_Should_Expose_Command_Histograms_And_Error_Counts(t *testing.T) {
+	response, err := http.Get("http://127.0.0.1:8002/metrics")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %v", err)
+	}
+	metrics := string(body)
+	expected := []string{
+		"# TYPE redigo_command_duration_seconds histogram\n",
+		"redigo_command_duration_seconds_bucket{command=\"get\",le=\"+Inf\"}",
+		"redigo_errors_total{code=\"5\"}",
+		"redigo_keys{type=\"string\"}",
+		"redigo_connected_clients ",
+		"redigo_memory_used_bytes ",
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Metric not found! %s", line)
+		}
+	}
+}
+
+func e2e_Connection_That_Sends_A_SLOWLOG_GET_Should_Receive_Latest_Commands(t *testing.T) {
+	response := make([]byte, 1024)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*2\r\n$7\r\nSLOWLOG\r\n$5\r\nRESET\r\n*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\nb\r\n*3\r\n$7\r\nSLOWLOG\r\n$3\r\nGET\r\n$1\r\n2\r\n"))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	slowLog := string(response[:n])
+	set := strings.Index(slowLog, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\nb\r\n")
+	reset := strings.Index(slowLog, "*2\r\n$7\r\nSLOWLOG\r\n$5\r\nRESET\r\n")
+	if !strings.HasPrefix(slowLog, "_\r\n_\r\n*2\r\n*6\r\n") || set < 0 || reset < 0 || set > reset {
+		t.Errorf("Unexpected response received! n = %d - response = %v", n, slowLog)
+	}
+	err = conn.Close()
+	if err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+}
+
+func e2e_Connection_That_Sends_A_MONITOR_Should_Receive_Commands_From_Other_Connections(t *testing.T) {
+	response := make([]byte, 1024)
+	monitorConn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer monitorConn.Close()
+	monitorConn.Write(fmt.Appendf([]byte{}, "*1\r\n$7\r\nMONITOR\r\n"))
+	n, err := monitorConn.Read(response)
+	if err != nil || string(response[:n]) != "_\r\n" {
+		t.Fatalf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$7\r\nmonitor\r\n$4\r\n\"a\"\n\r\n"))
+	if _, err := conn.Read(response); err != nil {
+		t.Errorf("An unexpected error occurred! %e", err)
+	}
+	conn.Close()
+
+	monitorConn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err = monitorConn.Read(response)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	line := string(response[:n])
+	if !strings.HasPrefix(line, "+") || !strings.HasSuffix(line, "] \"SET\" \"monitor\" \"\\\"a\\\"\\n\"\r\n") ||
+		!strings.Contains(line, " [0 127.0.0.1:") {
+		t.Errorf("Unexpected line received! %v", line)
+	}
+}
+
+func e2e_Connection_That_Sends_CLIENT_Commands_Should_Be_Able_To_Name_List_And_Kill_Clients(t *testing.T) {
+	response := make([]byte, 1024)
+	victim, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer victim.Close()
+	victim.Write(fmt.Appendf([]byte{}, "*3\r\n$6\r\nCLIENT\r\n$7\r\nSETNAME\r\n$6\r\nvictim\r\n*2\r\n$6\r\nCLIENT\r\n$7\r\nGETNAME\r\n*2\r\n$6\r\nCLIENT\r\n$2\r\nID\r\n"))
+	n, err := victim.Read(response)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	var id int
+	if _, err := fmt.Sscanf(string(response[:n]), "_\r\n$6\r\nvictim\r\n:%d\r\n", &id); err != nil {
+		t.Fatalf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer conn.Close()
+	idArg := fmt.Sprintf("%d", id)
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$4\r\nLIST\r\n$2\r\nID\r\n$%d\r\n%s\r\n", len(idArg), idArg))
+	n, err = conn.Read(response)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	list := string(response[:n])
+	if !strings.Contains(list, fmt.Sprintf("id=%d addr=%s", id, victim.LocalAddr().String())) || !strings.Contains(list, " name=victim ") ||
+		!strings.Contains(list, " cmd=client|id ") || strings.Count(list, " addr=") != 1 {
+		t.Errorf("Unexpected list received! %v", list)
+	}
+
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$4\r\nKILL\r\n$2\r\nID\r\n$%d\r\n%s\r\n", len(idArg), idArg))
+	n, err = conn.Read(response)
+	if err != nil || string(response[:n]) != ":1\r\n" {
+		t.Errorf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+	victim.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := victim.Read(response); err != io.EOF {
+		t.Errorf("Killed connection should be closed! %v", err)
+	}
+}
+
+func e2e_Connection_That_Sends_A_CLIENT_PAUSE_WRITE_Should_Delay_Only_Writes(t *testing.T) {
+	response := make([]byte, 1024)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer conn.Close()
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$5\r\nPAUSE\r\n$3\r\n300\r\n$5\r\nWRITE\r\n"))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n" {
+		t.Fatalf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+
+	start := time.Now()
+	conn.Write(fmt.Appendf([]byte{}, "*2\r\n$3\r\nGET\r\n$5\r\npause\r\n"))
+	if _, err := conn.Read(response); err != nil || time.Since(start) > 200*time.Millisecond {
+		t.Errorf("Reads should not be paused! %v - %v", err, time.Since(start))
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$5\r\npause\r\n$1\r\na\r\n"))
+	if _, err := conn.Read(response); err != nil || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("Writes should be paused! %v - %v", err, time.Since(start))
+	}
+}
+
+func e2e_Connection_That_Sends_A_CLIENT_PAUSE_ALL_Should_Delay_Every_Command_But_CLIENT_UNPAUSE(t *testing.T) {
+	response := make([]byte, 4096)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer conn.Close()
+	paused, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer paused.Close()
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCLIENT\r\n$5\r\nPAUSE\r\n$4\r\n2000\r\n$3\r\nALL\r\n"))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n" {
+		t.Fatalf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+
+	// Commands answered by the server itself, like INFO, wait too
+	start := time.Now()
+	paused.Write(fmt.Appendf([]byte{}, "*2\r\n$4\r\nINFO\r\n$6\r\nserver\r\n"))
+	paused.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := paused.Read(response); err == nil {
+		t.Errorf("INFO should be paused! %v", time.Since(start))
+	}
+	// CLIENT UNPAUSE is answered right away, letting INFO through
+	conn.Write(fmt.Appendf([]byte{}, "*2\r\n$6\r\nCLIENT\r\n$7\r\nUNPAUSE\r\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n" {
+		t.Errorf("Unexpected response received! %v - %v", err, string(response[:n]))
+	}
+	paused.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := paused.Read(response); err != nil || !strings.HasPrefix(string(response[:n]), "$") || time.Since(start) > time.Second {
+		t.Errorf("INFO should be answered once unpaused! %v - %v", err, time.Since(start))
+	}
+}
+
+func e2e_Connection_That_Sends_CONFIG_SET_Should_Change_Behaviour_Without_Restart(t *testing.T) {
+	response := make([]byte, 1024)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer conn.Close()
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$6\r\nCONFIG\r\n$3\r\nGET\r\n$10\r\nslowlog-m*\r\n"))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "*2\r\n$15\r\nslowlog-max-len\r\n$1\r\n8\r\n" {
+		t.Fatalf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$9\r\nmaxmemory\r\n$1\r\n1\r\n"))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n" {
+		t.Fatalf("Unexpected response received! %v - %q", err, string(response[:n]))
+	}
+	// Memory is sampled periodically
+	time.Sleep(300 * time.Millisecond)
+	conn.Write(fmt.Appendf([]byte{}, "*3\r\n$3\r\nSET\r\n$3\r\noom\r\n$1\r\na\r\n*2\r\n$3\r\nDEL\r\n$3\r\noom\r\n"))
+	if n, err := conn.Read(response); err != nil || !strings.HasPrefix(string(response[:n]), "-OOM command not allowed") {
+		t.Errorf("Writes should be rejected over maxmemory! %v - %q", err, string(response[:n]))
+	}
+
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$9\r\nmaxmemory\r\n$1\r\n0\r\n*3\r\n$3\r\nSET\r\n$3\r\noom\r\n$1\r\na\r\n"))
+	if n, err := conn.Read(response); err != nil || string(response[:n]) != "_\r\n_\r\n" {
+		t.Errorf("Writes should be accepted without maxmemory! %v - %q", err, string(response[:n]))
+	}
+	conn.Write(fmt.Appendf([]byte{}, "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$4\r\nport\r\n$4\r\n9000\r\n"))
+	if n, err := conn.Read(response); err != nil || !strings.HasSuffix(string(response[:n]), "can't set immutable config\r\n") {
+		t.Errorf("Immutable parameters should not change! %v - %q", err, string(response[:n]))
+	}
+}
+
+func e2e_Connection_That_Sends_A_SELECT_Should_Only_See_Keys_Of_The_Selected_Database(t *testing.T) {
+	response := make([]byte, 1024)
+	conn, err := net.Dial("tcp", "127.0.0.1:8000")
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %e", err)
+	}
+	defer conn.Close()
+	exchange := func(request string, expected string) {
+		t.Helper()
+		conn.Write([]byte(request))
+		if n, err := conn.Read(response); err != nil || string(response[:n]) != expected {
+			t.Errorf("Unexpected response received! %v - %q", err, string(response[:n]))
+		}
+	}
+
+	exchange("*2\r\n$6\r\nSELECT\r\n$1\r\n3\r\n*3\r\n$3\r\nSET\r\n$2\r\ndb\r\n$5\r\nthree\r\n", "_\r\n_\r\n")
+	exchange("*2\r\n$6\r\nSELECT\r\n$1\r\n4\r\n*2\r\n$3\r\nGET\r\n$2\r\ndb\r\n", "_\r\n_\r\n")
+	exchange("*3\r\n$6\r\nSWAPDB\r\n$1\r\n3\r\n$1\r\n4\r\n*2\r\n$3\r\nGET\r\n$2\r\ndb\r\n", "_\r\n$5\r\nthree\r\n")
+	exchange("*3\r\n$4\r\nMOVE\r\n$2\r\ndb\r\n$1\r\n5\r\n*2\r\n$3\r\nGET\r\n$2\r\ndb\r\n", ":1\r\n_\r\n")
+	exchange("*2\r\n$6\r\nSELECT\r\n$1\r\n5\r\n*1\r\n$7\r\nFLUSHDB\r\n*2\r\n$3\r\nGET\r\n$2\r\ndb\r\n", "_\r\n_\r\n_\r\n")
+	exchange("*2\r\n$6\r\nSELECT\r\n$2\r\n16\r\n", "-DB index is out of range\r\n")
+}
+
+func e2e_Connection_That_Sends_A_CLIENT_TRACKING_Should_Be_Told_When_Keys_It_Read_Change(t *testing.T, address string) {
+	response := make([]byte, 1024)
+	dial := func() net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatalf("An unexpected error occurred! %e", err)
+		}
+		return conn
+	}
+	// Push messages are written apart from responses, so they may take more than one read
+	exchange := func(conn net.Conn, request string, expected string) {
+		t.Helper()
+		conn.Write([]byte(request))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		received := ""
+		for len(received) < len(expected) {
+			n, err := conn.Read(response)
+			if err != nil {
+				break
+			}
+			received += string(response[:n])
+		}
+		if received != expected {
+			t.Errorf("Unexpected response received! %q", received)
+		}
+	}
+	tracking, bcast, writer := dial(), dial(), dial()
+	defer tracking.Close()
+	defer bcast.Close()
+	defer writer.Close()
+
+	exchange(tracking, "*3\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$2\r\nON\r\n*2\r\n$3\r\nGET\r\n$7\r\ntracked\r\n", "_\r\n_\r\n")
+	exchange(writer, "*3\r\n$3\r\nSET\r\n$7\r\ntracked\r\n$1\r\na\r\n", "_\r\n")
+	exchange(tracking, "", ">2\r\n$10\r\ninvalidate\r\n*1\r\n$7\r\ntracked\r\n")
+	// Keys have to be read again before being told about them once more
+	exchange(writer, "*3\r\n$3\r\nSET\r\n$7\r\ntracked\r\n$1\r\nb\r\n", "_\r\n")
+	exchange(tracking, "*1\r\n$4\r\nPING\r\n", "$4\r\nPONG\r\n")
+
+	exchange(bcast, "*5\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$2\r\nON\r\n$6\r\nPREFIX\r\n$5\r\nuser:\r\n",
+		"-PREFIX option requires BCAST mode to be enabled\r\n")
+	exchange(bcast, "*7\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$2\r\nON\r\n$5\r\nBCAST\r\n$6\r\nPREFIX\r\n$5\r\nuser:\r\n$6\r\nNOLOOP\r\n", "_\r\n")
+	exchange(writer, "*3\r\n$3\r\nSET\r\n$5\r\nother\r\n$1\r\na\r\n*3\r\n$3\r\nSET\r\n$6\r\nuser:1\r\n$1\r\na\r\n", "_\r\n_\r\n")
+	exchange(bcast, "", ">2\r\n$10\r\ninvalidate\r\n*1\r\n$6\r\nuser:1\r\n")
+	// Changes made by the client itself are not told with NOLOOP
+	exchange(bcast, "*3\r\n$3\r\nSET\r\n$6\r\nuser:2\r\n$1\r\na\r\n*1\r\n$4\r\nPING\r\n", "_\r\n$4\r\nPONG\r\n")
+
+	// Flushing a database tells every client to forget all of its keys
+	exchange(tracking, "*2\r\n$3\r\nGET\r\n$7\r\ntracked\r\n", "$1\r\nb\r\n")
+	exchange(writer, "*2\r\n$6\r\nSELECT\r\n$1\r\n6\r\n*1\r\n$7\r\nFLUSHDB\r\n", "_\r\n_\r\n")
+	exchange(tracking, "", ">2\r\n$10\r\ninvalidate\r\n_\r\n")
+	exchange(bcast, "", ">2\r\n$10\r\ninvalidate\r\n_\r\n")
+
+	exchange(tracking, "*3\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$3\r\nOFF\r\n*2\r\n$3\r\nGET\r\n$7\r\ntracked\r\n", "_\r\n$1\r\nb\r\n")
+	exchange(writer, "*2\r\n$6\r\nSELECT\r\n$1\r\n0\r\n*2\r\n$3\r\nDEL\r\n$7\r\ntracked\r\n", "_\r\n_\r\n")
+	exchange(tracking, "*1\r\n$4\r\nPING\r\n", "$4\r\nPONG\r\n")
+
+	// Past tracking-table-max-keys, keys are forgotten and their clients told as if they changed
+	other := dial()
+	defer other.Close()
+	exchange(writer, "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$23\r\ntracking-table-max-keys\r\n$1\r\n1\r\n", "_\r\n")
+	exchange(tracking, "*3\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$2\r\nON\r\n*2\r\n$3\r\nGET\r\n$5\r\nfirst\r\n", "_\r\n_\r\n")
+	exchange(other, "*3\r\n$6\r\nCLIENT\r\n$8\r\nTRACKING\r\n$2\r\nON\r\n*2\r\n$3\r\nGET\r\n$6\r\nsecond\r\n", "_\r\n_\r\n")
+	exchange(tracking, "", ">2\r\n$10\r\ninvalidate\r\n*1\r\n$5\r\nfirst\r\n")
+	// The key just read is kept
+	exchange(writer, "*3\r\n$3\r\nSET\r\n$6\r\nsecond\r\n$1\r\na\r\n", "_\r\n")
+	exchange(other, "", ">2\r\n$10\r\ninvalidate\r\n*1\r\n$6\r\nsecond\r\n")
+}
+
+// sendToNode sends commands to a node of the cluster over a new connection, returning what it answered
+func sendToNode(t *testing.T, port int, commands ...[]string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	defer conn.Close()
+	request := []byte{}
+	for _, command := range commands {
+		request = fmt.Appendf(request, "*%d\r\n", len(command))
+		for _, arg := range command {
+			request = fmt.Appendf(request, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	conn.Write(request)
+	response := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Fatalf("An unexpected error occurred! %v", err)
+	}
+	return string(response[:n])
+}
+
+func e2e_Cluster_Nodes_That_Meet_Should_Redirect_Keys_They_Do_Not_Serve(t *testing.T) {
+	if res := sendToNode(t, 8007, []string{"CLUSTER", "MEET", "127.0.0.1", "8008"}); res != "_\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	sendToNode(t, 8007, []string{"CLUSTER", "ADDSLOTSRANGE", "0", "8191"})
+	sendToNode(t, 8008, []string{"CLUSTER", "ADDSLOTSRANGE", "8192", "16383"})
+	for _, port := range []int{8007, 8008} {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			info := sendToNode(t, port, []string{"CLUSTER", "INFO"})
+			if strings.Contains(info, "cluster_state:ok") && strings.Contains(info, "cluster_known_nodes:2\r\n") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("The cluster did not converge in time! %q", info)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if res := sendToNode(t, 8007, []string{"CLUSTER", "KEYSLOT", "foo"}); res != ":12182\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8007, []string{"SET", "foo", "bar"}); res != "-MOVED 12182 127.0.0.1:8008\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8008, []string{"SET", "foo", "bar"}); res != "_\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+}
+
+func e2e_Cluster_Node_That_Migrates_A_Slot_Should_Answer_ASK_Until_It_Is_Done(t *testing.T) {
+	// Blob strings with an id are $40\r\n<id>\r\n
+	id7 := sendToNode(t, 8007, []string{"CLUSTER", "MYID"})[5:45]
+	id8 := sendToNode(t, 8008, []string{"CLUSTER", "MYID"})[5:45]
+	sendToNode(t, 8007, []string{"CLUSTER", "SETSLOT", "12182", "IMPORTING", id8})
+	sendToNode(t, 8008, []string{"CLUSTER", "SETSLOT", "12182", "MIGRATING", id7})
+	if res := sendToNode(t, 8008, []string{"MIGRATE", "127.0.0.1", "8007", "foo", "0", "1000"}); res != "_\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8008, []string{"GET", "foo"}); res != "-ASK 12182 127.0.0.1:8007\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8007, []string{"ASKING"}, []string{"GET", "foo"}); res != "_\r\n$3\r\nbar\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8007, []string{"GET", "foo"}); res != "-MOVED 12182 127.0.0.1:8008\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+
+	sendToNode(t, 8007, []string{"CLUSTER", "SETSLOT", "12182", "NODE", id7})
+	sendToNode(t, 8008, []string{"CLUSTER", "SETSLOT", "12182", "NODE", id7})
+	if res := sendToNode(t, 8008, []string{"GET", "foo"}); res != "-MOVED 12182 127.0.0.1:8007\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if res := sendToNode(t, 8007, []string{"GET", "foo"}); res != "$3\r\nbar\r\n" {
+		t.Errorf("Unexpected response received! %q", res)
+	}
+	if nodes := sendToNode(t, 8007, []string{"CLUSTER", "NODES"}); !strings.Contains(nodes, id7+" 127.0.0.1:8007@18007 myself,master") ||
+		!strings.Contains(nodes, " 0-8191 12182\n") {
+		t.Errorf("Unexpected response received! %q", nodes)
+	}
+}